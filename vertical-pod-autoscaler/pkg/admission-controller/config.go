@@ -21,9 +21,11 @@ import (
 	"time"
 
 	"k8s.io/api/admissionregistration/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog"
 )
 
@@ -55,16 +57,19 @@ func configTLS(clientset *kubernetes.Clientset, serverCert, serverKey []byte) *t
 }
 
 // register this webhook admission controller with the kube-apiserver
-// by creating MutatingWebhookConfiguration.
-func selfRegistration(clientset *kubernetes.Clientset, caCert []byte, namespace *string, url string, registerByURL bool) {
+// by creating MutatingWebhookConfiguration. Replicas of the admission
+// controller share the configuration: each of them updates it in place, so
+// the webhook stays registered while they start.
+func selfRegistration(clientset kubernetes.Interface, caCert []byte, namespace *string, url string, registerByURL bool) {
 	time.Sleep(10 * time.Second)
-	client := clientset.AdmissionregistrationV1beta1().MutatingWebhookConfigurations()
-	_, err := client.Get(webhookConfigName, metav1.GetOptions{})
-	if err == nil {
-		if err2 := client.Delete(webhookConfigName, nil); err2 != nil {
-			klog.Fatal(err2)
-		}
+	if err := registerWebhook(clientset, caCert, namespace, url, registerByURL); err != nil {
+		klog.Fatal(err)
 	}
+	klog.V(3).Info("Self registration as MutatingWebhook succeeded.")
+}
+
+func registerWebhook(clientset kubernetes.Interface, caCert []byte, namespace *string, url string, registerByURL bool) error {
+	client := clientset.AdmissionregistrationV1beta1().MutatingWebhookConfigurations()
 	RegisterClientConfig := v1beta1.WebhookClientConfig{}
 	if !registerByURL {
 		RegisterClientConfig.Service = &v1beta1.ServiceReference{
@@ -103,9 +108,17 @@ func selfRegistration(clientset *kubernetes.Clientset, caCert []byte, namespace
 			},
 		},
 	}
-	if _, err := client.Create(webhookConfig); err != nil {
-		klog.Fatal(err)
-	} else {
-		klog.V(3).Info("Self registration as MutatingWebhook succeeded.")
+	_, err := client.Create(webhookConfig)
+	if !errors.IsAlreadyExists(err) {
+		return err
 	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := client.Get(webhookConfigName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		webhookConfig.ResourceVersion = existing.ResourceVersion
+		_, err = client.Update(webhookConfig)
+		return err
+	})
 }
@@ -0,0 +1,42 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRegisterWebhookByReplicas(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	namespace := "kube-system"
+
+	assert.NoError(t, registerWebhook(clientset, []byte("ca-1"), &namespace, "", false))
+	// Another replica, e.g. with a rotated certificate, updates the
+	// configuration.
+	assert.NoError(t, registerWebhook(clientset, []byte("ca-2"), &namespace, "", false))
+
+	webhookConfig, err := clientset.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Get(webhookConfigName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, webhookConfig.Webhooks, 1) {
+		assert.Equal(t, []byte("ca-2"), webhookConfig.Webhooks[0].ClientConfig.CABundle)
+		assert.Equal(t, namespace, webhookConfig.Webhooks[0].ClientConfig.Service.Namespace)
+	}
+}
@@ -27,18 +27,20 @@ import (
 	"k8s.io/klog"
 )
 
-// ContainerResources holds resources request for container
+// ContainerResources holds resources request and limits for container
 type ContainerResources struct {
 	Requests v1.ResourceList
+	// Limits to set, for containers whose limits are controlled by VPA.
+	Limits v1.ResourceList
 }
 
 func newContainerResources() ContainerResources {
-	return ContainerResources{Requests: v1.ResourceList{}}
+	return ContainerResources{Requests: v1.ResourceList{}, Limits: v1.ResourceList{}}
 }
 
-// RecommendationProvider gets current recommendation, annotations and vpaName for the given pod.
+// RecommendationProvider gets current recommendation, annotations and the controlling VPA for the given pod.
 type RecommendationProvider interface {
-	GetContainersResourcesForPod(pod *v1.Pod) ([]ContainerResources, vpa_api_util.ContainerToAnnotationsMap, string, error)
+	GetContainersResourcesForPod(pod *v1.Pod) ([]ContainerResources, []ContainerResources, vpa_api_util.ContainerToAnnotationsMap, *vpa_types.VerticalPodAutoscaler, error)
 }
 
 type recommendationProvider struct {
@@ -56,10 +58,11 @@ func NewRecommendationProvider(vpaLister vpa_lister.VerticalPodAutoscalerLister,
 	}
 }
 
-// getContainersResources returns the recommended resources for each container in the given pod in the same order they are specified in the pod.Spec.
-func getContainersResources(pod *v1.Pod, podRecommendation vpa_types.RecommendedPodResources) []ContainerResources {
-	resources := make([]ContainerResources, len(pod.Spec.Containers))
-	for i, container := range pod.Spec.Containers {
+// getContainersResources returns the recommended resources for each of the given containers in the same order.
+// Limits are scaled proportionally to the requests for containers whose limits are controlled by VPA.
+func getContainersResources(containers []v1.Container, podRecommendation vpa_types.RecommendedPodResources, policy *vpa_types.PodResourcePolicy) []ContainerResources {
+	resources := make([]ContainerResources, len(containers))
+	for i, container := range containers {
 		resources[i] = newContainerResources()
 
 		recommendation := vpa_api_util.GetRecommendationForContainer(container.Name, &podRecommendation)
@@ -68,6 +71,9 @@ func getContainersResources(pod *v1.Pod, podRecommendation vpa_types.Recommended
 			continue
 		}
 		resources[i].Requests = recommendation.Target
+		if vpa_api_util.GetContainerControlledValues(container.Name, policy) == vpa_types.ContainerControlledValuesRequestsAndLimits {
+			resources[i].Limits = vpa_api_util.GetProportionalLimits(container, recommendation.Target)
+		}
 	}
 	return resources
 }
@@ -80,7 +86,8 @@ func (p *recommendationProvider) getMatchingVPA(pod *v1.Pod) *vpa_types.Vertical
 	}
 	onConfigs := make([]*vpa_api_util.VpaWithSelector, 0)
 	for _, vpaConfig := range configs {
-		if vpa_api_util.GetUpdateMode(vpaConfig) == vpa_types.UpdateModeOff {
+		// VPAs in dry-run preview their recommendations even when they are off.
+		if vpa_api_util.GetUpdateMode(vpaConfig) == vpa_types.UpdateModeOff && !vpa_api_util.IsDryRun(vpaConfig) {
 			continue
 		}
 		selector, err := p.selectorFetcher.Fetch(vpaConfig)
@@ -101,14 +108,14 @@ func (p *recommendationProvider) getMatchingVPA(pod *v1.Pod) *vpa_types.Vertical
 	return nil
 }
 
-// GetContainersResourcesForPod returns recommended request for a given pod, annotations and name of controlling VPA.
-// The returned slice corresponds 1-1 to containers in the Pod.
-func (p *recommendationProvider) GetContainersResourcesForPod(pod *v1.Pod) ([]ContainerResources, vpa_api_util.ContainerToAnnotationsMap, string, error) {
+// GetContainersResourcesForPod returns recommended request for a given pod, annotations and the controlling VPA.
+// The returned slices correspond 1-1 to containers and init containers in the Pod.
+func (p *recommendationProvider) GetContainersResourcesForPod(pod *v1.Pod) ([]ContainerResources, []ContainerResources, vpa_api_util.ContainerToAnnotationsMap, *vpa_types.VerticalPodAutoscaler, error) {
 	klog.V(2).Infof("updating requirements for pod %s.", pod.Name)
 	vpaConfig := p.getMatchingVPA(pod)
 	if vpaConfig == nil {
 		klog.V(2).Infof("no matching VPA found for pod %s", pod.Name)
-		return nil, nil, "", nil
+		return nil, nil, nil, nil, nil
 	}
 
 	var annotations vpa_api_util.ContainerToAnnotationsMap
@@ -119,9 +126,10 @@ func (p *recommendationProvider) GetContainersResourcesForPod(pod *v1.Pod) ([]Co
 		recommendedPodResources, annotations, err = p.recommendationProcessor.Apply(vpaConfig.Status.Recommendation, vpaConfig.Spec.ResourcePolicy, vpaConfig.Status.Conditions, pod)
 		if err != nil {
 			klog.V(2).Infof("cannot process recommendation for pod %s", pod.Name)
-			return nil, annotations, vpaConfig.Name, err
+			return nil, nil, annotations, vpaConfig, err
 		}
 	}
-	containerResources := getContainersResources(pod, *recommendedPodResources)
-	return containerResources, annotations, vpaConfig.Name, nil
+	containerResources := getContainersResources(pod.Spec.Containers, *recommendedPodResources, vpaConfig.Spec.ResourcePolicy)
+	initContainerResources := getContainersResources(pod.Spec.InitContainers, *recommendedPodResources, vpaConfig.Spec.ResourcePolicy)
+	return containerResources, initContainerResources, annotations, vpaConfig, nil
 }
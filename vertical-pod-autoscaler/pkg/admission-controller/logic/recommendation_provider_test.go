@@ -67,6 +67,8 @@ func TestUpdateResourceRequests(t *testing.T) {
 	initialized.ObjectMeta.Labels = labels
 
 	offVPA := vpaBuilder.WithUpdateMode(vpa_types.UpdateModeOff).Get()
+	dryRunOffVPA := vpaBuilder.WithUpdateMode(vpa_types.UpdateModeOff).Get()
+	dryRunOffVPA.Annotations = map[string]string{vpa_api_util.DryRunAnnotation: "true"}
 
 	targetBelowMinVPA := vpaBuilder.WithTarget("3", "150Mi").WithMinAllowed("4", "300Mi").WithMaxAllowed("5", "1Gi").Get()
 	targetAboveMaxVPA := vpaBuilder.WithTarget("7", "2Gi").WithMinAllowed("4", "300Mi").WithMaxAllowed("5", "1Gi").Get()
@@ -143,6 +145,13 @@ func TestUpdateResourceRequests(t *testing.T) {
 		expectedMem:    "200Mi",
 		expectedCPU:    "2",
 		labelSelector:  "app = testingApp",
+	}, {
+		pod:            uninitialized,
+		vpas:           []*vpa_types.VerticalPodAutoscaler{dryRunOffVPA},
+		expectedAction: true,
+		expectedMem:    "200Mi",
+		expectedCPU:    "2",
+		labelSelector:  "app = testingApp",
 	}, {
 		pod:            initialized,
 		vpas:           []*vpa_types.VerticalPodAutoscaler{vpaWithEmptyRecommendation},
@@ -180,10 +189,10 @@ func TestUpdateResourceRequests(t *testing.T) {
 				selectorFetcher:         mockSelectorFetcher,
 			}
 
-			resources, annotations, name, err := recommendationProvider.GetContainersResourcesForPod(tc.pod)
+			resources, _, annotations, vpa, err := recommendationProvider.GetContainersResourcesForPod(tc.pod)
 
 			if tc.expectedAction {
-				assert.Equal(t, vpaName, name)
+				assert.Equal(t, vpaName, vpa.Name)
 				assert.Nil(t, err)
 				assert.Equal(t, len(resources), 1)
 				expectedCPU, err := resource.ParseQuantity(tc.expectedCPU)
@@ -211,3 +220,138 @@ func TestUpdateResourceRequests(t *testing.T) {
 
 	}
 }
+
+func TestUpdateResourceLimits(t *testing.T) {
+	containerName := "container1"
+	labels := map[string]string{"app": "testingApp"}
+	requestsAndLimits := vpa_types.ContainerControlledValuesRequestsAndLimits
+	requestsOnly := vpa_types.ContainerControlledValuesRequestsOnly
+
+	pod := test.Pod().WithName("test_pod").AddContainer(test.BuildTestContainer(containerName, "1", "100Mi")).Get()
+	pod.ObjectMeta.Labels = labels
+	pod.Spec.Containers[0].Resources.Limits = apiv1.ResourceList{
+		apiv1.ResourceCPU:    resource.MustParse("2"),
+		apiv1.ResourceMemory: resource.MustParse("100Mi"),
+	}
+
+	for _, tc := range []struct {
+		name             string
+		controlledValues *vpa_types.ContainerControlledValues
+		expectedCPU      string
+		expectedLimits   apiv1.ResourceList
+	}{
+		{
+			name:           "default",
+			expectedCPU:    "2", // capped to limit
+			expectedLimits: apiv1.ResourceList{},
+		},
+		{
+			name:             "requests only",
+			controlledValues: &requestsOnly,
+			expectedCPU:      "2", // capped to limit
+			expectedLimits:   apiv1.ResourceList{},
+		},
+		{
+			name:             "requests and limits",
+			controlledValues: &requestsAndLimits,
+			expectedCPU:      "3",
+			expectedLimits: apiv1.ResourceList{
+				apiv1.ResourceCPU:    resource.MustParse("6"),
+				apiv1.ResourceMemory: resource.MustParse("200Mi"),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			vpa := test.VerticalPodAutoscaler().WithName("vpa1").WithContainer(containerName).WithTarget("3", "200Mi").Get()
+			vpa.Spec.ResourcePolicy = &vpa_types.PodResourcePolicy{
+				ContainerPolicies: []vpa_types.ContainerResourcePolicy{{
+					ContainerName:    containerName,
+					ControlledValues: tc.controlledValues,
+				}},
+			}
+			vpaNamespaceLister := &test.VerticalPodAutoscalerListerMock{}
+			vpaNamespaceLister.On("List").Return([]*vpa_types.VerticalPodAutoscaler{vpa}, nil)
+			vpaLister := &test.VerticalPodAutoscalerListerMock{}
+			vpaLister.On("VerticalPodAutoscalers", "default").Return(vpaNamespaceLister)
+			mockSelectorFetcher := target_mock.NewMockVpaTargetSelectorFetcher(ctrl)
+			mockSelectorFetcher.EXPECT().Fetch(gomock.Any()).AnyTimes().Return(parseLabelSelector("app = testingApp"), nil)
+
+			recommendationProvider := &recommendationProvider{
+				vpaLister:               vpaLister,
+				recommendationProcessor: api.NewCappingRecommendationProcessor(),
+				selectorFetcher:         mockSelectorFetcher,
+			}
+			resources, _, _, _, err := recommendationProvider.GetContainersResourcesForPod(pod)
+			assert.NoError(t, err)
+			if assert.Len(t, resources, 1) {
+				cpuRequest := resources[0].Requests[apiv1.ResourceCPU]
+				expectedCPU := resource.MustParse(tc.expectedCPU)
+				assert.Equal(t, expectedCPU.MilliValue(), cpuRequest.MilliValue())
+				assert.Len(t, resources[0].Limits, len(tc.expectedLimits))
+				for resourceName, expected := range tc.expectedLimits {
+					limit := resources[0].Limits[resourceName]
+					assert.Equal(t, expected.MilliValue(), limit.MilliValue(), "%s limit doesn't match", resourceName)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateInitContainerResources(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pod := test.Pod().WithName("test_pod").AddContainer(test.BuildTestContainer("container1", "1", "100Mi")).Get()
+	pod.ObjectMeta.Labels = map[string]string{"app": "testingApp"}
+	pod.Spec.InitContainers = []apiv1.Container{test.BuildTestContainer("init1", "1", "100Mi"), test.BuildTestContainer("init2", "1", "100Mi")}
+
+	recommended := apiv1.ResourceList{
+		apiv1.ResourceCPU:              resource.MustParse("2"),
+		apiv1.ResourceMemory:           resource.MustParse("200Mi"),
+		apiv1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+	}
+	vpa := test.VerticalPodAutoscaler().WithName("vpa1").WithContainer("container1").Get()
+	vpa.Status.Recommendation = &vpa_types.RecommendedPodResources{
+		ContainerRecommendations: []vpa_types.RecommendedContainerResources{
+			{ContainerName: "container1", Target: recommended.DeepCopy()},
+			{ContainerName: "init1", Target: recommended.DeepCopy()},
+		},
+	}
+	controlledResources := []apiv1.ResourceName{apiv1.ResourceCPU, apiv1.ResourceEphemeralStorage}
+	vpa.Spec.ResourcePolicy = &vpa_types.PodResourcePolicy{
+		ContainerPolicies: []vpa_types.ContainerResourcePolicy{{
+			ContainerName:       "init1",
+			ControlledResources: &controlledResources,
+		}},
+	}
+	vpaNamespaceLister := &test.VerticalPodAutoscalerListerMock{}
+	vpaNamespaceLister.On("List").Return([]*vpa_types.VerticalPodAutoscaler{vpa}, nil)
+	vpaLister := &test.VerticalPodAutoscalerListerMock{}
+	vpaLister.On("VerticalPodAutoscalers", "default").Return(vpaNamespaceLister)
+	mockSelectorFetcher := target_mock.NewMockVpaTargetSelectorFetcher(ctrl)
+	mockSelectorFetcher.EXPECT().Fetch(gomock.Any()).AnyTimes().Return(parseLabelSelector("app = testingApp"), nil)
+
+	recommendationProvider := &recommendationProvider{
+		vpaLister:               vpaLister,
+		recommendationProcessor: api.NewCappingRecommendationProcessor(),
+		selectorFetcher:         mockSelectorFetcher,
+	}
+	resources, initResources, _, _, err := recommendationProvider.GetContainersResourcesForPod(pod)
+	assert.NoError(t, err)
+	if assert.Len(t, resources, 1) {
+		// Ephemeral storage is not controlled by default.
+		assert.Len(t, resources[0].Requests, 2)
+		assert.NotContains(t, resources[0].Requests, apiv1.ResourceEphemeralStorage)
+	}
+	if assert.Len(t, initResources, 2) {
+		assert.Len(t, initResources[0].Requests, 2)
+		ephemeralStorage := initResources[0].Requests[apiv1.ResourceEphemeralStorage]
+		assert.Equal(t, int64(1024*1024*1024), ephemeralStorage.Value())
+		assert.NotContains(t, initResources[0].Requests, apiv1.ResourceMemory)
+		// No recommendation for the second init container.
+		assert.Empty(t, initResources[1].Requests)
+	}
+}
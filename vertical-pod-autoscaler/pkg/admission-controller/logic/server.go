@@ -21,27 +21,46 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
 
 	"strings"
 
 	"k8s.io/api/admission/v1beta1"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
 	metrics_admission "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/admission"
 	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 )
 
+// dryRunUpdatesAnnotation describes the resources a VPA in dry-run would set
+// in a pod.
+const dryRunUpdatesAnnotation = "vpaDryRunUpdates"
+
 // AdmissionServer is an admission webhook server that modifies pod resources request based on VPA recommendation
 type AdmissionServer struct {
 	recommendationProvider RecommendationProvider
 	podPreProcessor        PodPreProcessor
+	// dryRun makes the server preview the recommendations of all VPAs
+	// instead of applying them.
+	dryRun        bool
+	eventRecorder record.EventRecorder
 }
 
-// NewAdmissionServer constructs new AdmissionServer
-func NewAdmissionServer(recommendationProvider RecommendationProvider, podPreProcessor PodPreProcessor) *AdmissionServer {
-	return &AdmissionServer{recommendationProvider, podPreProcessor}
+// NewAdmissionServer constructs new AdmissionServer. In dry-run, and for the
+// VPAs with the vpa_api_util.DryRunAnnotation, the resources recommended for a pod are
+// recorded in a pod annotation, in an event on the VPA and in metrics instead
+// of being set in the pod. The eventRecorder can be nil.
+func NewAdmissionServer(recommendationProvider RecommendationProvider, podPreProcessor PodPreProcessor, dryRun bool, eventRecorder record.EventRecorder) *AdmissionServer {
+	return &AdmissionServer{
+		recommendationProvider: recommendationProvider,
+		podPreProcessor:        podPreProcessor,
+		dryRun:                 dryRun,
+		eventRecorder:          eventRecorder,
+	}
 }
 
 type patchRecord struct {
@@ -50,23 +69,29 @@ type patchRecord struct {
 	Value interface{} `json:"value"`
 }
 
-func (s *AdmissionServer) getPatchesForPodResourceRequest(raw []byte, namespace string) ([]patchRecord, error) {
+// getPatchesForPodResourceRequest returns the patches of the pod. It returns
+// true if the recommendation was only recorded in dry-run.
+func (s *AdmissionServer) getPatchesForPodResourceRequest(raw []byte, namespace string) ([]patchRecord, bool, error) {
 	pod := v1.Pod{}
 	if err := json.Unmarshal(raw, &pod); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	if len(pod.Name) == 0 {
 		pod.Name = pod.GenerateName + "%"
 		pod.Namespace = namespace
 	}
 	klog.V(4).Infof("Admitting pod %v", pod.ObjectMeta)
-	containersResources, annotationsPerContainer, vpaName, err := s.recommendationProvider.GetContainersResourcesForPod(&pod)
+	containersResources, initContainersResources, annotationsPerContainer, vpa, err := s.recommendationProvider.GetContainersResourcesForPod(&pod)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	pod, err = s.podPreProcessor.Process(pod)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	if vpa != nil && (s.dryRun || vpa_api_util.IsDryRun(vpa)) {
+		patches := s.getDryRunPatches(pod, vpa, containersResources, initContainersResources)
+		return patches, len(patches) > 0, nil
 	}
 	if annotationsPerContainer == nil {
 		annotationsPerContainer = vpa_api_util.ContainerToAnnotationsMap{}
@@ -74,55 +99,140 @@ func (s *AdmissionServer) getPatchesForPodResourceRequest(raw []byte, namespace
 	patches := []patchRecord{}
 	updatesAnnotation := []string{}
 	for i, containerResources := range containersResources {
-
-		// Add resources empty object if missing
-		if pod.Spec.Containers[i].Resources.Limits == nil &&
-			pod.Spec.Containers[i].Resources.Requests == nil {
-			patches = append(patches, patchRecord{
-				Op:    "add",
-				Path:  fmt.Sprintf("/spec/containers/%d/resources", i),
-				Value: v1.ResourceRequirements{},
-			})
+		newPatches, updatesAnnotationForContainer := getContainerPatches(pod.Spec.Containers[i], "containers", i, containerResources, annotationsPerContainer)
+		patches = append(patches, newPatches...)
+		updatesAnnotation = append(updatesAnnotation, fmt.Sprintf("container %d: ", i)+updatesAnnotationForContainer)
+	}
+	for i, containerResources := range initContainersResources {
+		if len(containerResources.Requests) == 0 && len(containerResources.Limits) == 0 {
+			// Init containers without recommendation are left untouched.
+			continue
 		}
+		newPatches, updatesAnnotationForContainer := getContainerPatches(pod.Spec.InitContainers[i], "initContainers", i, containerResources, annotationsPerContainer)
+		patches = append(patches, newPatches...)
+		updatesAnnotation = append(updatesAnnotation, fmt.Sprintf("init container %d: ", i)+updatesAnnotationForContainer)
+	}
+	if len(updatesAnnotation) > 0 {
+		vpaAnnotationValue := fmt.Sprintf("Pod resources updated by %s: ", vpa.Name) + strings.Join(updatesAnnotation, "; ")
+		patches = append(patches, getAnnotationPatch(pod, "vpaUpdates", vpaAnnotationValue))
+	}
+	return patches, false, nil
+}
 
-		// Add request empty map if missing
-		if pod.Spec.Containers[i].Resources.Requests == nil {
-			patches = append(patches, patchRecord{
-				Op:    "add",
-				Path:  fmt.Sprintf("/spec/containers/%d/resources/requests", i),
-				Value: v1.ResourceList{}})
+func getAnnotationPatch(pod v1.Pod, key, value string) patchRecord {
+	if pod.Annotations == nil {
+		return patchRecord{
+			Op:    "add",
+			Path:  "/metadata/annotations",
+			Value: map[string]string{key: value}}
+	}
+	return patchRecord{
+		Op:    "add",
+		Path:  "/metadata/annotations/" + key,
+		Value: value}
+}
+
+// getDryRunPatches records the resources the VPA would set in the pod in a
+// pod annotation, an event on the VPA and metrics. The only patch returned
+// adds the annotation, the resources of the pod are left untouched.
+func (s *AdmissionServer) getDryRunPatches(pod v1.Pod, vpa *vpa_types.VerticalPodAutoscaler, containersResources, initContainersResources []ContainerResources) []patchRecord {
+	updates := describeResourceUpdates(pod.Spec.Containers, containersResources)
+	updates = append(updates, describeResourceUpdates(pod.Spec.InitContainers, initContainersResources)...)
+	if len(updates) == 0 {
+		return []patchRecord{}
+	}
+	metrics_admission.OnDryRunPod()
+	message := fmt.Sprintf("Pod resources would be updated by %s: ", vpa.Name) + strings.Join(updates, "; ")
+	klog.V(2).Infof("Dry-run for pod %s/%s. %s", pod.Namespace, pod.Name, message)
+	if s.eventRecorder != nil {
+		s.eventRecorder.Eventf(vpa, v1.EventTypeNormal, "DryRunRecommendation", "Pod %s/%s: %s", pod.Namespace, pod.Name, message)
+	}
+	return []patchRecord{getAnnotationPatch(pod, dryRunUpdatesAnnotation, message)}
+}
+
+// describeResourceUpdates describes the changes of the requests and limits of
+// the containers to the given resources, and records the ratios of the
+// requests in metrics. Unchanged resources are skipped.
+func describeResourceUpdates(containers []v1.Container, resources []ContainerResources) []string {
+	updates := []string{}
+	for i, containerResources := range resources {
+		container := containers[i]
+		changes := describeResourceListUpdates("request", container.Resources.Requests, containerResources.Requests)
+		changes = append(changes, describeResourceListUpdates("limit", container.Resources.Limits, containerResources.Limits)...)
+		if len(changes) > 0 {
+			updates = append(updates, fmt.Sprintf("container %s: %s", container.Name, strings.Join(changes, ", ")))
 		}
+	}
+	return updates
+}
 
-		annotations, found := annotationsPerContainer[pod.Spec.Containers[i].Name]
-		if !found {
-			annotations = make([]string, 0)
+func describeResourceListUpdates(kind string, original, updated v1.ResourceList) []string {
+	resourceNames := make([]string, 0, len(updated))
+	for resourceName := range updated {
+		resourceNames = append(resourceNames, string(resourceName))
+	}
+	sort.Strings(resourceNames)
+	changes := make([]string, 0, len(resourceNames))
+	for _, resourceName := range resourceNames {
+		originalValue := original[v1.ResourceName(resourceName)]
+		updatedValue := updated[v1.ResourceName(resourceName)]
+		if kind == "request" && originalValue.MilliValue() > 0 {
+			metrics_admission.ObserveDryRunRequestRatio(resourceName, float64(updatedValue.MilliValue())/float64(originalValue.MilliValue()))
 		}
-		for resource, request := range containerResources.Requests {
-			// Set request
-			patches = append(patches, patchRecord{
-				Op:    "add",
-				Path:  fmt.Sprintf("/spec/containers/%d/resources/requests/%s", i, resource),
-				Value: request.String()})
-			annotations = append(annotations, fmt.Sprintf("%s request", resource))
+		if updatedValue.Cmp(originalValue) != 0 {
+			changes = append(changes, fmt.Sprintf("%s %s %s -> %s", resourceName, kind, originalValue.String(), updatedValue.String()))
 		}
+	}
+	return changes
+}
+
+// getContainerPatches returns the patches setting the recommended resources of
+// the container with the given index in the given list of the pod spec
+// ("containers" or "initContainers"), and the description of the update.
+func getContainerPatches(container v1.Container, containerList string, index int, containerResources ContainerResources, annotationsPerContainer vpa_api_util.ContainerToAnnotationsMap) ([]patchRecord, string) {
+	patches := []patchRecord{}
 
-		updatesAnnotation = append(updatesAnnotation, fmt.Sprintf("container %d: ", i)+strings.Join(annotations, ", "))
+	// Add resources empty object if missing
+	if container.Resources.Limits == nil &&
+		container.Resources.Requests == nil {
+		patches = append(patches, patchRecord{
+			Op:    "add",
+			Path:  fmt.Sprintf("/spec/%s/%d/resources", containerList, index),
+			Value: v1.ResourceRequirements{},
+		})
 	}
-	if len(updatesAnnotation) > 0 {
-		vpaAnnotationValue := fmt.Sprintf("Pod resources updated by %s: ", vpaName) + strings.Join(updatesAnnotation, "; ")
-		if pod.Annotations == nil {
-			patches = append(patches, patchRecord{
-				Op:    "add",
-				Path:  "/metadata/annotations",
-				Value: map[string]string{"vpaUpdates": vpaAnnotationValue}})
-		} else {
-			patches = append(patches, patchRecord{
-				Op:    "add",
-				Path:  "/metadata/annotations/vpaUpdates",
-				Value: vpaAnnotationValue})
-		}
+
+	// Add request empty map if missing
+	if container.Resources.Requests == nil {
+		patches = append(patches, patchRecord{
+			Op:    "add",
+			Path:  fmt.Sprintf("/spec/%s/%d/resources/requests", containerList, index),
+			Value: v1.ResourceList{}})
 	}
-	return patches, nil
+
+	annotations, found := annotationsPerContainer[container.Name]
+	if !found {
+		annotations = make([]string, 0)
+	}
+	for resource, request := range containerResources.Requests {
+		// Set request
+		patches = append(patches, patchRecord{
+			Op:    "add",
+			Path:  fmt.Sprintf("/spec/%s/%d/resources/requests/%s", containerList, index, resource),
+			Value: request.String()})
+		annotations = append(annotations, fmt.Sprintf("%s request", resource))
+	}
+
+	// Limits are only scaled for containers that have limits set.
+	for resource, limit := range containerResources.Limits {
+		// Set limit
+		patches = append(patches, patchRecord{
+			Op:    "add",
+			Path:  fmt.Sprintf("/spec/%s/%d/resources/limits/%s", containerList, index, resource),
+			Value: limit.String()})
+		annotations = append(annotations, fmt.Sprintf("%s limit", resource))
+	}
+	return patches, strings.Join(annotations, ", ")
 }
 
 func parseVPA(raw []byte) (*vpa_types.VerticalPodAutoscaler, error) {
@@ -139,12 +249,30 @@ var (
 		vpa_types.UpdateModeInitial:  struct{}{},
 		vpa_types.UpdateModeRecreate: struct{}{},
 		vpa_types.UpdateModeAuto:     struct{}{},
+		vpa_types.UpdateModeInPlace:  struct{}{},
 	}
 
 	possibleScalingModes = map[vpa_types.ContainerScalingMode]interface{}{
 		vpa_types.ContainerScalingModeAuto: struct{}{},
 		vpa_types.ContainerScalingModeOff:  struct{}{},
 	}
+
+	possibleControlledResources = map[v1.ResourceName]interface{}{
+		v1.ResourceCPU:              struct{}{},
+		v1.ResourceMemory:           struct{}{},
+		v1.ResourceEphemeralStorage: struct{}{},
+	}
+
+	possibleControlledValues = map[vpa_types.ContainerControlledValues]interface{}{
+		vpa_types.ContainerControlledValuesRequestsOnly:      struct{}{},
+		vpa_types.ContainerControlledValuesRequestsAndLimits: struct{}{},
+	}
+
+	possibleControllerMetricObjectKinds = map[vpa_types.ControllerMetricObjectKind]interface{}{
+		vpa_types.ControllerMetricObjectKindPod:     struct{}{},
+		vpa_types.ControllerMetricObjectKindService: struct{}{},
+		vpa_types.ControllerMetricObjectKindIngress: struct{}{},
+	}
 )
 
 func validateVPA(vpa *vpa_types.VerticalPodAutoscaler) error {
@@ -156,6 +284,9 @@ func validateVPA(vpa *vpa_types.VerticalPodAutoscaler) error {
 		if _, found := possibleUpdateModes[*mode]; !found {
 			return fmt.Errorf("unexpected UpdateMode value %s", *mode)
 		}
+		if err := validateActuationPolicy(vpa.Spec.UpdatePolicy.ActuationPolicy); err != nil {
+			return fmt.Errorf("invalid ActuationPolicy: %v", err)
+		}
 	}
 
 	if vpa.Spec.ResourcePolicy != nil {
@@ -169,18 +300,240 @@ func validateVPA(vpa *vpa_types.VerticalPodAutoscaler) error {
 					return fmt.Errorf("unexpected Mode value %s", *mode)
 				}
 			}
+			controlledValues := policy.ControlledValues
+			if controlledValues != nil {
+				if _, found := possibleControlledValues[*controlledValues]; !found {
+					return fmt.Errorf("unexpected ControlledValues value %s", *controlledValues)
+				}
+			}
+			if policy.ControlledResources != nil {
+				for _, resource := range *policy.ControlledResources {
+					if _, found := possibleControlledResources[resource]; !found {
+						return fmt.Errorf("unexpected ControlledResources value %s", resource)
+					}
+				}
+			}
+			recommender := policy.Recommender
+			if recommender != nil {
+				// Recommendation algorithms are registered in the recommender,
+				// so only the format of the name can be validated here.
+				if errs := validation.IsDNS1123Label(string(*recommender)); len(errs) > 0 {
+					return fmt.Errorf("unexpected Recommender value %s: %s", *recommender, strings.Join(errs, ", "))
+				}
+			}
 			for resource, min := range policy.MinAllowed {
 				max, found := policy.MaxAllowed[resource]
 				if found && max.Cmp(min) < 0 {
 					return fmt.Errorf("max resource for %v is lower than min", resource)
 				}
 			}
+			if err := validateControllerPolicy(policy.ControllerPolicy); err != nil {
+				return fmt.Errorf("invalid ControllerPolicy for container %s: %v", policy.ContainerName, err)
+			}
+		}
+	}
+
+	if err := validateControllerPolicy(vpa.Spec.ControllerPolicy); err != nil {
+		return fmt.Errorf("invalid ControllerPolicy: %v", err)
+	}
+
+	if err := validateAggregationPolicy(vpa.Spec.AggregationPolicy); err != nil {
+		return fmt.Errorf("invalid AggregationPolicy: %v", err)
+	}
+
+	if err := validateRecommenderPolicy(vpa.Spec.RecommenderPolicy); err != nil {
+		return fmt.Errorf("invalid RecommenderPolicy: %v", err)
+	}
+
+	if err := validateOOMBumpUpPolicy(vpa.Spec.OOMBumpUpPolicy); err != nil {
+		return fmt.Errorf("invalid OOMBumpUpPolicy: %v", err)
+	}
+
+	if len(vpa.Spec.Recommenders) > 1 {
+		return fmt.Errorf("Recommenders must not select more than one recommender")
+	}
+	for _, recommender := range vpa.Spec.Recommenders {
+		if recommender == nil || recommender.Name == "" {
+			return fmt.Errorf("Recommenders.Name is required")
 		}
 	}
 
 	return nil
 }
 
+func validateActuationPolicy(policy *vpa_types.ActuationPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MinPodUpdateInterval != nil && policy.MinPodUpdateInterval.Duration < 0 {
+		return fmt.Errorf("MinPodUpdateInterval must not be negative")
+	}
+	if policy.MinVpaUpdateInterval != nil && policy.MinVpaUpdateInterval.Duration < 0 {
+		return fmt.Errorf("MinVpaUpdateInterval must not be negative")
+	}
+	if policy.MinRelativeChange != nil && *policy.MinRelativeChange < 0 {
+		return fmt.Errorf("MinRelativeChange must not be negative")
+	}
+	if policy.MaxConcurrentDisruptions != nil && *policy.MaxConcurrentDisruptions < 1 {
+		return fmt.Errorf("MaxConcurrentDisruptions must be at least 1")
+	}
+	return nil
+}
+
+func validateControllerPolicy(policy *vpa_types.ControllerPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.SLA != nil && *policy.SLA <= 0 {
+		return fmt.Errorf("SLA must be positive")
+	}
+	if policy.ClosedLoopPole != nil && (*policy.ClosedLoopPole < 0 || *policy.ClosedLoopPole >= 1) {
+		return fmt.Errorf("ClosedLoopPole must be in range [0, 1)")
+	}
+	if policy.NominalPole != nil && (*policy.NominalPole < 0 || *policy.NominalPole >= 1) {
+		return fmt.Errorf("NominalPole must be in range [0, 1)")
+	}
+	if m := policy.NominalModel; m != nil {
+		if m.A1 != nil && *m.A1 < 0 {
+			return fmt.Errorf("NominalModel.A1 must not be negative")
+		}
+		if m.A2 != nil && *m.A2 < 0 {
+			return fmt.Errorf("NominalModel.A2 must not be negative")
+		}
+		if m.A3 != nil && *m.A3 <= 0 {
+			return fmt.Errorf("NominalModel.A3 must be positive")
+		}
+	}
+	if policy.MaxCPU != nil && policy.MaxCPU.Sign() <= 0 {
+		return fmt.Errorf("MaxCPU must be positive")
+	}
+	if policy.Memory != nil && policy.Memory.Sign() <= 0 {
+		return fmt.Errorf("Memory must be positive")
+	}
+	if policy.SamplingPeriod != nil && policy.SamplingPeriod.Duration <= 0 {
+		return fmt.Errorf("SamplingPeriod must be positive")
+	}
+	return validateControllerMetricSource(policy.MetricSource)
+}
+
+func validateAggregationPolicy(policy *vpa_types.AggregationPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.HistoryLength != nil && policy.HistoryLength.Duration <= 0 {
+		return fmt.Errorf("HistoryLength must be positive")
+	}
+	if policy.CPUHistogramDecayHalfLife != nil && policy.CPUHistogramDecayHalfLife.Duration <= 0 {
+		return fmt.Errorf("CPUHistogramDecayHalfLife must be positive")
+	}
+	if policy.MemoryHistogramDecayHalfLife != nil && policy.MemoryHistogramDecayHalfLife.Duration <= 0 {
+		return fmt.Errorf("MemoryHistogramDecayHalfLife must be positive")
+	}
+	// Smaller growths need too many histogram buckets.
+	if growth := policy.HistogramBucketSizeGrowth; growth != nil && (*growth < 0.01 || *growth > 1) {
+		return fmt.Errorf("HistogramBucketSizeGrowth must be in range [0.01, 1]")
+	}
+	if err := validateRecommendationPercentiles(policy.CPUPercentiles); err != nil {
+		return fmt.Errorf("invalid CPUPercentiles: %v", err)
+	}
+	if err := validateRecommendationPercentiles(policy.MemoryPercentiles); err != nil {
+		return fmt.Errorf("invalid MemoryPercentiles: %v", err)
+	}
+	// Histogram backends are registered in the recommender, so only the
+	// format of the name can be validated here.
+	if backend := policy.HistogramBackend; backend != nil {
+		if errs := validation.IsDNS1123Label(string(*backend)); len(errs) > 0 {
+			return fmt.Errorf("unexpected HistogramBackend value %s: %s", *backend, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+func validateOOMBumpUpPolicy(policy *vpa_types.OOMBumpUpPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.Ratio != nil && *policy.Ratio < 1 {
+		return fmt.Errorf("Ratio must be at least 1")
+	}
+	if policy.MinBumpUp != nil && policy.MinBumpUp.Sign() < 0 {
+		return fmt.Errorf("MinBumpUp must not be negative")
+	}
+	return nil
+}
+
+func validateRecommenderPolicy(policy *vpa_types.RecommenderPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if err := validateRecommendationPercentiles(policy.CPUPercentiles); err != nil {
+		return fmt.Errorf("invalid CPUPercentiles: %v", err)
+	}
+	if err := validateRecommendationPercentiles(policy.MemoryPercentiles); err != nil {
+		return fmt.Errorf("invalid MemoryPercentiles: %v", err)
+	}
+	if policy.SafetyMarginFraction != nil && *policy.SafetyMarginFraction < 0 {
+		return fmt.Errorf("SafetyMarginFraction must not be negative")
+	}
+	if confidence := policy.UpperBoundConfidence; confidence != nil && confidence.Multiplier != nil && *confidence.Multiplier < 0 {
+		return fmt.Errorf("UpperBoundConfidence.Multiplier must not be negative")
+	}
+	if confidence := policy.LowerBoundConfidence; confidence != nil && confidence.Multiplier != nil && *confidence.Multiplier < 0 {
+		return fmt.Errorf("LowerBoundConfidence.Multiplier must not be negative")
+	}
+	for resource, min := range policy.PodMinResources {
+		if resource != v1.ResourceCPU && resource != v1.ResourceMemory {
+			return fmt.Errorf("PodMinResources supports only cpu and memory, got %v", resource)
+		}
+		if min.Sign() < 0 {
+			return fmt.Errorf("PodMinResources for %v must not be negative", resource)
+		}
+	}
+	return nil
+}
+
+func validateRecommendationPercentiles(percentiles *vpa_types.RecommendationPercentiles) error {
+	if percentiles == nil {
+		return nil
+	}
+	for name, percentile := range map[string]*float64{
+		"Target":     percentiles.Target,
+		"LowerBound": percentiles.LowerBound,
+		"UpperBound": percentiles.UpperBound,
+	} {
+		if percentile != nil && (*percentile <= 0 || *percentile > 1) {
+			return fmt.Errorf("%s must be in range (0, 1]", name)
+		}
+	}
+	if percentiles.LowerBound != nil && percentiles.Target != nil && *percentiles.LowerBound > *percentiles.Target {
+		return fmt.Errorf("LowerBound must not be greater than Target")
+	}
+	if percentiles.Target != nil && percentiles.UpperBound != nil && *percentiles.Target > *percentiles.UpperBound {
+		return fmt.Errorf("Target must not be greater than UpperBound")
+	}
+	return nil
+}
+
+func validateControllerMetricSource(source *vpa_types.ControllerMetricSource) error {
+	if source == nil {
+		return nil
+	}
+	if source.ObjectKind != "" {
+		if _, found := possibleControllerMetricObjectKinds[source.ObjectKind]; !found {
+			return fmt.Errorf("unexpected MetricSource.ObjectKind %s", source.ObjectKind)
+		}
+	}
+	if source.Selector != nil {
+		if source.ObjectName != "" && source.ObjectName != "*" {
+			return fmt.Errorf("MetricSource.ObjectName and MetricSource.Selector cannot be both set")
+		}
+		if _, err := metav1.LabelSelectorAsSelector(source.Selector); err != nil {
+			return fmt.Errorf("invalid MetricSource.Selector: %v", err)
+		}
+	}
+	return nil
+}
+
 func getPatchesForVPADefaults(raw []byte) ([]patchRecord, error) {
 	vpa, err := parseVPA(raw)
 	if err != nil {
@@ -220,12 +573,13 @@ func (s *AdmissionServer) admit(data []byte) (*v1beta1.AdmissionResponse, metric
 	podResource := metav1.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
 	vpaResource := metav1.GroupVersionResource{Group: "autoscaling.k8s.io", Version: "v1beta1", Resource: "verticalpodautoscalers"}
 	var patches []patchRecord
+	var dryRun bool
 	var err error
 	resource := metrics_admission.Unknown
 
 	switch ar.Request.Resource {
 	case podResource:
-		patches, err = s.getPatchesForPodResourceRequest(ar.Request.Object.Raw, ar.Request.Namespace)
+		patches, dryRun, err = s.getPatchesForPodResourceRequest(ar.Request.Object.Raw, ar.Request.Namespace)
 		resource = metrics_admission.Pod
 	case vpaResource:
 		patches, err = getPatchesForVPADefaults(ar.Request.Object.Raw)
@@ -260,7 +614,9 @@ func (s *AdmissionServer) admit(data []byte) (*v1beta1.AdmissionResponse, metric
 	}
 
 	var status metrics_admission.AdmissionStatus
-	if len(patches) > 0 {
+	if dryRun {
+		status = metrics_admission.DryRun
+	} else if len(patches) > 0 {
 		status = metrics_admission.Applied
 	} else {
 		status = metrics_admission.Skipped
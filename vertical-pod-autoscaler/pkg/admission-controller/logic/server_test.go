@@ -0,0 +1,593 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/test"
+	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func quantityPtr(s string) *resource.Quantity {
+	q := resource.MustParse(s)
+	return &q
+}
+
+func TestValidateVPAControllerPolicy(t *testing.T) {
+	testCases := []struct {
+		name          string
+		vpaPolicy     *vpa_types.ControllerPolicy
+		containerPol  *vpa_types.ControllerPolicy
+		expectedError bool
+	}{
+		{
+			name: "no controller policy",
+		},
+		{
+			name: "valid controller policy",
+			vpaPolicy: &vpa_types.ControllerPolicy{
+				SLA:            floatPtr(0.5),
+				ClosedLoopPole: floatPtr(0.9),
+				NominalPole:    floatPtr(0.8),
+				NominalModel: &vpa_types.ControllerModel{
+					A1: floatPtr(0.1963),
+					A2: floatPtr(0.002),
+					A3: floatPtr(0.5658),
+				},
+				MaxCPU: quantityPtr("2"),
+				Memory: quantityPtr("128Mi"),
+			},
+			containerPol: &vpa_types.ControllerPolicy{SLA: floatPtr(0.2)},
+		},
+		{
+			name:          "non-positive SLA",
+			vpaPolicy:     &vpa_types.ControllerPolicy{SLA: floatPtr(0)},
+			expectedError: true,
+		},
+		{
+			name:          "closed loop pole out of range",
+			vpaPolicy:     &vpa_types.ControllerPolicy{ClosedLoopPole: floatPtr(1)},
+			expectedError: true,
+		},
+		{
+			name:          "negative nominal pole",
+			vpaPolicy:     &vpa_types.ControllerPolicy{NominalPole: floatPtr(-0.1)},
+			expectedError: true,
+		},
+		{
+			name:          "zero a3 coefficient",
+			vpaPolicy:     &vpa_types.ControllerPolicy{NominalModel: &vpa_types.ControllerModel{A3: floatPtr(0)}},
+			expectedError: true,
+		},
+		{
+			name:          "zero sampling period",
+			vpaPolicy:     &vpa_types.ControllerPolicy{SamplingPeriod: &metav1.Duration{}},
+			expectedError: true,
+		},
+		{
+			name:          "zero max CPU",
+			vpaPolicy:     &vpa_types.ControllerPolicy{MaxCPU: quantityPtr("0")},
+			expectedError: true,
+		},
+		{
+			name: "valid metric source",
+			vpaPolicy: &vpa_types.ControllerPolicy{MetricSource: &vpa_types.ControllerMetricSource{
+				ObjectKind: vpa_types.ControllerMetricObjectKindPod,
+				Selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"app": "front"}},
+			}},
+		},
+		{
+			name: "unknown metric object kind",
+			vpaPolicy: &vpa_types.ControllerPolicy{MetricSource: &vpa_types.ControllerMetricSource{
+				ObjectKind: "Node",
+			}},
+			expectedError: true,
+		},
+		{
+			name: "metric object name and selector both set",
+			vpaPolicy: &vpa_types.ControllerPolicy{MetricSource: &vpa_types.ControllerMetricSource{
+				ObjectName: "front",
+				Selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"app": "front"}},
+			}},
+			expectedError: true,
+		},
+		{
+			name:          "invalid container controller policy",
+			containerPol:  &vpa_types.ControllerPolicy{Memory: quantityPtr("-1Mi")},
+			expectedError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			vpa := vpa_types.VerticalPodAutoscaler{
+				Spec: vpa_types.VerticalPodAutoscalerSpec{
+					ControllerPolicy: tc.vpaPolicy,
+					ResourcePolicy: &vpa_types.PodResourcePolicy{
+						ContainerPolicies: []vpa_types.ContainerResourcePolicy{{
+							ContainerName:    "container1",
+							ControllerPolicy: tc.containerPol,
+						}},
+					},
+				},
+			}
+			err := validateVPA(&vpa)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateVPARecommender(t *testing.T) {
+	validRecommender := vpa_types.ContainerRecommenderResponseTimeController
+	customRecommender := vpa_types.ContainerRecommender("custom-controller")
+	invalidRecommender := vpa_types.ContainerRecommender("Custom_Controller")
+	for _, tc := range []struct {
+		recommender   *vpa_types.ContainerRecommender
+		expectedError bool
+	}{
+		{recommender: nil},
+		{recommender: &validRecommender},
+		{recommender: &customRecommender},
+		{recommender: &invalidRecommender, expectedError: true},
+	} {
+		vpa := vpa_types.VerticalPodAutoscaler{
+			Spec: vpa_types.VerticalPodAutoscalerSpec{
+				ResourcePolicy: &vpa_types.PodResourcePolicy{
+					ContainerPolicies: []vpa_types.ContainerResourcePolicy{{
+						ContainerName: "container1",
+						Recommender:   tc.recommender,
+					}},
+				},
+			},
+		}
+		err := validateVPA(&vpa)
+		if tc.expectedError {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestValidateVPAActuationPolicy(t *testing.T) {
+	zero := int32(0)
+	for _, tc := range []struct {
+		name          string
+		policy        *vpa_types.ActuationPolicy
+		expectedError bool
+	}{
+		{name: "no actuation policy"},
+		{
+			name: "valid actuation policy",
+			policy: &vpa_types.ActuationPolicy{
+				MinPodUpdateInterval: &metav1.Duration{Duration: time.Minute},
+				MinVpaUpdateInterval: &metav1.Duration{},
+				MinRelativeChange:    floatPtr(0.2),
+			},
+		},
+		{
+			name:          "negative pod update interval",
+			policy:        &vpa_types.ActuationPolicy{MinPodUpdateInterval: &metav1.Duration{Duration: -time.Minute}},
+			expectedError: true,
+		},
+		{
+			name:          "negative VPA update interval",
+			policy:        &vpa_types.ActuationPolicy{MinVpaUpdateInterval: &metav1.Duration{Duration: -time.Minute}},
+			expectedError: true,
+		},
+		{
+			name:          "negative relative change",
+			policy:        &vpa_types.ActuationPolicy{MinRelativeChange: floatPtr(-0.1)},
+			expectedError: true,
+		},
+		{
+			name:          "no concurrent disruptions",
+			policy:        &vpa_types.ActuationPolicy{MaxConcurrentDisruptions: &zero},
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			updateMode := vpa_types.UpdateModeAuto
+			vpa := vpa_types.VerticalPodAutoscaler{
+				Spec: vpa_types.VerticalPodAutoscalerSpec{
+					UpdatePolicy: &vpa_types.PodUpdatePolicy{UpdateMode: &updateMode, ActuationPolicy: tc.policy},
+				},
+			}
+			err := validateVPA(&vpa)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateVPAAggregationPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		policy        *vpa_types.AggregationPolicy
+		expectedError bool
+	}{
+		{name: "no aggregation policy"},
+		{
+			name: "valid aggregation policy",
+			policy: &vpa_types.AggregationPolicy{
+				HistoryLength:                &metav1.Duration{Duration: 24 * time.Hour},
+				CPUHistogramDecayHalfLife:    &metav1.Duration{Duration: time.Hour},
+				MemoryHistogramDecayHalfLife: &metav1.Duration{Duration: time.Hour},
+				HistogramBucketSizeGrowth:    floatPtr(0.1),
+				CPUPercentiles:               &vpa_types.RecommendationPercentiles{Target: floatPtr(0.99), UpperBound: floatPtr(1)},
+				MemoryPercentiles:            &vpa_types.RecommendationPercentiles{LowerBound: floatPtr(0.1)},
+				HistogramBackend:             histogramBackendPtr(vpa_types.HistogramBackendNonDecaying),
+			},
+		},
+		{
+			name:          "zero history length",
+			policy:        &vpa_types.AggregationPolicy{HistoryLength: &metav1.Duration{}},
+			expectedError: true,
+		},
+		{
+			name:          "negative half-life",
+			policy:        &vpa_types.AggregationPolicy{CPUHistogramDecayHalfLife: &metav1.Duration{Duration: -time.Hour}},
+			expectedError: true,
+		},
+		{
+			name:          "too small bucket size growth",
+			policy:        &vpa_types.AggregationPolicy{HistogramBucketSizeGrowth: floatPtr(0.001)},
+			expectedError: true,
+		},
+		{
+			name:          "percentile out of range",
+			policy:        &vpa_types.AggregationPolicy{MemoryPercentiles: &vpa_types.RecommendationPercentiles{Target: floatPtr(1.5)}},
+			expectedError: true,
+		},
+		{
+			name:          "invalid histogram backend name",
+			policy:        &vpa_types.AggregationPolicy{HistogramBackend: histogramBackendPtr("Non_Decaying")},
+			expectedError: true,
+		},
+		{
+			name: "target above upper bound",
+			policy: &vpa_types.AggregationPolicy{
+				CPUPercentiles: &vpa_types.RecommendationPercentiles{Target: floatPtr(0.9), UpperBound: floatPtr(0.8)},
+			},
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vpa := vpa_types.VerticalPodAutoscaler{
+				Spec: vpa_types.VerticalPodAutoscalerSpec{AggregationPolicy: tc.policy},
+			}
+			err := validateVPA(&vpa)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func histogramBackendPtr(backend vpa_types.HistogramBackend) *vpa_types.HistogramBackend {
+	return &backend
+}
+
+func TestValidateVPAOOMBumpUpPolicy(t *testing.T) {
+	negativeQuantity := resource.MustParse("-100Mi")
+	validQuantity := resource.MustParse("200Mi")
+	for _, tc := range []struct {
+		name          string
+		policy        *vpa_types.OOMBumpUpPolicy
+		expectedError bool
+	}{
+		{name: "no OOM bump up policy"},
+		{
+			name:   "valid OOM bump up policy",
+			policy: &vpa_types.OOMBumpUpPolicy{Ratio: floatPtr(1.5), MinBumpUp: &validQuantity},
+		},
+		{
+			name:          "ratio lower than 1",
+			policy:        &vpa_types.OOMBumpUpPolicy{Ratio: floatPtr(0.9)},
+			expectedError: true,
+		},
+		{
+			name:          "negative min bump up",
+			policy:        &vpa_types.OOMBumpUpPolicy{MinBumpUp: &negativeQuantity},
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vpa := vpa_types.VerticalPodAutoscaler{
+				Spec: vpa_types.VerticalPodAutoscalerSpec{OOMBumpUpPolicy: tc.policy},
+			}
+			err := validateVPA(&vpa)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateVPARecommenderPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		policy        *vpa_types.RecommenderPolicy
+		expectedError bool
+	}{
+		{name: "no recommender policy"},
+		{
+			name: "valid recommender policy",
+			policy: &vpa_types.RecommenderPolicy{
+				CPUPercentiles:       &vpa_types.RecommendationPercentiles{Target: floatPtr(0.99), UpperBound: floatPtr(1)},
+				MemoryPercentiles:    &vpa_types.RecommendationPercentiles{LowerBound: floatPtr(0.1)},
+				SafetyMarginFraction: floatPtr(0),
+				UpperBoundConfidence: &vpa_types.ConfidenceMultiplier{Multiplier: floatPtr(2), Exponent: floatPtr(1)},
+				LowerBoundConfidence: &vpa_types.ConfidenceMultiplier{Exponent: floatPtr(-1)},
+				PodMinResources: apiv1.ResourceList{
+					apiv1.ResourceCPU:    resource.MustParse("100m"),
+					apiv1.ResourceMemory: resource.MustParse("100Mi"),
+				},
+			},
+		},
+		{
+			name:          "recommender percentile out of range",
+			policy:        &vpa_types.RecommenderPolicy{CPUPercentiles: &vpa_types.RecommendationPercentiles{LowerBound: floatPtr(0)}},
+			expectedError: true,
+		},
+		{
+			name:          "negative safety margin",
+			policy:        &vpa_types.RecommenderPolicy{SafetyMarginFraction: floatPtr(-0.1)},
+			expectedError: true,
+		},
+		{
+			name:          "negative confidence multiplier",
+			policy:        &vpa_types.RecommenderPolicy{LowerBoundConfidence: &vpa_types.ConfidenceMultiplier{Multiplier: floatPtr(-1)}},
+			expectedError: true,
+		},
+		{
+			name: "unsupported min resource",
+			policy: &vpa_types.RecommenderPolicy{
+				PodMinResources: apiv1.ResourceList{apiv1.ResourceEphemeralStorage: resource.MustParse("1Gi")},
+			},
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vpa := vpa_types.VerticalPodAutoscaler{
+				Spec: vpa_types.VerticalPodAutoscalerSpec{RecommenderPolicy: tc.policy},
+			}
+			err := validateVPA(&vpa)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateVPARecommenders(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		recommenders  []*vpa_types.VerticalPodAutoscalerRecommenderSelector
+		expectedError bool
+	}{
+		{name: "no recommenders"},
+		{
+			name:         "one recommender",
+			recommenders: []*vpa_types.VerticalPodAutoscalerRecommenderSelector{{Name: "custom"}},
+		},
+		{
+			name:          "two recommenders",
+			recommenders:  []*vpa_types.VerticalPodAutoscalerRecommenderSelector{{Name: "custom"}, {Name: "other"}},
+			expectedError: true,
+		},
+		{
+			name:          "empty name",
+			recommenders:  []*vpa_types.VerticalPodAutoscalerRecommenderSelector{{}},
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vpa := vpa_types.VerticalPodAutoscaler{
+				Spec: vpa_types.VerticalPodAutoscalerSpec{Recommenders: tc.recommenders},
+			}
+			err := validateVPA(&vpa)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateVPAControlledValues(t *testing.T) {
+	requestsAndLimits := vpa_types.ContainerControlledValuesRequestsAndLimits
+	invalid := vpa_types.ContainerControlledValues("LimitsOnly")
+	for _, tc := range []struct {
+		controlledValues *vpa_types.ContainerControlledValues
+		expectedError    bool
+	}{
+		{controlledValues: nil},
+		{controlledValues: &requestsAndLimits},
+		{controlledValues: &invalid, expectedError: true},
+	} {
+		vpa := vpa_types.VerticalPodAutoscaler{
+			Spec: vpa_types.VerticalPodAutoscalerSpec{
+				ResourcePolicy: &vpa_types.PodResourcePolicy{
+					ContainerPolicies: []vpa_types.ContainerResourcePolicy{{
+						ContainerName:    "container1",
+						ControlledValues: tc.controlledValues,
+					}},
+				},
+			},
+		}
+		err := validateVPA(&vpa)
+		if tc.expectedError {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestValidateVPAControlledResources(t *testing.T) {
+	for _, tc := range []struct {
+		controlledResources *[]apiv1.ResourceName
+		expectedError       bool
+	}{
+		{controlledResources: nil},
+		{controlledResources: &[]apiv1.ResourceName{apiv1.ResourceCPU, apiv1.ResourceEphemeralStorage}},
+		{controlledResources: &[]apiv1.ResourceName{apiv1.ResourceStorage}, expectedError: true},
+	} {
+		vpa := vpa_types.VerticalPodAutoscaler{
+			Spec: vpa_types.VerticalPodAutoscalerSpec{
+				ResourcePolicy: &vpa_types.PodResourcePolicy{
+					ContainerPolicies: []vpa_types.ContainerResourcePolicy{{
+						ContainerName:       "container1",
+						ControlledResources: tc.controlledResources,
+					}},
+				},
+			},
+		}
+		err := validateVPA(&vpa)
+		if tc.expectedError {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestGetContainerPatchesForInitContainer(t *testing.T) {
+	container := apiv1.Container{Name: "init"}
+	containerResources := ContainerResources{
+		Requests: apiv1.ResourceList{apiv1.ResourceEphemeralStorage: resource.MustParse("1Gi")},
+	}
+	annotations := map[string][]string{"init": {"ephemeral-storage capped to maxAllowed"}}
+
+	patches, description := getContainerPatches(container, "initContainers", 1, containerResources, annotations)
+	assert.Equal(t, []patchRecord{
+		{Op: "add", Path: "/spec/initContainers/1/resources", Value: apiv1.ResourceRequirements{}},
+		{Op: "add", Path: "/spec/initContainers/1/resources/requests", Value: apiv1.ResourceList{}},
+		{Op: "add", Path: "/spec/initContainers/1/resources/requests/ephemeral-storage", Value: "1Gi"},
+	}, patches)
+	assert.Equal(t, "ephemeral-storage capped to maxAllowed, ephemeral-storage request", description)
+}
+
+type fakeRecommendationProvider struct {
+	resources []ContainerResources
+	vpa       *vpa_types.VerticalPodAutoscaler
+}
+
+func (p *fakeRecommendationProvider) GetContainersResourcesForPod(pod *apiv1.Pod) ([]ContainerResources, []ContainerResources, vpa_api_util.ContainerToAnnotationsMap, *vpa_types.VerticalPodAutoscaler, error) {
+	return p.resources, nil, nil, p.vpa, nil
+}
+
+func TestGetPatchesForPodResourceRequestDryRun(t *testing.T) {
+	pod := test.Pod().WithName("pod1").AddContainer(test.BuildTestContainer("container1", "1", "100Mi")).Get()
+	raw, err := json.Marshal(pod)
+	assert.NoError(t, err)
+	resources := []ContainerResources{{
+		Requests: apiv1.ResourceList{
+			apiv1.ResourceCPU:    resource.MustParse("2"),
+			apiv1.ResourceMemory: resource.MustParse("100Mi"),
+		},
+		Limits: apiv1.ResourceList{},
+	}}
+	vpa := test.VerticalPodAutoscaler().WithName("vpa1").WithContainer("container1").Get()
+	dryRunVPA := test.VerticalPodAutoscaler().WithName("vpa1").WithContainer("container1").Get()
+	dryRunVPA.Annotations = map[string]string{vpa_api_util.DryRunAnnotation: "true"}
+	dryRunPatch := patchRecord{
+		Op:    "add",
+		Path:  "/metadata/annotations",
+		Value: map[string]string{dryRunUpdatesAnnotation: "Pod resources would be updated by vpa1: container container1: cpu request 1 -> 2"},
+	}
+
+	testCases := []struct {
+		name            string
+		dryRun          bool
+		vpa             *vpa_types.VerticalPodAutoscaler
+		resources       []ContainerResources
+		expectedDryRun  bool
+		expectedPatches []patchRecord
+	}{
+		{
+			name:      "applied",
+			vpa:       vpa,
+			resources: resources,
+		},
+		{
+			name:            "dry-run flag",
+			dryRun:          true,
+			vpa:             vpa,
+			resources:       resources,
+			expectedDryRun:  true,
+			expectedPatches: []patchRecord{dryRunPatch},
+		},
+		{
+			name:            "dry-run annotation",
+			vpa:             dryRunVPA,
+			resources:       resources,
+			expectedDryRun:  true,
+			expectedPatches: []patchRecord{dryRunPatch},
+		},
+		{
+			name:            "dry-run without recommendation",
+			dryRun:          true,
+			vpa:             vpa,
+			resources:       []ContainerResources{newContainerResources()},
+			expectedPatches: []patchRecord{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := &fakeRecommendationProvider{resources: tc.resources, vpa: tc.vpa}
+			server := NewAdmissionServer(provider, NewDefaultPodPreProcessor(), tc.dryRun, test.FakeEventRecorder())
+			patches, dryRun, err := server.getPatchesForPodResourceRequest(raw, "default")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedDryRun, dryRun)
+			if tc.expectedPatches != nil {
+				assert.Equal(t, tc.expectedPatches, patches)
+			} else {
+				assert.NotEmpty(t, patches)
+				for _, patch := range patches {
+					assert.NotEqual(t, "/metadata/annotations/"+dryRunUpdatesAnnotation, patch.Path)
+				}
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ package v1beta2
 import (
 	autoscaling "k8s.io/api/autoscaling/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -85,6 +86,45 @@ type VerticalPodAutoscalerSpec struct {
 	// resources for all containers in the pod, without additional constraints.
 	// +optional
 	ResourcePolicy *PodResourcePolicy `json:"resourcePolicy,omitempty" protobuf:"bytes,3,opt,name=resourcePolicy"`
+
+	// Configures the response-time controller used to compute the CPU
+	// recommendation for the controlled containers. Fields that are not set
+	// take their values from the recommender's command line flags.
+	// It can be overridden per container in ContainerResourcePolicy.
+	// +optional
+	ControllerPolicy *ControllerPolicy `json:"controllerPolicy,omitempty" protobuf:"bytes,4,opt,name=controllerPolicy"`
+
+	// Controls how the recommender aggregates the usage history of the
+	// containers into histograms, and which percentiles of the histograms
+	// are recommended. Fields that are not set take the recommender defaults.
+	// +optional
+	AggregationPolicy *AggregationPolicy `json:"aggregationPolicy,omitempty" protobuf:"bytes,5,opt,name=aggregationPolicy"`
+
+	// Controls how the histogram recommender computes the recommendation
+	// from the usage histograms. Fields that are not set take their values
+	// from the recommender's command line flags or defaults.
+	// +optional
+	RecommenderPolicy *RecommenderPolicy `json:"recommenderPolicy,omitempty" protobuf:"bytes,6,opt,name=recommenderPolicy"`
+
+	// Recommender responsible for generating recommendation for this object.
+	// List should be empty (then the default recommender will generate the
+	// recommendation) or contain exactly one recommender.
+	// +optional
+	Recommenders []*VerticalPodAutoscalerRecommenderSelector `json:"recommenders,omitempty" protobuf:"bytes,7,opt,name=recommenders"`
+
+	// Controls how much the memory recommendation of a container is raised
+	// after it is killed for running out of memory. Fields that are not set
+	// take the recommender defaults.
+	// +optional
+	OOMBumpUpPolicy *OOMBumpUpPolicy `json:"oomBumpUpPolicy,omitempty" protobuf:"bytes,8,opt,name=oomBumpUpPolicy"`
+}
+
+// VerticalPodAutoscalerRecommenderSelector points to a specific Vertical Pod
+// Autoscaler recommender, by the name it is started with.
+type VerticalPodAutoscalerRecommenderSelector struct {
+	// Name of the recommender responsible for generating recommendation for
+	// this object.
+	Name string `json:"name" protobuf:"bytes,1,opt,name=name"`
 }
 
 // PodUpdatePolicy describes the rules on how changes are applied to the pods.
@@ -93,6 +133,32 @@ type PodUpdatePolicy struct {
 	// The default is 'Auto'.
 	// +optional
 	UpdateMode *UpdateMode `json:"updateMode,omitempty" protobuf:"bytes,1,opt,name=updateMode"`
+	// Limits how often and how much the updater changes the pod resources.
+	// +optional
+	ActuationPolicy *ActuationPolicy `json:"actuationPolicy,omitempty" protobuf:"bytes,2,opt,name=actuationPolicy"`
+}
+
+// ActuationPolicy limits the updates of the pods, so that recommendations
+// changing at every recommender iteration do not cause constant disruptions.
+// All fields are optional, unset fields mean no limit unless stated otherwise.
+type ActuationPolicy struct {
+	// Minimum time between two updates of the same pod. The creation of the
+	// pod counts as an update.
+	// +optional
+	MinPodUpdateInterval *metav1.Duration `json:"minPodUpdateInterval,omitempty" protobuf:"bytes,1,opt,name=minPodUpdateInterval"`
+	// Minimum time between two updates of any pods of the VPA.
+	// +optional
+	MinVpaUpdateInterval *metav1.Duration `json:"minVpaUpdateInterval,omitempty" protobuf:"bytes,2,opt,name=minVpaUpdateInterval"`
+	// Minimum relative change between the requested and the recommended
+	// resources for a pod within the recommended range to be updated.
+	// The default is 0.1.
+	// +optional
+	MinRelativeChange *float64 `json:"minRelativeChange,omitempty" protobuf:"fixed64,3,opt,name=minRelativeChange"`
+	// Maximum number of pods of the VPA disrupted at the same time, i.e.
+	// evicted and not yet replaced by a running pod. In-place resizes are not
+	// disruptions.
+	// +optional
+	MaxConcurrentDisruptions *int32 `json:"maxConcurrentDisruptions,omitempty" protobuf:"varint,4,opt,name=maxConcurrentDisruptions"`
 }
 
 // UpdateMode controls when autoscaler applies changes to the pod resoures.
@@ -115,6 +181,11 @@ const (
 	// using any available update method. Currently this is equivalent to
 	// Recreate, which is the only available update method.
 	UpdateModeAuto UpdateMode = "Auto"
+	// UpdateModeInPlace means that autoscaler assigns resources on pod
+	// creation and additionally updates them during the lifetime of the pod
+	// by changing the resources of the running pod, without restarting it.
+	// If the change is rejected, the pod is evicted and recreated instead.
+	UpdateModeInPlace UpdateMode = "InPlace"
 )
 
 // PodResourcePolicy controls how autoscaler computes the recommended resources
@@ -147,6 +218,26 @@ type ContainerResourcePolicy struct {
 	// for the container. The default is no maximum.
 	// +optional
 	MaxAllowed v1.ResourceList `json:"maxAllowed,omitempty" protobuf:"bytes,4,rep,name=maxAllowed,casttype=ResourceList,castkey=ResourceName"`
+	// Configures the response-time controller for the container. Fields that
+	// are set take precedence over the VPA-wide ControllerPolicy.
+	// +optional
+	ControllerPolicy *ControllerPolicy `json:"controllerPolicy,omitempty" protobuf:"bytes,5,opt,name=controllerPolicy"`
+	// Selects the algorithm used to compute the recommendation for the
+	// container, by the name it is registered with in the recommender. The
+	// default is given by the recommender --recommender-algorithm flag
+	// ("histogram" unless changed). Unknown algorithms are replaced by the
+	// default.
+	// +optional
+	Recommender *ContainerRecommender `json:"recommender,omitempty" protobuf:"bytes,6,opt,name=recommender"`
+	// Specifies which resource values should be controlled.
+	// The default is "RequestsOnly".
+	// +optional
+	ControlledValues *ContainerControlledValues `json:"controlledValues,omitempty" protobuf:"bytes,7,opt,name=controlledValues"`
+	// Specifies the resources that are recommended and applied for the
+	// container. The default is [cpu, memory]; "ephemeral-storage" has to be
+	// listed to autoscale the ephemeral storage of the container.
+	// +optional
+	ControlledResources *[]v1.ResourceName `json:"controlledResources,omitempty" protobuf:"bytes,8,rep,name=controlledResources"`
 }
 
 const (
@@ -166,6 +257,253 @@ const (
 	ContainerScalingModeOff ContainerScalingMode = "Off"
 )
 
+// ContainerControlledValues controls which resource values are autoscaled.
+type ContainerControlledValues string
+
+const (
+	// ContainerControlledValuesRequestsOnly means only the requests are
+	// autoscaled. The recommendation is capped to the limits of the container.
+	ContainerControlledValuesRequestsOnly ContainerControlledValues = "RequestsOnly"
+	// ContainerControlledValuesRequestsAndLimits means that both the requests
+	// and the limits are autoscaled. The limits are scaled proportionally to
+	// the requests, preserving the original request/limit ratio.
+	ContainerControlledValuesRequestsAndLimits ContainerControlledValues = "RequestsAndLimits"
+)
+
+// ContainerRecommender selects the algorithm that computes the recommendation
+// for a specific container. Besides the built-in algorithms below, it can be
+// the name of any algorithm registered in the recommender.
+type ContainerRecommender string
+
+const (
+	// ContainerRecommenderHistogram means the recommendation is computed from
+	// percentiles of the historical resource usage.
+	ContainerRecommenderHistogram ContainerRecommender = "histogram"
+	// ContainerRecommenderResponseTimeController means the CPU recommendation
+	// is computed by the response-time controller configured by ControllerPolicy.
+	ContainerRecommenderResponseTimeController ContainerRecommender = "response-time-controller"
+)
+
+// ControllerPolicy configures the response-time controller, which computes the
+// CPU recommendation so that the response time of the workload tracks the SLA.
+// All fields are optional, unset fields are defaulted by the recommender.
+type ControllerPolicy struct {
+	// Response time, in seconds, that the controller keeps the workload at.
+	// +optional
+	SLA *float64 `json:"sla,omitempty" protobuf:"fixed64,1,opt,name=sla"`
+	// Pole of the closed loop, in the range [0, 1). Values closer to 1 make
+	// the controller more conservative, values closer to 0 more aggressive.
+	// +optional
+	ClosedLoopPole *float64 `json:"closedLoopPole,omitempty" protobuf:"fixed64,2,opt,name=closedLoopPole"`
+	// Nominal pole of the controlled system, in the range [0, 1).
+	// +optional
+	NominalPole *float64 `json:"nominalPole,omitempty" protobuf:"fixed64,3,opt,name=nominalPole"`
+	// Nominal coefficients of the model relating the response time to the
+	// request rate and the allocated cores.
+	// +optional
+	NominalModel *ControllerModel `json:"nominalModel,omitempty" protobuf:"bytes,4,opt,name=nominalModel"`
+	// Maximum amount of CPU the controller recommends.
+	// +optional
+	MaxCPU *resource.Quantity `json:"maxCPU,omitempty" protobuf:"bytes,5,opt,name=maxCPU"`
+	// Amount of memory recommended for the controlled containers.
+	// +optional
+	Memory *resource.Quantity `json:"memory,omitempty" protobuf:"bytes,6,opt,name=memory"`
+	// Describes where the controller reads the response time and the request
+	// count from in the custom metrics API.
+	// +optional
+	MetricSource *ControllerMetricSource `json:"metricSource,omitempty" protobuf:"bytes,7,opt,name=metricSource"`
+	// Sampling period the poles and the nominal model are tuned for. The
+	// poles are adjusted to the actual period between controller iterations
+	// and the request rate is expressed as requests per sampling period.
+	// +optional
+	SamplingPeriod *metav1.Duration `json:"samplingPeriod,omitempty" protobuf:"bytes,8,opt,name=samplingPeriod"`
+}
+
+// ControllerMetricSource describes the objects and metrics in the custom
+// metrics API (custom.metrics.k8s.io) that the response-time controller uses
+// as its inputs. Fields that are not set are defaulted by the recommender.
+type ControllerMetricSource struct {
+	// Namespace of the described objects.
+	// +optional
+	Namespace string `json:"namespace,omitempty" protobuf:"bytes,1,opt,name=namespace"`
+	// Kind of the described objects. The default is "Pod".
+	// +optional
+	ObjectKind ControllerMetricObjectKind `json:"objectKind,omitempty" protobuf:"bytes,2,opt,name=objectKind"`
+	// Name of the described object. If not set, the metrics of all objects
+	// of the kind matching the selector are used.
+	// +optional
+	ObjectName string `json:"objectName,omitempty" protobuf:"bytes,3,opt,name=objectName"`
+	// Selector of the described objects. Can be used only if ObjectName is not set.
+	// If neither is set, pods in the VPA namespace are selected by the VPA
+	// selector. The values of all described objects are aggregated: response
+	// times are averaged weighted by request counts and request counts are summed.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty" protobuf:"bytes,4,opt,name=selector"`
+	// Name of the metric holding the response time, in seconds.
+	// +optional
+	ResponseTimeMetric string `json:"responseTimeMetric,omitempty" protobuf:"bytes,5,opt,name=responseTimeMetric"`
+	// Name of the metric holding the cumulative count of served requests.
+	// +optional
+	RequestCountMetric string `json:"requestCountMetric,omitempty" protobuf:"bytes,6,opt,name=requestCountMetric"`
+}
+
+// ControllerMetricObjectKind is the kind of objects described by the custom
+// metrics used by the response-time controller.
+type ControllerMetricObjectKind string
+
+const (
+	// ControllerMetricObjectKindPod means the metrics describe pods.
+	ControllerMetricObjectKindPod ControllerMetricObjectKind = "Pod"
+	// ControllerMetricObjectKindService means the metrics describe services.
+	ControllerMetricObjectKindService ControllerMetricObjectKind = "Service"
+	// ControllerMetricObjectKindIngress means the metrics describe ingresses.
+	ControllerMetricObjectKindIngress ControllerMetricObjectKind = "Ingress"
+)
+
+// ControllerModel holds the coefficients of the model used by the
+// response-time controller. For a request rate req and c allocated cores
+// the model predicts the response time
+// rt = a1 + 1000 * a2 * req / (req + 1000 * a3 * c).
+type ControllerModel struct {
+	// +optional
+	A1 *float64 `json:"a1,omitempty" protobuf:"fixed64,1,opt,name=a1"`
+	// +optional
+	A2 *float64 `json:"a2,omitempty" protobuf:"fixed64,2,opt,name=a2"`
+	// +optional
+	A3 *float64 `json:"a3,omitempty" protobuf:"fixed64,3,opt,name=a3"`
+}
+
+// AggregationPolicy configures the histograms the recommender aggregates the
+// usage samples of the containers in. All fields are optional, unset fields
+// are defaulted by the recommender.
+// Changing the histogram parameters of a VPA with usage history migrates the
+// history to the new parameters: samples keep their decayed weights and decay
+// with the new half-life from then on. If the bucket size growth or the
+// histogram backend changes, the history can't be migrated and is discarded.
+// The usage of a container matched by several VPAs with different policies is
+// aggregated with the policy of the oldest VPA. The other VPAs leave it out of
+// their recommendations and report the AggregationPolicyConflict condition.
+type AggregationPolicy struct {
+	// Length of the usage history kept. Usage aggregations without samples
+	// for this long are dropped. The default is 8 days.
+	// +optional
+	HistoryLength *metav1.Duration `json:"historyLength,omitempty" protobuf:"bytes,1,opt,name=historyLength"`
+	// Time it takes a CPU usage sample to lose half of its weight. The
+	// default is 24 hours.
+	// +optional
+	CPUHistogramDecayHalfLife *metav1.Duration `json:"cpuHistogramDecayHalfLife,omitempty" protobuf:"bytes,2,opt,name=cpuHistogramDecayHalfLife"`
+	// Time it takes a memory or ephemeral storage peak to lose half of its
+	// weight. The default is 24 hours.
+	// +optional
+	MemoryHistogramDecayHalfLife *metav1.Duration `json:"memoryHistogramDecayHalfLife,omitempty" protobuf:"bytes,3,opt,name=memoryHistogramDecayHalfLife"`
+	// Fraction by which each histogram bucket is wider than the previous one.
+	// Smaller values make the recommendation more precise at the cost of
+	// memory. The default is 0.05.
+	// +optional
+	HistogramBucketSizeGrowth *float64 `json:"histogramBucketSizeGrowth,omitempty" protobuf:"fixed64,4,opt,name=histogramBucketSizeGrowth"`
+	// Percentiles of the CPU usage histogram recommended. Deprecated: use
+	// the CPUPercentiles of the RecommenderPolicy, which take precedence.
+	// +optional
+	CPUPercentiles *RecommendationPercentiles `json:"cpuPercentiles,omitempty" protobuf:"bytes,5,opt,name=cpuPercentiles"`
+	// Percentiles of the memory peaks histogram recommended. Deprecated: use
+	// the MemoryPercentiles of the RecommenderPolicy, which take precedence.
+	// +optional
+	MemoryPercentiles *RecommendationPercentiles `json:"memoryPercentiles,omitempty" protobuf:"bytes,6,opt,name=memoryPercentiles"`
+	// Histogram implementation the usage samples are aggregated in. The
+	// default is "decaying". Changing it discards the usage history.
+	// +optional
+	HistogramBackend *HistogramBackend `json:"histogramBackend,omitempty" protobuf:"bytes,7,opt,name=histogramBackend"`
+}
+
+// HistogramBackend selects the histogram implementation the recommender
+// aggregates the usage samples in. Besides the built-in backends below, it
+// can be the name of any backend registered in the recommender.
+type HistogramBackend string
+
+const (
+	// HistogramBackendDecaying means the samples lose half of their weight
+	// with every decay half-life.
+	HistogramBackendDecaying HistogramBackend = "decaying"
+	// HistogramBackendNonDecaying means the samples keep their weight for the
+	// whole history length. The decay half-lives are ignored.
+	HistogramBackendNonDecaying HistogramBackend = "non-decaying"
+)
+
+// RecommenderPolicy configures the histogram recommender. The target is a
+// percentile of the usage histogram with a safety margin added. The bounds
+// are percentiles with the margin, multiplied by a confidence multiplier
+// depending on the length of the usage history. All fields are optional.
+type RecommenderPolicy struct {
+	// Fraction of the usage added as the safety margin to the target and the
+	// bounds. The default is the recommendation-margin-fraction flag.
+	// +optional
+	SafetyMarginFraction *float64 `json:"safetyMarginFraction,omitempty" protobuf:"fixed64,1,opt,name=safetyMarginFraction"`
+	// Confidence multiplier of the upper bound. The default multiplier is 1
+	// with exponent 1: the upper bound is multiplied by
+	// (1 + 1/history-length-in-days).
+	// +optional
+	UpperBoundConfidence *ConfidenceMultiplier `json:"upperBoundConfidence,omitempty" protobuf:"bytes,2,opt,name=upperBoundConfidence"`
+	// Confidence multiplier of the lower bound. The default multiplier is
+	// 0.001 with exponent -2.
+	// +optional
+	LowerBoundConfidence *ConfidenceMultiplier `json:"lowerBoundConfidence,omitempty" protobuf:"bytes,3,opt,name=lowerBoundConfidence"`
+	// Minimum resources recommended for the pod, split evenly among its
+	// regular containers. Init containers run one at a time before them, so
+	// each of them gets the whole minimum. Only CPU and memory are supported. The defaults are the
+	// pod-recommendation-min-cpu-millicores and pod-recommendation-min-memory-mb
+	// flags.
+	// +optional
+	PodMinResources v1.ResourceList `json:"podMinResources,omitempty" protobuf:"bytes,4,rep,name=podMinResources,casttype=ResourceList,castkey=ResourceName"`
+	// Percentiles of the CPU usage histogram recommended. The defaults are
+	// 0.9 for the target, 0.5 for the lower bound and 0.95 for the upper
+	// bound.
+	// +optional
+	CPUPercentiles *RecommendationPercentiles `json:"cpuPercentiles,omitempty" protobuf:"bytes,5,opt,name=cpuPercentiles"`
+	// Percentiles of the memory peaks histogram recommended. They also apply
+	// to ephemeral storage. The defaults are the same as for CPU.
+	// +optional
+	MemoryPercentiles *RecommendationPercentiles `json:"memoryPercentiles,omitempty" protobuf:"bytes,6,opt,name=memoryPercentiles"`
+}
+
+// ConfidenceMultiplier scales a recommendation bound by
+// (1 + multiplier/history-length-in-days)^exponent, so that the bound is
+// wider while the usage history is short.
+type ConfidenceMultiplier struct {
+	// +optional
+	Multiplier *float64 `json:"multiplier,omitempty" protobuf:"fixed64,1,opt,name=multiplier"`
+	// +optional
+	Exponent *float64 `json:"exponent,omitempty" protobuf:"fixed64,2,opt,name=exponent"`
+}
+
+// RecommendationPercentiles selects the percentiles of a usage histogram
+// recommended as the target, the lower bound and the upper bound. The
+// percentiles are in the range (0, 1].
+type RecommendationPercentiles struct {
+	// The default is 0.9.
+	// +optional
+	Target *float64 `json:"target,omitempty" protobuf:"fixed64,1,opt,name=target"`
+	// The default is 0.5.
+	// +optional
+	LowerBound *float64 `json:"lowerBound,omitempty" protobuf:"fixed64,2,opt,name=lowerBound"`
+	// The default is 0.95.
+	// +optional
+	UpperBound *float64 `json:"upperBound,omitempty" protobuf:"fixed64,3,opt,name=upperBound"`
+}
+
+// OOMBumpUpPolicy configures the memory sample recorded when a container is
+// OOM killed. The sample is the larger of the memory used multiplied by the
+// ratio and the memory used plus the minimum bump up, where the memory used is
+// the larger of the memory request and the recent memory peak of the container.
+type OOMBumpUpPolicy struct {
+	// Ratio by which the memory used is multiplied. Must be at least 1.
+	// The default is 1.2.
+	// +optional
+	Ratio *float64 `json:"ratio,omitempty" protobuf:"fixed64,1,opt,name=ratio"`
+	// Minimum memory added to the memory used. The default is 100Mi.
+	// +optional
+	MinBumpUp *resource.Quantity `json:"minBumpUp,omitempty" protobuf:"bytes,2,opt,name=minBumpUp"`
+}
+
 // VerticalPodAutoscalerStatus describes the runtime state of the autoscaler.
 type VerticalPodAutoscalerStatus struct {
 	// The most recently computed amount of resources recommended by the
@@ -179,6 +517,58 @@ type VerticalPodAutoscalerStatus struct {
 	// +patchMergeKey=type
 	// +patchStrategy=merge
 	Conditions []VerticalPodAutoscalerCondition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,2,rep,name=conditions"`
+
+	// RecommendationHistory lists the past recommendations, oldest first,
+	// with the inputs that explain them. An entry is added whenever the
+	// target or the algorithm of some container changes. Only kept if the
+	// recommender is run with a positive --recommendation-history-size,
+	// which bounds the number of entries.
+	// +optional
+	RecommendationHistory []RecommendationHistoryEntry `json:"recommendationHistory,omitempty" protobuf:"bytes,3,rep,name=recommendationHistory"`
+}
+
+// RecommendationHistoryEntry is a recommendation computed by the autoscaler
+// in the past.
+type RecommendationHistoryEntry struct {
+	// Time the recommendation was computed.
+	Time metav1.Time `json:"time" protobuf:"bytes,1,opt,name=time"`
+	// Recommendation and its explanation for each container.
+	// +optional
+	ContainerRecommendations []RecommendationExplanation `json:"containerRecommendations,omitempty" protobuf:"bytes,2,rep,name=containerRecommendations"`
+}
+
+// RecommendationExplanation is the recommended target of a container,
+// together with the inputs of the algorithm that produced it.
+type RecommendationExplanation struct {
+	// Name of the container.
+	ContainerName string `json:"containerName,omitempty" protobuf:"bytes,1,opt,name=containerName"`
+	// Recommended amount of resources. Observes ContainerResourcePolicy.
+	Target v1.ResourceList `json:"target" protobuf:"bytes,2,rep,name=target,casttype=ResourceList,castkey=ResourceName"`
+	// Algorithm that produced the recommendation.
+	// +optional
+	Recommender ContainerRecommender `json:"recommender,omitempty" protobuf:"bytes,3,opt,name=recommender"`
+	// Number of CPU usage samples aggregated for the container.
+	// +optional
+	TotalSamplesCount int `json:"totalSamplesCount,omitempty" protobuf:"varint,4,opt,name=totalSamplesCount"`
+	// Percentile of the CPU usage histogram used as the target, if the
+	// recommendation was computed by the histogram recommender.
+	// +optional
+	TargetCPUPercentile *float64 `json:"targetCPUPercentile,omitempty" protobuf:"fixed64,5,opt,name=targetCPUPercentile"`
+	// Percentile of the memory peaks histogram used as the target, if the
+	// recommendation was computed by the histogram recommender.
+	// +optional
+	TargetMemoryPercentile *float64 `json:"targetMemoryPercentile,omitempty" protobuf:"fixed64,6,opt,name=targetMemoryPercentile"`
+	// Number of OOMs of the container that bumped up the memory
+	// recommendation since the recommender started.
+	// +optional
+	OOMCount int `json:"oomCount,omitempty" protobuf:"varint,7,opt,name=oomCount"`
+	// Time of the last OOM of the container.
+	// +optional
+	LastOOMTime *metav1.Time `json:"lastOOMTime,omitempty" protobuf:"bytes,8,opt,name=lastOOMTime"`
+	// Difference between the SLA and the observed response time, in seconds,
+	// if the recommendation was computed by the response-time controller.
+	// +optional
+	ControllerError *float64 `json:"controllerError,omitempty" protobuf:"fixed64,9,opt,name=controllerError"`
 }
 
 // RecommendedPodResources is the recommendation of resources computed by
@@ -240,6 +630,21 @@ var (
 	// ConfigUnsupported indicates that this VPA configuration is unsupported
 	// and recommendations will not be provided for it.
 	ConfigUnsupported VerticalPodAutoscalerConditionType = "ConfigUnsupported"
+	// RecommenderSelected indicates which recommendation algorithm produced
+	// the recommendation of each container. The message lists the algorithm
+	// used per container.
+	RecommenderSelected VerticalPodAutoscalerConditionType = "RecommenderSelected"
+	// CustomMetricsUnavailable indicates that the response-time controller could
+	// not use its custom metrics for some of containers, because they are
+	// missing, stale or malformed. The message lists the affected containers
+	// and whether their last recommendation was held or replaced by the
+	// histogram recommendation.
+	CustomMetricsUnavailable VerticalPodAutoscalerConditionType = "CustomMetricsUnavailable"
+	// AggregationPolicyConflict indicates that some of the containers are
+	// also matched by an older VPA with a different aggregation policy. Their
+	// usage is aggregated with the policy of the other VPA and left out of
+	// the recommendation. The message lists the affected containers.
+	AggregationPolicyConflict VerticalPodAutoscalerConditionType = "AggregationPolicyConflict"
 )
 
 // VerticalPodAutoscalerCondition describes the state of
@@ -324,6 +729,74 @@ type VerticalPodAutoscalerCheckpointStatus struct {
 
 	// Total number of samples in the histograms.
 	TotalSamplesCount int `json:"totalSamplesCount,omitempty" protobuf:"bytes,7,opt,name=totalSamplesCount"`
+
+	// Checkpoint of the response-time controller state. Only present for
+	// containers whose recommendation is computed by the controller.
+	// +optional
+	ControllerState *ControllerStateCheckpoint `json:"controllerState,omitempty" protobuf:"bytes,8,opt,name=controllerState"`
+
+	// Checkpoint of histogram for consumption of ephemeral storage.
+	// +optional
+	EphemeralStorageHistogram HistogramCheckpoint `json:"ephemeralStorageHistogram,omitempty" protobuf:"bytes,9,opt,name=ephemeralStorageHistogram"`
+
+	// Parameters the histograms were built with. Not set in checkpoints
+	// of histograms built with the default parameters before they were
+	// recorded.
+	// +optional
+	HistogramParameters *HistogramParametersCheckpoint `json:"histogramParameters,omitempty" protobuf:"bytes,10,opt,name=histogramParameters"`
+}
+
+// HistogramParametersCheckpoint contains the parameters of the histograms of
+// a checkpoint, needed to load them.
+type HistogramParametersCheckpoint struct {
+	// Growth of the histogram bucket sizes.
+	BucketSizeGrowth float64 `json:"bucketSizeGrowth,omitempty" protobuf:"fixed64,1,opt,name=bucketSizeGrowth"`
+
+	// Half-life of the samples of the CPU histogram.
+	CPUDecayHalfLife metav1.Duration `json:"cpuDecayHalfLife,omitempty" protobuf:"bytes,2,opt,name=cpuDecayHalfLife"`
+
+	// Half-life of the samples of the memory and ephemeral storage histograms.
+	MemoryDecayHalfLife metav1.Duration `json:"memoryDecayHalfLife,omitempty" protobuf:"bytes,3,opt,name=memoryDecayHalfLife"`
+
+	// Histogram backend the histograms were built with. Not set in
+	// checkpoints of decaying histograms written before the backend was
+	// recorded.
+	// +optional
+	Backend HistogramBackend `json:"backend,omitempty" protobuf:"bytes,4,opt,name=backend,casttype=HistogramBackend"`
+}
+
+// ControllerStateCheckpoint contains data needed to resume the response-time
+// controller of a container.
+type ControllerStateCheckpoint struct {
+	// The time when the controller state was last updated.
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty" protobuf:"bytes,1,opt,name=lastUpdateTime"`
+
+	// Integral term of the controller.
+	IntegralTerm float64 `json:"integralTerm,omitempty" protobuf:"fixed64,2,opt,name=integralTerm"`
+
+	// Total of the request counters at the last update.
+	// Deprecated: replaced by RequestCounters. Only read from checkpoints
+	// written before the counters were kept per object.
+	// +optional
+	RequestCount float64 `json:"requestCount,omitempty" protobuf:"fixed64,3,opt,name=requestCount"`
+
+	// Last samples of the request counters of the objects described by the
+	// controller custom metrics, sorted by object.
+	// +optional
+	RequestCounters []RequestCounterSample `json:"requestCounters,omitempty" protobuf:"bytes,4,rep,name=requestCounters"`
+}
+
+// RequestCounterSample is a sample of the request counter of a single object
+// described by the controller custom metrics.
+type RequestCounterSample struct {
+	// Namespace and name of the described object, as "namespace/name".
+	Object string `json:"object" protobuf:"bytes,1,opt,name=object"`
+
+	// Value of the counter.
+	Value float64 `json:"value" protobuf:"fixed64,2,opt,name=value"`
+
+	// Time of the sample.
+	Timestamp metav1.Time `json:"timestamp,omitempty" protobuf:"bytes,3,opt,name=timestamp"`
 }
 
 // HistogramCheckpoint contains data needed to reconstruct the histogram.
@@ -23,9 +23,123 @@ package v1beta2
 import (
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ActuationPolicy) DeepCopyInto(out *ActuationPolicy) {
+	*out = *in
+	if in.MinPodUpdateInterval != nil {
+		in, out := &in.MinPodUpdateInterval, &out.MinPodUpdateInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.MinVpaUpdateInterval != nil {
+		in, out := &in.MinVpaUpdateInterval, &out.MinVpaUpdateInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.MinRelativeChange != nil {
+		in, out := &in.MinRelativeChange, &out.MinRelativeChange
+		*out = new(float64)
+		**out = **in
+	}
+	if in.MaxConcurrentDisruptions != nil {
+		in, out := &in.MaxConcurrentDisruptions, &out.MaxConcurrentDisruptions
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ActuationPolicy.
+func (in *ActuationPolicy) DeepCopy() *ActuationPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ActuationPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AggregationPolicy) DeepCopyInto(out *AggregationPolicy) {
+	*out = *in
+	if in.HistoryLength != nil {
+		in, out := &in.HistoryLength, &out.HistoryLength
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.CPUHistogramDecayHalfLife != nil {
+		in, out := &in.CPUHistogramDecayHalfLife, &out.CPUHistogramDecayHalfLife
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.MemoryHistogramDecayHalfLife != nil {
+		in, out := &in.MemoryHistogramDecayHalfLife, &out.MemoryHistogramDecayHalfLife
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.HistogramBucketSizeGrowth != nil {
+		in, out := &in.HistogramBucketSizeGrowth, &out.HistogramBucketSizeGrowth
+		*out = new(float64)
+		**out = **in
+	}
+	if in.CPUPercentiles != nil {
+		in, out := &in.CPUPercentiles, &out.CPUPercentiles
+		*out = new(RecommendationPercentiles)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MemoryPercentiles != nil {
+		in, out := &in.MemoryPercentiles, &out.MemoryPercentiles
+		*out = new(RecommendationPercentiles)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HistogramBackend != nil {
+		in, out := &in.HistogramBackend, &out.HistogramBackend
+		*out = new(HistogramBackend)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AggregationPolicy.
+func (in *AggregationPolicy) DeepCopy() *AggregationPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(AggregationPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfidenceMultiplier) DeepCopyInto(out *ConfidenceMultiplier) {
+	*out = *in
+	if in.Multiplier != nil {
+		in, out := &in.Multiplier, &out.Multiplier
+		*out = new(float64)
+		**out = **in
+	}
+	if in.Exponent != nil {
+		in, out := &in.Exponent, &out.Exponent
+		*out = new(float64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfidenceMultiplier.
+func (in *ConfidenceMultiplier) DeepCopy() *ConfidenceMultiplier {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfidenceMultiplier)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ContainerResourcePolicy) DeepCopyInto(out *ContainerResourcePolicy) {
 	*out = *in
@@ -48,6 +162,30 @@ func (in *ContainerResourcePolicy) DeepCopyInto(out *ContainerResourcePolicy) {
 			(*out)[key] = val.DeepCopy()
 		}
 	}
+	if in.ControllerPolicy != nil {
+		in, out := &in.ControllerPolicy, &out.ControllerPolicy
+		*out = new(ControllerPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Recommender != nil {
+		in, out := &in.Recommender, &out.Recommender
+		*out = new(ContainerRecommender)
+		**out = **in
+	}
+	if in.ControlledValues != nil {
+		in, out := &in.ControlledValues, &out.ControlledValues
+		*out = new(ContainerControlledValues)
+		**out = **in
+	}
+	if in.ControlledResources != nil {
+		in, out := &in.ControlledResources, &out.ControlledResources
+		*out = new([]v1.ResourceName)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]v1.ResourceName, len(*in))
+			copy(*out, *in)
+		}
+	}
 	return
 }
 
@@ -61,6 +199,138 @@ func (in *ContainerResourcePolicy) DeepCopy() *ContainerResourcePolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerMetricSource) DeepCopyInto(out *ControllerMetricSource) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerMetricSource.
+func (in *ControllerMetricSource) DeepCopy() *ControllerMetricSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerMetricSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerModel) DeepCopyInto(out *ControllerModel) {
+	*out = *in
+	if in.A1 != nil {
+		in, out := &in.A1, &out.A1
+		*out = new(float64)
+		**out = **in
+	}
+	if in.A2 != nil {
+		in, out := &in.A2, &out.A2
+		*out = new(float64)
+		**out = **in
+	}
+	if in.A3 != nil {
+		in, out := &in.A3, &out.A3
+		*out = new(float64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerModel.
+func (in *ControllerModel) DeepCopy() *ControllerModel {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerModel)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerPolicy) DeepCopyInto(out *ControllerPolicy) {
+	*out = *in
+	if in.SLA != nil {
+		in, out := &in.SLA, &out.SLA
+		*out = new(float64)
+		**out = **in
+	}
+	if in.ClosedLoopPole != nil {
+		in, out := &in.ClosedLoopPole, &out.ClosedLoopPole
+		*out = new(float64)
+		**out = **in
+	}
+	if in.NominalPole != nil {
+		in, out := &in.NominalPole, &out.NominalPole
+		*out = new(float64)
+		**out = **in
+	}
+	if in.NominalModel != nil {
+		in, out := &in.NominalModel, &out.NominalModel
+		*out = new(ControllerModel)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxCPU != nil {
+		in, out := &in.MaxCPU, &out.MaxCPU
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Memory != nil {
+		in, out := &in.Memory, &out.Memory
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.MetricSource != nil {
+		in, out := &in.MetricSource, &out.MetricSource
+		*out = new(ControllerMetricSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SamplingPeriod != nil {
+		in, out := &in.SamplingPeriod, &out.SamplingPeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerPolicy.
+func (in *ControllerPolicy) DeepCopy() *ControllerPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerStateCheckpoint) DeepCopyInto(out *ControllerStateCheckpoint) {
+	*out = *in
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+	if in.RequestCounters != nil {
+		in, out := &in.RequestCounters, &out.RequestCounters
+		*out = make([]RequestCounterSample, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerStateCheckpoint.
+func (in *ControllerStateCheckpoint) DeepCopy() *ControllerStateCheckpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerStateCheckpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HistogramCheckpoint) DeepCopyInto(out *HistogramCheckpoint) {
 	*out = *in
@@ -85,6 +355,50 @@ func (in *HistogramCheckpoint) DeepCopy() *HistogramCheckpoint {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HistogramParametersCheckpoint) DeepCopyInto(out *HistogramParametersCheckpoint) {
+	*out = *in
+	out.CPUDecayHalfLife = in.CPUDecayHalfLife
+	out.MemoryDecayHalfLife = in.MemoryDecayHalfLife
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HistogramParametersCheckpoint.
+func (in *HistogramParametersCheckpoint) DeepCopy() *HistogramParametersCheckpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(HistogramParametersCheckpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OOMBumpUpPolicy) DeepCopyInto(out *OOMBumpUpPolicy) {
+	*out = *in
+	if in.Ratio != nil {
+		in, out := &in.Ratio, &out.Ratio
+		*out = new(float64)
+		**out = **in
+	}
+	if in.MinBumpUp != nil {
+		in, out := &in.MinBumpUp, &out.MinBumpUp
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OOMBumpUpPolicy.
+func (in *OOMBumpUpPolicy) DeepCopy() *OOMBumpUpPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(OOMBumpUpPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodResourcePolicy) DeepCopyInto(out *PodResourcePolicy) {
 	*out = *in
@@ -116,6 +430,11 @@ func (in *PodUpdatePolicy) DeepCopyInto(out *PodUpdatePolicy) {
 		*out = new(UpdateMode)
 		**out = **in
 	}
+	if in.ActuationPolicy != nil {
+		in, out := &in.ActuationPolicy, &out.ActuationPolicy
+		*out = new(ActuationPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -129,6 +448,103 @@ func (in *PodUpdatePolicy) DeepCopy() *PodUpdatePolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecommendationExplanation) DeepCopyInto(out *RecommendationExplanation) {
+	*out = *in
+	if in.Target != nil {
+		in, out := &in.Target, &out.Target
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.TargetCPUPercentile != nil {
+		in, out := &in.TargetCPUPercentile, &out.TargetCPUPercentile
+		*out = new(float64)
+		**out = **in
+	}
+	if in.TargetMemoryPercentile != nil {
+		in, out := &in.TargetMemoryPercentile, &out.TargetMemoryPercentile
+		*out = new(float64)
+		**out = **in
+	}
+	if in.LastOOMTime != nil {
+		in, out := &in.LastOOMTime, &out.LastOOMTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ControllerError != nil {
+		in, out := &in.ControllerError, &out.ControllerError
+		*out = new(float64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecommendationExplanation.
+func (in *RecommendationExplanation) DeepCopy() *RecommendationExplanation {
+	if in == nil {
+		return nil
+	}
+	out := new(RecommendationExplanation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecommendationHistoryEntry) DeepCopyInto(out *RecommendationHistoryEntry) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.ContainerRecommendations != nil {
+		in, out := &in.ContainerRecommendations, &out.ContainerRecommendations
+		*out = make([]RecommendationExplanation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecommendationHistoryEntry.
+func (in *RecommendationHistoryEntry) DeepCopy() *RecommendationHistoryEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(RecommendationHistoryEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecommendationPercentiles) DeepCopyInto(out *RecommendationPercentiles) {
+	*out = *in
+	if in.Target != nil {
+		in, out := &in.Target, &out.Target
+		*out = new(float64)
+		**out = **in
+	}
+	if in.LowerBound != nil {
+		in, out := &in.LowerBound, &out.LowerBound
+		*out = new(float64)
+		**out = **in
+	}
+	if in.UpperBound != nil {
+		in, out := &in.UpperBound, &out.UpperBound
+		*out = new(float64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecommendationPercentiles.
+func (in *RecommendationPercentiles) DeepCopy() *RecommendationPercentiles {
+	if in == nil {
+		return nil
+	}
+	out := new(RecommendationPercentiles)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RecommendedContainerResources) DeepCopyInto(out *RecommendedContainerResources) {
 	*out = *in
@@ -196,6 +612,71 @@ func (in *RecommendedPodResources) DeepCopy() *RecommendedPodResources {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecommenderPolicy) DeepCopyInto(out *RecommenderPolicy) {
+	*out = *in
+	if in.SafetyMarginFraction != nil {
+		in, out := &in.SafetyMarginFraction, &out.SafetyMarginFraction
+		*out = new(float64)
+		**out = **in
+	}
+	if in.UpperBoundConfidence != nil {
+		in, out := &in.UpperBoundConfidence, &out.UpperBoundConfidence
+		*out = new(ConfidenceMultiplier)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LowerBoundConfidence != nil {
+		in, out := &in.LowerBoundConfidence, &out.LowerBoundConfidence
+		*out = new(ConfidenceMultiplier)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodMinResources != nil {
+		in, out := &in.PodMinResources, &out.PodMinResources
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.CPUPercentiles != nil {
+		in, out := &in.CPUPercentiles, &out.CPUPercentiles
+		*out = new(RecommendationPercentiles)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MemoryPercentiles != nil {
+		in, out := &in.MemoryPercentiles, &out.MemoryPercentiles
+		*out = new(RecommendationPercentiles)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecommenderPolicy.
+func (in *RecommenderPolicy) DeepCopy() *RecommenderPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RecommenderPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequestCounterSample) DeepCopyInto(out *RequestCounterSample) {
+	*out = *in
+	in.Timestamp.DeepCopyInto(&out.Timestamp)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestCounterSample.
+func (in *RequestCounterSample) DeepCopy() *RequestCounterSample {
+	if in == nil {
+		return nil
+	}
+	out := new(RequestCounterSample)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VerticalPodAutoscaler) DeepCopyInto(out *VerticalPodAutoscaler) {
 	*out = *in
@@ -309,6 +790,17 @@ func (in *VerticalPodAutoscalerCheckpointStatus) DeepCopyInto(out *VerticalPodAu
 	in.MemoryHistogram.DeepCopyInto(&out.MemoryHistogram)
 	in.FirstSampleStart.DeepCopyInto(&out.FirstSampleStart)
 	in.LastSampleStart.DeepCopyInto(&out.LastSampleStart)
+	if in.ControllerState != nil {
+		in, out := &in.ControllerState, &out.ControllerState
+		*out = new(ControllerStateCheckpoint)
+		(*in).DeepCopyInto(*out)
+	}
+	in.EphemeralStorageHistogram.DeepCopyInto(&out.EphemeralStorageHistogram)
+	if in.HistogramParameters != nil {
+		in, out := &in.HistogramParameters, &out.HistogramParameters
+		*out = new(HistogramParametersCheckpoint)
+		**out = **in
+	}
 	return
 }
 
@@ -372,6 +864,22 @@ func (in *VerticalPodAutoscalerList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VerticalPodAutoscalerRecommenderSelector) DeepCopyInto(out *VerticalPodAutoscalerRecommenderSelector) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VerticalPodAutoscalerRecommenderSelector.
+func (in *VerticalPodAutoscalerRecommenderSelector) DeepCopy() *VerticalPodAutoscalerRecommenderSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(VerticalPodAutoscalerRecommenderSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VerticalPodAutoscalerSpec) DeepCopyInto(out *VerticalPodAutoscalerSpec) {
 	*out = *in
@@ -390,6 +898,37 @@ func (in *VerticalPodAutoscalerSpec) DeepCopyInto(out *VerticalPodAutoscalerSpec
 		*out = new(PodResourcePolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ControllerPolicy != nil {
+		in, out := &in.ControllerPolicy, &out.ControllerPolicy
+		*out = new(ControllerPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AggregationPolicy != nil {
+		in, out := &in.AggregationPolicy, &out.AggregationPolicy
+		*out = new(AggregationPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RecommenderPolicy != nil {
+		in, out := &in.RecommenderPolicy, &out.RecommenderPolicy
+		*out = new(RecommenderPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Recommenders != nil {
+		in, out := &in.Recommenders, &out.Recommenders
+		*out = make([]*VerticalPodAutoscalerRecommenderSelector, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(VerticalPodAutoscalerRecommenderSelector)
+				**out = **in
+			}
+		}
+	}
+	if in.OOMBumpUpPolicy != nil {
+		in, out := &in.OOMBumpUpPolicy, &out.OOMBumpUpPolicy
+		*out = new(OOMBumpUpPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -418,6 +957,13 @@ func (in *VerticalPodAutoscalerStatus) DeepCopyInto(out *VerticalPodAutoscalerSt
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RecommendationHistory != nil {
+		in, out := &in.RecommendationHistory, &out.RecommendationHistory
+		*out = make([]RecommendationHistoryEntry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -25,9 +25,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
-	vpa_api "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/typed/autoscaling.k8s.io/v1beta2"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
-	api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
 	"k8s.io/klog"
 )
 
@@ -40,15 +38,15 @@ type CheckpointWriter interface {
 }
 
 type checkpointWriter struct {
-	vpaCheckpointClient vpa_api.VerticalPodAutoscalerCheckpointsGetter
-	cluster             *model.ClusterState
+	checkpointStore CheckpointStore
+	cluster         *model.ClusterState
 }
 
 // NewCheckpointWriter returns new instance of a CheckpointWriter
-func NewCheckpointWriter(cluster *model.ClusterState, vpaCheckpointClient vpa_api.VerticalPodAutoscalerCheckpointsGetter) CheckpointWriter {
+func NewCheckpointWriter(cluster *model.ClusterState, checkpointStore CheckpointStore) CheckpointWriter {
 	return &checkpointWriter{
-		vpaCheckpointClient: vpaCheckpointClient,
-		cluster:             cluster,
+		checkpointStore: checkpointStore,
+		cluster:         cluster,
 	}
 }
 
@@ -76,6 +74,12 @@ func getVpasToCheckpoint(clusterVpas map[model.VpaID]*model.Vpa) []*model.Vpa {
 }
 
 func (writer *checkpointWriter) StoreCheckpoints(ctx context.Context, now time.Time, minCheckpoints int) error {
+	// Buffered checkpoints are persisted even if ctx is done before all VPAs are processed.
+	defer func() {
+		if err := writer.checkpointStore.Flush(); err != nil {
+			klog.Errorf("Cannot flush VPA checkpoints. Reason: %+v", err)
+		}
+	}()
 	vpas := getVpasToCheckpoint(writer.cluster.Vpas)
 	for _, vpa := range vpas {
 
@@ -97,16 +101,19 @@ func (writer *checkpointWriter) StoreCheckpoints(ctx context.Context, now time.T
 				klog.Errorf("Cannot serialize checkpoint for vpa %v container %v. Reason: %+v", vpa.ID.VpaName, container, err)
 				continue
 			}
+			if controllerState, found := vpa.ControllerStates[container]; found {
+				containerCheckpoint.ControllerState = controllerState.SaveToCheckpoint()
+			}
 			checkpointName := fmt.Sprintf("%s-%s", vpa.ID.VpaName, container)
 			vpaCheckpoint := vpa_types.VerticalPodAutoscalerCheckpoint{
-				ObjectMeta: metav1.ObjectMeta{Name: checkpointName},
+				ObjectMeta: metav1.ObjectMeta{Name: checkpointName, Namespace: vpa.ID.Namespace},
 				Spec: vpa_types.VerticalPodAutoscalerCheckpointSpec{
 					ContainerName: container,
 					VPAObjectName: vpa.ID.VpaName,
 				},
 				Status: *containerCheckpoint,
 			}
-			err = api_util.CreateOrUpdateVpaCheckpoint(writer.vpaCheckpointClient.VerticalPodAutoscalerCheckpoints(vpa.ID.Namespace), &vpaCheckpoint)
+			err = writer.checkpointStore.Save(&vpaCheckpoint)
 			if err != nil {
 				klog.Errorf("Cannot save VPA %s/%s checkpoint for %s. Reason: %+v",
 					vpa.ID.Namespace, vpaCheckpoint.Spec.VPAObjectName, vpaCheckpoint.Spec.ContainerName, err)
@@ -123,7 +130,7 @@ func (writer *checkpointWriter) StoreCheckpoints(ctx context.Context, now time.T
 
 // Build the AggregateContainerState for the purpose of the checkpoint. This is an aggregation of state of all
 // containers that belong to pods matched by the VPA.
-// Note however that we exclude the most recent memory peak for each container (see below).
+// Note however that we exclude the most recent memory and ephemeral storage peaks for each container (see below).
 func buildAggregateContainerStateMap(vpa *model.Vpa, cluster *model.ClusterState, now time.Time) map[string]*model.AggregateContainerState {
 	aggregateContainerStateMap := vpa.AggregateStateByContainerName()
 	// Note: the memory peak from the current (ongoing) aggregation interval is not included in the
@@ -136,6 +143,7 @@ func buildAggregateContainerStateMap(vpa *model.Vpa, cluster *model.ClusterState
 			if vpa.UsesAggregation(aggregateKey) {
 				if aggregateContainerState, exists := aggregateContainerStateMap[containerName]; exists {
 					subtractCurrentContainerMemoryPeak(aggregateContainerState, container, now)
+					subtractCurrentContainerEphemeralStoragePeak(aggregateContainerState, container, now)
 				}
 			}
 		}
@@ -148,3 +156,9 @@ func subtractCurrentContainerMemoryPeak(a *model.AggregateContainerState, contai
 		a.AggregateMemoryPeaks.SubtractSample(model.BytesFromMemoryAmount(container.GetMaxMemoryPeak()), 1.0, container.WindowEnd)
 	}
 }
+
+func subtractCurrentContainerEphemeralStoragePeak(a *model.AggregateContainerState, container *model.ContainerState, now time.Time) {
+	if peak := container.GetEphemeralStoragePeak(); peak != 0 && now.Before(container.EphemeralStorageWindowEnd) {
+		a.AggregateEphemeralStoragePeaks.SubtractSample(model.BytesFromEphemeralStorageAmount(peak), 1.0, container.EphemeralStorageWindowEnd)
+	}
+}
@@ -82,6 +82,32 @@ func TestMergeContainerStateForCheckpointDropsRecentMemoryPeak(t *testing.T) {
 	}
 }
 
+func TestMergeContainerStateForCheckpointDropsRecentEphemeralStoragePeak(t *testing.T) {
+	cluster := model.NewClusterState()
+	cluster.AddOrUpdatePod(testPodID1, testLabels, apiv1.PodRunning)
+	assert.NoError(t, cluster.AddOrUpdateContainer(testContainerID1, testRequest))
+	container := cluster.GetContainer(testContainerID1)
+
+	timeNow := time.Unix(1, 0)
+	container.AddSample(&model.ContainerUsageSample{
+		timeNow, model.EphemeralStorageAmountFromBytes(1e10), 0, model.ResourceEphemeralStorage})
+	vpa := addVpa(cluster, testVpaID1, testSelectorStr)
+
+	// Verify that the current peak is excluded from the aggregation.
+	aggregateContainerStateMap := buildAggregateContainerStateMap(vpa, cluster, timeNow)
+	if assert.Contains(t, aggregateContainerStateMap, "container-1") {
+		assert.True(t, aggregateContainerStateMap["container-1"].AggregateEphemeralStoragePeaks.IsEmpty(),
+			"Current peak was not excluded from the aggregation.")
+	}
+	// Verify that an old peak is not excluded from the aggregation.
+	timeNow = timeNow.Add(model.MemoryAggregationInterval)
+	aggregateContainerStateMap = buildAggregateContainerStateMap(vpa, cluster, timeNow)
+	if assert.Contains(t, aggregateContainerStateMap, "container-1") {
+		assert.False(t, aggregateContainerStateMap["container-1"].AggregateEphemeralStoragePeaks.IsEmpty(),
+			"Old peak should not be excluded from the aggregation.")
+	}
+}
+
 func TestIsFetchingHistory(t *testing.T) {
 
 	testCases := []struct {
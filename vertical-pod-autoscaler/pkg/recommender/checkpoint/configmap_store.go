@@ -0,0 +1,252 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checkpoint
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/klog"
+)
+
+// maxConfigMapDataSize is the limit the API server validates the total size
+// of the keys and values of a ConfigMap against.
+const maxConfigMapDataSize = 1024 * 1024
+
+// configMapShard is the in-memory copy of a single ConfigMap. Checkpoints are
+// kept encoded so that unchanged entries are not serialized again on Flush.
+type configMapShard struct {
+	configMap *apiv1.ConfigMap
+	exists    bool
+	// changed holds the keys saved or deleted since the ConfigMap was last
+	// written, which are applied again if the ConfigMap has to be re-read.
+	changed map[string]bool
+}
+
+func (shard *configMapShard) markChanged(key string) {
+	if shard.changed == nil {
+		shard.changed = make(map[string]bool)
+	}
+	shard.changed[key] = true
+}
+
+type configMapStore struct {
+	client corev1.ConfigMapInterface
+	prefix string
+	shards []*configMapShard
+	loaded bool
+}
+
+// NewConfigMapStore returns a CheckpointStore spreading checkpoints over the
+// given number of ConfigMaps named <prefix>-<index>. Checkpoints are buffered
+// in memory and all modified ConfigMaps are written on Flush, so the number of
+// API calls per loop does not depend on the number of VPAs.
+func NewConfigMapStore(client corev1.ConfigMapInterface, prefix string, shards int) CheckpointStore {
+	return &configMapStore{
+		client: client,
+		prefix: prefix,
+		shards: make([]*configMapShard, shards),
+	}
+}
+
+func (s *configMapStore) shardName(index int) string {
+	return fmt.Sprintf("%s-%d", s.prefix, index)
+}
+
+// Namespaces are DNS labels and cannot contain dots, so the first dot
+// separates the namespace from the checkpoint name.
+func checkpointKey(namespace, name string) string {
+	return namespace + "." + name
+}
+
+func (s *configMapStore) shardFor(key string) *configMapShard {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return s.shards[hash.Sum32()%uint32(len(s.shards))]
+}
+
+func (s *configMapStore) load() error {
+	if s.loaded {
+		return nil
+	}
+	for i := range s.shards {
+		configMap, err := s.client.Get(s.shardName(i), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			configMap = nil
+		} else if err != nil {
+			return fmt.Errorf("cannot get checkpoint ConfigMap %v: %v", s.shardName(i), err)
+		}
+		s.shards[i] = &configMapShard{configMap: configMap, exists: configMap != nil}
+	}
+	s.loaded = true
+	return nil
+}
+
+func (s *configMapStore) List(namespace string) ([]vpa_types.VerticalPodAutoscalerCheckpoint, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	checkpoints := make([]vpa_types.VerticalPodAutoscalerCheckpoint, 0)
+	for _, shard := range s.shards {
+		if shard.configMap == nil {
+			continue
+		}
+		for key, data := range shard.configMap.BinaryData {
+			if !strings.HasPrefix(key, namespace+".") {
+				continue
+			}
+			checkpoint, err := decodeCheckpoint(data)
+			if err != nil {
+				klog.Errorf("Cannot decode checkpoint %v from ConfigMap %v. Reason: %+v", key, shard.configMap.Name, err)
+				continue
+			}
+			checkpoints = append(checkpoints, *checkpoint)
+		}
+	}
+	return checkpoints, nil
+}
+
+func (s *configMapStore) Save(checkpoint *vpa_types.VerticalPodAutoscalerCheckpoint) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+	stored := checkpoint.DeepCopy()
+	stored.ResourceVersion = ""
+	stored.UID = ""
+	data, err := encodeCheckpoint(stored)
+	if err != nil {
+		return fmt.Errorf("cannot encode checkpoint %v/%v: %v", checkpoint.Namespace, checkpoint.Name, err)
+	}
+	key := checkpointKey(checkpoint.Namespace, checkpoint.Name)
+	shard := s.shardFor(key)
+	if shard.configMap == nil {
+		shard.configMap = &apiv1.ConfigMap{}
+	}
+	if shard.configMap.BinaryData == nil {
+		shard.configMap.BinaryData = make(map[string][]byte)
+	}
+	shard.configMap.BinaryData[key] = data
+	shard.markChanged(key)
+	return nil
+}
+
+func (s *configMapStore) Delete(namespace, name string) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+	key := checkpointKey(namespace, name)
+	shard := s.shardFor(key)
+	if shard.configMap == nil {
+		return nil
+	}
+	if _, found := shard.configMap.BinaryData[key]; found {
+		delete(shard.configMap.BinaryData, key)
+		shard.markChanged(key)
+	}
+	return nil
+}
+
+func (s *configMapStore) Flush() error {
+	var lastErr error
+	for i, shard := range s.shards {
+		if shard == nil || len(shard.changed) == 0 {
+			continue
+		}
+		err := s.writeShard(i, shard)
+		if errors.IsConflict(err) || errors.IsAlreadyExists(err) {
+			// The ConfigMap was modified since it was read, e.g. by another
+			// recommender or by a write that failed after reaching the API
+			// server. Merge the changes into the current ConfigMap and retry.
+			klog.V(3).Infof("Checkpoint ConfigMap %v was modified, merging the changes: %v", s.shardName(i), err)
+			if err = s.refreshShard(i, shard); err == nil {
+				err = s.writeShard(i, shard)
+			}
+		}
+		if err != nil {
+			klog.Errorf("Cannot write checkpoint ConfigMap %v. Reason: %+v", s.shardName(i), err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (s *configMapStore) writeShard(index int, shard *configMapShard) error {
+	if size := configMapDataSize(shard.configMap); size > maxConfigMapDataSize {
+		return fmt.Errorf("checkpoint ConfigMap %v would hold %d bytes, more than the limit of %d bytes; increase --checkpoint-configmaps",
+			s.shardName(index), size, maxConfigMapDataSize)
+	}
+	var written *apiv1.ConfigMap
+	var err error
+	if !shard.exists {
+		shard.configMap.Name = s.shardName(index)
+		written, err = s.client.Create(shard.configMap)
+	} else {
+		written, err = s.client.Update(shard.configMap)
+	}
+	if err != nil {
+		return err
+	}
+	shard.configMap = written
+	shard.exists = true
+	shard.changed = nil
+	return nil
+}
+
+// refreshShard reads the ConfigMap of the shard again and applies the changed
+// keys of the in-memory copy to it.
+func (s *configMapStore) refreshShard(index int, shard *configMapShard) error {
+	configMap, err := s.client.Get(s.shardName(index), metav1.GetOptions{})
+	exists := true
+	if errors.IsNotFound(err) {
+		configMap = &apiv1.ConfigMap{}
+		exists = false
+	} else if err != nil {
+		return fmt.Errorf("cannot get checkpoint ConfigMap %v: %v", s.shardName(index), err)
+	}
+	if configMap.BinaryData == nil {
+		configMap.BinaryData = make(map[string][]byte)
+	}
+	for key := range shard.changed {
+		if data, found := shard.configMap.BinaryData[key]; found {
+			configMap.BinaryData[key] = data
+		} else {
+			delete(configMap.BinaryData, key)
+		}
+	}
+	shard.configMap = configMap
+	shard.exists = exists
+	return nil
+}
+
+// configMapDataSize returns the size of the data of the ConfigMap as counted
+// by the API server validation.
+func configMapDataSize(configMap *apiv1.ConfigMap) int {
+	size := 0
+	for key, value := range configMap.Data {
+		size += len(key) + len(value)
+	}
+	for key, value := range configMap.BinaryData {
+		size += len(key) + len(value)
+	}
+	return size
+}
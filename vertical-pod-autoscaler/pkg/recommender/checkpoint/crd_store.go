@@ -0,0 +1,56 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checkpoint
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	vpa_api "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/typed/autoscaling.k8s.io/v1beta2"
+	api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+)
+
+type crdStore struct {
+	vpaCheckpointClient vpa_api.VerticalPodAutoscalerCheckpointsGetter
+}
+
+// NewCRDStore returns a CheckpointStore writing every checkpoint directly
+// to its own VerticalPodAutoscalerCheckpoint object.
+func NewCRDStore(vpaCheckpointClient vpa_api.VerticalPodAutoscalerCheckpointsGetter) CheckpointStore {
+	return &crdStore{vpaCheckpointClient: vpaCheckpointClient}
+}
+
+func (s *crdStore) List(namespace string) ([]vpa_types.VerticalPodAutoscalerCheckpoint, error) {
+	checkpointList, err := s.vpaCheckpointClient.VerticalPodAutoscalerCheckpoints(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return checkpointList.Items, nil
+}
+
+func (s *crdStore) Save(checkpoint *vpa_types.VerticalPodAutoscalerCheckpoint) error {
+	stored := checkpoint.DeepCopy()
+	stored.ResourceVersion = ""
+	return api_util.CreateOrUpdateVpaCheckpoint(s.vpaCheckpointClient.VerticalPodAutoscalerCheckpoints(checkpoint.Namespace), stored)
+}
+
+func (s *crdStore) Delete(namespace, name string) error {
+	return s.vpaCheckpointClient.VerticalPodAutoscalerCheckpoints(namespace).Delete(name, &metav1.DeleteOptions{})
+}
+
+func (s *crdStore) Flush() error {
+	return nil
+}
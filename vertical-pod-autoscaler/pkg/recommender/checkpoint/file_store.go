@@ -0,0 +1,142 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checkpoint
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+)
+
+type fileStore struct {
+	path        string
+	checkpoints map[string]vpa_types.VerticalPodAutoscalerCheckpoint
+	dirty       bool
+}
+
+// NewFileStore returns a CheckpointStore keeping all checkpoints in a single
+// gzip compressed snapshot at path, e.g. on a persistent volume. The snapshot
+// is replaced atomically on Flush.
+func NewFileStore(path string) CheckpointStore {
+	return &fileStore{path: path}
+}
+
+func (s *fileStore) load() error {
+	if s.checkpoints != nil {
+		return nil
+	}
+	checkpoints := make(map[string]vpa_types.VerticalPodAutoscalerCheckpoint)
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		s.checkpoints = checkpoints
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("cannot read checkpoint file %v: %v", s.path, err)
+	}
+	var snapshot []vpa_types.VerticalPodAutoscalerCheckpoint
+	if err := decodeGzipJSON(data, &snapshot); err != nil {
+		return fmt.Errorf("cannot decode checkpoint file %v: %v", s.path, err)
+	}
+	for _, checkpoint := range snapshot {
+		checkpoints[checkpointKey(checkpoint.Namespace, checkpoint.Name)] = checkpoint
+	}
+	s.checkpoints = checkpoints
+	return nil
+}
+
+func (s *fileStore) List(namespace string) ([]vpa_types.VerticalPodAutoscalerCheckpoint, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	checkpoints := make([]vpa_types.VerticalPodAutoscalerCheckpoint, 0)
+	for _, checkpoint := range s.checkpoints {
+		if checkpoint.Namespace == namespace {
+			checkpoints = append(checkpoints, *checkpoint.DeepCopy())
+		}
+	}
+	return checkpoints, nil
+}
+
+func (s *fileStore) Save(checkpoint *vpa_types.VerticalPodAutoscalerCheckpoint) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+	stored := checkpoint.DeepCopy()
+	stored.ResourceVersion = ""
+	stored.UID = ""
+	s.checkpoints[checkpointKey(checkpoint.Namespace, checkpoint.Name)] = *stored
+	s.dirty = true
+	return nil
+}
+
+func (s *fileStore) Delete(namespace, name string) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+	key := checkpointKey(namespace, name)
+	if _, found := s.checkpoints[key]; found {
+		delete(s.checkpoints, key)
+		s.dirty = true
+	}
+	return nil
+}
+
+func (s *fileStore) Flush() error {
+	if !s.dirty {
+		return nil
+	}
+	keys := make([]string, 0, len(s.checkpoints))
+	for key := range s.checkpoints {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	snapshot := make([]vpa_types.VerticalPodAutoscalerCheckpoint, 0, len(keys))
+	for _, key := range keys {
+		snapshot = append(snapshot, s.checkpoints[key])
+	}
+	data, err := encodeGzipJSON(snapshot)
+	if err != nil {
+		return fmt.Errorf("cannot encode checkpoints: %v", err)
+	}
+	// Write to a temporary file in the same directory and rename it, so that
+	// a crash never leaves a partially written snapshot behind.
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("cannot create temporary checkpoint file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot write checkpoint file %v: %v", tmp.Name(), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot sync checkpoint file %v: %v", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot close checkpoint file %v: %v", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("cannot replace checkpoint file %v: %v", s.path, err)
+	}
+	s.dirty = false
+	return nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checkpoint
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	vpa_api "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/typed/autoscaling.k8s.io/v1beta2"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// CRDStore keeps every checkpoint in its own VerticalPodAutoscalerCheckpoint object.
+	CRDStore = "crd"
+	// ConfigMapStore keeps checkpoints in a fixed number of ConfigMaps.
+	ConfigMapStore = "configmap"
+	// FileStore keeps all checkpoints in a single local file.
+	FileStore = "file"
+)
+
+// CheckpointStore persists VerticalPodAutoscalerCheckpoints. Implementations
+// may buffer writes, which are persisted on Flush.
+type CheckpointStore interface {
+	// List returns all checkpoints stored for the given namespace.
+	List(namespace string) ([]vpa_types.VerticalPodAutoscalerCheckpoint, error)
+	// Save creates or updates the checkpoint.
+	Save(checkpoint *vpa_types.VerticalPodAutoscalerCheckpoint) error
+	// Delete removes the checkpoint with the given namespace and name.
+	Delete(namespace, name string) error
+	// Flush persists changes buffered since the last Flush.
+	Flush() error
+}
+
+// StoreConfig describes which CheckpointStore to use and how to configure it.
+type StoreConfig struct {
+	// Backend is one of CRDStore, ConfigMapStore or FileStore.
+	Backend string
+	// Namespace holds the ConfigMaps of the ConfigMapStore.
+	Namespace string
+	// ConfigMapPrefix is the prefix of the names of the ConfigMapStore shards.
+	ConfigMapPrefix string
+	// Shards is the number of ConfigMaps used by the ConfigMapStore.
+	Shards int
+	// File is the path of the FileStore snapshot.
+	File string
+}
+
+// NewCheckpointStore returns the CheckpointStore described by config.
+func NewCheckpointStore(config StoreConfig, vpaCheckpointClient vpa_api.VerticalPodAutoscalerCheckpointsGetter,
+	configMapClient corev1.ConfigMapsGetter) (CheckpointStore, error) {
+	switch config.Backend {
+	case CRDStore:
+		return NewCRDStore(vpaCheckpointClient), nil
+	case ConfigMapStore:
+		if config.Shards <= 0 {
+			return nil, fmt.Errorf("number of checkpoint ConfigMaps must be positive, got %d", config.Shards)
+		}
+		return NewConfigMapStore(configMapClient.ConfigMaps(config.Namespace), config.ConfigMapPrefix, config.Shards), nil
+	case FileStore:
+		if config.File == "" {
+			return nil, fmt.Errorf("checkpoint file not set")
+		}
+		return NewFileStore(config.File), nil
+	}
+	return nil, fmt.Errorf("unknown checkpoint store %q", config.Backend)
+}
+
+// MigrateCheckpoints copies all checkpoints of the given namespaces from one
+// store to another and removes them from the source once they are persisted
+// in the destination.
+func MigrateCheckpoints(from, to CheckpointStore, namespaces []string) error {
+	migrated := make([]vpa_types.VerticalPodAutoscalerCheckpoint, 0)
+	for _, namespace := range namespaces {
+		checkpoints, err := from.List(namespace)
+		if err != nil {
+			return fmt.Errorf("cannot list checkpoints from namespace %v: %v", namespace, err)
+		}
+		for i := range checkpoints {
+			if err := to.Save(&checkpoints[i]); err != nil {
+				return fmt.Errorf("cannot save checkpoint %v/%v: %v", namespace, checkpoints[i].Name, err)
+			}
+		}
+		migrated = append(migrated, checkpoints...)
+	}
+	if err := to.Flush(); err != nil {
+		return fmt.Errorf("cannot flush migrated checkpoints: %v", err)
+	}
+	for _, checkpoint := range migrated {
+		if err := from.Delete(checkpoint.Namespace, checkpoint.Name); err != nil {
+			klog.Errorf("Cannot delete migrated checkpoint %v/%v. Reason: %+v", checkpoint.Namespace, checkpoint.Name, err)
+		}
+	}
+	if err := from.Flush(); err != nil {
+		klog.Errorf("Cannot flush checkpoint store after migration. Reason: %+v", err)
+	}
+	klog.V(1).Infof("Migrated %d VPA checkpoints", len(migrated))
+	return nil
+}
+
+// encodeCheckpoint serializes the checkpoint as gzip compressed JSON. The
+// histogram bucket weights make up most of a checkpoint and compress well.
+func encodeCheckpoint(checkpoint *vpa_types.VerticalPodAutoscalerCheckpoint) ([]byte, error) {
+	return encodeGzipJSON(checkpoint)
+}
+
+func decodeCheckpoint(data []byte) (*vpa_types.VerticalPodAutoscalerCheckpoint, error) {
+	checkpoint := &vpa_types.VerticalPodAutoscalerCheckpoint{}
+	if err := decodeGzipJSON(data, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+func encodeGzipJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(writer).Encode(v); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeGzipJSON(data []byte, v interface{}) error {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	decoded, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, v)
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checkpoint
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	vpa_fake "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+)
+
+func makeCheckpoint(namespace, vpaName, containerName string) *vpa_types.VerticalPodAutoscalerCheckpoint {
+	return &vpa_types.VerticalPodAutoscalerCheckpoint{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: vpaName + "-" + containerName},
+		Spec: vpa_types.VerticalPodAutoscalerCheckpointSpec{
+			VPAObjectName: vpaName,
+			ContainerName: containerName,
+		},
+		Status: vpa_types.VerticalPodAutoscalerCheckpointStatus{
+			TotalSamplesCount: 10,
+			CPUHistogram: vpa_types.HistogramCheckpoint{
+				BucketWeights: map[int]uint32{0: 10000, 3: 5000, 17: 1},
+				TotalWeight:   12.5,
+			},
+		},
+	}
+}
+
+func checkpointNames(checkpoints []vpa_types.VerticalPodAutoscalerCheckpoint) []string {
+	names := make([]string, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		names = append(names, checkpoint.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// testCheckpointStore checks the behaviour shared by all stores. reopen
+// returns a new store reading the data persisted by the previous one.
+func testCheckpointStore(t *testing.T, reopen func() CheckpointStore) {
+	store := reopen()
+	checkpoints, err := store.List("namespace-1")
+	assert.NoError(t, err)
+	assert.Empty(t, checkpoints)
+
+	assert.NoError(t, store.Save(makeCheckpoint("namespace-1", "vpa-1", "container-1")))
+	assert.NoError(t, store.Save(makeCheckpoint("namespace-1", "vpa-1", "container-2")))
+	assert.NoError(t, store.Save(makeCheckpoint("namespace-2", "vpa-1", "container-1")))
+	updated := makeCheckpoint("namespace-1", "vpa-1", "container-1")
+	updated.Status.TotalSamplesCount = 20
+	assert.NoError(t, store.Save(updated))
+	assert.NoError(t, store.Flush())
+
+	store = reopen()
+	checkpoints, err = store.List("namespace-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vpa-1-container-1", "vpa-1-container-2"}, checkpointNames(checkpoints))
+	for _, checkpoint := range checkpoints {
+		if checkpoint.Name == updated.Name {
+			assert.Equal(t, updated.Spec, checkpoint.Spec)
+			assert.Equal(t, updated.Status, checkpoint.Status)
+		}
+	}
+
+	assert.NoError(t, store.Delete("namespace-1", "vpa-1-container-2"))
+	assert.NoError(t, store.Delete("namespace-1", "missing"))
+	assert.NoError(t, store.Flush())
+
+	store = reopen()
+	checkpoints, err = store.List("namespace-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vpa-1-container-1"}, checkpointNames(checkpoints))
+	checkpoints, err = store.List("namespace-2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vpa-1-container-1"}, checkpointNames(checkpoints))
+}
+
+func TestConfigMapStore(t *testing.T) {
+	client := fake.NewSimpleClientset().CoreV1().ConfigMaps("kube-system")
+	testCheckpointStore(t, func() CheckpointStore { return NewConfigMapStore(client, "vpa-checkpoints", 2) })
+
+	configMaps, err := client.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.True(t, len(configMaps.Items) <= 2)
+	for _, configMap := range configMaps.Items {
+		for key, data := range configMap.BinaryData {
+			checkpoint, err := decodeCheckpoint(data)
+			assert.NoError(t, err)
+			assert.Equal(t, checkpointKey(checkpoint.Namespace, checkpoint.Name), key)
+		}
+	}
+}
+
+func TestConfigMapStoreMergesConcurrentChanges(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	client := clientset.CoreV1().ConfigMaps("kube-system")
+	store := NewConfigMapStore(client, "vpa-checkpoints", 1)
+	other := NewConfigMapStore(client, "vpa-checkpoints", 1)
+	_, err := store.List("namespace-1")
+	assert.NoError(t, err)
+	_, err = other.List("namespace-1")
+	assert.NoError(t, err)
+
+	// The ConfigMap is created by the other store after it was read.
+	assert.NoError(t, other.Save(makeCheckpoint("namespace-1", "vpa-1", "container-1")))
+	assert.NoError(t, other.Flush())
+	assert.NoError(t, store.Save(makeCheckpoint("namespace-1", "vpa-2", "container-1")))
+	assert.NoError(t, store.Flush())
+
+	// The ConfigMap is updated by the other store after it was read.
+	assert.NoError(t, store.Save(makeCheckpoint("namespace-1", "vpa-3", "container-1")))
+	assert.NoError(t, store.Delete("namespace-1", "vpa-1-container-1"))
+	conflicts := 0
+	clientset.PrependReactor("update", "configmaps", func(action core.Action) (bool, runtime.Object, error) {
+		if conflicts > 0 {
+			return false, nil, nil
+		}
+		conflicts++
+		return true, nil, errors.NewConflict(apiv1.Resource("configmaps"), "vpa-checkpoints-0", fmt.Errorf("modified"))
+	})
+	assert.NoError(t, store.Flush())
+	assert.Equal(t, 1, conflicts)
+
+	checkpoints, err := NewConfigMapStore(client, "vpa-checkpoints", 1).List("namespace-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vpa-2-container-1", "vpa-3-container-1"}, checkpointNames(checkpoints))
+}
+
+func TestConfigMapStoreSizeLimit(t *testing.T) {
+	client := fake.NewSimpleClientset().CoreV1().ConfigMaps("kube-system")
+	store := NewConfigMapStore(client, "vpa-checkpoints", 1)
+	assert.NoError(t, store.Save(makeCheckpoint("namespace-1", "vpa-1", "container-1")))
+	store.(*configMapStore).shards[0].configMap.BinaryData["namespace-1.large"] = make([]byte, maxConfigMapDataSize)
+
+	err := store.Flush()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "--checkpoint-configmaps")
+	configMaps, err := client.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, configMaps.Items)
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoints")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoints.json.gz")
+	testCheckpointStore(t, func() CheckpointStore { return NewFileStore(path) })
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1, "temporary files should be removed")
+}
+
+func TestNewCheckpointStore(t *testing.T) {
+	vpaClient := vpa_fake.NewSimpleClientset().AutoscalingV1beta2()
+	kubeClient := fake.NewSimpleClientset().CoreV1()
+	cases := []struct {
+		name    string
+		config  StoreConfig
+		wantErr bool
+	}{
+		{name: "crd", config: StoreConfig{Backend: CRDStore}},
+		{name: "configmap", config: StoreConfig{Backend: ConfigMapStore, Namespace: "kube-system", ConfigMapPrefix: "vpa", Shards: 4}},
+		{name: "configmap without shards", config: StoreConfig{Backend: ConfigMapStore}, wantErr: true},
+		{name: "file", config: StoreConfig{Backend: FileStore, File: "/tmp/checkpoints"}},
+		{name: "file without path", config: StoreConfig{Backend: FileStore}, wantErr: true},
+		{name: "unknown", config: StoreConfig{Backend: "etcd"}, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := NewCheckpointStore(tc.config, vpaClient, kubeClient)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, store)
+			}
+		})
+	}
+}
+
+func TestMigrateCheckpoints(t *testing.T) {
+	vpaClient := vpa_fake.NewSimpleClientset(
+		makeCheckpoint("namespace-1", "vpa-1", "container-1"),
+		makeCheckpoint("namespace-2", "vpa-2", "container-1")).AutoscalingV1beta2()
+	from := NewCRDStore(vpaClient)
+	configMapClient := fake.NewSimpleClientset().CoreV1().ConfigMaps("kube-system")
+	to := NewConfigMapStore(configMapClient, "vpa-checkpoints", 4)
+
+	assert.NoError(t, MigrateCheckpoints(from, to, []string{"namespace-1", "namespace-2"}))
+
+	migrated := NewConfigMapStore(configMapClient, "vpa-checkpoints", 4)
+	for namespace, name := range map[string]string{"namespace-1": "vpa-1-container-1", "namespace-2": "vpa-2-container-1"} {
+		checkpoints, err := migrated.List(namespace)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{name}, checkpointNames(checkpoints))
+		checkpoints, err = from.List(namespace)
+		assert.NoError(t, err)
+		assert.Empty(t, checkpoints)
+	}
+}
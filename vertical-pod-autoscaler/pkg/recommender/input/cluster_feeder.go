@@ -26,11 +26,13 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
 	vpa_clientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
 	vpa_api "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/typed/autoscaling.k8s.io/v1beta2"
 	vpa_lister "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/listers/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/checkpoint"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/history"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/metrics"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/oom"
@@ -50,10 +52,20 @@ import (
 
 const (
 	defaultResyncPeriod time.Duration = 10 * time.Minute
+	// DefaultRecommenderName is the name of the recommender handling the VPAs
+	// that don't select a recommender.
+	DefaultRecommenderName = "default"
+	// nodeOomWatchRetryDelay is the time to wait before watching the node
+	// OOM events again after the watch could not be started.
+	nodeOomWatchRetryDelay = 10 * time.Second
+	// podUIDIndex is the name of the index of the pod informer by pod UID.
+	podUIDIndex = "uid"
 )
 
 var (
-	beta1APIDeprecated = flag.Bool("beta1-api-deprecated", true, `If v1beta1 API objects should be marked as deprecated.`)
+	beta1APIDeprecated   = flag.Bool("beta1-api-deprecated", true, `If v1beta1 API objects should be marked as deprecated.`)
+	nodeOomEventsEnabled = flag.Bool("watch-node-oom-events", false, `If OOM kills reported in events about nodes, e.g. by node-problem-detector, should be recorded in addition to the OOMs in pod statuses and eviction events.`)
+	nodeOomEventReason   = flag.String("node-oom-event-reason", oom.DefaultNodeOomEventReason, `Reason of the events about nodes reporting kernel OOM kills.`)
 )
 
 // ClusterStateFeeder can update state of ClusterState object.
@@ -62,13 +74,19 @@ type ClusterStateFeeder interface {
 	// InitFromHistoryProvider loads historical pod spec into clusterState.
 	InitFromHistoryProvider(historyProvider history.HistoryProvider)
 
+	// ReconcileWithHistory replaces the usage aggregated in clusterState
+	// with the cluster history, filling the gaps in the real time metrics.
+	// The history is fetched in the background by
+	// FetchClusterHistoryPeriodically.
+	ReconcileWithHistory(clusterHistory map[model.PodID]*history.PodHistory)
+
 	// InitFromCheckpoints loads historical checkpoints into clusterState.
 	InitFromCheckpoints()
 
 	// LoadVPAs updates clusterState with current state of VPAs.
 	LoadVPAs()
 
-	// LoadPods updates slusterState with current specification of Pods and their Containers.
+	// LoadPods updates slusterState with current specification of Pods and their Containers, including init containers.
 	LoadPods()
 
 	// LoadRealTimeMetrics updates clusterState with current usage metrics of containers.
@@ -89,26 +107,41 @@ type ClusterStateFeederFactory struct {
 	OOMObserver           oom.Observer
 	LegacySelectorFetcher target.VpaTargetSelectorFetcher
 	SelectorFetcher       target.VpaTargetSelectorFetcher
+	// CheckpointStore stores the checkpoints. If nil, checkpoints are
+	// stored in VerticalPodAutoscalerCheckpoint objects using VpaCheckpointClient.
+	CheckpointStore checkpoint.CheckpointStore
+	// RecommenderName is the name of this recommender. Only the VPAs
+	// selecting it are loaded. DefaultRecommenderName if empty.
+	RecommenderName string
 }
 
 // Make creates new ClusterStateFeeder with internal data providers, based on kube client.
 func (m ClusterStateFeederFactory) Make() *clusterStateFeeder {
+	recommenderName := m.RecommenderName
+	if recommenderName == "" {
+		recommenderName = DefaultRecommenderName
+	}
+	checkpointStore := m.CheckpointStore
+	if checkpointStore == nil {
+		checkpointStore = checkpoint.NewCRDStore(m.VpaCheckpointClient)
+	}
 	return &clusterStateFeeder{
 		coreClient:            m.KubeClient.CoreV1(),
 		metricsClient:         m.MetricsClient,
 		oomChan:               m.OOMObserver.GetObservedOomsChannel(),
-		vpaCheckpointClient:   m.VpaCheckpointClient,
+		checkpointStore:       checkpointStore,
 		vpaLister:             m.VpaLister,
 		clusterState:          m.ClusterState,
 		specClient:            spec.NewSpecClient(m.PodLister),
 		legacySelectorFetcher: m.LegacySelectorFetcher,
 		selectorFetcher:       m.SelectorFetcher,
+		recommenderName:       recommenderName,
 	}
 }
 
 // NewClusterStateFeeder creates new ClusterStateFeeder with internal data providers, based on kube client config.
 // Deprecated; Use ClusterStateFeederFactory instead.
-func NewClusterStateFeeder(config *rest.Config, clusterState *model.ClusterState) ClusterStateFeeder {
+func NewClusterStateFeeder(config *rest.Config, clusterState *model.ClusterState, recommenderName string, checkpointStore checkpoint.CheckpointStore) ClusterStateFeeder {
 	kubeClient := kube_client.NewForConfigOrDie(config)
 	podLister, oomObserver := NewPodListerAndOOMObserver(kubeClient)
 	factory := informers.NewSharedInformerFactory(kubeClient, defaultResyncPeriod)
@@ -118,10 +151,12 @@ func NewClusterStateFeeder(config *rest.Config, clusterState *model.ClusterState
 		KubeClient:            kubeClient,
 		MetricsClient:         newMetricsClient(config),
 		VpaCheckpointClient:   vpa_clientset.NewForConfigOrDie(config).AutoscalingV1beta2(),
+		CheckpointStore:       checkpointStore,
 		VpaLister:             vpa_api_util.NewAllVpasLister(vpa_clientset.NewForConfigOrDie(config), make(chan struct{})),
 		ClusterState:          clusterState,
 		LegacySelectorFetcher: target.NewBeta1TargetSelectorFetcher(config),
 		SelectorFetcher:       target.NewVpaTargetSelectorFetcher(config, kubeClient, factory),
+		RecommenderName:       recommenderName,
 	}.Make()
 }
 
@@ -130,6 +165,28 @@ func newMetricsClient(config *rest.Config) metrics.MetricsClient {
 	return metrics.NewMetricsClient(metricsGetter)
 }
 
+// FetchClusterHistoryPeriodically fetches the cluster history from the
+// provider in the background and sends it on the returned channel. The next
+// fetch starts the interval after the previous history was received, so
+// fetching the history doesn't delay the loops of the recommender.
+func FetchClusterHistoryPeriodically(historyProvider history.HistoryProvider, interval time.Duration) <-chan map[model.PodID]*history.PodHistory {
+	historyChan := make(chan map[model.PodID]*history.PodHistory)
+	go func() {
+		for {
+			time.Sleep(interval)
+			start := time.Now()
+			clusterHistory, err := historyProvider.GetClusterHistory()
+			if err != nil {
+				klog.Errorf("Cannot get cluster history: %v", err)
+				continue
+			}
+			klog.V(3).Infof("Fetched history of #%v pods in %v", len(clusterHistory), time.Since(start))
+			historyChan <- clusterHistory
+		}
+	}()
+	return historyChan
+}
+
 // WatchEvictionEventsWithRetries watches new Events with reason=Evicted and passes them to the observer.
 func WatchEvictionEventsWithRetries(kubeClient kube_client.Interface, observer oom.Observer) {
 	go func() {
@@ -165,8 +222,75 @@ func watchEvictionEvents(evictedEventChan <-chan watch.Event, observer oom.Obser
 	}
 }
 
-// Creates clients watching pods: PodLister (listing only not terminated pods).
-func newPodClients(kubeClient kube_client.Interface, resourceEventHandler cache.ResourceEventHandler) v1lister.PodLister {
+// WatchNodeOomEventsWithRetries watches Events about nodes that report OOM
+// kills, as recognized by the parser, and passes the kills to the observer.
+func WatchNodeOomEventsWithRetries(kubeClient kube_client.Interface, observer oom.Observer, podIndexer cache.Indexer, parser oom.NodeOomEventParser) {
+	go func() {
+		options := metav1.ListOptions{
+			FieldSelector: fields.AndSelectors(
+				fields.OneTermEqualSelector("involvedObject.kind", "Node"),
+				fields.OneTermEqualSelector("reason", parser.EventReason())).String(),
+		}
+
+		for {
+			watchInterface, err := kubeClient.CoreV1().Events("").Watch(options)
+			if err != nil {
+				klog.Errorf("Cannot initialize watching node OOM events. Reason %v", err)
+				time.Sleep(nodeOomWatchRetryDelay)
+				continue
+			}
+			watchNodeOomEvents(watchInterface.ResultChan(), observer, podIndexer, parser)
+		}
+	}()
+}
+
+func watchNodeOomEvents(eventChan <-chan watch.Event, observer oom.Observer, podIndexer cache.Indexer, parser oom.NodeOomEventParser) {
+	for {
+		watchEvent, ok := <-eventChan
+		if !ok {
+			klog.V(3).Infof("Node OOM event chan closed")
+			return
+		}
+		// Repeated OOM kills may be reported by updating the count of an event.
+		if watchEvent.Type != watch.Added && watchEvent.Type != watch.Modified {
+			continue
+		}
+		event, ok := watchEvent.Object.(*apiv1.Event)
+		if !ok {
+			continue
+		}
+		kill, ok := parser.Parse(event)
+		if !ok {
+			klog.V(4).Infof("Event %v/%v doesn't identify an OOM killed container", event.Namespace, event.Name)
+			continue
+		}
+		observer.OnNodeOomKill(kill, findPodByUID(podIndexer, kill.PodUID))
+	}
+}
+
+func podUIDIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*apiv1.Pod)
+	if !ok {
+		return nil, fmt.Errorf("object %v is not a pod", obj)
+	}
+	return []string{string(pod.UID)}, nil
+}
+
+func findPodByUID(podIndexer cache.Indexer, uid types.UID) *apiv1.Pod {
+	pods, err := podIndexer.ByIndex(podUIDIndex, string(uid))
+	if err != nil {
+		klog.Errorf("Cannot get pod with UID %v. Reason: %+v", uid, err)
+		return nil
+	}
+	if len(pods) == 0 {
+		return nil
+	}
+	return pods[0].(*apiv1.Pod)
+}
+
+// Creates clients watching pods: PodLister (listing only not terminated pods)
+// and the Indexer it lists from, which also indexes the pods by UID.
+func newPodClients(kubeClient kube_client.Interface, resourceEventHandler cache.ResourceEventHandler) (v1lister.PodLister, cache.Indexer) {
 	selector := fields.ParseSelectorOrDie("status.phase!=" + string(apiv1.PodPending))
 	podListWatch := cache.NewListWatchFromClient(kubeClient.CoreV1().RESTClient(), "pods", apiv1.NamespaceAll, selector)
 	indexer, controller := cache.NewIndexerInformer(
@@ -174,19 +298,22 @@ func newPodClients(kubeClient kube_client.Interface, resourceEventHandler cache.
 		&apiv1.Pod{},
 		time.Hour,
 		resourceEventHandler,
-		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc, podUIDIndex: podUIDIndexFunc},
 	)
 	podLister := v1lister.NewPodLister(indexer)
 	stopCh := make(chan struct{})
 	go controller.Run(stopCh)
-	return podLister
+	return podLister, indexer
 }
 
 // NewPodListerAndOOMObserver creates pair of pod lister and OOM observer.
 func NewPodListerAndOOMObserver(kubeClient kube_client.Interface) (v1lister.PodLister, oom.Observer) {
 	oomObserver := oom.NewObserver()
-	podLister := newPodClients(kubeClient, oomObserver)
+	podLister, podIndexer := newPodClients(kubeClient, oomObserver)
 	WatchEvictionEventsWithRetries(kubeClient, oomObserver)
+	if *nodeOomEventsEnabled {
+		WatchNodeOomEventsWithRetries(kubeClient, oomObserver, podIndexer, oom.NewKernelOomEventParser(*nodeOomEventReason))
+	}
 	return podLister, oomObserver
 }
 
@@ -195,35 +322,61 @@ type clusterStateFeeder struct {
 	specClient            spec.SpecClient
 	metricsClient         metrics.MetricsClient
 	oomChan               <-chan oom.OomInfo
-	vpaCheckpointClient   vpa_api.VerticalPodAutoscalerCheckpointsGetter
+	checkpointStore       checkpoint.CheckpointStore
 	vpaLister             vpa_lister.VerticalPodAutoscalerLister
 	clusterState          *model.ClusterState
 	legacySelectorFetcher target.VpaTargetSelectorFetcher
 	selectorFetcher       target.VpaTargetSelectorFetcher
+	recommenderName       string
 }
 
 func (feeder *clusterStateFeeder) InitFromHistoryProvider(historyProvider history.HistoryProvider) {
 	klog.V(3).Info("Initializing VPA from history provider")
+	feeder.loadHistory(historyProvider)
+}
+
+func (feeder *clusterStateFeeder) ReconcileWithHistory(clusterHistory map[model.PodID]*history.PodHistory) {
+	klog.V(3).Info("Reconciling VPA with history")
+	feeder.applyHistory(clusterHistory)
+}
+
+// loadHistory replaces the usage aggregated in clusterState with the history
+// from the provider. If the history is not available, clusterState is kept.
+func (feeder *clusterStateFeeder) loadHistory(historyProvider history.HistoryProvider) {
 	clusterHistory, err := historyProvider.GetClusterHistory()
 	if err != nil {
 		klog.Errorf("Cannot get cluster history: %v", err)
+		return
 	}
+	feeder.applyHistory(clusterHistory)
+}
+
+// applyHistory replaces the usage aggregated in clusterState with the given
+// history. The pods and containers of the history missing in clusterState are
+// added, the pods with their last known labels.
+func (feeder *clusterStateFeeder) applyHistory(clusterHistory map[model.PodID]*history.PodHistory) {
+	samples := make(map[model.ContainerID][]model.ContainerUsageSample)
 	for podID, podHistory := range clusterHistory {
-		klog.V(4).Infof("Adding pod %v with labels %v", podID, podHistory.LastLabels)
-		feeder.clusterState.AddOrUpdatePod(podID, podHistory.LastLabels, apiv1.PodUnknown)
+		if _, found := feeder.clusterState.Pods[podID]; !found {
+			klog.V(4).Infof("Adding pod %v with labels %v", podID, podHistory.LastLabels)
+			feeder.clusterState.AddOrUpdatePod(podID, podHistory.LastLabels, apiv1.PodUnknown)
+		}
 		for containerName, sampleList := range podHistory.Samples {
 			containerID := model.ContainerID{
 				PodID:         podID,
 				ContainerName: containerName}
-			klog.V(4).Infof("Adding %d samples for container %v", len(sampleList), containerID)
-			for _, sample := range sampleList {
-				feeder.clusterState.AddSample(
-					&model.ContainerUsageSampleWithKey{
-						ContainerUsageSample: sample,
-						Container:            containerID})
+			if feeder.clusterState.GetContainer(containerID) == nil {
+				if err := feeder.clusterState.AddOrUpdateContainer(containerID, nil); err != nil {
+					klog.Warningf("Failed to add container %+v. Reason: %+v", containerID, err)
+					continue
+				}
 			}
+			klog.V(4).Infof("Adding %d samples for container %v", len(sampleList), containerID)
+			samples[containerID] = sampleList
 		}
 	}
+	added := feeder.clusterState.ReplaceUsage(samples)
+	klog.V(3).Infof("ClusterState fed with #%v ContainerUsageSamples from history of #%v pods", added, len(clusterHistory))
 }
 
 func (feeder *clusterStateFeeder) setVpaCheckpoint(checkpoint *vpa_types.VerticalPodAutoscalerCheckpoint) error {
@@ -233,12 +386,15 @@ func (feeder *clusterStateFeeder) setVpaCheckpoint(checkpoint *vpa_types.Vertica
 		return fmt.Errorf("cannot load checkpoint to missing VPA object %+v", vpaID)
 	}
 
-	cs := model.NewAggregateContainerState()
+	cs := model.NewAggregateContainerStateWithConfig(vpa.AggregationConfig)
 	err := cs.LoadFromCheckpoint(&checkpoint.Status)
 	if err != nil {
 		return fmt.Errorf("cannot load checkpoint for VPA %+v. Reason: %v", vpa.ID, err)
 	}
 	vpa.ContainersInitialAggregateState[checkpoint.Spec.ContainerName] = cs
+	if checkpoint.Status.ControllerState != nil {
+		vpa.ControllerStateForContainer(checkpoint.Spec.ContainerName).LoadFromCheckpoint(checkpoint.Status.ControllerState)
+	}
 	return nil
 }
 
@@ -253,12 +409,12 @@ func (feeder *clusterStateFeeder) InitFromCheckpoints() {
 
 	for namespace := range namespaces {
 		klog.V(3).Infof("Fetching checkpoints from namespace %s", namespace)
-		checkpointList, err := feeder.vpaCheckpointClient.VerticalPodAutoscalerCheckpoints(namespace).List(metav1.ListOptions{})
+		checkpoints, err := feeder.checkpointStore.List(namespace)
 
 		if err != nil {
 			klog.Errorf("Cannot list VPA checkpoints from namespace %v. Reason: %+v", namespace, err)
 		}
-		for _, checkpoint := range checkpointList.Items {
+		for _, checkpoint := range checkpoints {
 
 			klog.V(3).Infof("Loading VPA %s/%s checkpoint for %s", checkpoint.ObjectMeta.Namespace, checkpoint.Spec.VPAObjectName, checkpoint.Spec.ContainerName)
 			err = feeder.setVpaCheckpoint(&checkpoint)
@@ -274,6 +430,17 @@ func (feeder *clusterStateFeeder) GarbageCollectCheckpoints() {
 	klog.V(3).Info("Starting garbage collection of checkpoints")
 	feeder.LoadVPAs()
 
+	// Checkpoints of VPAs handled by other recommenders are not orphaned.
+	allVpaKeys := make(map[model.VpaID]bool)
+	allVpas, err := feeder.vpaLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Cannot list VPAs. Reason: %+v", err)
+		return
+	}
+	for _, vpa := range allVpas {
+		allVpaKeys[model.VpaID{Namespace: vpa.Namespace, VpaName: vpa.Name}] = true
+	}
+
 	namspaceList, err := feeder.coreClient.Namespaces().List(metav1.ListOptions{})
 	if err != nil {
 		klog.Errorf("Cannot list namespaces. Reason: %+v", err)
@@ -282,15 +449,14 @@ func (feeder *clusterStateFeeder) GarbageCollectCheckpoints() {
 
 	for _, namespaceItem := range namspaceList.Items {
 		namespace := namespaceItem.Name
-		checkpointList, err := feeder.vpaCheckpointClient.VerticalPodAutoscalerCheckpoints(namespace).List(metav1.ListOptions{})
+		checkpoints, err := feeder.checkpointStore.List(namespace)
 		if err != nil {
 			klog.Errorf("Cannot list VPA checkpoints from namespace %v. Reason: %+v", namespace, err)
 		}
-		for _, checkpoint := range checkpointList.Items {
+		for _, checkpoint := range checkpoints {
 			vpaID := model.VpaID{Namespace: checkpoint.Namespace, VpaName: checkpoint.Spec.VPAObjectName}
-			_, exists := feeder.clusterState.Vpas[vpaID]
-			if !exists {
-				err = feeder.vpaCheckpointClient.VerticalPodAutoscalerCheckpoints(namespace).Delete(checkpoint.Name, &metav1.DeleteOptions{})
+			if !allVpaKeys[vpaID] {
+				err = feeder.checkpointStore.Delete(namespace, checkpoint.Name)
 				if err == nil {
 					klog.V(3).Infof("Orphaned VPA checkpoint cleanup - deleting %v/%v.", namespace, checkpoint.Name)
 				} else {
@@ -299,6 +465,9 @@ func (feeder *clusterStateFeeder) GarbageCollectCheckpoints() {
 			}
 		}
 	}
+	if err := feeder.checkpointStore.Flush(); err != nil {
+		klog.Errorf("Cannot flush VPA checkpoints. Reason: %+v", err)
+	}
 }
 
 // Fetch VPA objects and load them into the cluster state.
@@ -309,7 +478,8 @@ func (feeder *clusterStateFeeder) LoadVPAs() {
 		klog.Errorf("Cannot list VPAs. Reason: %+v", err)
 		return
 	}
-	klog.V(3).Infof("Fetched %d VPAs.", len(vpaCRDs))
+	vpaCRDs = filterVPAs(feeder.recommenderName, vpaCRDs)
+	klog.V(3).Infof("Fetched %d VPAs handled by recommender %s.", len(vpaCRDs), feeder.recommenderName)
 	// Add or update existing VPAs in the model.
 	vpaKeys := make(map[model.VpaID]bool)
 	for _, vpaCRD := range vpaCRDs {
@@ -346,6 +516,27 @@ func (feeder *clusterStateFeeder) LoadVPAs() {
 	feeder.clusterState.ObservedVpas = vpaCRDs
 }
 
+// filterVPAs returns the VPAs selecting the recommender with the given name.
+// VPAs that don't select a recommender are handled by the default one.
+func filterVPAs(recommenderName string, allVpaCRDs []*vpa_types.VerticalPodAutoscaler) []*vpa_types.VerticalPodAutoscaler {
+	vpaCRDs := make([]*vpa_types.VerticalPodAutoscaler, 0, len(allVpaCRDs))
+	for _, vpaCRD := range allVpaCRDs {
+		if getRecommenderName(vpaCRD) == recommenderName {
+			vpaCRDs = append(vpaCRDs, vpaCRD)
+		} else {
+			klog.V(6).Infof("Skipping VPA %s/%s, handled by another recommender", vpaCRD.Namespace, vpaCRD.Name)
+		}
+	}
+	return vpaCRDs
+}
+
+func getRecommenderName(vpa *vpa_types.VerticalPodAutoscaler) string {
+	if len(vpa.Spec.Recommenders) == 0 || vpa.Spec.Recommenders[0] == nil {
+		return DefaultRecommenderName
+	}
+	return vpa.Spec.Recommenders[0].Name
+}
+
 // Load pod into the cluster state.
 func (feeder *clusterStateFeeder) LoadPods() {
 	podSpecs, err := feeder.specClient.GetPodSpecs()
@@ -367,6 +558,9 @@ func (feeder *clusterStateFeeder) LoadPods() {
 		for _, container := range pod.Containers {
 			feeder.clusterState.AddOrUpdateContainer(container.ID, container.Request)
 		}
+		for _, container := range pod.InitContainers {
+			feeder.clusterState.AddOrUpdateInitContainer(container.ID, container.Request)
+		}
 	}
 }
 
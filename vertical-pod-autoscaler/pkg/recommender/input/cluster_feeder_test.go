@@ -19,16 +19,22 @@ package input
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/golang/mock/gomock"
+	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
 	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/history"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/oom"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
 	target_mock "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target/mock"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/test"
+	"k8s.io/client-go/tools/cache"
 )
 
 func parseLabelSelector(selector string) labels.Selector {
@@ -165,6 +171,7 @@ func TestLegacySelector(t *testing.T) {
 				clusterState:          clusterState,
 				legacySelectorFetcher: legacyTargetSelectorFetcher,
 				selectorFetcher:       targetSelectorFetcher,
+				recommenderName:       DefaultRecommenderName,
 			}
 
 			// legacyTargetSelectorFetcher is called twice:
@@ -206,3 +213,154 @@ func TestLegacySelector(t *testing.T) {
 	}
 
 }
+
+func TestLoadVPAsFiltersByRecommender(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	defaultVpa := test.VerticalPodAutoscaler().WithName("default-vpa").WithContainer("container").WithNamespace("testNamespace").Get()
+	selectedVpa := test.VerticalPodAutoscaler().WithName("selected-vpa").WithContainer("container").WithNamespace("testNamespace").Get()
+	selectedVpa.Spec.Recommenders = []*vpa_types.VerticalPodAutoscalerRecommenderSelector{{Name: "custom"}}
+	otherVpa := test.VerticalPodAutoscaler().WithName("other-vpa").WithContainer("container").WithNamespace("testNamespace").Get()
+	otherVpa.Spec.Recommenders = []*vpa_types.VerticalPodAutoscalerRecommenderSelector{{Name: "other"}}
+	vpaLister := &test.VerticalPodAutoscalerListerMock{}
+	vpaLister.On("List").Return([]*vpa_types.VerticalPodAutoscaler{defaultVpa, selectedVpa, otherVpa}, nil)
+
+	legacyTargetSelectorFetcher := target_mock.NewMockVpaTargetSelectorFetcher(ctrl)
+	targetSelectorFetcher := target_mock.NewMockVpaTargetSelectorFetcher(ctrl)
+	legacyTargetSelectorFetcher.EXPECT().Fetch(gomock.Any()).AnyTimes().Return(nil, nil)
+	targetSelectorFetcher.EXPECT().Fetch(gomock.Any()).AnyTimes().Return(parseLabelSelector("app = test"), nil)
+
+	for _, tc := range []struct {
+		recommenderName string
+		expectedVpa     *vpa_types.VerticalPodAutoscaler
+	}{
+		{recommenderName: DefaultRecommenderName, expectedVpa: defaultVpa},
+		{recommenderName: "custom", expectedVpa: selectedVpa},
+	} {
+		clusterState := model.NewClusterState()
+		clusterStateFeeder := clusterStateFeeder{
+			vpaLister:             vpaLister,
+			clusterState:          clusterState,
+			legacySelectorFetcher: legacyTargetSelectorFetcher,
+			selectorFetcher:       targetSelectorFetcher,
+			recommenderName:       tc.recommenderName,
+		}
+		clusterStateFeeder.LoadVPAs()
+
+		assert.Len(t, clusterState.Vpas, 1, "recommender %s", tc.recommenderName)
+		assert.Contains(t, clusterState.Vpas, model.VpaID{Namespace: tc.expectedVpa.Namespace, VpaName: tc.expectedVpa.Name})
+		assert.Equal(t, []*vpa_types.VerticalPodAutoscaler{tc.expectedVpa}, clusterState.ObservedVpas)
+	}
+}
+
+type fakeHistoryProvider struct {
+	history map[model.PodID]*history.PodHistory
+	err     error
+}
+
+func (f *fakeHistoryProvider) GetClusterHistory() (map[model.PodID]*history.PodHistory, error) {
+	return f.history, f.err
+}
+
+// sequenceHistoryProvider returns the results sent on the channel in order.
+type sequenceHistoryProvider chan fakeHistoryProvider
+
+func (p sequenceHistoryProvider) GetClusterHistory() (map[model.PodID]*history.PodHistory, error) {
+	result := <-p
+	return result.history, result.err
+}
+
+func TestFetchClusterHistoryPeriodically(t *testing.T) {
+	clusterHistory := map[model.PodID]*history.PodHistory{
+		{Namespace: "default", PodName: "pod"}: {LastLabels: map[string]string{"app": "test"}},
+	}
+	provider := make(sequenceHistoryProvider, 2)
+	// Histories that are not available are skipped.
+	provider <- fakeHistoryProvider{err: fmt.Errorf("timeout")}
+	provider <- fakeHistoryProvider{history: clusterHistory}
+
+	historyChan := FetchClusterHistoryPeriodically(provider, time.Millisecond)
+	select {
+	case fetched := <-historyChan:
+		assert.Equal(t, clusterHistory, fetched)
+	case <-time.After(10 * time.Second):
+		t.Fatal("history not fetched")
+	}
+	select {
+	case <-historyChan:
+		t.Fatal("unexpected history")
+	default:
+	}
+}
+
+func TestReconcileWithHistory(t *testing.T) {
+	now := time.Unix(3600, 0)
+	livePodID := model.PodID{Namespace: "default", PodName: "live"}
+	deadPodID := model.PodID{Namespace: "default", PodName: "dead"}
+	liveContainerID := model.ContainerID{PodID: livePodID, ContainerName: "container"}
+	deadContainerID := model.ContainerID{PodID: deadPodID, ContainerName: "container"}
+	cpuSample := func(timestamp time.Time) model.ContainerUsageSample {
+		return model.ContainerUsageSample{MeasureStart: timestamp, Usage: model.CPUAmountFromCores(1), Resource: model.ResourceCPU}
+	}
+
+	clusterState := model.NewClusterState()
+	clusterState.AddOrUpdatePod(livePodID, labels.Set{"app": "live"}, apiv1.PodRunning)
+	assert.NoError(t, clusterState.AddOrUpdateContainer(liveContainerID, nil))
+	assert.NoError(t, clusterState.AddSample(&model.ContainerUsageSampleWithKey{ContainerUsageSample: cpuSample(now), Container: liveContainerID}))
+	feeder := clusterStateFeeder{clusterState: clusterState}
+
+	feeder.ReconcileWithHistory(map[model.PodID]*history.PodHistory{
+		livePodID: {
+			LastLabels: map[string]string{"app": "stale"},
+			Samples: map[string][]model.ContainerUsageSample{"container": {
+				cpuSample(now.Add(-2 * time.Minute)), cpuSample(now.Add(-time.Minute))}}},
+		deadPodID: {
+			LastLabels: map[string]string{"app": "dead"},
+			Samples:    map[string][]model.ContainerUsageSample{"container": {cpuSample(now.Add(-time.Hour))}}},
+	})
+	// The live pod keeps its labels and phase.
+	assert.Equal(t, apiv1.PodRunning, clusterState.Pods[livePodID].Phase)
+	assert.Equal(t, labels.Set{"app": "live"}, clusterState.MakeAggregateStateKey(clusterState.Pods[livePodID], "container").Labels())
+	assert.Equal(t, now.Add(-time.Minute), clusterState.GetContainer(liveContainerID).LastCPUSampleStart)
+	// The dead pod is added from the history.
+	assert.Equal(t, labels.Set{"app": "dead"}, clusterState.MakeAggregateStateKey(clusterState.Pods[deadPodID], "container").Labels())
+	assert.Equal(t, now.Add(-time.Hour), clusterState.GetContainer(deadContainerID).LastCPUSampleStart)
+}
+
+func TestWatchNodeOomEvents(t *testing.T) {
+	pod := test.Pod().WithName("pod1").AddContainer(test.BuildTestContainer("container1", "1", "1Gi")).Get()
+	pod.UID = "4d6d2c8b-3e4f-11e9-9f3a-42010a800002"
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+		Name:        "container1",
+		ContainerID: "docker://0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}}
+	other := test.Pod().WithName("pod2").AddContainer(test.BuildTestContainer("container1", "1", "1Gi")).Get()
+	other.UID = "5e7e3d9c-4f50-11e9-9f3a-42010a800002"
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{podUIDIndex: podUIDIndexFunc})
+	assert.NoError(t, indexer.Add(other))
+	assert.NoError(t, indexer.Add(pod))
+	observer := oom.NewObserver()
+
+	events := make(chan watch.Event, 3)
+	events <- watch.Event{Type: watch.Added, Object: &apiv1.Event{
+		InvolvedObject: apiv1.ObjectReference{Kind: "Node", Name: "node1"},
+		Reason:         oom.DefaultNodeOomEventReason,
+		Message:        "oom-kill:task_memcg=/kubepods/pod4d6d2c8b-3e4f-11e9-9f3a-42010a800002/0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef,task=app",
+		LastTimestamp:  metav1.NewTime(time.Unix(1000, 0)),
+	}}
+	events <- watch.Event{Type: watch.Added, Object: &apiv1.Event{
+		InvolvedObject: apiv1.ObjectReference{Kind: "Node", Name: "node1"},
+		Reason:         oom.DefaultNodeOomEventReason,
+		Message:        "Killed process 1234 (app)",
+	}}
+	close(events)
+	watchNodeOomEvents(events, observer, indexer, oom.NewKernelOomEventParser(oom.DefaultNodeOomEventReason))
+
+	ooms := observer.GetObservedOomsChannel()
+	assert.Len(t, ooms, 1)
+	info := <-ooms
+	assert.Equal(t, model.ContainerID{PodID: model.PodID{Namespace: pod.Namespace, PodName: "pod1"}, ContainerName: "container1"}, info.ContainerID)
+	assert.Equal(t, model.MemoryAmountFromBytes(1024*1024*1024), info.Memory)
+	assert.Equal(t, time.Unix(1000, 0).UTC(), info.Timestamp)
+}
@@ -17,23 +17,56 @@ limitations under the License.
 package history
 
 import (
+	"bytes"
 	"fmt"
-	"net/http"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
 )
 
+const (
+	// Default queries of the history, for cAdvisor metrics. The usage of
+	// CPU is averaged and the usage of memory and ephemeral storage is
+	// maximized over the resolution.
+	defaultCPUQuery              = "rate(container_cpu_usage_seconds_total{{.PodSelector}}[{{.Resolution}}])"
+	defaultMemoryQuery           = "max_over_time(container_memory_usage_bytes{{.PodSelector}}[{{.Resolution}}])"
+	defaultEphemeralStorageQuery = "max_over_time(container_fs_usage_bytes{{.PodSelector}}[{{.Resolution}}])"
+)
+
 // PrometheusHistoryProviderConfig allow to select which metrics
 // should be queried to get real resource utilization.
 type PrometheusHistoryProviderConfig struct {
-	Address                                            string
+	Client                                             PrometheusClientConfig
 	HistoryLength, PodLabelPrefix, PodLabelsMetricName string
 	PodNamespaceLabel, PodNameLabel                    string
 	CtrNamespaceLabel, CtrPodNameLabel, CtrNameLabel   string
 	CadvisorMetricsJobName                             string
+	// HistoryResolution is the step of the range queries, as a Prometheus
+	// duration.
+	HistoryResolution string
+	// The history is fetched in range queries of at most QueryChunkLength,
+	// QueryConcurrency of them at a time.
+	QueryChunkLength time.Duration
+	QueryConcurrency int
+	// Templates of the queries of the usage of resources and of the pod
+	// labels, overriding the default cAdvisor queries, e.g. to use recording
+	// rules. The queries must return instant vectors. The templates get the
+	// PodSelector, with the braces, matching the cAdvisor metrics of the
+	// containers, and the Resolution. The pod labels are queried from the
+	// PodLabelsMetricName by default.
+	CPUQuery, MemoryQuery, EphemeralStorageQuery, PodLabelsQuery string
+}
+
+// queryTemplateData is passed to the templates of the queries.
+type queryTemplateData struct {
+	PodSelector string
+	Resolution  string
 }
 
 // PodHistory represents history of usage and labels for a given pod.
@@ -60,14 +93,161 @@ type HistoryProvider interface {
 type prometheusHistoryProvider struct {
 	prometheusClient PrometheusClient
 	config           PrometheusHistoryProviderConfig
+	historyLength    time.Duration
+	resolution       time.Duration
+	// Queries rendered from the templates.
+	cpuQuery, memoryQuery, ephemeralStorageQuery, podLabelsQuery string
+	// now returns the end of the history.
+	now func() time.Time
 }
 
 // NewPrometheusHistoryProvider contructs a history provider that gets data from Prometheus.
-func NewPrometheusHistoryProvider(config PrometheusHistoryProviderConfig) HistoryProvider {
-	return &prometheusHistoryProvider{
-		prometheusClient: NewPrometheusClient(&http.Client{}, config.Address),
+func NewPrometheusHistoryProvider(config PrometheusHistoryProviderConfig) (HistoryProvider, error) {
+	prometheusClient, err := NewPrometheusClient(config.Client)
+	if err != nil {
+		return nil, err
+	}
+	return newPrometheusHistoryProvider(prometheusClient, config)
+}
+
+func newPrometheusHistoryProvider(prometheusClient PrometheusClient, config PrometheusHistoryProviderConfig) (*prometheusHistoryProvider, error) {
+	historyLength, err := parsePrometheusDuration(config.HistoryLength)
+	if err != nil {
+		return nil, fmt.Errorf("invalid history length: %v", err)
+	}
+	resolution, err := parsePrometheusDuration(config.HistoryResolution)
+	if err != nil {
+		return nil, fmt.Errorf("invalid history resolution: %v", err)
+	}
+	if resolution <= 0 {
+		return nil, fmt.Errorf("history resolution must be positive, got %s", config.HistoryResolution)
+	}
+	if config.QueryChunkLength < resolution {
+		config.QueryChunkLength = resolution
+	}
+	if config.QueryConcurrency < 1 {
+		config.QueryConcurrency = 1
+	}
+	data := queryTemplateData{
+		PodSelector: fmt.Sprintf("{job=\"%s\", %s=~\".+\", %s!=\"POD\", %s!=\"\"}",
+			config.CadvisorMetricsJobName, config.CtrPodNameLabel,
+			config.CtrNameLabel, config.CtrNameLabel),
+		Resolution: config.HistoryResolution,
+	}
+	p := &prometheusHistoryProvider{
+		prometheusClient: prometheusClient,
 		config:           config,
+		historyLength:    historyLength,
+		resolution:       resolution,
+		now:              time.Now,
+	}
+	for _, query := range []struct {
+		name     string
+		template string
+		fallback string
+		result   *string
+	}{
+		{"cpu", config.CPUQuery, defaultCPUQuery, &p.cpuQuery},
+		{"memory", config.MemoryQuery, defaultMemoryQuery, &p.memoryQuery},
+		{"ephemeral storage", config.EphemeralStorageQuery, defaultEphemeralStorageQuery, &p.ephemeralStorageQuery},
+		{"pod labels", config.PodLabelsQuery, config.PodLabelsMetricName, &p.podLabelsQuery},
+	} {
+		queryTemplate := query.template
+		if queryTemplate == "" {
+			queryTemplate = query.fallback
+		}
+		*query.result, err = renderQuery(queryTemplate, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s query: %v", query.name, err)
+		}
 	}
+	return p, nil
+}
+
+func renderQuery(queryTemplate string, data queryTemplateData) (string, error) {
+	t, err := template.New("query").Option("missingkey=error").Parse(queryTemplate)
+	if err != nil {
+		return "", err
+	}
+	var query bytes.Buffer
+	if err := t.Execute(&query, data); err != nil {
+		return "", err
+	}
+	return query.String(), nil
+}
+
+var prometheusDurationRegexp = regexp.MustCompile("^([0-9]+)(y|w|d)$")
+
+// parsePrometheusDuration parses a duration in the format of Prometheus,
+// e.g. 8d, also accepting the format of Go, e.g. 1h30m.
+func parsePrometheusDuration(duration string) (time.Duration, error) {
+	match := prometheusDurationRegexp.FindStringSubmatch(duration)
+	if match == nil {
+		return time.ParseDuration(duration)
+	}
+	value, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, err
+	}
+	unit := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour, "y": 365 * 24 * time.Hour}[match[2]]
+	return time.Duration(value) * unit, nil
+}
+
+// timeRange is a time range of a range query, including both ends.
+type timeRange struct {
+	start, end time.Time
+}
+
+// getChunks splits the history into time ranges of at most
+// QueryChunkLength. The queries are evaluated every resolution, the last one
+// at the end of the history, each covering the preceding resolution. The
+// evaluation times of adjacent chunks don't overlap.
+func (p *prometheusHistoryProvider) getChunks() []timeRange {
+	end := p.now().Truncate(p.resolution)
+	start := end.Add(-p.historyLength).Add(p.resolution)
+	chunks := make([]timeRange, 0)
+	for chunkStart := start; !chunkStart.After(end); {
+		chunkEnd := chunkStart.Add(p.config.QueryChunkLength - p.resolution)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+		chunks = append(chunks, timeRange{chunkStart, chunkEnd})
+		chunkStart = chunkEnd.Add(p.resolution)
+	}
+	return chunks
+}
+
+// getTimeseries runs the query over the history, in chunks queried
+// concurrently. The samples of a timeseries may be split among several
+// returned timeseries with the same labels.
+func (p *prometheusHistoryProvider) getTimeseries(query string) ([]Timeseries, error) {
+	chunks := p.getChunks()
+	results := make([][]Timeseries, len(chunks))
+	errs := make([]error, len(chunks))
+	indexes := make(chan int, len(chunks))
+	for i := range chunks {
+		indexes <- i
+	}
+	close(indexes)
+	var wg sync.WaitGroup
+	for worker := 0; worker < p.config.QueryConcurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i], errs[i] = p.prometheusClient.GetTimeseries(query, chunks[i].start, chunks[i].end, p.resolution)
+			}
+		}()
+	}
+	wg.Wait()
+	tss := make([]Timeseries, 0)
+	for i := range chunks {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		tss = append(tss, results[i]...)
+	}
+	return tss, nil
 }
 
 func (p *prometheusHistoryProvider) getContainerIDFromLabels(labels map[string]string) (*model.ContainerID, error) {
@@ -114,13 +294,15 @@ func (p *prometheusHistoryProvider) getPodLabelsMap(metricLabels map[string]stri
 }
 
 func resourceAmountFromValue(value float64, resource model.ResourceName) model.ResourceAmount {
-	// This assumes CPU value is in cores and memory in bytes, which is true
-	// for the metrics this class queries from Prometheus.
+	// This assumes CPU value is in cores and memory and ephemeral storage in
+	// bytes, which is true for the metrics this class queries from Prometheus.
 	switch resource {
 	case model.ResourceCPU:
 		return model.CPUAmountFromCores(value)
 	case model.ResourceMemory:
 		return model.MemoryAmountFromBytes(value)
+	case model.ResourceEphemeralStorage:
+		return model.EphemeralStorageAmountFromBytes(value)
 	}
 	return model.ResourceAmount(0)
 }
@@ -137,7 +319,7 @@ func getContainerUsageSamplesFromSamples(samples []Sample, resource model.Resour
 }
 
 func (p *prometheusHistoryProvider) readResourceHistory(res map[model.PodID]*PodHistory, query string, resource model.ResourceName) error {
-	tss, err := p.prometheusClient.GetTimeseries(query)
+	tss, err := p.getTimeseries(query)
 	if err != nil {
 		return fmt.Errorf("cannot get timeseries for %v: %v", resource, err)
 	}
@@ -160,7 +342,7 @@ func (p *prometheusHistoryProvider) readResourceHistory(res map[model.PodID]*Pod
 }
 
 func (p *prometheusHistoryProvider) readLastLabels(res map[model.PodID]*PodHistory, query string) error {
-	tss, err := p.prometheusClient.GetTimeseries(query)
+	tss, err := p.getTimeseries(query)
 	if err != nil {
 		return fmt.Errorf("cannot get timeseries for labels: %v", err)
 	}
@@ -187,14 +369,15 @@ func (p *prometheusHistoryProvider) readLastLabels(res map[model.PodID]*PodHisto
 
 func (p *prometheusHistoryProvider) GetClusterHistory() (map[model.PodID]*PodHistory, error) {
 	res := make(map[model.PodID]*PodHistory)
-	podSelector := fmt.Sprintf("job=\"%s\", %s=~\".+\", %s!=\"POD\", %s!=\"\"",
-		p.config.CadvisorMetricsJobName, p.config.CtrPodNameLabel,
-		p.config.CtrNameLabel, p.config.CtrNameLabel)
-	err := p.readResourceHistory(res, fmt.Sprintf("container_cpu_usage_seconds_total{%s}[%s]", podSelector, p.config.HistoryLength), model.ResourceCPU)
+	err := p.readResourceHistory(res, p.cpuQuery, model.ResourceCPU)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get usage history: %v", err)
+	}
+	err = p.readResourceHistory(res, p.memoryQuery, model.ResourceMemory)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get usage history: %v", err)
 	}
-	err = p.readResourceHistory(res, fmt.Sprintf("container_memory_usage_bytes{%s}[%s]", podSelector, p.config.HistoryLength), model.ResourceMemory)
+	err = p.readResourceHistory(res, p.ephemeralStorageQuery, model.ResourceEphemeralStorage)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get usage history: %v", err)
 	}
@@ -203,6 +386,6 @@ func (p *prometheusHistoryProvider) GetClusterHistory() (map[model.PodID]*PodHis
 			sort.Slice(samples, func(i, j int) bool { return samples[i].MeasureStart.Before(samples[j].MeasureStart) })
 		}
 	}
-	p.readLastLabels(res, fmt.Sprintf("%s[%s]", p.config.PodLabelsMetricName, p.config.HistoryLength))
+	p.readLastLabels(res, p.podLabelsQuery)
 	return res, nil
 }
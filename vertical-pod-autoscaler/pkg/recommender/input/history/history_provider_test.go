@@ -18,6 +18,9 @@ package history
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,9 +30,15 @@ import (
 )
 
 const (
-	cpuQuery    = "container_cpu_usage_seconds_total{job=\"kubernetes-cadvisor\", pod_name=~\".+\", name!=\"POD\", name!=\"\"}[8d]"
-	memoryQuery = "container_memory_usage_bytes{job=\"kubernetes-cadvisor\", pod_name=~\".+\", name!=\"POD\", name!=\"\"}[8d]"
-	labelsQuery = "up{job=\"kubernetes-pods\"}[8d]"
+	cpuQuery              = "rate(container_cpu_usage_seconds_total{job=\"kubernetes-cadvisor\", pod_name=~\".+\", name!=\"POD\", name!=\"\"}[1h])"
+	memoryQuery           = "max_over_time(container_memory_usage_bytes{job=\"kubernetes-cadvisor\", pod_name=~\".+\", name!=\"POD\", name!=\"\"}[1h])"
+	ephemeralStorageQuery = "max_over_time(container_fs_usage_bytes{job=\"kubernetes-cadvisor\", pod_name=~\".+\", name!=\"POD\", name!=\"\"}[1h])"
+	labelsQuery           = "up{job=\"kubernetes-pods\"}"
+)
+
+var (
+	// End of the history in the tests.
+	testNow = time.Unix(1000*3600, 0)
 )
 
 type mockPrometheusClient struct {
@@ -38,8 +47,9 @@ type mockPrometheusClient struct {
 
 func getDefaultPrometheusHistoryProviderConfigForTest() PrometheusHistoryProviderConfig {
 	return PrometheusHistoryProviderConfig{
-		Address:                "",
 		HistoryLength:          "8d",
+		HistoryResolution:      "1h",
+		QueryChunkLength:       8 * 24 * time.Hour,
 		PodLabelPrefix:         "pod_label_",
 		PodLabelsMetricName:    "up{job=\"kubernetes-pods\"}",
 		PodNamespaceLabel:      "kubernetes_namespace",
@@ -51,7 +61,14 @@ func getDefaultPrometheusHistoryProviderConfigForTest() PrometheusHistoryProvide
 	}
 }
 
-func (m *mockPrometheusClient) GetTimeseries(query string) ([]Timeseries, error) {
+func newTestHistoryProvider(t *testing.T, client PrometheusClient, config PrometheusHistoryProviderConfig) *prometheusHistoryProvider {
+	historyProvider, err := newPrometheusHistoryProvider(client, config)
+	assert.NoError(t, err)
+	historyProvider.now = func() time.Time { return testNow }
+	return historyProvider
+}
+
+func (m *mockPrometheusClient) GetTimeseries(query string, start, end time.Time, step time.Duration) ([]Timeseries, error) {
 	args := m.Called(query)
 	var returnArg []Timeseries
 	if args.Get(0) != nil {
@@ -62,10 +79,8 @@ func (m *mockPrometheusClient) GetTimeseries(query string) ([]Timeseries, error)
 
 func TestGetEmptyClusterHistory(t *testing.T) {
 	mockClient := mockPrometheusClient{}
-	historyProvider := prometheusHistoryProvider{
-		config:           getDefaultPrometheusHistoryProviderConfigForTest(),
-		prometheusClient: &mockClient}
-	mockClient.On("GetTimeseries", mock.AnythingOfType("string")).Times(3).Return(
+	historyProvider := newTestHistoryProvider(t, &mockClient, getDefaultPrometheusHistoryProviderConfigForTest())
+	mockClient.On("GetTimeseries", mock.AnythingOfType("string")).Times(4).Return(
 		[]Timeseries{}, nil)
 	tss, err := historyProvider.GetClusterHistory()
 	assert.Nil(t, err)
@@ -75,10 +90,8 @@ func TestGetEmptyClusterHistory(t *testing.T) {
 
 func TestPrometheusError(t *testing.T) {
 	mockClient := mockPrometheusClient{}
-	historyProvider := prometheusHistoryProvider{
-		config:           getDefaultPrometheusHistoryProviderConfigForTest(),
-		prometheusClient: &mockClient}
-	mockClient.On("GetTimeseries", mock.AnythingOfType("string")).Times(3).Return(
+	historyProvider := newTestHistoryProvider(t, &mockClient, getDefaultPrometheusHistoryProviderConfigForTest())
+	mockClient.On("GetTimeseries", mock.AnythingOfType("string")).Times(4).Return(
 		nil, fmt.Errorf("bla"))
 	_, err := historyProvider.GetClusterHistory()
 	assert.NotNil(t, err)
@@ -86,9 +99,7 @@ func TestPrometheusError(t *testing.T) {
 
 func TestGetCPUSamples(t *testing.T) {
 	mockClient := mockPrometheusClient{}
-	historyProvider := prometheusHistoryProvider{
-		config:           getDefaultPrometheusHistoryProviderConfigForTest(),
-		prometheusClient: &mockClient}
+	historyProvider := newTestHistoryProvider(t, &mockClient, getDefaultPrometheusHistoryProviderConfigForTest())
 	mockClient.On("GetTimeseries", cpuQuery).Return(
 		[]Timeseries{{
 			Labels: map[string]string{
@@ -98,6 +109,7 @@ func TestGetCPUSamples(t *testing.T) {
 			Samples: []Sample{{
 				Value: 5.5, Timestamp: time.Unix(1, 0)}}}}, nil)
 	mockClient.On("GetTimeseries", memoryQuery).Return([]Timeseries{}, nil)
+	mockClient.On("GetTimeseries", ephemeralStorageQuery).Return([]Timeseries{}, nil)
 	mockClient.On("GetTimeseries", labelsQuery).Return([]Timeseries{}, nil)
 	podID := model.PodID{Namespace: "default", PodName: "pod"}
 	podHistory := &PodHistory{
@@ -113,9 +125,7 @@ func TestGetCPUSamples(t *testing.T) {
 
 func TestGetMemorySamples(t *testing.T) {
 	mockClient := mockPrometheusClient{}
-	historyProvider := prometheusHistoryProvider{
-		config:           getDefaultPrometheusHistoryProviderConfigForTest(),
-		prometheusClient: &mockClient}
+	historyProvider := newTestHistoryProvider(t, &mockClient, getDefaultPrometheusHistoryProviderConfigForTest())
 	mockClient.On("GetTimeseries", cpuQuery).Return([]Timeseries{}, nil)
 	mockClient.On("GetTimeseries", memoryQuery).Return(
 		[]Timeseries{{
@@ -125,6 +135,7 @@ func TestGetMemorySamples(t *testing.T) {
 				"name":      "container"},
 			Samples: []Sample{{
 				Value: 12345, Timestamp: time.Unix(1, 0)}}}}, nil)
+	mockClient.On("GetTimeseries", ephemeralStorageQuery).Return([]Timeseries{}, nil)
 	mockClient.On("GetTimeseries", labelsQuery).Return([]Timeseries{}, nil)
 	podID := model.PodID{Namespace: "default", PodName: "pod"}
 	podHistory := &PodHistory{
@@ -140,11 +151,10 @@ func TestGetMemorySamples(t *testing.T) {
 
 func TestGetLabels(t *testing.T) {
 	mockClient := mockPrometheusClient{}
-	historyProvider := prometheusHistoryProvider{
-		config:           getDefaultPrometheusHistoryProviderConfigForTest(),
-		prometheusClient: &mockClient}
+	historyProvider := newTestHistoryProvider(t, &mockClient, getDefaultPrometheusHistoryProviderConfigForTest())
 	mockClient.On("GetTimeseries", cpuQuery).Return([]Timeseries{}, nil)
 	mockClient.On("GetTimeseries", memoryQuery).Return([]Timeseries{}, nil)
+	mockClient.On("GetTimeseries", ephemeralStorageQuery).Return([]Timeseries{}, nil)
 	mockClient.On("GetTimeseries", labelsQuery).Return([]Timeseries{
 		{
 			Labels: map[string]string{
@@ -169,3 +179,160 @@ func TestGetLabels(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, histories, map[model.PodID]*PodHistory{podID: podHistory})
 }
+
+func TestGetEphemeralStorageSamples(t *testing.T) {
+	mockClient := mockPrometheusClient{}
+	historyProvider := newTestHistoryProvider(t, &mockClient, getDefaultPrometheusHistoryProviderConfigForTest())
+	mockClient.On("GetTimeseries", cpuQuery).Return([]Timeseries{}, nil)
+	mockClient.On("GetTimeseries", memoryQuery).Return([]Timeseries{}, nil)
+	mockClient.On("GetTimeseries", ephemeralStorageQuery).Return(
+		[]Timeseries{{
+			Labels: map[string]string{
+				"namespace": "default",
+				"pod_name":  "pod",
+				"name":      "container"},
+			Samples: []Sample{{
+				Value: 54321, Timestamp: time.Unix(1, 0)}}}}, nil)
+	mockClient.On("GetTimeseries", labelsQuery).Return([]Timeseries{}, nil)
+	podID := model.PodID{Namespace: "default", PodName: "pod"}
+	podHistory := &PodHistory{
+		LastLabels: map[string]string{},
+		Samples: map[string][]model.ContainerUsageSample{"container": {{
+			MeasureStart: time.Unix(1, 0),
+			Usage:        model.EphemeralStorageAmountFromBytes(54321),
+			Resource:     model.ResourceEphemeralStorage}}}}
+	histories, err := historyProvider.GetClusterHistory()
+	assert.Nil(t, err)
+	assert.Equal(t, histories, map[model.PodID]*PodHistory{podID: podHistory})
+}
+
+func TestCustomQueries(t *testing.T) {
+	config := getDefaultPrometheusHistoryProviderConfigForTest()
+	config.CPUQuery = "namespace_pod_container:cpu_usage:rate{{.PodSelector}}"
+	config.MemoryQuery = "namespace_pod_container:memory_usage:max{{.PodSelector}}"
+	config.PodLabelsQuery = "kube_pod_labels"
+	mockClient := mockPrometheusClient{}
+	historyProvider := newTestHistoryProvider(t, &mockClient, config)
+	mockClient.On("GetTimeseries", "namespace_pod_container:cpu_usage:rate{job=\"kubernetes-cadvisor\", pod_name=~\".+\", name!=\"POD\", name!=\"\"}").Return(
+		[]Timeseries{{
+			Labels: map[string]string{
+				"namespace": "default",
+				"pod_name":  "pod",
+				"name":      "container"},
+			Samples: []Sample{{
+				Value: 5.5, Timestamp: time.Unix(1, 0)}}}}, nil)
+	mockClient.On("GetTimeseries", "namespace_pod_container:memory_usage:max{job=\"kubernetes-cadvisor\", pod_name=~\".+\", name!=\"POD\", name!=\"\"}").Return([]Timeseries{}, nil)
+	mockClient.On("GetTimeseries", ephemeralStorageQuery).Return([]Timeseries{}, nil)
+	mockClient.On("GetTimeseries", "kube_pod_labels").Return([]Timeseries{}, nil)
+	histories, err := historyProvider.GetClusterHistory()
+	assert.Nil(t, err)
+	assert.Equal(t, map[model.PodID]*PodHistory{{Namespace: "default", PodName: "pod"}: {
+		LastLabels: map[string]string{},
+		Samples: map[string][]model.ContainerUsageSample{"container": {{
+			MeasureStart: time.Unix(1, 0),
+			Usage:        model.CPUAmountFromCores(5.5),
+			Resource:     model.ResourceCPU}}}}}, histories)
+	mockClient.AssertExpectations(t)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(config *PrometheusHistoryProviderConfig)
+	}{
+		{"invalid template", func(config *PrometheusHistoryProviderConfig) { config.CPUQuery = "rate(x{{.PodSelector}[5m])" }},
+		{"unknown template field", func(config *PrometheusHistoryProviderConfig) { config.MemoryQuery = "x{{.Selector}}" }},
+		{"invalid history length", func(config *PrometheusHistoryProviderConfig) { config.HistoryLength = "8 days" }},
+		{"invalid resolution", func(config *PrometheusHistoryProviderConfig) { config.HistoryResolution = "0s" }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := getDefaultPrometheusHistoryProviderConfigForTest()
+			tc.modify(&config)
+			_, err := newPrometheusHistoryProvider(&mockPrometheusClient{}, config)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParsePrometheusDuration(t *testing.T) {
+	for _, tc := range []struct {
+		duration string
+		expected time.Duration
+	}{
+		{"8d", 8 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"1y", 365 * 24 * time.Hour},
+		{"1h", time.Hour},
+		{"1h30m", 90 * time.Minute},
+	} {
+		t.Run(tc.duration, func(t *testing.T) {
+			duration, err := parsePrometheusDuration(tc.duration)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, duration)
+		})
+	}
+}
+
+func TestGetChunks(t *testing.T) {
+	config := getDefaultPrometheusHistoryProviderConfigForTest()
+	config.HistoryLength = "2d"
+	config.QueryChunkLength = 30 * time.Hour
+	historyProvider := newTestHistoryProvider(t, &mockPrometheusClient{}, config)
+	assert.Equal(t, []timeRange{
+		{testNow.Add(-47 * time.Hour), testNow.Add(-18 * time.Hour)},
+		{testNow.Add(-17 * time.Hour), testNow},
+	}, historyProvider.getChunks())
+}
+
+// fakePrometheus serves range queries, returning for every query a sample of
+// a single container at the start of each range.
+type fakePrometheus struct {
+	mutex    sync.Mutex
+	requests []*http.Request
+}
+
+func (f *fakePrometheus) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mutex.Lock()
+	f.requests = append(f.requests, req)
+	f.mutex.Unlock()
+	if req.URL.Path != "/api/v1/query_range" {
+		http.NotFound(w, req)
+		return
+	}
+	fmt.Fprintf(w, `{"status": "success", "data": {"resultType": "matrix", "result": [
+		{"metric": {"namespace": "default", "pod_name": "pod", "name": "container"}, "values": [[%s, "1"]]}]}}`,
+		req.URL.Query().Get("start"))
+}
+
+func TestGetClusterHistoryFromFakePrometheus(t *testing.T) {
+	fake := &fakePrometheus{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	config := getDefaultPrometheusHistoryProviderConfigForTest()
+	config.Client = PrometheusClientConfig{Address: server.URL, Headers: map[string]string{"X-Scope-OrgID": "team-a"}}
+	config.HistoryLength = "3d"
+	config.QueryChunkLength = 24 * time.Hour
+	config.QueryConcurrency = 2
+	historyProvider, err := NewPrometheusHistoryProvider(config)
+	assert.NoError(t, err)
+	historyProvider.(*prometheusHistoryProvider).now = func() time.Time { return testNow }
+
+	histories, err := historyProvider.GetClusterHistory()
+	assert.NoError(t, err)
+	// 4 queries of 3 chunks each.
+	assert.Len(t, fake.requests, 12)
+	for _, req := range fake.requests {
+		assert.Equal(t, "team-a", req.Header.Get("X-Scope-OrgID"))
+		assert.Equal(t, "3600", req.URL.Query().Get("step"))
+	}
+	podHistory := histories[model.PodID{Namespace: "default", PodName: "pod"}]
+	if assert.NotNil(t, podHistory) {
+		var cpuSamples []time.Time
+		for _, sample := range podHistory.Samples["container"] {
+			if sample.Resource == model.ResourceCPU {
+				cpuSamples = append(cpuSamples, sample.MeasureStart)
+			}
+		}
+		assert.Equal(t, []time.Time{testNow.Add(-71 * time.Hour), testNow.Add(-47 * time.Hour), testNow.Add(-23 * time.Hour)}, cpuSamples)
+	}
+}
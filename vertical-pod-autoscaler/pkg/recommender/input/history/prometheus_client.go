@@ -17,9 +17,14 @@ limitations under the License.
 package history
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -30,35 +35,113 @@ var (
 
 // PrometheusClient talks to Prometheus using its HTTP API.
 type PrometheusClient interface {
-	// Given a particular query (that's supposed to return range vectors
-	// in Prometheus terminology), gets the results from Prometheus.
-	GetTimeseries(query string) ([]Timeseries, error)
+	// Given a particular query (that's supposed to return instant vectors
+	// in Prometheus terminology), gets the results from Prometheus
+	// evaluated at every step in the time range.
+	GetTimeseries(query string, start, end time.Time, step time.Duration) ([]Timeseries, error)
 }
 
-type httpGetter interface {
-	Get(url string) (*http.Response, error)
+// PrometheusClientConfig configures the connection to Prometheus.
+type PrometheusClientConfig struct {
+	Address string
+	// Headers added to every request, e.g. a tenant header of a proxy.
+	Headers map[string]string
+	// File holding the bearer token sent in the Authorization header. The
+	// file is read for every request, so the token can be rotated.
+	BearerTokenFile string
+	// Files holding the CA certificate verifying Prometheus and the client
+	// certificate and key, all PEM encoded.
+	CAFile, CertFile, KeyFile string
+	// Disables the verification of the certificate of Prometheus.
+	InsecureSkipVerify bool
+}
+
+type httpDoer interface {
+	Do(req *http.Request) (*http.Response, error)
 }
 
 // An implementation of PrometheusClient.
 type prometheusClient struct {
-	httpClient httpGetter
-	address    string
+	httpClient      httpDoer
+	address         string
+	headers         map[string]string
+	bearerTokenFile string
 }
 
 // NewPrometheusClient constructs a prometheusClient.
-func NewPrometheusClient(httpClient httpGetter, address string) PrometheusClient {
-	return &prometheusClient{httpClient: httpClient, address: address}
+func NewPrometheusClient(config PrometheusClientConfig) (PrometheusClient, error) {
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}}
+	return newPrometheusClient(httpClient, config), nil
+}
+
+func newPrometheusClient(httpClient httpDoer, config PrometheusClientConfig) PrometheusClient {
+	return &prometheusClient{
+		httpClient:      httpClient,
+		address:         config.Address,
+		headers:         config.Headers,
+		bearerTokenFile: config.BearerTokenFile,
+	}
+}
+
+func newTLSConfig(config PrometheusClientConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CAFile != "" {
+		caCert, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read Prometheus CA file: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates in Prometheus CA file %s", config.CAFile)
+		}
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't load Prometheus client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
 }
 
-// Changes Prometheus address and query into a full escaped URL to call.
-func getUrlWithQuery(address, query string) (string, error) {
+// ParseHeaders parses a comma separated list of name=value pairs, e.g.
+// "X-Scope-OrgID=team-a,X-Other=b".
+func ParseHeaders(headers string) (map[string]string, error) {
+	result := make(map[string]string)
+	if headers == "" {
+		return result, nil
+	}
+	for _, header := range strings.Split(headers, ",") {
+		nameValue := strings.SplitN(header, "=", 2)
+		if len(nameValue) != 2 || strings.TrimSpace(nameValue[0]) == "" {
+			return nil, fmt.Errorf("invalid header %q, expected name=value", header)
+		}
+		result[strings.TrimSpace(nameValue[0])] = strings.TrimSpace(nameValue[1])
+	}
+	return result, nil
+}
+
+// Changes Prometheus address, query and its time range into a full escaped
+// URL to call.
+func getUrlWithQuery(address, query string, start, end time.Time, step time.Duration) (string, error) {
 	url, err := url.Parse(address)
 	if err != nil {
 		return "", err
 	}
-	url.Path = "api/v1/query"
+	url.Path = strings.TrimSuffix(url.Path, "/") + "/api/v1/query_range"
 	queryValues := url.Query()
 	queryValues.Set("query", query)
+	queryValues.Set("start", strconv.FormatInt(start.Unix(), 10))
+	queryValues.Set("end", strconv.FormatInt(end.Unix(), 10))
+	queryValues.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
 	url.RawQuery = queryValues.Encode()
 	return url.String(), nil
 }
@@ -76,18 +159,41 @@ func retry(callback func() error, attempts int, delay time.Duration) error {
 	}
 }
 
-func (c *prometheusClient) GetTimeseries(query string) ([]Timeseries, error) {
-	url, err := getUrlWithQuery(c.address, query)
+func (c *prometheusClient) newRequest(url string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	if c.bearerTokenFile != "" {
+		token, err := ioutil.ReadFile(c.bearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read Prometheus bearer token file: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	return req, nil
+}
+
+func (c *prometheusClient) GetTimeseries(query string, start, end time.Time, step time.Duration) ([]Timeseries, error) {
+	url, err := getUrlWithQuery(c.address, query, start, end, step)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't construct url to Prometheus: %v", err)
 	}
 	var resp *http.Response
 	err = retry(func() error {
-		resp, err = c.httpClient.Get(url)
+		req, err := c.newRequest(url)
+		if err != nil {
+			return err
+		}
+		resp, err = c.httpClient.Do(req)
 		if err != nil {
 			return fmt.Errorf("error getting data from Prometheus: %v", err)
 		}
 		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
 			return fmt.Errorf("bad HTTP status: %v %s", resp.StatusCode, http.StatusText(resp.StatusCode))
 		}
 		return nil
@@ -95,5 +201,6 @@ func (c *prometheusClient) GetTimeseries(query string) ([]Timeseries, error) {
 	if err != nil {
 		return nil, fmt.Errorf("retrying GetTimeseries unsuccessful: %v", err)
 	}
+	defer resp.Body.Close()
 	return decodeTimeseriesFromResponse(resp.Body)
 }
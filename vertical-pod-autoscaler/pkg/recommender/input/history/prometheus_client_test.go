@@ -17,8 +17,13 @@ limitations under the License.
 package history
 
 import (
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -35,12 +40,17 @@ const (
 		        "result": []}}`
 )
 
-type mockHTTPGetter struct {
+var (
+	testStart = time.Unix(3600, 0)
+	testEnd   = time.Unix(7200, 0)
+)
+
+type mockHTTPDoer struct {
 	mock.Mock
 }
 
-func (m mockHTTPGetter) Get(url string) (*http.Response, error) {
-	args := m.Called(url)
+func (m mockHTTPDoer) Do(req *http.Request) (*http.Response, error) {
+	args := m.Called(req.URL.String())
 	var returnArg http.Response
 	if args.Get(0) != nil {
 		returnArg = args.Get(0).(http.Response)
@@ -63,29 +73,37 @@ func newReaderPseudoCloser(s string) readerPseudoCloser {
 
 func TestUrl(t *testing.T) {
 	retryDelay = time.Hour
-	mockGetter := mockHTTPGetter{}
-	client := NewPrometheusClient(&mockGetter, "https://1.1.1.1")
-	mockGetter.On("Get", "https://1.1.1.1/api/v1/query?query=up%7Ba%3Db%7D%5B2d%5D").Times(1).Return(
+	mockDoer := mockHTTPDoer{}
+	client := newPrometheusClient(&mockDoer, PrometheusClientConfig{Address: "https://1.1.1.1"})
+	mockDoer.On("Do", "https://1.1.1.1/api/v1/query_range?end=7200&query=up%7Ba%3Db%7D&start=3600&step=60").Times(1).Return(
 		http.Response{
 			StatusCode: http.StatusOK,
 			Body:       newReaderPseudoCloser(correctResponse)}, nil)
-	tss, err := client.GetTimeseries("up{a=b}[2d]")
+	tss, err := client.GetTimeseries("up{a=b}", testStart, testEnd, time.Minute)
 	assert.Nil(t, err)
 	assert.NotNil(t, tss)
 	assert.Empty(t, tss)
 }
 
+func TestUrlWithPath(t *testing.T) {
+	url, err := getUrlWithQuery("http://thanos/prometheus/", "up", testStart, testEnd, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://thanos/prometheus/api/v1/query_range?end=7200&query=up&start=3600&step=3600", url)
+}
+
 func TestSuccessfulRetry(t *testing.T) {
 	retryDelay = 100 * time.Millisecond
-	mockGetter := mockHTTPGetter{}
-	client := NewPrometheusClient(&mockGetter, "http://bla.com")
-	mockGetter.On("Get", mock.AnythingOfType("string")).Times(1).Return(
-		http.Response{StatusCode: http.StatusInternalServerError}, nil)
-	mockGetter.On("Get", mock.AnythingOfType("string")).Times(1).Return(
+	mockDoer := mockHTTPDoer{}
+	client := newPrometheusClient(&mockDoer, PrometheusClientConfig{Address: "http://bla.com"})
+	mockDoer.On("Do", mock.AnythingOfType("string")).Times(1).Return(
+		http.Response{
+			StatusCode: http.StatusInternalServerError,
+			Body:       newReaderPseudoCloser("")}, nil)
+	mockDoer.On("Do", mock.AnythingOfType("string")).Times(1).Return(
 		http.Response{
 			StatusCode: http.StatusOK,
 			Body:       newReaderPseudoCloser(correctResponse)}, nil)
-	tss, err := client.GetTimeseries("up")
+	tss, err := client.GetTimeseries("up", testStart, testEnd, time.Minute)
 	assert.Nil(t, err)
 	assert.NotNil(t, tss)
 	assert.Empty(t, tss)
@@ -93,10 +111,68 @@ func TestSuccessfulRetry(t *testing.T) {
 
 func TestUnsuccessfulRetries(t *testing.T) {
 	retryDelay = 10 * time.Millisecond
-	mockGetter := mockHTTPGetter{}
-	client := NewPrometheusClient(&mockGetter, "http://bla.com")
-	mockGetter.On("Get", mock.AnythingOfType("string")).Times(numRetries).Return(
-		http.Response{StatusCode: http.StatusInternalServerError}, nil)
-	_, err := client.GetTimeseries("up")
+	mockDoer := mockHTTPDoer{}
+	client := newPrometheusClient(&mockDoer, PrometheusClientConfig{Address: "http://bla.com"})
+	mockDoer.On("Do", mock.AnythingOfType("string")).Times(numRetries).Return(
+		http.Response{
+			StatusCode: http.StatusInternalServerError,
+			Body:       newReaderPseudoCloser("")}, nil)
+	_, err := client.GetTimeseries("up", testStart, testEnd, time.Minute)
 	assert.NotNil(t, err)
 }
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders("X-Scope-OrgID=team-a, X-Token=a=b")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"X-Scope-OrgID": "team-a", "X-Token": "a=b"}, headers)
+	headers, err = ParseHeaders("")
+	assert.NoError(t, err)
+	assert.Empty(t, headers)
+	_, err = ParseHeaders("X-Scope-OrgID")
+	assert.Error(t, err)
+}
+
+func writeTempFile(t *testing.T, dir, name string, content []byte) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, ioutil.WriteFile(path, content, 0600))
+	return path
+}
+
+func TestClientAuthenticatesToFakePrometheus(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, correctResponse)
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "prometheus-client")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caFile := writeTempFile(t, dir, "ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	tokenFile := writeTempFile(t, dir, "token", []byte("secret\n"))
+
+	retryDelay = 10 * time.Millisecond
+	client, err := NewPrometheusClient(PrometheusClientConfig{Address: server.URL, CAFile: caFile, BearerTokenFile: tokenFile})
+	assert.NoError(t, err)
+	_, err = client.GetTimeseries("up", testStart, testEnd, time.Minute)
+	assert.NoError(t, err)
+
+	// Without the CA the certificate of the server is not trusted.
+	client, err = NewPrometheusClient(PrometheusClientConfig{Address: server.URL, BearerTokenFile: tokenFile})
+	assert.NoError(t, err)
+	_, err = client.GetTimeseries("up", testStart, testEnd, time.Minute)
+	assert.Error(t, err)
+
+	// Without the token the request is not authorized.
+	client, err = NewPrometheusClient(PrometheusClientConfig{Address: server.URL, InsecureSkipVerify: true})
+	assert.NoError(t, err)
+	_, err = client.GetTimeseries("up", testStart, testEnd, time.Minute)
+	assert.Error(t, err)
+}
+
+func TestInvalidCAFile(t *testing.T) {
+	_, err := NewPrometheusClient(PrometheusClientConfig{Address: "https://prometheus", CAFile: "/nonexistent/ca.crt"})
+	assert.Error(t, err)
+}
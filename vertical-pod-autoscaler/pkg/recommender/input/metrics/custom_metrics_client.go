@@ -0,0 +1,118 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// AllObjects can be passed as the object name to CustomMetricsClient to get
+// the metric for all objects matching the selector.
+const AllObjects = "*"
+
+// CustomMetricObjectReference identifies the object described by a custom metric.
+type CustomMetricObjectReference struct {
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	APIVersion string `json:"apiVersion"`
+}
+
+// CustomMetricValue is the value of a custom metric for a single object, as
+// served by the custom.metrics.k8s.io/v1beta1 API.
+type CustomMetricValue struct {
+	// Object the metric describes.
+	DescribedObject CustomMetricObjectReference `json:"describedObject"`
+	// Name of the metric.
+	MetricName string `json:"metricName"`
+	// Time at which the metric was produced.
+	Timestamp metav1.Time `json:"timestamp"`
+	// Value of the metric, serialized as a resource quantity.
+	Value string `json:"value"`
+}
+
+// CustomMetricValueList is a list of values of a custom metric.
+type CustomMetricValueList struct {
+	Items []CustomMetricValue `json:"items"`
+}
+
+// CustomMetricsClient provides access to metrics served by the custom metrics API.
+type CustomMetricsClient interface {
+	// GetObjectsMetric returns the values of the metric for objects of the
+	// given resource (e.g. "pods", "services") in the namespace. The object
+	// name can be AllObjects, in which case the objects are filtered by the
+	// selector.
+	GetObjectsMetric(namespace, resource, name string, selector labels.Selector, metricName string) (*CustomMetricValueList, error)
+}
+
+type customMetricsClient struct {
+	client rest.Interface
+}
+
+// CustomMetricsGroupVersion is the version of the custom metrics API used by CustomMetricsClient.
+var CustomMetricsGroupVersion = schema.GroupVersion{Group: "custom.metrics.k8s.io", Version: "v1beta1"}
+
+// NewCustomMetricsClient creates new instance of CustomMetricsClient. It
+// requires a REST client for the custom.metrics.k8s.io/v1beta1 API group.
+func NewCustomMetricsClient(client rest.Interface) CustomMetricsClient {
+	return &customMetricsClient{client: client}
+}
+
+// NewCustomMetricsClientForConfig creates new instance of CustomMetricsClient
+// talking to the custom metrics API server given by the config.
+func NewCustomMetricsClientForConfig(config *rest.Config) (CustomMetricsClient, error) {
+	configShallowCopy := *config
+	configShallowCopy.GroupVersion = &CustomMetricsGroupVersion
+	configShallowCopy.APIPath = "/apis"
+	configShallowCopy.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: scheme.Codecs}
+	if configShallowCopy.UserAgent == "" {
+		configShallowCopy.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+	client, err := rest.RESTClientFor(&configShallowCopy)
+	if err != nil {
+		return nil, err
+	}
+	return NewCustomMetricsClient(client), nil
+}
+
+func (c *customMetricsClient) GetObjectsMetric(namespace, resource, name string, selector labels.Selector, metricName string) (*CustomMetricValueList, error) {
+	request := c.client.Get().
+		Namespace(namespace).
+		Resource(resource).
+		Name(name).
+		SubResource(metricName)
+	if selector != nil && !selector.Empty() {
+		request = request.Param("labelSelector", selector.String())
+	}
+	data, err := request.Do().Raw()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get custom metric %s for %s %s/%s: %v", metricName, resource, namespace, name, err)
+	}
+	metrics := &CustomMetricValueList{}
+	if err := json.Unmarshal(data, metrics); err != nil {
+		return nil, fmt.Errorf("cannot decode custom metric %s for %s %s/%s: %v", metricName, resource, namespace, name, err)
+	}
+	return metrics, nil
+}
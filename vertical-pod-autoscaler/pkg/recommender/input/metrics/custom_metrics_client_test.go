@@ -0,0 +1,80 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+)
+
+const customMetricsResponse = `{
+  "kind": "MetricValueList",
+  "apiVersion": "custom.metrics.k8s.io/v1beta1",
+  "metadata": {"selfLink": "/apis/custom.metrics.k8s.io/v1beta1/namespaces/shop/pods/%2A/response_time"},
+  "items": [
+    {
+      "describedObject": {"kind": "Pod", "namespace": "shop", "name": "front-1", "apiVersion": "/v1"},
+      "metricName": "response_time",
+      "timestamp": "2019-03-01T10:00:00Z",
+      "value": "250m"
+    }
+  ]
+}`
+
+func newTestCustomMetricsClient(t *testing.T, handler http.HandlerFunc) (CustomMetricsClient, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	client, err := NewCustomMetricsClientForConfig(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+	return client, server
+}
+
+func TestGetObjectsMetric(t *testing.T) {
+	var requestedPath, requestedSelector string
+	client, server := newTestCustomMetricsClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		requestedSelector = r.URL.Query().Get("labelSelector")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(customMetricsResponse))
+	})
+	defer server.Close()
+
+	selector := labels.SelectorFromSet(labels.Set{"app": "front"})
+	metrics, err := client.GetObjectsMetric("shop", "pods", AllObjects, selector, "response_time")
+	assert.NoError(t, err)
+	assert.Equal(t, "/apis/custom.metrics.k8s.io/v1beta1/namespaces/shop/pods/*/response_time", requestedPath)
+	assert.Equal(t, "app=front", requestedSelector)
+	if assert.Len(t, metrics.Items, 1) {
+		assert.Equal(t, "front-1", metrics.Items[0].DescribedObject.Name)
+		assert.Equal(t, "250m", metrics.Items[0].Value)
+		assert.Equal(t, int64(1551434400), metrics.Items[0].Timestamp.Unix())
+	}
+}
+
+func TestGetObjectsMetricError(t *testing.T) {
+	client, server := newTestCustomMetricsClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer server.Close()
+
+	_, err := client.GetObjectsMetric("shop", "services", "front", nil, "response_time")
+	assert.Error(t, err)
+}
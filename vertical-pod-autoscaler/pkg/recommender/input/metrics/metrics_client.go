@@ -107,8 +107,13 @@ func calculateUsage(containerUsage k8sapiv1.ResourceList) model.Resources {
 	memoryQuantity := containerUsage[k8sapiv1.ResourceMemory]
 	memoryBytes := memoryQuantity.Value()
 
-	return model.Resources{
+	usage := model.Resources{
 		model.ResourceCPU:    model.ResourceAmount(cpuMillicores),
 		model.ResourceMemory: model.ResourceAmount(memoryBytes),
 	}
+	// Ephemeral storage is only reported by some metrics servers.
+	if ephemeralStorageQuantity, found := containerUsage[k8sapiv1.ResourceEphemeralStorage]; found {
+		usage[model.ResourceEphemeralStorage] = model.ResourceAmount(ephemeralStorageQuantity.Value())
+	}
+	return usage
 }
@@ -0,0 +1,150 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oom
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+)
+
+// DefaultNodeOomEventReason is the reason of the events node-problem-detector
+// reports kernel OOM kills with.
+const DefaultNodeOomEventReason = "OOMKilling"
+
+// NodeOomKill is an OOM kill of a container reported by the node it runs on.
+type NodeOomKill struct {
+	Timestamp time.Time
+	// PodUID is the UID of the pod of the killed container.
+	PodUID types.UID
+	// ContainerID is the ID of the killed container in the container
+	// runtime, without the runtime prefix.
+	ContainerID string
+}
+
+// NodeOomEventParser extracts OOM kills from the events about nodes. Other
+// reporters of OOM kills are supported by implementing it.
+type NodeOomEventParser interface {
+	// EventReason returns the reason of the events reporting OOM kills.
+	EventReason() string
+	// Parse returns the OOM kill reported in the event. It returns false if
+	// the event doesn't identify the killed container.
+	Parse(event *apiv1.Event) (NodeOomKill, bool)
+}
+
+// The memory cgroup of the killed task identifies the pod and the container.
+// With the cgroupfs driver it is /kubepods/<qos>/pod<uid>/<container id>.
+// With the systemd driver it is .../kubepods-<qos>-pod<uid>.slice/<runtime>-<container id>.scope,
+// where the dashes in the pod UID are replaced by underscores.
+var taskMemcgRegexp = regexp.MustCompile(
+	`task_memcg=\S*pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})(?:\.slice)?/(?:[a-z]+-)?([0-9a-f]{64})`)
+
+type kernelOomEventParser struct {
+	reason string
+}
+
+// NewKernelOomEventParser returns a parser of the events with the given
+// reason reporting the kernel OOM killer log line, e.g. the events of
+// node-problem-detector's kernel monitor. The line must contain the memory
+// cgroup of the killed task, which is logged by Linux 4.19 and newer.
+func NewKernelOomEventParser(reason string) NodeOomEventParser {
+	return &kernelOomEventParser{reason: reason}
+}
+
+func (p *kernelOomEventParser) EventReason() string {
+	return p.reason
+}
+
+func (p *kernelOomEventParser) Parse(event *apiv1.Event) (NodeOomKill, bool) {
+	if event.Reason != p.reason || event.InvolvedObject.Kind != "Node" {
+		return NodeOomKill{}, false
+	}
+	match := taskMemcgRegexp.FindStringSubmatch(event.Message)
+	if match == nil {
+		return NodeOomKill{}, false
+	}
+	return NodeOomKill{
+		Timestamp:   eventTime(event),
+		PodUID:      types.UID(strings.Replace(match[1], "_", "-", -1)),
+		ContainerID: match[2],
+	}, true
+}
+
+func eventTime(event *apiv1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time.UTC()
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time.UTC()
+	}
+	return event.CreationTimestamp.Time.UTC()
+}
+
+// runtimeContainerID strips the runtime prefix, e.g. docker://, from the
+// container ID in a container status.
+func runtimeContainerID(containerID string) string {
+	if i := strings.Index(containerID, "://"); i >= 0 {
+		return containerID[i+3:]
+	}
+	return containerID
+}
+
+// findKilledContainer returns the name of the container of the pod with the
+// given runtime ID. The ID is either the ID of the running container or, if
+// it was restarted, of the previous one.
+func findKilledContainer(pod *apiv1.Pod, containerID string) (string, bool) {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if runtimeContainerID(containerStatus.ContainerID) == containerID {
+			return containerStatus.Name, true
+		}
+		if terminated := containerStatus.LastTerminationState.Terminated; terminated != nil &&
+			runtimeContainerID(terminated.ContainerID) == containerID {
+			return containerStatus.Name, true
+		}
+	}
+	return "", false
+}
+
+func parseNodeOomKill(kill NodeOomKill, pod *apiv1.Pod) (OomInfo, bool) {
+	if pod == nil || pod.UID != kill.PodUID {
+		return OomInfo{}, false
+	}
+	containerName, found := findKilledContainer(pod, kill.ContainerID)
+	if !found {
+		return OomInfo{}, false
+	}
+	spec := findSpec(containerName, pod.Spec.Containers)
+	if spec == nil {
+		return OomInfo{}, false
+	}
+	memory := spec.Resources.Requests[apiv1.ResourceMemory]
+	return OomInfo{
+		Timestamp: kill.Timestamp,
+		Memory:    model.ResourceAmount(memory.Value()),
+		ContainerID: model.ContainerID{
+			PodID: model.PodID{
+				Namespace: pod.Namespace,
+				PodName:   pod.Name,
+			},
+			ContainerName: containerName,
+		},
+	}, true
+}
@@ -17,7 +17,9 @@ limitations under the License.
 package oom
 
 import (
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	apiv1 "k8s.io/api/core/v1"
@@ -35,22 +37,44 @@ type OomInfo struct {
 	ContainerID model.ContainerID
 }
 
+// oomDeduplicationWindow is the time within which OOMs of the same
+// container reported by different sources are counted once, unless the
+// sources report different runtime IDs of the killed container.
+const oomDeduplicationWindow = time.Minute
+
+// observedOom is an OOM recently passed to the channel.
+type observedOom struct {
+	timestamp time.Time
+	// runtimeContainerID is the ID of the killed container in the
+	// container runtime, empty if the source doesn't report it.
+	runtimeContainerID string
+}
+
 // Observer can observe pod resource update and collect OOM events.
 type Observer interface {
 	GetObservedOomsChannel() chan OomInfo
 	OnEvent(*apiv1.Event)
+	// OnNodeOomKill translates an OOM kill reported by a node to OomInfo.
+	// The pod is the pod with the UID of the killed container, nil if it
+	// is not known.
+	OnNodeOomKill(kill NodeOomKill, pod *apiv1.Pod)
 	cache.ResourceEventHandler
 }
 
 // observer can observe pod resource update and collect OOM events.
 type observer struct {
 	observedOomsChannel chan OomInfo
+	// The same OOM can be observed in the pod status, in an eviction event
+	// and in a node event. Guards against recording it more than once.
+	mutex      sync.Mutex
+	recentOoms map[model.ContainerID][]observedOom
 }
 
 // NewObserver returns new instance of the observer.
 func NewObserver() *observer {
 	return &observer{
 		observedOomsChannel: make(chan OomInfo, 5000),
+		recentOoms:          make(map[model.ContainerID][]observedOom),
 	}
 }
 
@@ -58,6 +82,57 @@ func (o *observer) GetObservedOomsChannel() chan OomInfo {
 	return o.observedOomsChannel
 }
 
+// isDuplicate returns true if the OOM was already observed, and remembers it
+// otherwise. OOMs with the same runtime container ID are the same kill. A
+// container restarted in a fast crash loop can be killed several times a
+// minute, so OOMs with different runtime IDs are different kills. If the ID
+// is not known, OOMs of the container within oomDeduplicationWindow are
+// considered the same.
+func (o *observer) isDuplicate(oomInfo OomInfo, runtimeContainerID string) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	for containerID, observedOoms := range o.recentOoms {
+		recent := observedOoms[:0]
+		for _, observed := range observedOoms {
+			if oomInfo.Timestamp.Sub(observed.timestamp) <= oomDeduplicationWindow {
+				recent = append(recent, observed)
+			}
+		}
+		if len(recent) == 0 {
+			delete(o.recentOoms, containerID)
+		} else {
+			o.recentOoms[containerID] = recent
+		}
+	}
+	for _, observed := range o.recentOoms[oomInfo.ContainerID] {
+		if runtimeContainerID != "" && observed.runtimeContainerID != "" {
+			if runtimeContainerID == observed.runtimeContainerID {
+				return true
+			}
+			continue
+		}
+		diff := oomInfo.Timestamp.Sub(observed.timestamp)
+		if diff < oomDeduplicationWindow && diff > -oomDeduplicationWindow {
+			return true
+		}
+	}
+	o.recentOoms[oomInfo.ContainerID] = append(o.recentOoms[oomInfo.ContainerID], observedOom{
+		timestamp:          oomInfo.Timestamp,
+		runtimeContainerID: runtimeContainerID,
+	})
+	return false
+}
+
+// observe passes the OOM to the channel unless it was already observed. The
+// runtime container ID is empty if the source doesn't report it.
+func (o *observer) observe(oomInfo OomInfo, runtimeContainerID string) {
+	if o.isDuplicate(oomInfo, runtimeContainerID) {
+		klog.V(3).Infof("Skipping OOM of %v at %v, already observed", oomInfo.ContainerID, oomInfo.Timestamp)
+		return
+	}
+	o.observedOomsChannel <- oomInfo
+}
+
 func parseEvictionEvent(event *apiv1.Event) []OomInfo {
 	if event.Reason != "Evicted" ||
 		event.InvolvedObject.Kind != "Pod" {
@@ -109,8 +184,50 @@ func parseEvictionEvent(event *apiv1.Event) []OomInfo {
 func (o *observer) OnEvent(event *apiv1.Event) {
 	klog.V(1).Infof("OOM Observer processing event: %+v", event)
 	for _, oomInfo := range parseEvictionEvent(event) {
-		o.observedOomsChannel <- oomInfo
+		o.observe(oomInfo, "")
+	}
+}
+
+// OnNodeOomKill translates an OOM kill reported by a node to OomInfo.
+func (o *observer) OnNodeOomKill(kill NodeOomKill, pod *apiv1.Pod) {
+	oomInfo, ok := parseNodeOomKill(kill, pod)
+	if !ok {
+		klog.V(3).Infof("Cannot find container %v of pod %v killed by OOM", kill.ContainerID, kill.PodUID)
+		return
 	}
+	o.observe(oomInfo, kill.ContainerID)
+}
+
+// Message of a pod evicted by the kubelet, with a sentence for each
+// container using more than its request of the starved resource.
+var evictionMessageRegexp = regexp.MustCompile(`Container (\S+) was using (\S+), which exceeds its request of \S+\.`)
+
+// parseEvictedPod translates the eviction of the pod for node memory
+// pressure to OomInfo of the containers using more than their requests.
+func parseEvictedPod(pod *apiv1.Pod, timestamp time.Time) []OomInfo {
+	if pod.Status.Reason != "Evicted" || !strings.HasPrefix(pod.Status.Message, "The node was low on resource: memory.") {
+		return []OomInfo{}
+	}
+	result := []OomInfo{}
+	for _, match := range evictionMessageRegexp.FindAllStringSubmatch(pod.Status.Message, -1) {
+		memory, err := resource.ParseQuantity(match[2])
+		if err != nil {
+			klog.Errorf("Cannot parse resource quantity in eviction message %v. Error: %v", match[2], err)
+			continue
+		}
+		result = append(result, OomInfo{
+			Timestamp: timestamp,
+			Memory:    model.ResourceAmount(memory.Value()),
+			ContainerID: model.ContainerID{
+				PodID: model.PodID{
+					Namespace: pod.Namespace,
+					PodName:   pod.Name,
+				},
+				ContainerName: match[1],
+			},
+		})
+	}
+	return result
 }
 
 func findStatus(name string, containerStatuses []apiv1.ContainerStatus) *apiv1.ContainerStatus {
@@ -167,11 +284,17 @@ func (o *observer) OnUpdate(oldObj, newObj interface{}) {
 							ContainerName: containerStatus.Name,
 						},
 					}
-					o.observedOomsChannel <- oomInfo
+					o.observe(oomInfo, runtimeContainerID(containerStatus.LastTerminationState.Terminated.ContainerID))
 				}
 			}
 		}
 	}
+
+	if oldPod.Status.Reason != "Evicted" {
+		for _, oomInfo := range parseEvictedPod(newPod, time.Now().UTC()) {
+			o.observe(oomInfo, "")
+		}
+	}
 }
 
 // OnDelete is Noop
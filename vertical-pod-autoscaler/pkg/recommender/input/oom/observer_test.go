@@ -263,3 +263,234 @@ reason: Evicted
 		assert.Equal(t, tc.oomInfo, oomInfoArray)
 	}
 }
+
+const cgroupfsOomEventYaml = `
+apiVersion: v1
+kind: Event
+metadata:
+  name: node1.oom
+  namespace: default
+  creationTimestamp: 2018-02-23T13:38:48Z
+involvedObject:
+  kind: Node
+  name: node1
+reason: OOMKilling
+lastTimestamp: 2018-02-23T13:38:50Z
+message: "oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=0123,mems_allowed=0,oom_memcg=/kubepods/burstable/pod4d6d2c8b-3e4f-11e9-9f3a-42010a800002/0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef,task_memcg=/kubepods/burstable/pod4d6d2c8b-3e4f-11e9-9f3a-42010a800002/0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef,task=app,pid=1234,uid=0"
+`
+
+const systemdOomEventYaml = `
+apiVersion: v1
+kind: Event
+metadata:
+  name: node1.oom
+  namespace: default
+  creationTimestamp: 2018-02-23T13:38:48Z
+involvedObject:
+  kind: Node
+  name: node1
+reason: OOMKilling
+message: "oom-kill:constraint=CONSTRAINT_MEMCG,task_memcg=/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod4d6d2c8b_3e4f_11e9_9f3a_42010a800002.slice/docker-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef.scope,task=app,pid=1234,uid=0"
+`
+
+const legacyOomEventYaml = `
+apiVersion: v1
+kind: Event
+metadata:
+  name: node1.oom
+  namespace: default
+involvedObject:
+  kind: Node
+  name: node1
+reason: OOMKilling
+message: "Killed process 1234 (app) total-vm:1234kB, anon-rss:1234kB, file-rss:0kB"
+`
+
+const oomKilledPodYaml = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: Pod1
+  namespace: mockNamespace
+  uid: 4d6d2c8b-3e4f-11e9-9f3a-42010a800002
+spec:
+  containers:
+  - name: Name11
+    resources:
+      requests:
+        memory: "1024"
+status:
+  containerStatuses:
+  - name: Name11
+    restartCount: 1
+    containerID: docker://fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210
+    lastState:
+      terminated:
+        containerID: docker://0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef
+        finishedAt: 2018-02-23T13:38:48Z
+        reason: OOMKilled
+`
+
+func TestKernelOomEventParser(t *testing.T) {
+	timestamp := func(str string) time.Time {
+		timestamp, err := time.Parse(time.RFC3339, str)
+		assert.NoError(t, err)
+		return timestamp.UTC()
+	}
+	testCases := []struct {
+		name         string
+		event        string
+		reason       string
+		expectedKill NodeOomKill
+		expectedOk   bool
+	}{
+		{
+			name:   "cgroupfs driver",
+			event:  cgroupfsOomEventYaml,
+			reason: DefaultNodeOomEventReason,
+			expectedKill: NodeOomKill{
+				Timestamp:   timestamp("2018-02-23T13:38:50Z"),
+				PodUID:      "4d6d2c8b-3e4f-11e9-9f3a-42010a800002",
+				ContainerID: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			},
+			expectedOk: true,
+		},
+		{
+			name:   "systemd driver",
+			event:  systemdOomEventYaml,
+			reason: DefaultNodeOomEventReason,
+			expectedKill: NodeOomKill{
+				Timestamp:   timestamp("2018-02-23T13:38:48Z"),
+				PodUID:      "4d6d2c8b-3e4f-11e9-9f3a-42010a800002",
+				ContainerID: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			},
+			expectedOk: true,
+		},
+		{
+			name:   "no memory cgroup",
+			event:  legacyOomEventYaml,
+			reason: DefaultNodeOomEventReason,
+		},
+		{
+			name:   "other reason",
+			event:  cgroupfsOomEventYaml,
+			reason: "KernelOOM",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			event, err := newEvent(tc.event)
+			assert.NoError(t, err)
+			kill, ok := NewKernelOomEventParser(tc.reason).Parse(event)
+			assert.Equal(t, tc.expectedOk, ok)
+			assert.Equal(t, tc.expectedKill, kill)
+		})
+	}
+}
+
+func TestNodeOomKillReceived(t *testing.T) {
+	pod, err := newPod(oomKilledPodYaml)
+	assert.NoError(t, err)
+	timestamp := time.Unix(1000, 0).UTC()
+	kill := NodeOomKill{
+		Timestamp:   timestamp,
+		PodUID:      pod.UID,
+		ContainerID: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+	observer := NewObserver()
+	observer.OnNodeOomKill(kill, pod)
+
+	info := <-observer.observedOomsChannel
+	assert.Equal(t, "mockNamespace", info.ContainerID.PodID.Namespace)
+	assert.Equal(t, "Pod1", info.ContainerID.PodID.PodName)
+	assert.Equal(t, "Name11", info.ContainerID.ContainerName)
+	assert.Equal(t, model.ResourceAmount(1024), info.Memory)
+	assert.Equal(t, timestamp, info.Timestamp)
+
+	// Kills of unknown pods or containers are skipped.
+	unknownContainer := kill
+	unknownContainer.ContainerID = "unknown"
+	observer.OnNodeOomKill(unknownContainer, pod)
+	observer.OnNodeOomKill(kill, nil)
+	assert.Empty(t, observer.observedOomsChannel)
+}
+
+func TestOOMDeduplicated(t *testing.T) {
+	p1, err := newPod(pod1Yaml)
+	assert.NoError(t, err)
+	p2, err := newPod(pod2Yaml)
+	assert.NoError(t, err)
+	pod, err := newPod(oomKilledPodYaml)
+	assert.NoError(t, err)
+	finishedAt, err := time.Parse(time.RFC3339, "2018-02-23T13:38:48Z")
+	assert.NoError(t, err)
+
+	observer := NewObserver()
+	observer.OnUpdate(p1, p2)
+	// The same OOM reported by the node.
+	observer.OnNodeOomKill(NodeOomKill{
+		Timestamp:   finishedAt.Add(2 * time.Second),
+		PodUID:      pod.UID,
+		ContainerID: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}, pod)
+	// Another OOM of the container.
+	observer.OnNodeOomKill(NodeOomKill{
+		Timestamp:   finishedAt.Add(10 * time.Minute),
+		PodUID:      pod.UID,
+		ContainerID: "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210",
+	}, pod)
+
+	assert.Len(t, observer.observedOomsChannel, 2)
+}
+
+func TestOOMDeduplicatedByRuntimeContainerID(t *testing.T) {
+	pod, err := newPod(oomKilledPodYaml)
+	assert.NoError(t, err)
+	restarted := pod.DeepCopy()
+	restarted.Status.ContainerStatuses[0].RestartCount = 2
+	restarted.Status.ContainerStatuses[0].LastTerminationState.Terminated.ContainerID = restarted.Status.ContainerStatuses[0].ContainerID
+	restarted.Status.ContainerStatuses[0].LastTerminationState.Terminated.FinishedAt.Time = pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.FinishedAt.Add(10 * time.Second)
+	previous := pod.DeepCopy()
+	previous.Status.ContainerStatuses[0].RestartCount = 0
+
+	observer := NewObserver()
+	// Two kills of a container in a fast crash loop.
+	observer.OnUpdate(previous, pod)
+	observer.OnUpdate(pod, restarted)
+	// The same kills reported by the node.
+	for containerID, killedPod := range map[string]*v1.Pod{
+		"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef": pod,
+		"fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210": restarted,
+	} {
+		observer.OnNodeOomKill(NodeOomKill{
+			Timestamp:   pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.FinishedAt.Add(5 * time.Second),
+			PodUID:      pod.UID,
+			ContainerID: containerID,
+		}, killedPod)
+	}
+
+	assert.Len(t, observer.observedOomsChannel, 2)
+}
+
+func TestEvictedPodReceived(t *testing.T) {
+	p1, err := newPod(pod1Yaml)
+	assert.NoError(t, err)
+	evicted := p1.DeepCopy()
+	evicted.Status.Phase = v1.PodFailed
+	evicted.Status.Reason = "Evicted"
+	evicted.Status.Message = "The node was low on resource: memory. Container Name11 was using 2048Ki, which exceeds its request of 1Ki. "
+
+	observer := NewObserver()
+	observer.OnUpdate(p1, evicted)
+	assert.Len(t, observer.observedOomsChannel, 1)
+	info := <-observer.observedOomsChannel
+	assert.Equal(t, "Name11", info.ContainerID.ContainerName)
+	assert.Equal(t, model.ResourceAmount(2048*1024), info.Memory)
+
+	// Already evicted pod and evictions for other resources are skipped.
+	observer.OnUpdate(evicted, evicted)
+	diskPressure := evicted.DeepCopy()
+	diskPressure.Status.Message = "The node was low on resource: ephemeral-storage. Container Name11 was using 2048Ki, which exceeds its request of 0. "
+	observer.OnUpdate(p1, diskPressure)
+	assert.Empty(t, observer.observedOomsChannel)
+}
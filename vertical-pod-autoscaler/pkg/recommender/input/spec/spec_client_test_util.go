@@ -44,6 +44,14 @@ metadata:
   labels:
     Pod1LabelKey: Pod1LabelValue
 spec:
+  initContainers:
+  - name: Name10
+    image: Name10Image
+    resources:
+      requests:
+        memory: "256Mi"
+        cpu: "250m"
+        ephemeral-storage: "1Gi"
   containers:
   - name: Name11
     image: Name11Image
@@ -113,7 +121,11 @@ func newSpecClientTestCase() *specClientTestCase {
 	containerSpec21 := newTestContainerSpec(podID2, "Name21", 2000, 2048*1024*1024)
 	containerSpec22 := newTestContainerSpec(podID2, "Name22", 4000, 4096*1024*1024)
 
+	initContainerSpec10 := newTestContainerSpec(podID1, "Name10", 250, 256*1024*1024)
+	initContainerSpec10.Request[model.ResourceEphemeralStorage] = model.ResourceAmount(1024 * 1024 * 1024)
+
 	podSpec1 := newTestPodSpec(podID1, containerSpec11, containerSpec12)
+	podSpec1.InitContainers = []BasicContainerSpec{initContainerSpec10}
 	podSpec2 := newTestPodSpec(podID2, containerSpec21, containerSpec22)
 
 	return &specClientTestCase{
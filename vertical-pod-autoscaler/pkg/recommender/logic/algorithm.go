@@ -0,0 +1,92 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"fmt"
+	"sort"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/metrics"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+)
+
+// RecommendationAlgorithm computes the recommendation for a single container.
+// Algorithms are registered under a name with RegisterRecommendationAlgorithm
+// and selected per container with ContainerResourcePolicy.Recommender.
+type RecommendationAlgorithm interface {
+	// Recommend returns the recommended resources for the container.
+	Recommend(input RecommendationInput) RecommendedContainerResources
+}
+
+// RecommendationInput is the input of RecommendationAlgorithm for a single
+// container.
+type RecommendationInput struct {
+	// Name of the container.
+	ContainerName string
+	// Aggregated resource usage of the container.
+	AggregateState *model.AggregateContainerState
+	// VPA the container belongs to. Gives access to the VPA spec, the last
+	// recommendation and the per-VPA state of the algorithms.
+	Vpa *model.Vpa
+	// Minimum resources recommended for the container.
+	MinResources model.Resources
+	// Client of the custom metrics API. Can be nil.
+	CustomMetricsClient metrics.CustomMetricsClient
+}
+
+// RecommendationAlgorithmFactory creates a RecommendationAlgorithm. Factories
+// are called by CreatePodResourceRecommender, after the flags are parsed.
+type RecommendationAlgorithmFactory func() RecommendationAlgorithm
+
+var algorithmFactories = make(map[vpa_types.ContainerRecommender]RecommendationAlgorithmFactory)
+
+func init() {
+	RegisterRecommendationAlgorithm(vpa_types.ContainerRecommenderHistogram, CreateHistogramAlgorithm)
+	RegisterRecommendationAlgorithm(vpa_types.ContainerRecommenderResponseTimeController, func() RecommendationAlgorithm {
+		return NewResponseTimeControllerAlgorithm(CreateHistogramAlgorithm())
+	})
+}
+
+// RegisterRecommendationAlgorithm makes the algorithm created by the factory
+// available under the given name. It is meant to be called from init
+// functions and panics if the name is already registered.
+func RegisterRecommendationAlgorithm(name vpa_types.ContainerRecommender, factory RecommendationAlgorithmFactory) {
+	if _, found := algorithmFactories[name]; found {
+		panic(fmt.Sprintf("recommendation algorithm %s is already registered", name))
+	}
+	algorithmFactories[name] = factory
+}
+
+// RegisteredRecommendationAlgorithms returns the sorted names of the
+// registered recommendation algorithms.
+func RegisteredRecommendationAlgorithms() []vpa_types.ContainerRecommender {
+	names := make([]vpa_types.ContainerRecommender, 0, len(algorithmFactories))
+	for name := range algorithmFactories {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+func createRecommendationAlgorithms() map[vpa_types.ContainerRecommender]RecommendationAlgorithm {
+	algorithms := make(map[vpa_types.ContainerRecommender]RecommendationAlgorithm, len(algorithmFactories))
+	for name, factory := range algorithmFactories {
+		algorithms[name] = factory()
+	}
+	return algorithms
+}
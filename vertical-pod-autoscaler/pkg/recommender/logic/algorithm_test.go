@@ -0,0 +1,84 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+)
+
+type constAlgorithm struct {
+	name vpa_types.ContainerRecommender
+}
+
+func (a *constAlgorithm) Recommend(input RecommendationInput) RecommendedContainerResources {
+	return RecommendedContainerResources{
+		Target:      model.Resources{model.ResourceCPU: model.CPUAmountFromCores(1)},
+		Recommender: a.name,
+	}
+}
+
+func TestRegisterRecommendationAlgorithm(t *testing.T) {
+	name := vpa_types.ContainerRecommender("test-algorithm")
+	RegisterRecommendationAlgorithm(name, func() RecommendationAlgorithm { return &constAlgorithm{name} })
+	defer delete(algorithmFactories, name)
+
+	assert.Equal(t, []vpa_types.ContainerRecommender{
+		vpa_types.ContainerRecommenderHistogram,
+		vpa_types.ContainerRecommenderResponseTimeController,
+		name,
+	}, RegisteredRecommendationAlgorithms())
+	assert.Panics(t, func() {
+		RegisterRecommendationAlgorithm(name, func() RecommendationAlgorithm { return &constAlgorithm{name} })
+	})
+
+	algorithms := createRecommendationAlgorithms()
+	assert.Len(t, algorithms, 3)
+	assert.Equal(t, &constAlgorithm{name}, algorithms[name])
+}
+
+func TestAlgorithmSelection(t *testing.T) {
+	custom := vpa_types.ContainerRecommender("custom")
+	unknown := vpa_types.ContainerRecommender("unknown")
+	recommender := &podResourceRecommender{
+		algorithms: map[vpa_types.ContainerRecommender]RecommendationAlgorithm{
+			vpa_types.ContainerRecommenderHistogram: &constAlgorithm{vpa_types.ContainerRecommenderHistogram},
+			custom:                                  &constAlgorithm{custom},
+		},
+		defaultAlgorithm: vpa_types.ContainerRecommenderHistogram,
+	}
+
+	vpa := model.NewVpa(model.VpaID{Namespace: "default", VpaName: "vpa"}, nil, time.Now())
+	vpa.ResourcePolicy = &vpa_types.PodResourcePolicy{
+		ContainerPolicies: []vpa_types.ContainerResourcePolicy{
+			{ContainerName: "container-1", Recommender: &custom},
+			{ContainerName: "container-2", Recommender: &unknown},
+		},
+	}
+	resources := recommender.GetRecommendedPodResources(model.ContainerNameToAggregateStateMap{
+		"container-1": &model.AggregateContainerState{},
+		"container-2": &model.AggregateContainerState{},
+		"container-3": &model.AggregateContainerState{},
+	}, vpa)
+	assert.Equal(t, custom, resources["container-1"].Recommender)
+	assert.Equal(t, vpa_types.ContainerRecommenderHistogram, resources["container-2"].Recommender)
+	assert.Equal(t, vpa_types.ContainerRecommenderHistogram, resources["container-3"].Recommender)
+}
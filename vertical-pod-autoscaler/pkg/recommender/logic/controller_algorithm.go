@@ -0,0 +1,411 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/metrics"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+	vpa_utils "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+	"k8s.io/klog"
+)
+
+const (
+	// CustomMetricsFetchFailed means the custom metrics API returned an error.
+	CustomMetricsFetchFailed = "FetchFailed"
+	// CustomMetricsMissing means the custom metrics API returned no values.
+	CustomMetricsMissing = "Missing"
+	// CustomMetricsStale means the metric is older than control-metrics-max-age.
+	CustomMetricsStale = "Stale"
+	// CustomMetricsMalformed means the metric value cannot be parsed.
+	CustomMetricsMalformed = "Malformed"
+	// CustomMetricsNoRate means the request rate cannot be computed yet,
+	// because there is a single sample of the request counters.
+	CustomMetricsNoRate = "NoRate"
+
+	// CustomMetricsFallbackHold means the last recommendation was kept.
+	CustomMetricsFallbackHold = "HoldLastRecommendation"
+	// CustomMetricsFallbackHistogram means the recommendation was computed
+	// by the histogram recommender.
+	CustomMetricsFallbackHistogram = "Histogram"
+)
+
+// CustomMetricsProblem describes why the response-time controller could not
+// use its custom metrics and how the recommendation was computed instead.
+type CustomMetricsProblem struct {
+	// Reason is one of the CustomMetrics* reasons.
+	Reason string
+	// Message is a human readable description of the problem.
+	Message string
+	// Fallback is one of the CustomMetricsFallback* values.
+	Fallback string
+}
+
+func newCustomMetricsProblem(reason string, format string, args ...interface{}) *CustomMetricsProblem {
+	return &CustomMetricsProblem{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+// responseTimeControllerAlgorithm computes the CPU recommendation with a
+// feedback controller keeping the response time of the application, read from
+// the custom metrics API, at the SLA. Its state is kept per container in
+// model.Vpa.ControllerStates.
+type responseTimeControllerAlgorithm struct {
+	fallback RecommendationAlgorithm
+}
+
+// NewResponseTimeControllerAlgorithm returns the response-time controller. The
+// fallback algorithm is used when the custom metrics are unusable and there is
+// no previous recommendation to hold.
+func NewResponseTimeControllerAlgorithm(fallback RecommendationAlgorithm) RecommendationAlgorithm {
+	return &responseTimeControllerAlgorithm{fallback: fallback}
+}
+
+// Recommend returns the recommendation of the response-time controller. If the
+// custom metrics are unusable, the controller is not updated and the last
+// recommendation is held, or, if there is none, the recommendation of the
+// fallback algorithm is returned.
+//
+// The request rate is computed from the samples of the request counters and
+// expressed as requests per replica in the sampling period, which is what the
+// nominal model refers to. The poles are adjusted to the actual period since
+// the previous iteration, so that the controller behaves the same regardless
+// of the recommender interval. If the previous iteration is more than
+// control-max-stale-periods sampling periods old, e.g. after a restart from a
+// checkpoint or a metrics outage, the controller starts afresh instead.
+func (a *responseTimeControllerAlgorithm) Recommend(input RecommendationInput) RecommendedContainerResources {
+	params := GetControllerParams(input.Vpa, input.ContainerName)
+	controllerState := input.Vpa.ControllerStateForContainer(input.ContainerName)
+	now := time.Now()
+	selector, err := metricSelector(params.MetricSource, input.Vpa)
+	if err != nil {
+		return a.fallbackResources(input, newCustomMetricsProblem(CustomMetricsFetchFailed, "invalid metric selector: %v", err))
+	}
+	responseTimes, problem := readMetric(input.CustomMetricsClient, params.MetricSource, selector, params.MetricSource.ResponseTimeMetric, now)
+	if problem != nil {
+		return a.fallbackResources(input, problem)
+	}
+	requestCounts, problem := readMetric(input.CustomMetricsClient, params.MetricSource, selector, params.MetricSource.RequestCountMetric, now)
+	if problem != nil {
+		return a.fallbackResources(input, problem)
+	}
+	requestRates := counterRates(controllerState.RequestCounters, requestCounts)
+	if len(requestRates) == 0 && controllerState.LegacyRequestCount > 0 {
+		requestRates = legacyCounterRates(controllerState.LegacyRequestCount, controllerState.LastUpdate, requestCounts)
+	}
+	controllerState.LegacyRequestCount = 0
+	controllerState.RequestCounters = make(map[string]model.CounterSample, len(requestCounts))
+	for object, sample := range requestCounts {
+		controllerState.RequestCounters[object] = model.CounterSample{Value: sample.value, Timestamp: sample.timestamp}
+	}
+	if len(requestRates) == 0 {
+		return a.fallbackResources(input, newCustomMetricsProblem(CustomMetricsNoRate,
+			"no two samples of metric %s to compute the request rate from", params.MetricSource.RequestCountMetric))
+	}
+	responseTime, requestRate := aggregateMetrics(responseTimes, requestRates)
+
+	replicas := input.Vpa.PodCount
+	if replicas < 1 {
+		klog.V(2).Infof("No live pods of VPA %s/%s, computing the controller input for a single replica", input.Vpa.ID.Namespace, input.Vpa.ID.VpaName)
+		replicas = 1
+	}
+	period := params.SamplingPeriod
+	if !controllerState.LastUpdate.IsZero() {
+		period = now.Sub(controllerState.LastUpdate)
+		if maxPeriod := time.Duration(*control_maxStalePeriods) * params.SamplingPeriod; period > maxPeriod {
+			klog.V(2).Infof("Response-time controller of VPA %s/%s container %s was last updated %v ago, resetting it",
+				input.Vpa.ID.Namespace, input.Vpa.ID.VpaName, input.ContainerName, period.Round(time.Second))
+			controllerState.IntegralTerm = 0
+			period = params.SamplingPeriod
+		}
+	}
+	requests := requestRate / float64(replicas) * params.SamplingPeriod.Seconds()
+	step := StepController(params, controllerState, responseTime, requests, period)
+	controllerState.LastUpdate = now
+
+	klog.V(4).Infof("Response-time controller of VPA %s/%s container %s: %+v, corrected integral term %.3f",
+		input.Vpa.ID.Namespace, input.Vpa.ID.VpaName, input.ContainerName, step, controllerState.IntegralTerm)
+
+	return RecommendedContainerResources{
+		Target: model.Resources{
+			model.ResourceCPU:    model.CPUAmountFromCores(step.Cores),
+			model.ResourceMemory: model.MemoryAmountFromBytes(params.MemoryBytes),
+		},
+		LowerBound: model.Resources{
+			model.ResourceCPU:    model.CPUAmountFromCores(params.MinCores),
+			model.ResourceMemory: model.MemoryAmountFromBytes(params.MemoryBytes),
+		},
+		UpperBound: model.Resources{
+			model.ResourceCPU:    model.CPUAmountFromCores(params.MaxCores),
+			model.ResourceMemory: model.MemoryAmountFromBytes(params.MemoryBytes),
+		},
+		Recommender:    vpa_types.ContainerRecommenderResponseTimeController,
+		ControllerStep: &step,
+	}
+}
+
+// ControllerStep holds the terms computed in an iteration of the response-time
+// controller.
+type ControllerStep struct {
+	// Requests per replica in the sampling period, the load in the nominal model.
+	Requests float64
+	// Observed response time, in seconds.
+	ResponseTime float64
+	// Difference between the SLA and the observed response time.
+	Error float64
+	// Proportional term.
+	ProportionalTerm float64
+	// Integral term, before it is corrected for the saturation of the cores.
+	IntegralTerm float64
+	// Response time the controller asks for.
+	Output float64
+	// Cores the nominal model needs to reach Output.
+	TargetCores float64
+	// Recommended cores: TargetCores within [MinCores, MaxCores], or MaxCores
+	// if the response time is above the SLA.
+	Cores float64
+	// Response time the nominal model predicts with the recommended cores.
+	PredictedResponseTime float64
+	// Set if Cores is clamped at MaxCores.
+	Saturated bool
+}
+
+// StepController runs an iteration of the response-time controller for the
+// observed response time and requests per replica in the sampling period.
+// Period is the time since the previous iteration, the poles are adjusted to
+// it. The integral term of the state is updated, other fields are left to the
+// caller.
+func StepController(params ControllerParams, state *model.ControllerState, responseTime, requests float64, period time.Duration) ControllerStep {
+	closedLoopPole := poleForPeriod(params.ClosedLoopPole, period, params.SamplingPeriod)
+	nominalPole := poleForPeriod(params.NominalPole, period, params.SamplingPeriod)
+
+	req := requests    // active requests + queue of requests
+	rt := responseTime // mean of the response times
+	error := params.SLA - rt
+	ke := (closedLoopPole - 1) / (nominalPole - 1) * error
+	ui := state.IntegralTerm + (1-nominalPole)*ke
+	ut := ui + ke
+
+	targetCore := req * (ut - params.A1Nom - 1000.0*params.A2Nom) / (1000.0 * params.A3Nom * (params.A1Nom - ut))
+
+	approxCore := 0.0
+	if error < 0 {
+		approxCore = params.MaxCores
+	} else {
+		approxCore = math.Min(math.Max(math.Abs(targetCore), params.MinCores), params.MaxCores)
+	}
+
+	approxUt := ((1000.0*params.A2Nom+params.A1Nom)*req + 1000.0*params.A1Nom*params.A3Nom*approxCore) / (req + 1000.0*params.A3Nom*approxCore)
+	state.IntegralTerm = approxUt - ke
+
+	return ControllerStep{
+		Requests:              req,
+		ResponseTime:          rt,
+		Error:                 error,
+		ProportionalTerm:      ke,
+		IntegralTerm:          ui,
+		Output:                ut,
+		TargetCores:           targetCore,
+		Cores:                 approxCore,
+		PredictedResponseTime: approxUt,
+		Saturated:             approxCore >= params.MaxCores,
+	}
+}
+
+// fallbackResources returns the recommendation used when the controller cannot
+// use its custom metrics.
+func (a *responseTimeControllerAlgorithm) fallbackResources(input RecommendationInput, problem *CustomMetricsProblem) RecommendedContainerResources {
+	klog.Warningf("Response-time controller cannot use custom metrics for container %s of VPA %s/%s: %v",
+		input.ContainerName, input.Vpa.ID.Namespace, input.Vpa.ID.VpaName, problem.Message)
+	if lastRecommendation := vpa_utils.GetRecommendationForContainer(input.ContainerName, input.Vpa.Recommendation); lastRecommendation != nil {
+		target := lastRecommendation.UncappedTarget
+		if len(target) == 0 {
+			target = lastRecommendation.Target
+		}
+		problem.Fallback = CustomMetricsFallbackHold
+		return RecommendedContainerResources{
+			Target:               model.ResourcesFromResourceList(target),
+			LowerBound:           model.ResourcesFromResourceList(lastRecommendation.LowerBound),
+			UpperBound:           model.ResourcesFromResourceList(lastRecommendation.UpperBound),
+			Recommender:          vpa_types.ContainerRecommenderResponseTimeController,
+			CustomMetricsProblem: problem,
+		}
+	}
+	problem.Fallback = CustomMetricsFallbackHistogram
+	resources := a.fallback.Recommend(input)
+	resources.CustomMetricsProblem = problem
+	return resources
+}
+
+// metricSelector returns the selector of the objects described by the metric
+// source. Pods in the VPA namespace are selected by the VPA pod selector,
+// unless the source has its own selector or object name.
+func metricSelector(source ControllerMetricSource, vpa *model.Vpa) (labels.Selector, error) {
+	if source.Selector != nil {
+		return metav1.LabelSelectorAsSelector(source.Selector)
+	}
+	if source.Resource == controllerMetricResources[vpa_types.ControllerMetricObjectKindPod] &&
+		source.ObjectName == metrics.AllObjects && source.Namespace == vpa.ID.Namespace && vpa.PodSelector != nil {
+		return vpa.PodSelector, nil
+	}
+	return labels.Everything(), nil
+}
+
+// metricSample is a value of a custom metric with the time it was produced.
+type metricSample struct {
+	value     float64
+	timestamp time.Time
+}
+
+// readMetric returns the samples of the metric with the given name keyed by
+// the described object, or the reason why the metric cannot be used. Stale
+// and malformed values are skipped, the metric is unusable only if no value
+// is left. Values without timestamp are assumed to be produced now.
+func readMetric(client metrics.CustomMetricsClient, source ControllerMetricSource, selector labels.Selector,
+	metricName string, now time.Time) (map[string]metricSample, *CustomMetricsProblem) {
+	if client == nil {
+		return nil, newCustomMetricsProblem(CustomMetricsFetchFailed, "custom metrics client is not configured")
+	}
+	values, err := client.GetObjectsMetric(source.Namespace, source.Resource, source.ObjectName, selector, metricName)
+	if err != nil {
+		return nil, newCustomMetricsProblem(CustomMetricsFetchFailed, "cannot get metric %s: %v", metricName, err)
+	}
+	if values == nil || len(values.Items) == 0 {
+		return nil, newCustomMetricsProblem(CustomMetricsMissing, "no values of metric %s", metricName)
+	}
+	result := make(map[string]metricSample, len(values.Items))
+	var problem *CustomMetricsProblem
+	for _, item := range values.Items {
+		object := item.DescribedObject.Namespace + "/" + item.DescribedObject.Name
+		if age := now.Sub(item.Timestamp.Time); *control_metricsMaxAge > 0 && !item.Timestamp.IsZero() && age > *control_metricsMaxAge {
+			problem = newCustomMetricsProblem(CustomMetricsStale, "metric %s of %s is %v old", metricName, object, age.Round(time.Second))
+			continue
+		}
+		value, err := parseValue(item.Value)
+		if err != nil {
+			problem = newCustomMetricsProblem(CustomMetricsMalformed, "metric %s of %s: %v", metricName, object, err)
+			continue
+		}
+		timestamp := item.Timestamp.Time
+		if timestamp.IsZero() {
+			timestamp = now
+		}
+		result[object] = metricSample{value: value, timestamp: timestamp}
+	}
+	if len(result) == 0 {
+		return nil, problem
+	}
+	return result, nil
+}
+
+// aggregateMetrics returns the mean of the response times of the described
+// objects, weighted by their request rates, and the total request rate.
+// If there were no requests, the plain mean of the response times is used.
+func aggregateMetrics(responseTimes map[string]metricSample, requestRates map[string]float64) (responseTime, requestRate float64) {
+	weightedSum, weights, sum := 0.0, 0.0, 0.0
+	for object, rt := range responseTimes {
+		weightedSum += rt.value * requestRates[object]
+		weights += requestRates[object]
+		sum += rt.value
+	}
+	for _, rate := range requestRates {
+		requestRate += rate
+	}
+	if weights > 0 {
+		return weightedSum / weights, requestRate
+	}
+	return sum / float64(len(responseTimes)), requestRate
+}
+
+// counterRates returns the per-second rate of every counter with a previous
+// sample older than the current one. A counter lower than its previous sample
+// is assumed to be reset, e.g. by a pod restart, and to count from zero since.
+func counterRates(previous map[string]model.CounterSample, current map[string]metricSample) map[string]float64 {
+	rates := make(map[string]float64)
+	for object, sample := range current {
+		last, found := previous[object]
+		if !found {
+			continue
+		}
+		elapsed := sample.timestamp.Sub(last.Timestamp).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		increase := sample.value - last.Value
+		if increase < 0 {
+			increase = sample.value
+		}
+		rates[object] = increase / elapsed
+	}
+	return rates
+}
+
+// legacyCounterRates returns the per-second rates of the counters since
+// lastUpdate, when only the total of the counters at lastUpdate is known. The
+// increase of the total is split among the objects in proportion to their
+// counters.
+func legacyCounterRates(previousTotal float64, lastUpdate time.Time, current map[string]metricSample) map[string]float64 {
+	rates := make(map[string]float64)
+	total := 0.0
+	var latest time.Time
+	for _, sample := range current {
+		total += sample.value
+		if sample.timestamp.After(latest) {
+			latest = sample.timestamp
+		}
+	}
+	elapsed := latest.Sub(lastUpdate).Seconds()
+	if lastUpdate.IsZero() || elapsed <= 0 || total <= 0 {
+		return rates
+	}
+	increase := total - previousTotal
+	if increase < 0 {
+		increase = total
+	}
+	for object, sample := range current {
+		rates[object] = increase / elapsed * sample.value / total
+	}
+	return rates
+}
+
+// poleForPeriod returns the discrete-time pole equivalent to the given pole,
+// tuned for the nominal period, when sampling with the given period.
+func poleForPeriod(pole float64, period, nominalPeriod time.Duration) float64 {
+	if period <= 0 || nominalPeriod <= 0 {
+		return pole
+	}
+	return math.Pow(pole, period.Seconds()/nominalPeriod.Seconds())
+}
+
+// parseValue converts a custom metric value, serialized as a resource
+// quantity (e.g. "250m"), to float64.
+func parseValue(value string) (float64, error) {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse value %q: %v", value, err)
+	}
+	if quantity.Sign() < 0 {
+		return 0, fmt.Errorf("negative value %q", value)
+	}
+	return float64(quantity.MilliValue()) / 1000.0, nil
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/metrics"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+)
+
+func podMetricValue(name, value string, timestamp time.Time) metrics.CustomMetricValue {
+	return metrics.CustomMetricValue{
+		DescribedObject: metrics.CustomMetricObjectReference{Kind: "Pod", Namespace: "default", Name: name},
+		Value:           value,
+		Timestamp:       metav1.NewTime(timestamp),
+	}
+}
+
+func TestAggregateMetrics(t *testing.T) {
+	responseTimes := map[string]metricSample{
+		"default/pod-1": {value: 0.1},
+		"default/pod-2": {value: 0.4},
+	}
+	responseTime, requestRate := aggregateMetrics(responseTimes, map[string]float64{"default/pod-1": 3, "default/pod-2": 1})
+	assert.InDelta(t, 0.175, responseTime, 1e-9)
+	assert.Equal(t, 4.0, requestRate)
+
+	// Without requests the response times are averaged.
+	responseTime, requestRate = aggregateMetrics(responseTimes, map[string]float64{})
+	assert.InDelta(t, 0.25, responseTime, 1e-9)
+	assert.Equal(t, 0.0, requestRate)
+}
+
+func TestCounterRates(t *testing.T) {
+	now := time.Now()
+	previous := map[string]model.CounterSample{
+		"default/pod-1": {Value: 100, Timestamp: now.Add(-10 * time.Second)},
+		"default/pod-2": {Value: 500, Timestamp: now.Add(-20 * time.Second)},
+		"default/pod-3": {Value: 10, Timestamp: now},
+	}
+	current := map[string]metricSample{
+		"default/pod-1": {value: 150, timestamp: now},
+		// Counter reset by a restart of the pod.
+		"default/pod-2": {value: 40, timestamp: now},
+		// Not updated since the previous sample.
+		"default/pod-3": {value: 10, timestamp: now},
+		// No previous sample.
+		"default/pod-4": {value: 70, timestamp: now},
+	}
+	assert.Equal(t, map[string]float64{
+		"default/pod-1": 5,
+		"default/pod-2": 2,
+	}, counterRates(previous, current))
+}
+
+func TestLegacyCounterRates(t *testing.T) {
+	now := time.Now()
+	current := map[string]metricSample{
+		"default/pod-1": {value: 300, timestamp: now},
+		"default/pod-2": {value: 100, timestamp: now.Add(-5 * time.Second)},
+	}
+	// The total grew by 200 in 20s, split 3:1 between the pods.
+	assert.Equal(t, map[string]float64{
+		"default/pod-1": 7.5,
+		"default/pod-2": 2.5,
+	}, legacyCounterRates(200, now.Add(-20*time.Second), current))
+	assert.Empty(t, legacyCounterRates(200, time.Time{}, current))
+}
+
+func TestPoleForPeriod(t *testing.T) {
+	assert.Equal(t, 0.8, poleForPeriod(0.8, 30*time.Second, 30*time.Second))
+	assert.InDelta(t, 0.64, poleForPeriod(0.8, time.Minute, 30*time.Second), 1e-9)
+	assert.Equal(t, 0.0, poleForPeriod(0, time.Minute, 30*time.Second))
+	assert.Equal(t, 0.8, poleForPeriod(0.8, 0, 30*time.Second))
+}
+
+func TestReadMetricSkipsUnusableValues(t *testing.T) {
+	now := time.Now()
+	client := &fakeCustomMetricsClient{values: map[string]*metrics.CustomMetricValueList{
+		"response_time": {Items: []metrics.CustomMetricValue{
+			podMetricValue("pod-1", "100m", now),
+			podMetricValue("pod-2", "abc", now),
+			podMetricValue("pod-3", "300m", now.Add(-time.Hour)),
+		}},
+		"stale_time": {Items: []metrics.CustomMetricValue{
+			podMetricValue("pod-1", "100m", now.Add(-time.Hour)),
+		}},
+	}}
+	source := DefaultControllerParams("default").MetricSource
+
+	values, problem := readMetric(client, source, labels.Everything(), "response_time", now)
+	assert.Nil(t, problem)
+	assert.Equal(t, map[string]metricSample{"default/pod-1": {value: 0.1, timestamp: metav1.NewTime(now).Time}}, values)
+
+	_, problem = readMetric(client, source, labels.Everything(), "stale_time", now)
+	if assert.NotNil(t, problem) {
+		assert.Equal(t, CustomMetricsStale, problem.Reason)
+	}
+}
+
+func TestMetricSelector(t *testing.T) {
+	podSelector := labels.SelectorFromSet(labels.Set{"app": "front"})
+	vpa := model.NewVpa(model.VpaID{Namespace: "default", VpaName: "vpa"}, podSelector, time.Now())
+
+	source := DefaultControllerParams("default").MetricSource
+	source.Namespace = "default"
+	selector, err := metricSelector(source, vpa)
+	assert.NoError(t, err)
+	assert.Equal(t, podSelector, selector)
+
+	source.Namespace = "ingress"
+	selector, err = metricSelector(source, vpa)
+	assert.NoError(t, err)
+	assert.True(t, selector.Empty())
+
+	source.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "ingress"}}
+	selector, err = metricSelector(source, vpa)
+	assert.NoError(t, err)
+	assert.Equal(t, "app=ingress", selector.String())
+}
+
+func TestControllerDividesRequestsByPodCount(t *testing.T) {
+	now := time.Now()
+	containerNameToAggregateStateMap := model.ContainerNameToAggregateStateMap{
+		"container-1": &model.AggregateContainerState{},
+	}
+	// 100 requests served by two pods.
+	twoPods := newTestPodResourceRecommender(NewConstEstimator(model.Resources{}), &fakeCustomMetricsClient{
+		values: map[string]*metrics.CustomMetricValueList{
+			"response_time": {Items: []metrics.CustomMetricValue{
+				podMetricValue("pod-1", "2", now),
+				podMetricValue("pod-2", "2", now),
+			}},
+			"response_count": {Items: []metrics.CustomMetricValue{
+				podMetricValue("pod-1", "60", now),
+				podMetricValue("pod-2", "40", now),
+			}},
+		}})
+	// 50 requests served by one pod.
+	onePod := newTestPodResourceRecommender(NewConstEstimator(model.Resources{}), &fakeCustomMetricsClient{
+		values: map[string]*metrics.CustomMetricValueList{
+			"response_time":  {Items: []metrics.CustomMetricValue{podMetricValue("pod-1", "2", now)}},
+			"response_count": {Items: []metrics.CustomMetricValue{podMetricValue("pod-1", "50", now)}},
+		}})
+
+	vpa1 := newControllerVpa()
+	vpa1.PodCount = 2
+	vpa1.ControllerStateForContainer("container-1").RequestCounters = map[string]model.CounterSample{
+		"default/pod-1": {Timestamp: now.Add(-30 * time.Second)},
+		"default/pod-2": {Timestamp: now.Add(-30 * time.Second)},
+	}
+	resources1 := twoPods.GetRecommendedPodResources(containerNameToAggregateStateMap, vpa1)["container-1"]
+	vpa2 := newControllerVpa()
+	vpa2.PodCount = 1
+	vpa2.ControllerStateForContainer("container-1").RequestCounters = map[string]model.CounterSample{
+		"default/pod-1": {Timestamp: now.Add(-30 * time.Second)},
+	}
+	resources2 := onePod.GetRecommendedPodResources(containerNameToAggregateStateMap, vpa2)["container-1"]
+
+	assert.Nil(t, resources1.CustomMetricsProblem)
+	assert.Nil(t, resources2.CustomMetricsProblem)
+	assert.Equal(t, resources2.Target, resources1.Target)
+	assert.InDelta(t, vpa2.ControllerStateForContainer("container-1").IntegralTerm,
+		vpa1.ControllerStateForContainer("container-1").IntegralTerm, 1e-9)
+}
+
+func TestControllerIndependentOfCounterSamplingPeriod(t *testing.T) {
+	now := time.Now()
+	containerNameToAggregateStateMap := model.ContainerNameToAggregateStateMap{
+		"container-1": &model.AggregateContainerState{},
+	}
+	recommender := newTestPodResourceRecommender(NewConstEstimator(model.Resources{}), &fakeCustomMetricsClient{
+		values: map[string]*metrics.CustomMetricValueList{
+			"response_time":  {Items: []metrics.CustomMetricValue{podMetricValue("pod-1", "2", now)}},
+			"response_count": {Items: []metrics.CustomMetricValue{podMetricValue("pod-1", "1200", now)}},
+		}})
+
+	// The same request rate of 10 requests per second, sampled 30s and 60s apart.
+	vpa1 := newControllerVpa()
+	vpa1.ControllerStateForContainer("container-1").RequestCounters = map[string]model.CounterSample{
+		"default/pod-1": {Value: 900, Timestamp: now.Add(-30 * time.Second)},
+	}
+	resources1 := recommender.GetRecommendedPodResources(containerNameToAggregateStateMap, vpa1)["container-1"]
+	vpa2 := newControllerVpa()
+	vpa2.ControllerStateForContainer("container-1").RequestCounters = map[string]model.CounterSample{
+		"default/pod-1": {Value: 600, Timestamp: now.Add(-60 * time.Second)},
+	}
+	resources2 := recommender.GetRecommendedPodResources(containerNameToAggregateStateMap, vpa2)["container-1"]
+
+	assert.Nil(t, resources1.CustomMetricsProblem)
+	assert.Equal(t, resources1.Target, resources2.Target)
+	assert.InDelta(t, vpa1.ControllerStateForContainer("container-1").IntegralTerm,
+		vpa2.ControllerStateForContainer("container-1").IntegralTerm, 1e-9)
+}
+
+func TestControllerResetsAfterStaleUpdate(t *testing.T) {
+	now := time.Now()
+	containerNameToAggregateStateMap := model.ContainerNameToAggregateStateMap{
+		"container-1": &model.AggregateContainerState{},
+	}
+	recommender := newTestPodResourceRecommender(NewConstEstimator(model.Resources{}), &fakeCustomMetricsClient{
+		values: map[string]*metrics.CustomMetricValueList{
+			"response_time":  {Items: []metrics.CustomMetricValue{podMetricValue("pod-1", "2", now)}},
+			"response_count": {Items: []metrics.CustomMetricValue{podMetricValue("pod-1", "1200", now)}},
+		}})
+
+	// A controller that has not run for hours behaves like a fresh one with
+	// the same request rate, instead of applying the full integral
+	// correction with deadbeat poles.
+	fresh := newControllerVpa()
+	fresh.ControllerStateForContainer("container-1").RequestCounters = map[string]model.CounterSample{
+		"default/pod-1": {Value: 900, Timestamp: now.Add(-30 * time.Second)},
+	}
+	freshResources := recommender.GetRecommendedPodResources(containerNameToAggregateStateMap, fresh)["container-1"]
+	stale := newControllerVpa()
+	staleState := stale.ControllerStateForContainer("container-1")
+	staleState.LastUpdate = now.Add(-3 * time.Hour)
+	staleState.IntegralTerm = 0.5
+	staleState.RequestCounters = map[string]model.CounterSample{
+		"default/pod-1": {Value: 1200 - 10*3*3600, Timestamp: now.Add(-3 * time.Hour)},
+	}
+	staleResources := recommender.GetRecommendedPodResources(containerNameToAggregateStateMap, stale)["container-1"]
+
+	assert.Nil(t, staleResources.CustomMetricsProblem)
+	assert.Equal(t, freshResources.Target, staleResources.Target)
+	assert.InDelta(t, fresh.ControllerStateForContainer("container-1").IntegralTerm, staleState.IntegralTerm, 1e-9)
+}
+
+func TestStepController(t *testing.T) {
+	params := ControllerParams{
+		SLA:            0.6,
+		ClosedLoopPole: 0.9,
+		NominalPole:    0.8,
+		A1Nom:          0.1963,
+		A2Nom:          0.002,
+		A3Nom:          0.5658,
+		MinCores:       0.025,
+		MaxCores:       4,
+		SamplingPeriod: 30 * time.Second,
+	}
+
+	// Above the SLA the controller allocates the maximum.
+	state := &model.ControllerState{}
+	step := StepController(params, state, 0.9, 150, 30*time.Second)
+	assert.Equal(t, params.MaxCores, step.Cores)
+	assert.InDelta(t, -0.3, step.Error, 1e-9)
+	assert.True(t, step.Saturated)
+	assert.InDelta(t, step.PredictedResponseTime-step.ProportionalTerm, state.IntegralTerm, 1e-9)
+
+	// Below the SLA the controller releases cores, moving the response time
+	// toward the SLA. Without saturation the model reaches the output.
+	step = StepController(params, state, step.PredictedResponseTime, 150, 30*time.Second)
+	assert.True(t, step.Cores >= params.MinCores && step.Cores < params.MaxCores)
+	assert.False(t, step.Saturated)
+	assert.InDelta(t, step.TargetCores, step.Cores, 1e-9)
+	assert.InDelta(t, step.Output, step.PredictedResponseTime, 1e-9)
+	assert.True(t, step.PredictedResponseTime > step.ResponseTime)
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/metrics"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+	vpa_utils "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+)
+
+// ControllerParams is the configuration of the response-time controller
+// for a single container, with all defaults resolved.
+type ControllerParams struct {
+	// SLA is the response time set point, in seconds.
+	SLA float64
+	// ClosedLoopPole controls how conservative the controller is.
+	ClosedLoopPole float64
+	// NominalPole is the nominal pole of the controlled system.
+	NominalPole float64
+	// A1Nom, A2Nom and A3Nom are the nominal coefficients of the model.
+	A1Nom float64
+	A2Nom float64
+	A3Nom float64
+	// MinCores is the minimum number of cores the controller recommends.
+	MinCores float64
+	// MaxCores is the maximum number of cores the controller recommends.
+	MaxCores float64
+	// MemoryBytes is the memory recommended for the container.
+	MemoryBytes float64
+	// MetricSource is where the controller reads its inputs from.
+	MetricSource ControllerMetricSource
+	// SamplingPeriod is the period the poles and the nominal model refer to.
+	SamplingPeriod time.Duration
+}
+
+// ControllerMetricSource describes the objects and metrics in the custom
+// metrics API that are the inputs of the controller.
+type ControllerMetricSource struct {
+	// Namespace of the described objects.
+	Namespace string
+	// Resource of the described objects in the custom metrics API, e.g. "pods".
+	Resource string
+	// ObjectName is the name of the described object or metrics.AllObjects.
+	ObjectName string
+	// Selector of the described objects, used if ObjectName is metrics.AllObjects. Can be nil.
+	Selector *metav1.LabelSelector
+	// ResponseTimeMetric is the name of the response time metric.
+	ResponseTimeMetric string
+	// RequestCountMetric is the name of the request counter metric.
+	RequestCountMetric string
+}
+
+var controllerMetricResources = map[vpa_types.ControllerMetricObjectKind]string{
+	vpa_types.ControllerMetricObjectKindPod:     "pods",
+	vpa_types.ControllerMetricObjectKindService: "services",
+	vpa_types.ControllerMetricObjectKindIngress: "ingresses.extensions",
+}
+
+// DefaultControllerParams returns the controller configuration given by the
+// command line flags for a VPA in the given namespace.
+func DefaultControllerParams(vpaNamespace string) ControllerParams {
+	metricsNamespace := *control_metricsNamespace
+	if metricsNamespace == "" {
+		metricsNamespace = vpaNamespace
+	}
+	return ControllerParams{
+		SLA:            *control_sla,
+		ClosedLoopPole: *control_a,
+		NominalPole:    *control_pNom,
+		A1Nom:          *control_a1Nom,
+		A2Nom:          *control_a2Nom,
+		A3Nom:          *control_a3Nom,
+		MinCores:       *podMinCPUMillicores / 1000.0,
+		MaxCores:       *control_coreMax,
+		MemoryBytes:    *control_memory * 1024 * 1024,
+		SamplingPeriod: *control_samplingPeriod,
+		MetricSource: ControllerMetricSource{
+			Namespace:          metricsNamespace,
+			Resource:           controllerMetricResources[vpa_types.ControllerMetricObjectKindPod],
+			ObjectName:         metrics.AllObjects,
+			ResponseTimeMetric: *control_responseTimeMetric,
+			RequestCountMetric: *control_requestCountMetric,
+		},
+	}
+}
+
+// GetControllerParams returns the controller configuration for the container
+// with the given name. The defaults are overridden by the VPA-wide
+// ControllerPolicy, which in turn is overridden by the ControllerPolicy of the
+// matching ContainerResourcePolicy.
+func GetControllerParams(vpa *model.Vpa, containerName string) ControllerParams {
+	params := DefaultControllerParams(vpa.ID.Namespace)
+	params.applyPolicy(vpa.ControllerPolicy)
+	if containerPolicy := vpa_utils.GetContainerResourcePolicy(containerName, vpa.ResourcePolicy); containerPolicy != nil {
+		params.applyPolicy(containerPolicy.ControllerPolicy)
+	}
+	return params
+}
+
+func (p *ControllerParams) applyPolicy(policy *vpa_types.ControllerPolicy) {
+	if policy == nil {
+		return
+	}
+	if policy.SLA != nil {
+		p.SLA = *policy.SLA
+	}
+	if policy.ClosedLoopPole != nil {
+		p.ClosedLoopPole = *policy.ClosedLoopPole
+	}
+	if policy.NominalPole != nil {
+		p.NominalPole = *policy.NominalPole
+	}
+	if m := policy.NominalModel; m != nil {
+		if m.A1 != nil {
+			p.A1Nom = *m.A1
+		}
+		if m.A2 != nil {
+			p.A2Nom = *m.A2
+		}
+		if m.A3 != nil {
+			p.A3Nom = *m.A3
+		}
+	}
+	if policy.MaxCPU != nil {
+		p.MaxCores = float64(policy.MaxCPU.MilliValue()) / 1000.0
+	}
+	if policy.Memory != nil {
+		p.MemoryBytes = float64(policy.Memory.Value())
+	}
+	if policy.MetricSource != nil {
+		p.MetricSource.applyMetricSource(policy.MetricSource)
+	}
+	if policy.SamplingPeriod != nil {
+		p.SamplingPeriod = policy.SamplingPeriod.Duration
+	}
+}
+
+func (s *ControllerMetricSource) applyMetricSource(source *vpa_types.ControllerMetricSource) {
+	if source.Namespace != "" {
+		s.Namespace = source.Namespace
+	}
+	if resource, found := controllerMetricResources[source.ObjectKind]; found {
+		s.Resource = resource
+	}
+	if source.ObjectName != "" {
+		s.ObjectName = source.ObjectName
+		s.Selector = nil
+	}
+	if source.Selector != nil {
+		s.ObjectName = metrics.AllObjects
+		s.Selector = source.Selector
+	}
+	if source.ResponseTimeMetric != "" {
+		s.ResponseTimeMetric = source.ResponseTimeMetric
+	}
+	if source.RequestCountMetric != "" {
+		s.RequestCountMetric = source.RequestCountMetric
+	}
+}
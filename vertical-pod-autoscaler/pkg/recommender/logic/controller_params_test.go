@@ -0,0 +1,109 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/metrics"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+)
+
+func TestGetControllerParamsDefaults(t *testing.T) {
+	vpa := model.NewVpa(model.VpaID{Namespace: "default", VpaName: "vpa"}, nil, time.Now())
+	assert.Equal(t, DefaultControllerParams("default"), GetControllerParams(vpa, "container"))
+}
+
+func TestGetControllerParamsOverrides(t *testing.T) {
+	vpaSLA, containerSLA, a3 := 2.0, 0.5, 0.7
+	maxCPU := resource.MustParse("1500m")
+	memory := resource.MustParse("256Mi")
+
+	vpa := model.NewVpa(model.VpaID{Namespace: "default", VpaName: "vpa"}, nil, time.Now())
+	vpa.ControllerPolicy = &vpa_types.ControllerPolicy{
+		SLA:          &vpaSLA,
+		NominalModel: &vpa_types.ControllerModel{A3: &a3},
+		MaxCPU:       &maxCPU,
+	}
+	vpa.ResourcePolicy = &vpa_types.PodResourcePolicy{
+		ContainerPolicies: []vpa_types.ContainerResourcePolicy{{
+			ContainerName: "container-1",
+			ControllerPolicy: &vpa_types.ControllerPolicy{
+				SLA:    &containerSLA,
+				Memory: &memory,
+			},
+		}},
+	}
+
+	defaults := DefaultControllerParams("default")
+	params := GetControllerParams(vpa, "container-1")
+	assert.Equal(t, containerSLA, params.SLA)
+	assert.Equal(t, a3, params.A3Nom)
+	assert.Equal(t, 1.5, params.MaxCores)
+	assert.Equal(t, float64(256*1024*1024), params.MemoryBytes)
+	assert.Equal(t, defaults.NominalPole, params.NominalPole)
+	assert.Equal(t, defaults.A1Nom, params.A1Nom)
+
+	params = GetControllerParams(vpa, "container-2")
+	assert.Equal(t, vpaSLA, params.SLA)
+	assert.Equal(t, defaults.MemoryBytes, params.MemoryBytes)
+}
+
+func TestGetControllerParamsMetricSource(t *testing.T) {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "front"}}
+	vpa := model.NewVpa(model.VpaID{Namespace: "shop", VpaName: "vpa"}, nil, time.Now())
+	vpa.ControllerPolicy = &vpa_types.ControllerPolicy{
+		MetricSource: &vpa_types.ControllerMetricSource{
+			ObjectKind:         vpa_types.ControllerMetricObjectKindService,
+			ObjectName:         "front",
+			ResponseTimeMetric: "latency",
+		},
+	}
+	vpa.ResourcePolicy = &vpa_types.PodResourcePolicy{
+		ContainerPolicies: []vpa_types.ContainerResourcePolicy{{
+			ContainerName: "container-1",
+			ControllerPolicy: &vpa_types.ControllerPolicy{
+				MetricSource: &vpa_types.ControllerMetricSource{
+					Namespace:  "ingress",
+					ObjectKind: vpa_types.ControllerMetricObjectKindPod,
+					Selector:   selector,
+				},
+			},
+		}},
+	}
+
+	defaults := DefaultControllerParams("shop")
+	source := GetControllerParams(vpa, "container-2").MetricSource
+	assert.Equal(t, defaults.MetricSource.Namespace, source.Namespace)
+	assert.Equal(t, "services", source.Resource)
+	assert.Equal(t, "front", source.ObjectName)
+	assert.Nil(t, source.Selector)
+	assert.Equal(t, "latency", source.ResponseTimeMetric)
+	assert.Equal(t, defaults.MetricSource.RequestCountMetric, source.RequestCountMetric)
+
+	source = GetControllerParams(vpa, "container-1").MetricSource
+	assert.Equal(t, "ingress", source.Namespace)
+	assert.Equal(t, "pods", source.Resource)
+	assert.Equal(t, metrics.AllObjects, source.ObjectName)
+	assert.Equal(t, selector, source.Selector)
+	assert.Equal(t, "latency", source.ResponseTimeMetric)
+}
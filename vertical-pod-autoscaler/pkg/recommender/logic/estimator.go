@@ -39,7 +39,8 @@ type constEstimator struct {
 }
 
 // Simple implementation of the ResourceEstimator interface. It returns specific
-// percentiles of CPU usage distribution and memory peaks distribution.
+// percentiles of CPU usage distribution and memory peaks distribution. The
+// ephemeral storage peaks distribution uses the memory percentile.
 type percentileEstimator struct {
 	cpuPercentile    float64
 	memoryPercentile float64
@@ -93,14 +94,21 @@ func (e *constEstimator) GetResourceEstimation(s *model.AggregateContainerState)
 	return e.resources
 }
 
-// Returns specific percentiles of CPU and memory peaks distributions.
+// Returns specific percentiles of CPU and memory peaks distributions, and of
+// the ephemeral storage peaks distribution if ephemeral storage usage was
+// observed.
 func (e *percentileEstimator) GetResourceEstimation(s *model.AggregateContainerState) model.Resources {
-	return model.Resources{
+	resources := model.Resources{
 		model.ResourceCPU: model.CPUAmountFromCores(
 			s.AggregateCPUUsage.Percentile(e.cpuPercentile)),
 		model.ResourceMemory: model.MemoryAmountFromBytes(
 			s.AggregateMemoryPeaks.Percentile(e.memoryPercentile)),
 	}
+	if s.AggregateEphemeralStoragePeaks != nil && !s.AggregateEphemeralStoragePeaks.IsEmpty() {
+		resources[model.ResourceEphemeralStorage] = model.EphemeralStorageAmountFromBytes(
+			s.AggregateEphemeralStoragePeaks.Percentile(e.memoryPercentile))
+	}
+	return resources
 }
 
 // Returns a non-negative real number that heuristically measures how much
@@ -59,6 +59,26 @@ func TestPercentileEstimator(t *testing.T) {
 	maxRelativeError := 0.05 // Allow 5% relative error to account for histogram rounding.
 	assert.InEpsilon(t, 1.0, model.CoresFromCPUAmount(resourceEstimation[model.ResourceCPU]), maxRelativeError)
 	assert.InEpsilon(t, 2e9, model.BytesFromMemoryAmount(resourceEstimation[model.ResourceMemory]), maxRelativeError)
+	assert.NotContains(t, resourceEstimation, model.ResourceEphemeralStorage)
+}
+
+// Verifies that the percentileEstimator estimates ephemeral storage with the
+// memory percentile, once ephemeral storage usage was observed.
+func TestPercentileEstimatorEphemeralStorage(t *testing.T) {
+	ephemeralStoragePeaksHistogram := util.NewHistogram(model.EphemeralStorageHistogramOptions)
+	ephemeralStoragePeaksHistogram.AddSample(1e10, 1.0, anyTime)
+	ephemeralStoragePeaksHistogram.AddSample(2e10, 1.0, anyTime)
+	ephemeralStoragePeaksHistogram.AddSample(3e10, 1.0, anyTime)
+	estimator := NewPercentileEstimator(0.2, 0.5)
+
+	resourceEstimation := estimator.GetResourceEstimation(
+		&model.AggregateContainerState{
+			AggregateCPUUsage:              util.NewHistogram(model.CPUHistogramOptions),
+			AggregateMemoryPeaks:           util.NewHistogram(model.MemoryHistogramOptions),
+			AggregateEphemeralStoragePeaks: ephemeralStoragePeaksHistogram,
+		})
+	maxRelativeError := 0.05 // Allow 5% relative error to account for histogram rounding.
+	assert.InEpsilon(t, 2e10, model.BytesFromEphemeralStorageAmount(resourceEstimation[model.ResourceEphemeralStorage]), maxRelativeError)
 }
 
 // Verifies that the confidenceMultiplier calculates the internal
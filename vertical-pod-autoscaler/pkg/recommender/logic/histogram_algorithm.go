@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+)
+
+// histogramAlgorithm computes the recommendation from the usage histograms
+// of AggregateContainerState.
+type histogramAlgorithm struct {
+	targetEstimator     ResourceEstimator
+	lowerBoundEstimator ResourceEstimator
+	upperBoundEstimator ResourceEstimator
+	// usePolicy is set if the estimators are created from the percentiles
+	// of the VPA AggregationPolicy and from the VPA RecommenderPolicy when
+	// they set any.
+	usePolicy bool
+}
+
+// histogramPercentiles are the percentiles of the CPU usage and memory peaks
+// histograms recommended as the target and the bounds.
+type histogramPercentiles struct {
+	targetCPU        float64
+	lowerBoundCPU    float64
+	upperBoundCPU    float64
+	targetMemory     float64
+	lowerBoundMemory float64
+	upperBoundMemory float64
+}
+
+var defaultHistogramPercentiles = histogramPercentiles{
+	targetCPU:        0.9,
+	lowerBoundCPU:    0.5,
+	upperBoundCPU:    0.95,
+	targetMemory:     0.9,
+	lowerBoundMemory: 0.5,
+	upperBoundMemory: 0.95,
+}
+
+// getHistogramPercentiles returns the deprecated percentiles set in the
+// aggregation policy, with the defaults for the percentiles that are not set. Returns
+// false if the policy doesn't set any percentile.
+func getHistogramPercentiles(policy *vpa_types.AggregationPolicy) (histogramPercentiles, bool) {
+	percentiles := defaultHistogramPercentiles
+	if policy == nil || (policy.CPUPercentiles == nil && policy.MemoryPercentiles == nil) {
+		return percentiles, false
+	}
+	if cpu := policy.CPUPercentiles; cpu != nil {
+		setPercentile(&percentiles.targetCPU, cpu.Target)
+		setPercentile(&percentiles.lowerBoundCPU, cpu.LowerBound)
+		setPercentile(&percentiles.upperBoundCPU, cpu.UpperBound)
+	}
+	if memory := policy.MemoryPercentiles; memory != nil {
+		setPercentile(&percentiles.targetMemory, memory.Target)
+		setPercentile(&percentiles.lowerBoundMemory, memory.LowerBound)
+		setPercentile(&percentiles.upperBoundMemory, memory.UpperBound)
+	}
+	return percentiles, true
+}
+
+func setPercentile(percentile *float64, value *float64) {
+	if value != nil && *value > 0 && *value <= 1 {
+		*percentile = *value
+	}
+}
+
+// NewHistogramAlgorithm returns a RecommendationAlgorithm computing the target,
+// lower bound and upper bound with the given estimators.
+func NewHistogramAlgorithm(targetEstimator, lowerBoundEstimator, upperBoundEstimator ResourceEstimator) RecommendationAlgorithm {
+	return &histogramAlgorithm{
+		targetEstimator:     targetEstimator,
+		lowerBoundEstimator: lowerBoundEstimator,
+		upperBoundEstimator: upperBoundEstimator,
+	}
+}
+
+func (a *histogramAlgorithm) Recommend(input RecommendationInput) RecommendedContainerResources {
+	targetEstimator, lowerBoundEstimator, upperBoundEstimator := a.targetEstimator, a.lowerBoundEstimator, a.upperBoundEstimator
+	if a.usePolicy && input.Vpa != nil && hasHistogramPolicy(input.Vpa) {
+		targetEstimator, lowerBoundEstimator, upperBoundEstimator = createHistogramEstimators(GetHistogramParams(input.Vpa))
+	}
+	return RecommendedContainerResources{
+		Target:      WithMinResources(input.MinResources, targetEstimator).GetResourceEstimation(input.AggregateState),
+		LowerBound:  WithMinResources(input.MinResources, lowerBoundEstimator).GetResourceEstimation(input.AggregateState),
+		UpperBound:  WithMinResources(input.MinResources, upperBoundEstimator).GetResourceEstimation(input.AggregateState),
+		Recommender: vpa_types.ContainerRecommenderHistogram,
+	}
+}
+
+// CreateHistogramAlgorithm returns the histogram algorithm with the default
+// percentiles, safety margin and confidence multipliers. They can be
+// overridden by the VPA RecommenderPolicy, and the percentiles also by the
+// deprecated percentiles of the VPA AggregationPolicy.
+func CreateHistogramAlgorithm() RecommendationAlgorithm {
+	targetEstimator, lowerBoundEstimator, upperBoundEstimator := createHistogramEstimators(DefaultHistogramParams())
+	return &histogramAlgorithm{
+		targetEstimator:     targetEstimator,
+		lowerBoundEstimator: lowerBoundEstimator,
+		upperBoundEstimator: upperBoundEstimator,
+		usePolicy:           true,
+	}
+}
+
+// createHistogramEstimators returns the target, lower bound and upper bound
+// estimators for the params.
+func createHistogramEstimators(params HistogramParams) (ResourceEstimator, ResourceEstimator, ResourceEstimator) {
+	targetEstimator := NewPercentileEstimator(params.TargetCPUPercentile, params.TargetMemoryPercentile)
+	lowerBoundEstimator := NewPercentileEstimator(params.LowerBoundCPUPercentile, params.LowerBoundMemoryPercentile)
+	upperBoundEstimator := NewPercentileEstimator(params.UpperBoundCPUPercentile, params.UpperBoundMemoryPercentile)
+
+	targetEstimator = WithMargin(params.SafetyMarginFraction, targetEstimator)
+	lowerBoundEstimator = WithMargin(params.SafetyMarginFraction, lowerBoundEstimator)
+	upperBoundEstimator = WithMargin(params.SafetyMarginFraction, upperBoundEstimator)
+
+	// Apply confidence multiplier to the upper bound estimator. This means
+	// that the updater will be less eager to evict pods with short history
+	// in order to reclaim unused resources.
+	upperBoundEstimator = WithConfidenceMultiplier(params.UpperBoundConfidenceMultiplier, params.UpperBoundConfidenceExponent, upperBoundEstimator)
+
+	// Apply confidence multiplier to the lower bound estimator. This means
+	// that the updater will be less eager to evict pods with short history
+	// in order to provision them with more resources.
+	lowerBoundEstimator = WithConfidenceMultiplier(params.LowerBoundConfidenceMultiplier, params.LowerBoundConfidenceExponent, lowerBoundEstimator)
+
+	return targetEstimator, lowerBoundEstimator, upperBoundEstimator
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+)
+
+func TestGetHistogramPercentilesDefaults(t *testing.T) {
+	percentiles, found := getHistogramPercentiles(nil)
+	assert.False(t, found)
+	assert.Equal(t, defaultHistogramPercentiles, percentiles)
+
+	percentiles, found = getHistogramPercentiles(&vpa_types.AggregationPolicy{})
+	assert.False(t, found)
+	assert.Equal(t, defaultHistogramPercentiles, percentiles)
+}
+
+func TestGetHistogramPercentilesOverrides(t *testing.T) {
+	target, upperBound, invalid := 0.8, 0.99, 1.5
+	percentiles, found := getHistogramPercentiles(&vpa_types.AggregationPolicy{
+		CPUPercentiles:    &vpa_types.RecommendationPercentiles{Target: &target},
+		MemoryPercentiles: &vpa_types.RecommendationPercentiles{UpperBound: &upperBound, LowerBound: &invalid},
+	})
+	assert.True(t, found)
+	expected := defaultHistogramPercentiles
+	expected.targetCPU = 0.8
+	expected.upperBoundMemory = 0.99
+	assert.Equal(t, expected, percentiles)
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+)
+
+// HistogramParams is the configuration of the histogram recommender for a
+// VPA, with all defaults resolved.
+type HistogramParams struct {
+	// Percentiles of the CPU usage histogram recommended as the target and
+	// the bounds.
+	TargetCPUPercentile     float64
+	LowerBoundCPUPercentile float64
+	UpperBoundCPUPercentile float64
+	// Percentiles of the memory peaks histogram recommended as the target
+	// and the bounds. They also apply to ephemeral storage.
+	TargetMemoryPercentile     float64
+	LowerBoundMemoryPercentile float64
+	UpperBoundMemoryPercentile float64
+	// SafetyMarginFraction is the fraction of the usage added to the
+	// recommendation.
+	SafetyMarginFraction float64
+	// Confidence multiplier and exponent of the upper bound.
+	UpperBoundConfidenceMultiplier float64
+	UpperBoundConfidenceExponent   float64
+	// Confidence multiplier and exponent of the lower bound.
+	LowerBoundConfidenceMultiplier float64
+	LowerBoundConfidenceExponent   float64
+}
+
+// DefaultHistogramParams returns the histogram recommender configuration
+// given by the command line flags.
+func DefaultHistogramParams() HistogramParams {
+	params := HistogramParams{
+		SafetyMarginFraction: *safetyMarginFraction,
+
+		// Using the confidence multiplier 1 with exponent +1 means that
+		// the upper bound is multiplied by (1 + 1/history-length-in-days).
+		// See estimator.go to see how the history length and the confidence
+		// multiplier are determined. The formula yields the following multipliers:
+		// No history     : *INF  (do not force pod eviction)
+		// 12h history    : *3    (force pod eviction if the request is > 3 * upper bound)
+		// 24h history    : *2
+		// 1 week history : *1.14
+		UpperBoundConfidenceMultiplier: 1.0,
+		UpperBoundConfidenceExponent:   1.0,
+
+		// Using the confidence multiplier 0.001 with exponent -2 means that
+		// the lower bound is multiplied by the factor (1 + 0.001/history-length-in-days)^-2
+		// (which is very rapidly converging to 1.0).
+		// See estimator.go to see how the history length and the confidence
+		// multiplier are determined. The formula yields the following multipliers:
+		// No history   : *0   (do not force pod eviction)
+		// 5m history   : *0.6 (force pod eviction if the request is < 0.6 * lower bound)
+		// 30m history  : *0.9
+		// 60m history  : *0.95
+		LowerBoundConfidenceMultiplier: 0.001,
+		LowerBoundConfidenceExponent:   -2.0,
+	}
+	params.setPercentiles(defaultHistogramPercentiles)
+	return params
+}
+
+// GetHistogramParams returns the histogram recommender configuration for the
+// VPA. The defaults are overridden by the deprecated percentiles of the VPA
+// AggregationPolicy and then by the VPA RecommenderPolicy. Invalid values,
+// rejected by the admission controller, are ignored.
+func GetHistogramParams(vpa *model.Vpa) HistogramParams {
+	params := DefaultHistogramParams()
+	if percentiles, found := getHistogramPercentiles(vpa.AggregationPolicy); found {
+		params.setPercentiles(percentiles)
+	}
+	params.applyPolicy(vpa.RecommenderPolicy)
+	return params
+}
+
+// hasHistogramPolicy returns true if the VPA overrides any of the default
+// histogram recommender params.
+func hasHistogramPolicy(vpa *model.Vpa) bool {
+	_, found := getHistogramPercentiles(vpa.AggregationPolicy)
+	return found || vpa.RecommenderPolicy != nil
+}
+
+func (p *HistogramParams) setPercentiles(percentiles histogramPercentiles) {
+	p.TargetCPUPercentile = percentiles.targetCPU
+	p.LowerBoundCPUPercentile = percentiles.lowerBoundCPU
+	p.UpperBoundCPUPercentile = percentiles.upperBoundCPU
+	p.TargetMemoryPercentile = percentiles.targetMemory
+	p.LowerBoundMemoryPercentile = percentiles.lowerBoundMemory
+	p.UpperBoundMemoryPercentile = percentiles.upperBoundMemory
+}
+
+func (p *HistogramParams) applyPolicy(policy *vpa_types.RecommenderPolicy) {
+	if policy == nil {
+		return
+	}
+	if cpu := policy.CPUPercentiles; cpu != nil {
+		setPercentile(&p.TargetCPUPercentile, cpu.Target)
+		setPercentile(&p.LowerBoundCPUPercentile, cpu.LowerBound)
+		setPercentile(&p.UpperBoundCPUPercentile, cpu.UpperBound)
+	}
+	if memory := policy.MemoryPercentiles; memory != nil {
+		setPercentile(&p.TargetMemoryPercentile, memory.Target)
+		setPercentile(&p.LowerBoundMemoryPercentile, memory.LowerBound)
+		setPercentile(&p.UpperBoundMemoryPercentile, memory.UpperBound)
+	}
+	if margin := policy.SafetyMarginFraction; margin != nil && *margin >= 0 {
+		p.SafetyMarginFraction = *margin
+	}
+	setConfidenceMultiplier(&p.UpperBoundConfidenceMultiplier, &p.UpperBoundConfidenceExponent, policy.UpperBoundConfidence)
+	setConfidenceMultiplier(&p.LowerBoundConfidenceMultiplier, &p.LowerBoundConfidenceExponent, policy.LowerBoundConfidence)
+}
+
+func setConfidenceMultiplier(multiplier, exponent *float64, confidence *vpa_types.ConfidenceMultiplier) {
+	if confidence == nil {
+		return
+	}
+	if confidence.Multiplier != nil && *confidence.Multiplier >= 0 {
+		*multiplier = *confidence.Multiplier
+	}
+	if confidence.Exponent != nil {
+		*exponent = *confidence.Exponent
+	}
+}
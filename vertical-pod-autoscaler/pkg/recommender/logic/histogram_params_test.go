@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+)
+
+func TestGetHistogramParamsDefaults(t *testing.T) {
+	vpa := model.NewVpa(model.VpaID{Namespace: "default", VpaName: "vpa"}, nil, time.Now())
+	assert.Equal(t, DefaultHistogramParams(), GetHistogramParams(vpa))
+	assert.Equal(t, *safetyMarginFraction, GetHistogramParams(vpa).SafetyMarginFraction)
+}
+
+func TestGetHistogramParamsOverrides(t *testing.T) {
+	target, upperBound, invalid := 0.99, 0.999, 1.5
+	margin, multiplier, exponent := 0.3, 2.0, 0.5
+
+	vpa := model.NewVpa(model.VpaID{Namespace: "default", VpaName: "vpa"}, nil, time.Now())
+	vpa.AggregationPolicy = &vpa_types.AggregationPolicy{
+		CPUPercentiles:    &vpa_types.RecommendationPercentiles{Target: &target},
+		MemoryPercentiles: &vpa_types.RecommendationPercentiles{UpperBound: &upperBound, LowerBound: &invalid},
+	}
+	vpa.RecommenderPolicy = &vpa_types.RecommenderPolicy{
+		SafetyMarginFraction: &margin,
+		UpperBoundConfidence: &vpa_types.ConfidenceMultiplier{Multiplier: &multiplier},
+		LowerBoundConfidence: &vpa_types.ConfidenceMultiplier{Exponent: &exponent},
+	}
+
+	expected := DefaultHistogramParams()
+	expected.TargetCPUPercentile = 0.99
+	expected.UpperBoundMemoryPercentile = 0.999
+	expected.SafetyMarginFraction = 0.3
+	expected.UpperBoundConfidenceMultiplier = 2.0
+	expected.LowerBoundConfidenceExponent = 0.5
+	assert.Equal(t, expected, GetHistogramParams(vpa))
+}
+
+func TestGetHistogramParamsRecommenderPolicyPercentilesTakePrecedence(t *testing.T) {
+	aggregationTarget, aggregationLowerBound, recommenderTarget := 0.95, 0.4, 0.99
+
+	vpa := model.NewVpa(model.VpaID{Namespace: "default", VpaName: "vpa"}, nil, time.Now())
+	vpa.AggregationPolicy = &vpa_types.AggregationPolicy{
+		CPUPercentiles: &vpa_types.RecommendationPercentiles{Target: &aggregationTarget, LowerBound: &aggregationLowerBound},
+	}
+	vpa.RecommenderPolicy = &vpa_types.RecommenderPolicy{
+		CPUPercentiles:    &vpa_types.RecommendationPercentiles{Target: &recommenderTarget},
+		MemoryPercentiles: &vpa_types.RecommendationPercentiles{Target: &recommenderTarget},
+	}
+
+	expected := DefaultHistogramParams()
+	expected.TargetCPUPercentile = 0.99
+	expected.LowerBoundCPUPercentile = 0.4
+	expected.TargetMemoryPercentile = 0.99
+	assert.Equal(t, expected, GetHistogramParams(vpa))
+}
+
+func TestHistogramAlgorithmUsesRecommenderPolicy(t *testing.T) {
+	state := model.NewAggregateContainerState()
+	timestamp := time.Now()
+	for i := 1; i <= 100; i++ {
+		state.AggregateCPUUsage.AddSample(float64(i)/100, 1.0, timestamp)
+	}
+	vpa := model.NewVpa(model.VpaID{Namespace: "default", VpaName: "vpa"}, nil, time.Now())
+	algorithm := CreateHistogramAlgorithm()
+	input := RecommendationInput{AggregateState: state, Vpa: vpa, MinResources: model.Resources{}}
+	defaultTarget := algorithm.Recommend(input).Target[model.ResourceCPU]
+
+	percentile, margin := 0.5, 0.0
+	vpa.RecommenderPolicy = &vpa_types.RecommenderPolicy{
+		CPUPercentiles:       &vpa_types.RecommendationPercentiles{Target: &percentile},
+		SafetyMarginFraction: &margin,
+	}
+	target := algorithm.Recommend(input).Target[model.ResourceCPU]
+	assert.True(t, target < defaultTarget, "target %v should be lower than %v", target, defaultTarget)
+	assert.InEpsilon(t, 0.5, model.CoresFromCPUAmount(target), 0.1)
+}
@@ -18,61 +18,42 @@ package logic
 
 import (
 	"flag"
-	"math"
-	"fmt"
+	"time"
 
+	apiv1 "k8s.io/api/core/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/metrics"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
-	
-	// client-go
-	"time"
-	"encoding/json"
-	"strconv"
-	
-	"k8s.io/client-go/kubernetes"
+	vpa_utils "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
 	"k8s.io/klog"
 )
 
 var (
-	safetyMarginFraction = flag.Float64("recommendation-margin-fraction", 0.15, `Fraction of usage added as the safety margin to the recommended request`)
-	podMinCPUMillicores  = flag.Float64("pod-recommendation-min-cpu-millicores", 25, `Minimum CPU recommendation for a pod`)
-	podMinMemoryMb       = flag.Float64("pod-recommendation-min-memory-mb", 250, `Minimum memory recommendation for a pod`)
-
-	uiOld = 0.0
-	old_count = 0.0 // store the previous value of the requests counter
-
-	control_replicasNum = flag.Float64("control-replicas", 2, `Number of replicasets of pod to be scaled`)
-	control_pNom    		= flag.Float64("control-p-nom", 0.8, ``)
-	control_sla     		= flag.Float64("control-sla", 1.0, `Service level agreement to guarantee`) // set point of the system
-	control_a       		= flag.Float64("control-a", 0.5, `Value from 0 to 1 to change how the control is conservative`)
-	control_a1Nom   		= flag.Float64("control-a1-nom", 0.1963, ``)
-	control_a2Nom   		= flag.Float64("control-a2-nom", 0.002, ``)
-	control_a3Nom   		= flag.Float64("control-a3-nom", 0.5658, ``)
-	control_coreMax 		= flag.Float64("control-core-max", 1.0, `The maximum amount of cores to afford for the scaling`)
-	control_memory 			= flag.Float64("control-memory", 128, `Memory recommended by custom recommender`)
+	safetyMarginFraction    = flag.Float64("recommendation-margin-fraction", 0.15, `Fraction of usage added as the safety margin to the recommended request`)
+	podMinCPUMillicores     = flag.Float64("pod-recommendation-min-cpu-millicores", 25, `Minimum CPU recommendation for a pod`)
+	podMinMemoryMb          = flag.Float64("pod-recommendation-min-memory-mb", 250, `Minimum memory recommendation for a pod`)
+	recommendationAlgorithm = flag.String("recommender-algorithm", string(vpa_types.ContainerRecommenderHistogram), `Recommendation algorithm used for containers that don't select one in the VPA resource policy`)
+
+	control_pNom            = flag.Float64("control-p-nom", 0.8, `Default nominal pole of the controlled system, can be overridden by the VPA controllerPolicy`)
+	control_sla             = flag.Float64("control-sla", 1.0, `Default service level agreement to guarantee, can be overridden by the VPA controllerPolicy`) // set point of the system
+	control_a               = flag.Float64("control-a", 0.5, `Default value from 0 to 1 to change how the control is conservative, can be overridden by the VPA controllerPolicy`)
+	control_a1Nom           = flag.Float64("control-a1-nom", 0.1963, `Default nominal a1 coefficient of the controller model`)
+	control_a2Nom           = flag.Float64("control-a2-nom", 0.002, `Default nominal a2 coefficient of the controller model`)
+	control_a3Nom           = flag.Float64("control-a3-nom", 0.5658, `Default nominal a3 coefficient of the controller model`)
+	control_coreMax         = flag.Float64("control-core-max", 1.0, `Default maximum amount of cores to afford for the scaling, can be overridden by the VPA controllerPolicy`)
+	control_memory          = flag.Float64("control-memory", 128, `Default memory in MB recommended by custom recommender, can be overridden by the VPA controllerPolicy`)
+	control_samplingPeriod  = flag.Duration("control-sampling-period", 30*time.Second, `Default sampling period the controller poles and nominal model are tuned for, can be overridden by the VPA controllerPolicy`)
+	control_maxStalePeriods = flag.Int("control-max-stale-periods", 4, `Number of sampling periods without a controller iteration after which the controller restarts with a reset integral term instead of catching up`)
+
+	control_metricsNamespace   = flag.String("control-metrics-namespace", "nginx-ingress", `Default namespace of the objects described by the controller custom metrics, the VPA namespace is used if empty`)
+	control_responseTimeMetric = flag.String("control-response-time-metric", "response_time", `Default name of the custom metric holding the response time`)
+	control_requestCountMetric = flag.String("control-request-count-metric", "response_count", `Default name of the custom metric holding the request counter`)
+	control_metricsMaxAge      = flag.Duration("control-metrics-max-age", 2*time.Minute, `Maximum age of the controller custom metrics, older metrics are treated as unavailable. Zero disables the check`)
 )
 
-type MetricValueList struct {
-	Kind       string `json:"kind"`
-	APIVersion string `json:"apiVersion"`
-	Metadata   struct {
-		SelfLink string `json:"selfLink"`
-	} `json:"metadata"`
-	Items []struct {
-		DescribedObject struct {
-			Kind          string    `json:"kind"`
-			Namespace     string    `json:"namespace"`
-			Name          string    `json:"name"`
-			ApiVersion 		string 		`json:"apiVersion"`
-		} `json:"describedObject"`
-		MetricName  string    `json:"metricName"`
-		Timestamp	  time.Time `json:"timestamp"`				
-		Value 			string    `json:"value"`
-	} `json:"items"`
-}
-
 // PodResourceRecommender computes resource recommendation for a Vpa object.
 type PodResourceRecommender interface {
-	GetRecommendedPodResources(containerNameToAggregateStateMap model.ContainerNameToAggregateStateMap, customClient *kubernetes.Clientset) RecommendedPodResources
+	GetRecommendedPodResources(containerNameToAggregateStateMap model.ContainerNameToAggregateStateMap, vpa *model.Vpa) RecommendedPodResources
 }
 
 // RecommendedPodResources is a Map from container name to recommended resources.
@@ -87,196 +68,111 @@ type RecommendedContainerResources struct {
 	LowerBound model.Resources
 	// Recommended maximum amount of resources.
 	UpperBound model.Resources
+	// Algorithm that produced the recommendation.
+	Recommender vpa_types.ContainerRecommender
+	// Set if the response-time controller could not use its custom metrics.
+	// Nil otherwise.
+	CustomMetricsProblem *CustomMetricsProblem
+	// Terms of the response-time controller, if it computed the
+	// recommendation. Nil otherwise.
+	ControllerStep *ControllerStep
 }
 
 type podResourceRecommender struct {
-	targetEstimator     ResourceEstimator
-	lowerBoundEstimator ResourceEstimator
-	upperBoundEstimator ResourceEstimator
+	algorithms          map[vpa_types.ContainerRecommender]RecommendationAlgorithm
+	defaultAlgorithm    vpa_types.ContainerRecommender
+	customMetricsClient metrics.CustomMetricsClient
 }
 
-func (r *podResourceRecommender) GetRecommendedPodResources(containerNameToAggregateStateMap model.ContainerNameToAggregateStateMap, customClient *kubernetes.Clientset) RecommendedPodResources {
+func (r *podResourceRecommender) GetRecommendedPodResources(containerNameToAggregateStateMap model.ContainerNameToAggregateStateMap, vpa *model.Vpa) RecommendedPodResources {
 	var recommendation = make(RecommendedPodResources)
 	if len(containerNameToAggregateStateMap) == 0 {
 		return recommendation
 	}
 
-	fraction := 1.0 / float64(len(containerNameToAggregateStateMap))
-	minResources := model.Resources{
-		model.ResourceCPU:    model.ScaleResource(model.CPUAmountFromCores(*podMinCPUMillicores*0.001), fraction),
-		model.ResourceMemory: model.ScaleResource(model.MemoryAmountFromBytes(*podMinMemoryMb*1024*1024), fraction),
+	// The pod minimum is split among the regular containers, which run
+	// together. Init containers run one at a time before them, so each of
+	// them gets the whole pod minimum.
+	regularContainers := 0
+	for _, aggregatedContainerState := range containerNameToAggregateStateMap {
+		if !aggregatedContainerState.IsInitContainer {
+			regularContainers++
+		}
 	}
-
-	recommender := &podResourceRecommender{
-		WithMinResources(minResources, r.targetEstimator),
-		WithMinResources(minResources, r.lowerBoundEstimator),
-		WithMinResources(minResources, r.upperBoundEstimator),
+	podMinResources := getPodMinResources(vpa)
+	minResources := podMinResources
+	if regularContainers > 0 {
+		fraction := 1.0 / float64(regularContainers)
+		minResources = model.Resources{
+			model.ResourceCPU:    model.ScaleResource(podMinResources[model.ResourceCPU], fraction),
+			model.ResourceMemory: model.ScaleResource(podMinResources[model.ResourceMemory], fraction),
+		}
 	}
 
 	for containerName, aggregatedContainerState := range containerNameToAggregateStateMap {
-		recommendation[containerName] = recommender.estimateContainerResources(aggregatedContainerState, customClient, containerName)
+		containerMinResources := minResources
+		if aggregatedContainerState.IsInitContainer {
+			containerMinResources = podMinResources
+		}
+		recommendation[containerName] = r.getAlgorithm(vpa, containerName).Recommend(RecommendationInput{
+			ContainerName:       containerName,
+			AggregateState:      aggregatedContainerState,
+			Vpa:                 vpa,
+			MinResources:        containerMinResources,
+			CustomMetricsClient: r.customMetricsClient,
+		})
 	}
 	return recommendation
 }
 
-// Takes AggregateContainerState and returns a container recommendation.
-func (r *podResourceRecommender) estimateContainerResources(s *model.AggregateContainerState,
-	customClient *kubernetes.Clientset, containerName string) RecommendedContainerResources {
-
-	// fmt.Println("Container Name:", containerName)	
-	if (containerName == "pwitter-front" || containerName == "azure-vote-front") {
-		// custom metrics
-		var metrics MetricValueList
-		metricName := "response_time"
-		err := getMetrics(customClient, &metrics, metricName)
-		if err != nil {
-			klog.Errorf("Cannot get metric %s from Prometheus. Reason: %+v", metricName, err)
-		}
-		response_time := parseValue(metrics.Items[0].Value)
-		// fmt.Println("Response time:", response_time)
-		
-		metricName = "response_count"
-		err = getMetrics(customClient, &metrics, metricName)
-		if err != nil {
-			klog.Errorf("Cannot get metric %s from Prometheus. Reason: %+v", metricName, err)
-		}
-		response_count := parseValue(metrics.Items[0].Value)
-		// fmt.Println("Response count:", response_count)
-
-		requests := response_count - old_count
-		old_count = response_count // new count
-		respTime := response_time
-	
-		req := float64(requests / (*control_replicasNum)) // active requests + queue of requests
-		rt := respTime // mean of the response times
-		error := (*control_sla) - rt
-		ke := ((*control_a)-1)/((*control_pNom)-1)*error
-		ui := uiOld+(1-(*control_pNom))*ke
-		ut := ui+ke
-	
-		targetCore := req*(ut-(*control_a1Nom)-1000.0*(*control_a2Nom))/(1000.0*(*control_a3Nom)*((*control_a1Nom)-ut))
-	
-		approxCore := 0.0
-		if error < 0 {
-			approxCore = *control_coreMax
-		} else {
-			approxCore = math.Min(math.Max(math.Abs(targetCore), *podMinCPUMillicores/1000.0), *control_coreMax)
-		}
-		
-		approxUt := ((1000.0*(*control_a2Nom)+(*control_a1Nom))*req+1000.0*(*control_a1Nom)*(*control_a3Nom)*approxCore)/(req+1000.0*(*control_a3Nom)*approxCore)
-		uiOld = approxUt-ke
-
-		// fmt.Println(
-		// 	"== Controller debug ==",
-		// 	"\nRequests:", req,
-		// 	"\nResponse time:", rt,
-		// 	" s\nerror:", error,
-		// 	"\nke:", ke,
-		// 	"\nui:", ui,
-		// 	"\nut:", ut,
-		// 	"\ntargetCore:", targetCore,
-		// 	"\napproxCore:", approxCore,
-		// 	"\napproxUt:", approxUt,
-		// 	"\nuiOld:", uiOld)
-		
-		fmt.Printf("%.3f, %.3f, %.3f, %.3f, %.3f, %.3f, %.3f, %.3f, %.3f, %.3f\n",
-			req, rt, error, ke, ui, ut, targetCore, approxCore, approxUt, uiOld)
-
-		return RecommendedContainerResources{
-			Target: model.Resources{
-				model.ResourceCPU: model.CPUAmountFromCores(approxCore),
-				model.ResourceMemory: model.MemoryAmountFromBytes(*control_memory*1024*1024),
-			},
-			LowerBound: model.Resources{
-				model.ResourceCPU: model.CPUAmountFromCores(*podMinCPUMillicores/1000.0),
-				model.ResourceMemory: model.MemoryAmountFromBytes(*control_memory*1024*1024),
-			},
-			UpperBound: model.Resources{
-				model.ResourceCPU: model.CPUAmountFromCores(*control_coreMax),
-				model.ResourceMemory: model.MemoryAmountFromBytes(*control_memory*1024*1024),
-			},
-		}
-	} else {
-		return RecommendedContainerResources{
-			r.targetEstimator.GetResourceEstimation(s),
-			r.lowerBoundEstimator.GetResourceEstimation(s),
-			r.upperBoundEstimator.GetResourceEstimation(s),
-		}
+// getPodMinResources returns the minimum CPU and memory recommended for the
+// pods of the VPA, given by the flags unless set in the RecommenderPolicy.
+func getPodMinResources(vpa *model.Vpa) model.Resources {
+	minResources := model.Resources{
+		model.ResourceCPU:    model.CPUAmountFromCores(*podMinCPUMillicores * 0.001),
+		model.ResourceMemory: model.MemoryAmountFromBytes(*podMinMemoryMb * 1024 * 1024),
 	}
-	
-}
-
-// CreatePodResourceRecommender returns the primary recommender.
-func CreatePodResourceRecommender() PodResourceRecommender {
-	targetCPUPercentile := 0.9
-	lowerBoundCPUPercentile := 0.5
-	upperBoundCPUPercentile := 0.95
-
-	targetMemoryPeaksPercentile := 0.9
-	lowerBoundMemoryPeaksPercentile := 0.5
-	upperBoundMemoryPeaksPercentile := 0.95
-
-	targetEstimator := NewPercentileEstimator(targetCPUPercentile, targetMemoryPeaksPercentile)
-	lowerBoundEstimator := NewPercentileEstimator(lowerBoundCPUPercentile, lowerBoundMemoryPeaksPercentile)
-	upperBoundEstimator := NewPercentileEstimator(upperBoundCPUPercentile, upperBoundMemoryPeaksPercentile)
-
-	targetEstimator = WithMargin(*safetyMarginFraction, targetEstimator)
-	lowerBoundEstimator = WithMargin(*safetyMarginFraction, lowerBoundEstimator)
-	upperBoundEstimator = WithMargin(*safetyMarginFraction, upperBoundEstimator)
-
-	// Apply confidence multiplier to the upper bound estimator. This means
-	// that the updater will be less eager to evict pods with short history
-	// in order to reclaim unused resources.
-	// Using the confidence multiplier 1 with exponent +1 means that
-	// the upper bound is multiplied by (1 + 1/history-length-in-days).
-	// See estimator.go to see how the history length and the confidence
-	// multiplier are determined. The formula yields the following multipliers:
-	// No history     : *INF  (do not force pod eviction)
-	// 12h history    : *3    (force pod eviction if the request is > 3 * upper bound)
-	// 24h history    : *2
-	// 1 week history : *1.14
-	upperBoundEstimator = WithConfidenceMultiplier(1.0, 1.0, upperBoundEstimator)
-
-	// Apply confidence multiplier to the lower bound estimator. This means
-	// that the updater will be less eager to evict pods with short history
-	// in order to provision them with more resources.
-	// Using the confidence multiplier 0.001 with exponent -2 means that
-	// the lower bound is multiplied by the factor (1 + 0.001/history-length-in-days)^-2
-	// (which is very rapidly converging to 1.0).
-	// See estimator.go to see how the history length and the confidence
-	// multiplier are determined. The formula yields the following multipliers:
-	// No history   : *0   (do not force pod eviction)
-	// 5m history   : *0.6 (force pod eviction if the request is < 0.6 * lower bound)
-	// 30m history  : *0.9
-	// 60m history  : *0.95
-	lowerBoundEstimator = WithConfidenceMultiplier(0.001, -2.0, lowerBoundEstimator)
-
-	return &podResourceRecommender{
-		targetEstimator,
-		lowerBoundEstimator,
-		upperBoundEstimator}
+	if vpa == nil || vpa.RecommenderPolicy == nil {
+		return minResources
+	}
+	if cpu, found := vpa.RecommenderPolicy.PodMinResources[apiv1.ResourceCPU]; found {
+		minResources[model.ResourceCPU] = model.CPUAmountFromCores(float64(cpu.MilliValue()) / 1000.0)
+	}
+	if memory, found := vpa.RecommenderPolicy.PodMinResources[apiv1.ResourceMemory]; found {
+		minResources[model.ResourceMemory] = model.MemoryAmountFromBytes(float64(memory.Value()))
+	}
+	return minResources
 }
 
-
-func getMetrics(clientset *kubernetes.Clientset, metrics *MetricValueList, metricName string) error {
-	data, err := clientset.RESTClient().Get().AbsPath("apis/custom.metrics.k8s.io/v1beta1/namespaces/nginx-ingress/pods/*/"+metricName).DoRaw()
-	if err != nil {
-		return err
+// getAlgorithm returns the algorithm selected for the container by the VPA
+// resource policy. The default algorithm is used if there is no VPA, the
+// policy doesn't select one or selects an algorithm that is not registered.
+func (r *podResourceRecommender) getAlgorithm(vpa *model.Vpa, containerName string) RecommendationAlgorithm {
+	if vpa == nil {
+		return r.algorithms[r.defaultAlgorithm]
+	}
+	name := vpa_utils.GetContainerRecommender(containerName, vpa.ResourcePolicy, r.defaultAlgorithm)
+	algorithm, found := r.algorithms[name]
+	if !found {
+		klog.Warningf("Unknown recommendation algorithm %s for container %s of VPA %s/%s, using %s",
+			name, containerName, vpa.ID.Namespace, vpa.ID.VpaName, r.defaultAlgorithm)
+		return r.algorithms[r.defaultAlgorithm]
 	}
-	err = json.Unmarshal(data, &metrics)
-	return err
+	return algorithm
 }
 
-func parseValue(value string) (float64) {
-	multiplier := 1.0
-	if value[len(value)-1] == 'm' {
-		multiplier = 0.001
-		value = value[:len(value)-1]
+// CreatePodResourceRecommender returns the primary recommender. It uses the
+// registered recommendation algorithms, selected per container by the VPA
+// resource policy. The custom metrics client is passed to the algorithms.
+func CreatePodResourceRecommender(customMetricsClient metrics.CustomMetricsClient) PodResourceRecommender {
+	algorithms := createRecommendationAlgorithms()
+	defaultAlgorithm := vpa_types.ContainerRecommender(*recommendationAlgorithm)
+	if _, found := algorithms[defaultAlgorithm]; !found {
+		klog.Fatalf("Unknown recommendation algorithm %s, registered algorithms: %v", defaultAlgorithm, RegisteredRecommendationAlgorithms())
 	}
-
-	fValue, err := strconv.ParseFloat(value, 64)
-	if err != nil || fValue < 0 {
-		return 0.0
+	return &podResourceRecommender{
+		algorithms:          algorithms,
+		defaultAlgorithm:    defaultAlgorithm,
+		customMetricsClient: customMetricsClient,
 	}
-	return fValue * multiplier
 }
@@ -17,29 +17,63 @@ limitations under the License.
 package logic
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/metrics"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
 )
 
+func newTestPodResourceRecommender(estimator ResourceEstimator, client metrics.CustomMetricsClient) *podResourceRecommender {
+	histogram := NewHistogramAlgorithm(estimator, estimator, estimator)
+	return &podResourceRecommender{
+		algorithms: map[vpa_types.ContainerRecommender]RecommendationAlgorithm{
+			vpa_types.ContainerRecommenderHistogram:              histogram,
+			vpa_types.ContainerRecommenderResponseTimeController: NewResponseTimeControllerAlgorithm(histogram),
+		},
+		defaultAlgorithm:    vpa_types.ContainerRecommenderHistogram,
+		customMetricsClient: client,
+	}
+}
+
 func TestMinResourcesApplied(t *testing.T) {
 	constEstimator := NewConstEstimator(model.Resources{
 		model.ResourceCPU:    model.CPUAmountFromCores(0.001),
 		model.ResourceMemory: model.MemoryAmountFromBytes(1e6),
 	})
-	recommender := podResourceRecommender{
-		constEstimator,
-		constEstimator,
-		constEstimator}
+	recommender := newTestPodResourceRecommender(constEstimator, nil)
 
+	vpa := model.NewVpa(model.VpaID{Namespace: "default", VpaName: "vpa"}, nil, time.Now())
 	containerNameToAggregateStateMap := model.ContainerNameToAggregateStateMap{
 		"container-1": &model.AggregateContainerState{},
 	}
 
-	recommendedResources := recommender.GetRecommendedPodResources(containerNameToAggregateStateMap)
+	recommendedResources := recommender.GetRecommendedPodResources(containerNameToAggregateStateMap, vpa)
 	assert.Equal(t, model.CPUAmountFromCores(*podMinCPUMillicores/1000), recommendedResources["container-1"].Target[model.ResourceCPU])
 	assert.Equal(t, model.MemoryAmountFromBytes(*podMinMemoryMb*1024*1024), recommendedResources["container-1"].Target[model.ResourceMemory])
+	assert.Equal(t, vpa_types.ContainerRecommenderHistogram, recommendedResources["container-1"].Recommender)
+}
+
+func TestRecommendWithoutVpa(t *testing.T) {
+	constEstimator := NewConstEstimator(model.Resources{
+		model.ResourceCPU:    model.CPUAmountFromCores(0.001),
+		model.ResourceMemory: model.MemoryAmountFromBytes(1e6),
+	})
+	recommender := newTestPodResourceRecommender(constEstimator, nil)
+	containerNameToAggregateStateMap := model.ContainerNameToAggregateStateMap{
+		"container-1": &model.AggregateContainerState{},
+	}
+
+	recommendedResources := recommender.GetRecommendedPodResources(containerNameToAggregateStateMap, nil)
+	assert.Equal(t, model.CPUAmountFromCores(*podMinCPUMillicores/1000), recommendedResources["container-1"].Target[model.ResourceCPU])
+	assert.Equal(t, vpa_types.ContainerRecommenderHistogram, recommendedResources["container-1"].Recommender)
 }
 
 func TestMinResourcesSplitAcrossContainers(t *testing.T) {
@@ -47,19 +81,224 @@ func TestMinResourcesSplitAcrossContainers(t *testing.T) {
 		model.ResourceCPU:    model.CPUAmountFromCores(0.001),
 		model.ResourceMemory: model.MemoryAmountFromBytes(1e6),
 	})
-	recommender := podResourceRecommender{
-		constEstimator,
-		constEstimator,
-		constEstimator}
+	recommender := newTestPodResourceRecommender(constEstimator, nil)
 
+	vpa := model.NewVpa(model.VpaID{Namespace: "default", VpaName: "vpa"}, nil, time.Now())
 	containerNameToAggregateStateMap := model.ContainerNameToAggregateStateMap{
 		"container-1": &model.AggregateContainerState{},
 		"container-2": &model.AggregateContainerState{},
 	}
 
-	recommendedResources := recommender.GetRecommendedPodResources(containerNameToAggregateStateMap)
+	recommendedResources := recommender.GetRecommendedPodResources(containerNameToAggregateStateMap, vpa)
 	assert.Equal(t, model.CPUAmountFromCores((*podMinCPUMillicores/1000)/2), recommendedResources["container-1"].Target[model.ResourceCPU])
 	assert.Equal(t, model.CPUAmountFromCores((*podMinCPUMillicores/1000)/2), recommendedResources["container-1"].Target[model.ResourceCPU])
 	assert.Equal(t, model.MemoryAmountFromBytes((*podMinMemoryMb*1024*1024)/2), recommendedResources["container-2"].Target[model.ResourceMemory])
 	assert.Equal(t, model.MemoryAmountFromBytes((*podMinMemoryMb*1024*1024)/2), recommendedResources["container-2"].Target[model.ResourceMemory])
 }
+
+func TestMinResourcesWithInitContainer(t *testing.T) {
+	constEstimator := NewConstEstimator(model.Resources{
+		model.ResourceCPU:    model.CPUAmountFromCores(0.001),
+		model.ResourceMemory: model.MemoryAmountFromBytes(1e6),
+	})
+	recommender := newTestPodResourceRecommender(constEstimator, nil)
+
+	vpa := model.NewVpa(model.VpaID{Namespace: "default", VpaName: "vpa"}, nil, time.Now())
+	containerNameToAggregateStateMap := model.ContainerNameToAggregateStateMap{
+		"init":      &model.AggregateContainerState{IsInitContainer: true},
+		"container": &model.AggregateContainerState{},
+	}
+
+	// The init container doesn't take a share of the regular container's
+	// minimum and gets the whole pod minimum itself.
+	recommendedResources := recommender.GetRecommendedPodResources(containerNameToAggregateStateMap, vpa)
+	for _, containerName := range []string{"init", "container"} {
+		assert.Equal(t, model.CPUAmountFromCores(*podMinCPUMillicores/1000), recommendedResources[containerName].Target[model.ResourceCPU], containerName)
+		assert.Equal(t, model.MemoryAmountFromBytes(*podMinMemoryMb*1024*1024), recommendedResources[containerName].Target[model.ResourceMemory], containerName)
+	}
+}
+
+func TestMinResourcesFromRecommenderPolicy(t *testing.T) {
+	constEstimator := NewConstEstimator(model.Resources{
+		model.ResourceCPU:    model.CPUAmountFromCores(0.001),
+		model.ResourceMemory: model.MemoryAmountFromBytes(1e6),
+	})
+	recommender := newTestPodResourceRecommender(constEstimator, nil)
+
+	vpa := model.NewVpa(model.VpaID{Namespace: "default", VpaName: "vpa"}, nil, time.Now())
+	vpa.RecommenderPolicy = &vpa_types.RecommenderPolicy{
+		PodMinResources: apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("200m")},
+	}
+	containerNameToAggregateStateMap := model.ContainerNameToAggregateStateMap{
+		"container-1": &model.AggregateContainerState{},
+		"container-2": &model.AggregateContainerState{},
+	}
+
+	recommendedResources := recommender.GetRecommendedPodResources(containerNameToAggregateStateMap, vpa)
+	assert.Equal(t, model.CPUAmountFromCores(0.1), recommendedResources["container-1"].Target[model.ResourceCPU])
+	assert.Equal(t, model.MemoryAmountFromBytes((*podMinMemoryMb*1024*1024)/2), recommendedResources["container-1"].Target[model.ResourceMemory])
+}
+
+type fakeCustomMetricsClient struct {
+	values map[string]*metrics.CustomMetricValueList
+	err    error
+}
+
+func (c *fakeCustomMetricsClient) GetObjectsMetric(namespace, resource, name string, selector labels.Selector, metricName string) (*metrics.CustomMetricValueList, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.values[metricName], nil
+}
+
+func metricValues(value string, timestamp time.Time) *metrics.CustomMetricValueList {
+	return &metrics.CustomMetricValueList{
+		Items: []metrics.CustomMetricValue{{Value: value, Timestamp: metav1.NewTime(timestamp)}},
+	}
+}
+
+func newControllerVpa() *model.Vpa {
+	recommender := vpa_types.ContainerRecommenderResponseTimeController
+	vpa := model.NewVpa(model.VpaID{Namespace: "default", VpaName: "vpa"}, nil, time.Now())
+	vpa.ResourcePolicy = &vpa_types.PodResourcePolicy{
+		ContainerPolicies: []vpa_types.ContainerResourcePolicy{{
+			ContainerName: "container-1",
+			Recommender:   &recommender,
+		}},
+	}
+	return vpa
+}
+
+func TestControllerCustomMetricsUnavailable(t *testing.T) {
+	constEstimator := NewConstEstimator(model.Resources{
+		model.ResourceCPU:    model.CPUAmountFromCores(2),
+		model.ResourceMemory: model.MemoryAmountFromBytes(1e9),
+	})
+	now := time.Now()
+	testCases := []struct {
+		name           string
+		client         metrics.CustomMetricsClient
+		expectedReason string
+	}{
+		{
+			name:           "no client",
+			expectedReason: CustomMetricsFetchFailed,
+		},
+		{
+			name:           "fetch error",
+			client:         &fakeCustomMetricsClient{err: fmt.Errorf("not found")},
+			expectedReason: CustomMetricsFetchFailed,
+		},
+		{
+			name:           "no values",
+			client:         &fakeCustomMetricsClient{values: map[string]*metrics.CustomMetricValueList{"response_time": {}}},
+			expectedReason: CustomMetricsMissing,
+		},
+		{
+			name: "stale value",
+			client: &fakeCustomMetricsClient{values: map[string]*metrics.CustomMetricValueList{
+				"response_time": metricValues("250m", now.Add(-time.Hour)),
+			}},
+			expectedReason: CustomMetricsStale,
+		},
+		{
+			name: "malformed value",
+			client: &fakeCustomMetricsClient{values: map[string]*metrics.CustomMetricValueList{
+				"response_time":  metricValues("250m", now),
+				"response_count": metricValues("NaN", now),
+			}},
+			expectedReason: CustomMetricsMalformed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recommender := newTestPodResourceRecommender(constEstimator, tc.client)
+			containerNameToAggregateStateMap := model.ContainerNameToAggregateStateMap{
+				"container-1": &model.AggregateContainerState{},
+			}
+
+			// Without previous recommendation the histogram recommender is used.
+			vpa := newControllerVpa()
+			resources := recommender.GetRecommendedPodResources(containerNameToAggregateStateMap, vpa)["container-1"]
+			assert.Equal(t, vpa_types.ContainerRecommenderHistogram, resources.Recommender)
+			assert.Equal(t, model.CPUAmountFromCores(2), resources.Target[model.ResourceCPU])
+			if assert.NotNil(t, resources.CustomMetricsProblem) {
+				assert.Equal(t, tc.expectedReason, resources.CustomMetricsProblem.Reason)
+				assert.Equal(t, CustomMetricsFallbackHistogram, resources.CustomMetricsProblem.Fallback)
+			}
+			assert.Equal(t, model.ControllerState{}, *vpa.ControllerStateForContainer("container-1"))
+
+			// The previous recommendation is held.
+			vpa.Recommendation = &vpa_types.RecommendedPodResources{
+				ContainerRecommendations: []vpa_types.RecommendedContainerResources{{
+					ContainerName: "container-1",
+					Target: apiv1.ResourceList{
+						apiv1.ResourceCPU:    resource.MustParse("300m"),
+						apiv1.ResourceMemory: resource.MustParse("128Mi"),
+					},
+					UncappedTarget: apiv1.ResourceList{
+						apiv1.ResourceCPU:    resource.MustParse("500m"),
+						apiv1.ResourceMemory: resource.MustParse("128Mi"),
+					},
+				}},
+			}
+			resources = recommender.GetRecommendedPodResources(containerNameToAggregateStateMap, vpa)["container-1"]
+			assert.Equal(t, vpa_types.ContainerRecommenderResponseTimeController, resources.Recommender)
+			assert.Equal(t, model.CPUAmountFromCores(0.5), resources.Target[model.ResourceCPU])
+			assert.Equal(t, model.MemoryAmountFromBytes(128*1024*1024), resources.Target[model.ResourceMemory])
+			if assert.NotNil(t, resources.CustomMetricsProblem) {
+				assert.Equal(t, tc.expectedReason, resources.CustomMetricsProblem.Reason)
+				assert.Equal(t, CustomMetricsFallbackHold, resources.CustomMetricsProblem.Fallback)
+			}
+		})
+	}
+}
+
+func TestControllerCustomMetricsAvailable(t *testing.T) {
+	constEstimator := NewConstEstimator(model.Resources{})
+	now := time.Now()
+	client := &fakeCustomMetricsClient{values: map[string]*metrics.CustomMetricValueList{
+		"response_time":  metricValues("250m", now),
+		"response_count": metricValues("100", now),
+	}}
+	recommender := newTestPodResourceRecommender(constEstimator, client)
+	containerNameToAggregateStateMap := model.ContainerNameToAggregateStateMap{
+		"container-1": &model.AggregateContainerState{},
+	}
+
+	// The first sample of the request counter only sets the baseline.
+	vpa := newControllerVpa()
+	resources := recommender.GetRecommendedPodResources(containerNameToAggregateStateMap, vpa)["container-1"]
+	if assert.NotNil(t, resources.CustomMetricsProblem) {
+		assert.Equal(t, CustomMetricsNoRate, resources.CustomMetricsProblem.Reason)
+	}
+	assert.Nil(t, resources.ControllerStep)
+	state := vpa.ControllerStateForContainer("container-1")
+	assert.Equal(t, 100.0, state.RequestCounters["/"].Value)
+	assert.True(t, state.LastUpdate.IsZero())
+
+	state.RequestCounters["/"] = model.CounterSample{Value: 40, Timestamp: now.Add(-30 * time.Second)}
+	resources = recommender.GetRecommendedPodResources(containerNameToAggregateStateMap, vpa)["container-1"]
+	assert.Equal(t, vpa_types.ContainerRecommenderResponseTimeController, resources.Recommender)
+	assert.Nil(t, resources.CustomMetricsProblem)
+	if assert.NotNil(t, resources.ControllerStep) {
+		assert.Equal(t, 0.25, resources.ControllerStep.ResponseTime)
+	}
+	assert.Equal(t, 100.0, state.RequestCounters["/"].Value)
+	assert.False(t, state.LastUpdate.IsZero())
+}
+
+func TestParseValue(t *testing.T) {
+	value, err := parseValue("250m")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.25, value)
+
+	value, err = parseValue("12")
+	assert.NoError(t, err)
+	assert.Equal(t, 12.0, value)
+
+	for _, malformed := range []string{"", "abc", "-1"} {
+		_, err = parseValue(malformed)
+		assert.Error(t, err, malformed)
+	}
+}
@@ -22,10 +22,14 @@ import (
 
 	kube_flag "k8s.io/apiserver/pkg/util/flag"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/common"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/history"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/routines"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/leaderelection"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics"
 	metrics_recommender "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/recommender"
+	kube_client "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog"
 )
@@ -38,17 +42,32 @@ var (
 	address                = flag.String("address", ":8942", "The address to expose Prometheus metrics.")
 	kubeApiQps             = flag.Float64("kube-api-qps", 5.0, `QPS limit when making requests to Kubernetes apiserver`)
 	kubeApiBurst           = flag.Float64("kube-api-burst", 10.0, `QPS burst limit when making requests to Kubernetes apiserver`)
+	recommenderName        = flag.String("recommender-name", input.DefaultRecommenderName, `Name of the recommender. Only the VPAs selecting this recommender in their recommenders field are handled, the default recommender also handles the VPAs that don't select any`)
 
 	storage = flag.String("storage", "", `Specifies storage mode. Supported values: prometheus, checkpoint (default)`)
 	// prometheus history provider configs
-	historyLength       = flag.String("history-length", "8d", `How much time back prometheus have to be queried to get historical metrics`)
-	podLabelPrefix      = flag.String("pod-label-prefix", "pod_label_", `Which prefix to look for pod labels in metrics`)
-	podLabelsMetricName = flag.String("metric-for-pod-labels", "up{job=\"kubernetes-pods\"}", `Which metric to look for pod labels in metrics`)
-	podNamespaceLabel   = flag.String("pod-namespace-label", "kubernetes_namespace", `Label name to look for container names`)
-	podNameLabel        = flag.String("pod-name-label", "kubernetes_pod_name", `Label name to look for container names`)
-	ctrNamespaceLabel   = flag.String("container-namespace-label", "namespace", `Label name to look for container names`)
-	ctrPodNameLabel     = flag.String("container-pod-name-label", "pod_name", `Label name to look for container names`)
-	ctrNameLabel        = flag.String("container-name-label", "name", `Label name to look for container names`)
+	historyLength                = flag.String("history-length", "8d", `How much time back prometheus have to be queried to get historical metrics`)
+	podLabelPrefix               = flag.String("pod-label-prefix", "pod_label_", `Which prefix to look for pod labels in metrics`)
+	podLabelsMetricName          = flag.String("metric-for-pod-labels", "up{job=\"kubernetes-pods\"}", `Which metric to look for pod labels in metrics`)
+	podNamespaceLabel            = flag.String("pod-namespace-label", "kubernetes_namespace", `Label name to look for container names`)
+	podNameLabel                 = flag.String("pod-name-label", "kubernetes_pod_name", `Label name to look for container names`)
+	ctrNamespaceLabel            = flag.String("container-namespace-label", "namespace", `Label name to look for container names`)
+	ctrPodNameLabel              = flag.String("container-pod-name-label", "pod_name", `Label name to look for container names`)
+	ctrNameLabel                 = flag.String("container-name-label", "name", `Label name to look for container names`)
+	historyReconcileInterval     = flag.Duration("prometheus-reconcile-interval", 0, `How often the usage aggregated from the real time metrics is replaced with the history from Prometheus, filling the gaps in the metrics. The history is fetched in the background, so the interval should be well above the time of fetching it, e.g. hours. 0 loads the history only at startup`)
+	historyResolution            = flag.String("history-resolution", "1h", `Resolution at which Prometheus is queried for historical metrics`)
+	queryChunkLength             = flag.Duration("prometheus-query-chunk-length", 24*time.Hour, `Maximum time range of a single Prometheus query, longer history is fetched in several queries`)
+	queryConcurrency             = flag.Int("prometheus-query-concurrency", 1, `Number of Prometheus queries of the history run concurrently`)
+	cpuQuery                     = flag.String("prometheus-cpu-query", "", `Template of the Prometheus query of the CPU usage of containers, in cores. Defaults to the rate of the cAdvisor metric`)
+	memoryQuery                  = flag.String("prometheus-memory-query", "", `Template of the Prometheus query of the memory usage of containers, in bytes. Defaults to the cAdvisor metric`)
+	ephemeralStorageQuery        = flag.String("prometheus-ephemeral-storage-query", "", `Template of the Prometheus query of the ephemeral storage usage of containers, in bytes. Defaults to the cAdvisor metric`)
+	podLabelsQuery               = flag.String("prometheus-pod-labels-query", "", `Template of the Prometheus query of the pod labels. Defaults to --metric-for-pod-labels`)
+	prometheusHeaders            = flag.String("prometheus-headers", "", `Comma separated list of name=value headers sent to Prometheus`)
+	prometheusBearerTokenFile    = flag.String("prometheus-bearer-token-file", "", `File with the bearer token sent to Prometheus`)
+	prometheusCAFile             = flag.String("prometheus-ca-file", "", `File with the PEM encoded CA certificate verifying Prometheus`)
+	prometheusCertFile           = flag.String("prometheus-cert-file", "", `File with the PEM encoded client certificate sent to Prometheus`)
+	prometheusKeyFile            = flag.String("prometheus-key-file", "", `File with the PEM encoded key of the client certificate sent to Prometheus`)
+	prometheusInsecureSkipVerify = flag.Bool("prometheus-insecure-skip-verify", false, `Skip the verification of the certificate of Prometheus`)
 )
 
 func main() {
@@ -57,17 +76,39 @@ func main() {
 
 	config := createKubeConfig(float32(*kubeApiQps), int(*kubeApiBurst))
 
-	healthCheck := metrics.NewHealthCheck(*metricsFetcherInterval*5, true)
+	// Inactivity is checked once this replica runs the recommender, which
+	// may wait for the leadership.
+	healthCheck := metrics.NewHealthCheck(*metricsFetcherInterval*5, false)
 	metrics.Initialize(*address, healthCheck)
 	metrics_recommender.Register()
 
+	leaderelection.Run(kube_client.NewForConfigOrDie(config), "vpa-recommender-"+*recommenderName, func() {
+		healthCheck.StartMonitoring()
+		run(config, healthCheck)
+	})
+}
+
+func run(config *rest.Config, healthCheck *metrics.HealthCheck) {
 	useCheckpoints := *storage != "prometheus"
-	recommender := routines.NewRecommender(config, *checkpointsGCInterval, useCheckpoints)
+	recommender := routines.NewRecommender(config, *checkpointsGCInterval, useCheckpoints, *recommenderName)
+	var historyProvider history.HistoryProvider
 	if useCheckpoints {
 		recommender.GetClusterStateFeeder().InitFromCheckpoints()
 	} else {
+		headers, err := history.ParseHeaders(*prometheusHeaders)
+		if err != nil {
+			klog.Fatalf("Invalid Prometheus headers: %v", err)
+		}
 		config := history.PrometheusHistoryProviderConfig{
-			Address:                *prometheusAddress,
+			Client: history.PrometheusClientConfig{
+				Address:            *prometheusAddress,
+				Headers:            headers,
+				BearerTokenFile:    *prometheusBearerTokenFile,
+				CAFile:             *prometheusCAFile,
+				CertFile:           *prometheusCertFile,
+				KeyFile:            *prometheusKeyFile,
+				InsecureSkipVerify: *prometheusInsecureSkipVerify,
+			},
 			HistoryLength:          *historyLength,
 			PodLabelPrefix:         *podLabelPrefix,
 			PodLabelsMetricName:    *podLabelsMetricName,
@@ -77,16 +118,37 @@ func main() {
 			CtrPodNameLabel:        *ctrPodNameLabel,
 			CtrNameLabel:           *ctrNameLabel,
 			CadvisorMetricsJobName: *prometheusJobName,
+			HistoryResolution:      *historyResolution,
+			QueryChunkLength:       *queryChunkLength,
+			QueryConcurrency:       *queryConcurrency,
+			CPUQuery:               *cpuQuery,
+			MemoryQuery:            *memoryQuery,
+			EphemeralStorageQuery:  *ephemeralStorageQuery,
+			PodLabelsQuery:         *podLabelsQuery,
+		}
+		historyProvider, err = history.NewPrometheusHistoryProvider(config)
+		if err != nil {
+			klog.Fatalf("Failed to create the Prometheus history provider: %v", err)
 		}
-		recommender.GetClusterStateFeeder().InitFromHistoryProvider(history.NewPrometheusHistoryProvider(config))
+		recommender.GetClusterStateFeeder().InitFromHistoryProvider(historyProvider)
 	}
 
+	// The history is fetched in the background and applied between the loops,
+	// so that slow queries don't delay the recommendations.
+	var historyChan <-chan map[model.PodID]*history.PodHistory
+	if historyProvider != nil && *historyReconcileInterval > 0 {
+		historyChan = input.FetchClusterHistoryPeriodically(historyProvider, *historyReconcileInterval)
+	}
 	ticker := time.Tick(*metricsFetcherInterval)
 	for range ticker {
+		select {
+		case clusterHistory := <-historyChan:
+			recommender.GetClusterStateFeeder().ReconcileWithHistory(clusterHistory)
+		default:
+		}
 		recommender.RunOnce()
 		healthCheck.UpdateLastActivity()
 	}
-
 }
 
 func createKubeConfig(kubeApiQps float32, kubeApiBurst int) *rest.Config {
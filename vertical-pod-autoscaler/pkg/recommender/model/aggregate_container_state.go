@@ -14,7 +14,8 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// VPA collects CPU and memory usage measurements from all containers running in
+// VPA collects CPU, memory and ephemeral storage usage measurements from all
+// containers (including init containers) running in
 // the cluster and aggregates them in memory in structures called
 // AggregateContainerState.
 // During aggregation the usage samples are grouped together by the key called
@@ -43,6 +44,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/util"
+	"k8s.io/klog"
 )
 
 // ContainerNameToAggregateStateMap maps a container name to AggregateContainerState
@@ -71,7 +73,8 @@ type ContainerStateAggregator interface {
 // AggregateContainerState holds input signals aggregated from a set of containers.
 // It can be used as an input to compute the recommendation.
 // The CPU and memory distributions use decaying histograms by default
-// (see NewAggregateContainerState()).
+// (see NewAggregateContainerState()), with the parameters of its
+// AggregationConfig.
 // Implements ContainerStateAggregator interface.
 type AggregateContainerState struct {
 	// AggregateCPUUsage is a distribution of all CPU samples.
@@ -79,16 +82,32 @@ type AggregateContainerState struct {
 	// AggregateMemoryPeaks is a distribution of memory peaks from all containers:
 	// each container should add one peak per memory aggregation interval (e.g. once every 24h).
 	AggregateMemoryPeaks util.Histogram
+	// AggregateEphemeralStoragePeaks is a distribution of ephemeral storage
+	// peaks from all containers: each container should add one peak per
+	// memory aggregation interval.
+	AggregateEphemeralStoragePeaks util.Histogram
 	// Note: first/last sample timestamps as well as the sample count are based only on CPU samples.
 	FirstSampleStart  time.Time
 	LastSampleStart   time.Time
 	TotalSamplesCount int
+	// Number of OOMs recorded for the containers and the time of the last
+	// one. They are not checkpointed, so they cover the OOMs seen since the
+	// recommender started.
+	OOMCount    int
+	LastOOMTime time.Time
+	// IsInitContainer is set if the aggregated containers are init
+	// containers. They run before the regular containers of the pod, so
+	// their recommendations are not added to those of the regular ones.
+	IsInitContainer bool
+	// config holds the parameters of the histograms and of the expiration.
+	config AggregationConfig
 }
 
 // MergeContainerState merges two AggregateContainerStates.
 func (a *AggregateContainerState) MergeContainerState(other *AggregateContainerState) {
 	a.AggregateCPUUsage.Merge(other.AggregateCPUUsage)
 	a.AggregateMemoryPeaks.Merge(other.AggregateMemoryPeaks)
+	a.AggregateEphemeralStoragePeaks.Merge(other.AggregateEphemeralStoragePeaks)
 
 	if !other.FirstSampleStart.IsZero() && other.FirstSampleStart.Before(a.FirstSampleStart) {
 		a.FirstSampleStart = other.FirstSampleStart
@@ -97,16 +116,69 @@ func (a *AggregateContainerState) MergeContainerState(other *AggregateContainerS
 		a.LastSampleStart = other.LastSampleStart
 	}
 	a.TotalSamplesCount += other.TotalSamplesCount
+	a.IsInitContainer = a.IsInitContainer || other.IsInitContainer
+	a.OOMCount += other.OOMCount
+	if other.LastOOMTime.After(a.LastOOMTime) {
+		a.LastOOMTime = other.LastOOMTime
+	}
 }
 
-// NewAggregateContainerState returns a new, empty AggregateContainerState.
+// NewAggregateContainerState returns a new, empty AggregateContainerState
+// with the default aggregation parameters.
 func NewAggregateContainerState() *AggregateContainerState {
-	return &AggregateContainerState{
-		AggregateCPUUsage:    util.NewDecayingHistogram(CPUHistogramOptions, CPUHistogramDecayHalfLife),
-		AggregateMemoryPeaks: util.NewDecayingHistogram(MemoryHistogramOptions, MemoryHistogramDecayHalfLife),
+	return NewAggregateContainerStateWithConfig(DefaultAggregationConfig())
+}
+
+// NewAggregateContainerStateWithConfig returns a new, empty
+// AggregateContainerState with the given aggregation parameters.
+func NewAggregateContainerStateWithConfig(config AggregationConfig) *AggregateContainerState {
+	a := &AggregateContainerState{config: config}
+	a.resetHistograms()
+	return a
+}
+
+func (a *AggregateContainerState) resetHistograms() {
+	backend := a.config.histogramBackend()
+	a.AggregateCPUUsage = backend.NewHistogram(a.config.cpuHistogramOptions(), a.config.CPUHistogramDecayHalfLife)
+	a.AggregateMemoryPeaks = backend.NewHistogram(a.config.memoryHistogramOptions(), a.config.MemoryHistogramDecayHalfLife)
+	a.AggregateEphemeralStoragePeaks = backend.NewHistogram(a.config.ephemeralStorageHistogramOptions(), a.config.MemoryHistogramDecayHalfLife)
+}
+
+// Config returns the aggregation parameters of the AggregateContainerState.
+func (a *AggregateContainerState) Config() AggregationConfig {
+	return a.config
+}
+
+// UpdateConfig changes the aggregation parameters. The aggregated history is
+// migrated to the new histograms through a checkpoint: the samples keep their
+// decayed weights and decay with the new half-lives from then on. If the
+// histogram buckets or the histogram backend change, the history can't be
+// migrated and is discarded.
+func (a *AggregateContainerState) UpdateConfig(config AggregationConfig) {
+	if a.config == config {
+		return
+	}
+	checkpoint, err := a.SaveToCheckpoint()
+	a.config = config
+	a.resetHistograms()
+	if err == nil {
+		err = a.LoadFromCheckpoint(checkpoint)
+	}
+	if err != nil {
+		klog.V(2).Infof("Discarding usage history after the change of the aggregation parameters: %v", err)
+		a.resetUsage()
 	}
 }
 
+// resetUsage discards the aggregated usage, keeping the aggregation
+// parameters and the recorded OOMs.
+func (a *AggregateContainerState) resetUsage() {
+	a.resetHistograms()
+	a.FirstSampleStart = time.Time{}
+	a.LastSampleStart = time.Time{}
+	a.TotalSamplesCount = 0
+}
+
 // AddSample aggregates a single usage sample.
 func (a *AggregateContainerState) AddSample(sample *ContainerUsageSample) {
 	switch sample.Resource {
@@ -114,20 +186,33 @@ func (a *AggregateContainerState) AddSample(sample *ContainerUsageSample) {
 		a.addCPUSample(sample)
 	case ResourceMemory:
 		a.AggregateMemoryPeaks.AddSample(BytesFromMemoryAmount(sample.Usage), 1.0, sample.MeasureStart)
+	case ResourceEphemeralStorage:
+		a.AggregateEphemeralStoragePeaks.AddSample(BytesFromEphemeralStorageAmount(sample.Usage), 1.0, sample.MeasureStart)
 	default:
 		panic(fmt.Sprintf("AddSample doesn't support resource '%s'", sample.Resource))
 	}
 }
 
+// RecordOOM counts an OOM of a container. The memory sample bumped up after
+// the OOM is aggregated with AddSample().
+func (a *AggregateContainerState) RecordOOM(timestamp time.Time) {
+	a.OOMCount++
+	if timestamp.After(a.LastOOMTime) {
+		a.LastOOMTime = timestamp
+	}
+}
+
 // SubtractSample removes a single usage sample from an aggregation.
 // The subtracted sample should be equal to some sample that was aggregated with
 // AddSample() in the past.
-// Only memory samples can be subtracted at the moment. Support for CPU could be
-// added if necessary.
+// Only memory and ephemeral storage samples can be subtracted at the moment.
+// Support for CPU could be added if necessary.
 func (a *AggregateContainerState) SubtractSample(sample *ContainerUsageSample) {
 	switch sample.Resource {
 	case ResourceMemory:
 		a.AggregateMemoryPeaks.SubtractSample(BytesFromMemoryAmount(sample.Usage), 1.0, sample.MeasureStart)
+	case ResourceEphemeralStorage:
+		a.AggregateEphemeralStoragePeaks.SubtractSample(BytesFromEphemeralStorageAmount(sample.Usage), 1.0, sample.MeasureStart)
 	default:
 		panic(fmt.Sprintf("SubtractSample doesn't support resource '%s'", sample.Resource))
 	}
@@ -161,22 +246,49 @@ func (a *AggregateContainerState) SaveToCheckpoint() (*vpa_types.VerticalPodAuto
 	if err != nil {
 		return nil, err
 	}
+	ephemeralStorage, err := a.AggregateEphemeralStoragePeaks.SaveToChekpoint()
+	if err != nil {
+		return nil, err
+	}
 	return &vpa_types.VerticalPodAutoscalerCheckpointStatus{
-		FirstSampleStart:  metav1.NewTime(a.FirstSampleStart),
-		LastSampleStart:   metav1.NewTime(a.LastSampleStart),
-		TotalSamplesCount: a.TotalSamplesCount,
-		MemoryHistogram:   *memory,
-		CPUHistogram:      *cpu,
-		Version:           SupportedCheckpointVersion,
+		FirstSampleStart:          metav1.NewTime(a.FirstSampleStart),
+		LastSampleStart:           metav1.NewTime(a.LastSampleStart),
+		TotalSamplesCount:         a.TotalSamplesCount,
+		MemoryHistogram:           *memory,
+		CPUHistogram:              *cpu,
+		EphemeralStorageHistogram: *ephemeralStorage,
+		HistogramParameters: &vpa_types.HistogramParametersCheckpoint{
+			BucketSizeGrowth:    a.config.HistogramBucketSizeGrowth,
+			CPUDecayHalfLife:    metav1.Duration{Duration: a.config.CPUHistogramDecayHalfLife},
+			MemoryDecayHalfLife: metav1.Duration{Duration: a.config.MemoryHistogramDecayHalfLife},
+			Backend:             a.config.HistogramBackend,
+		},
+		Version: SupportedCheckpointVersion,
 	}, nil
 }
 
 // LoadFromCheckpoint deserializes data from VerticalPodAutoscalerCheckpointStatus
-// into the AggregateContainerState.
+// into the AggregateContainerState. Checkpoints of histograms with different
+// buckets or built by a different backend are rejected. Histograms with different half-lives are loaded, the
+// samples decay with the half-lives of the AggregateContainerState from then on.
 func (a *AggregateContainerState) LoadFromCheckpoint(checkpoint *vpa_types.VerticalPodAutoscalerCheckpointStatus) error {
 	if checkpoint.Version != SupportedCheckpointVersion {
 		return fmt.Errorf("unsuported checkpoint version %s", checkpoint.Version)
 	}
+	bucketSizeGrowth := HistogramBucketSizeGrowth
+	backend := vpa_types.HistogramBackendDecaying
+	if checkpoint.HistogramParameters != nil {
+		bucketSizeGrowth = checkpoint.HistogramParameters.BucketSizeGrowth
+		if checkpoint.HistogramParameters.Backend != "" {
+			backend = checkpoint.HistogramParameters.Backend
+		}
+	}
+	if bucketSizeGrowth != a.config.HistogramBucketSizeGrowth {
+		return fmt.Errorf("checkpoint histogram bucket size growth %v doesn't match %v", bucketSizeGrowth, a.config.HistogramBucketSizeGrowth)
+	}
+	if configBackend := a.config.HistogramBackend; backend != configBackend && !(configBackend == "" && backend == vpa_types.HistogramBackendDecaying) {
+		return fmt.Errorf("checkpoint histogram backend %s doesn't match %s", backend, configBackend)
+	}
 	a.TotalSamplesCount = checkpoint.TotalSamplesCount
 	a.FirstSampleStart = checkpoint.FirstSampleStart.Time
 	a.LastSampleStart = checkpoint.LastSampleStart.Time
@@ -188,23 +300,39 @@ func (a *AggregateContainerState) LoadFromCheckpoint(checkpoint *vpa_types.Verti
 	if err != nil {
 		return err
 	}
+	// Checkpoints written before ephemeral storage was tracked have an empty
+	// histogram, which is loaded as such.
+	err = a.AggregateEphemeralStoragePeaks.LoadFromCheckpoint(&checkpoint.EphemeralStorageHistogram)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
 func (a *AggregateContainerState) isExpired(now time.Time) bool {
-	return !a.LastSampleStart.IsZero() && now.Sub(a.LastSampleStart) >= MemoryAggregationWindowLength
+	historyLength := a.config.HistoryLength
+	if historyLength == 0 {
+		historyLength = MemoryAggregationWindowLength
+	}
+	return !a.LastSampleStart.IsZero() && now.Sub(a.LastSampleStart) >= historyLength
 }
 
 // AggregateStateByContainerName takes a set of AggregateContainerStates and merge them
 // grouping by the container name. The result is a map from the container name to the aggregation
-// from all input containers with the given name.
-func AggregateStateByContainerName(aggregateContainerStateMap aggregateContainerStatesMap) ContainerNameToAggregateStateMap {
+// from all input containers with the given name, with the given aggregation parameters.
+// AggregateContainerStates with other parameters, e.g. also matched by a VPA with a different
+// AggregationPolicy, can't be merged and are skipped.
+func AggregateStateByContainerName(aggregateContainerStateMap aggregateContainerStatesMap, config AggregationConfig) ContainerNameToAggregateStateMap {
 	containerNameToAggregateStateMap := make(ContainerNameToAggregateStateMap)
 	for aggregationKey, aggregation := range aggregateContainerStateMap {
+		if aggregation.config != config {
+			klog.V(4).Infof("Skipping aggregation %+v with different aggregation parameters", aggregationKey)
+			continue
+		}
 		containerName := aggregationKey.ContainerName()
 		aggregateContainerState, isInitialized := containerNameToAggregateStateMap[containerName]
 		if !isInitialized {
-			aggregateContainerState = NewAggregateContainerState()
+			aggregateContainerState = NewAggregateContainerStateWithConfig(config)
 			containerNameToAggregateStateMap[containerName] = aggregateContainerState
 		}
 		aggregateContainerState.MergeContainerState(aggregation)
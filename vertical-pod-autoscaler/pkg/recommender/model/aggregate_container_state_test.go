@@ -100,7 +100,7 @@ func TestAggregateStateByContainerName(t *testing.T) {
 	assert.NoError(t, addTestMemorySample(cluster, containers[3], 10e9)) // app-C
 
 	// Build the AggregateContainerStateMap.
-	aggregateResources := AggregateStateByContainerName(cluster.aggregateStateMap)
+	aggregateResources := AggregateStateByContainerName(cluster.aggregateStateMap, DefaultAggregationConfig())
 	assert.Contains(t, aggregateResources, "app-A")
 	assert.Contains(t, aggregateResources, "app-B")
 	assert.Contains(t, aggregateResources, "app-C")
@@ -136,6 +136,7 @@ func TestAggregateContainerStateSaveToCheckpoint(t *testing.T) {
 	cs.AggregateCPUUsage.AddSample(1, 33, t2)
 	cs.AggregateMemoryPeaks.AddSample(1, 55, t1)
 	cs.AggregateMemoryPeaks.AddSample(10000000, 55, t1)
+	cs.AggregateEphemeralStoragePeaks.AddSample(1e10, 55, t1)
 	checkpoint, err := cs.SaveToCheckpoint()
 
 	assert.NoError(t, err)
@@ -150,6 +151,7 @@ func TestAggregateContainerStateSaveToCheckpoint(t *testing.T) {
 	// Full tests are part of the Histogram.
 	assert.Len(t, checkpoint.CPUHistogram.BucketWeights, 1)
 	assert.Len(t, checkpoint.MemoryHistogram.BucketWeights, 2)
+	assert.Len(t, checkpoint.EphemeralStorageHistogram.BucketWeights, 1)
 }
 
 func TestAggregateContainerStateLoadFromCheckpointFailsForVersionMismatch(t *testing.T) {
@@ -193,6 +195,18 @@ func TestAggregateContainerStateLoadFromCheckpoint(t *testing.T) {
 	assert.Equal(t, 20, cs.TotalSamplesCount)
 	assert.False(t, cs.AggregateCPUUsage.IsEmpty())
 	assert.False(t, cs.AggregateMemoryPeaks.IsEmpty())
+	// Checkpoints without ephemeral storage histogram are accepted.
+	assert.True(t, cs.AggregateEphemeralStoragePeaks.IsEmpty())
+
+	checkpoint.EphemeralStorageHistogram = vpa_types.HistogramCheckpoint{
+		BucketWeights: map[int]uint32{
+			0: 10,
+		},
+		TotalWeight: 55.0,
+	}
+	cs = NewAggregateContainerState()
+	assert.NoError(t, cs.LoadFromCheckpoint(&checkpoint))
+	assert.False(t, cs.AggregateEphemeralStoragePeaks.IsEmpty())
 }
 
 func TestAggregateContainerStateIsExpired(t *testing.T) {
@@ -201,3 +215,125 @@ func TestAggregateContainerStateIsExpired(t *testing.T) {
 	assert.False(t, cs.isExpired(testTimestamp.Add(7*24*time.Hour)))
 	assert.True(t, cs.isExpired(testTimestamp.Add(8*24*time.Hour)))
 }
+
+func TestAggregateContainerStateIsExpiredWithHistoryLength(t *testing.T) {
+	config := DefaultAggregationConfig()
+	config.HistoryLength = 24 * time.Hour
+	cs := NewAggregateContainerStateWithConfig(config)
+	cs.LastSampleStart = testTimestamp
+	assert.False(t, cs.isExpired(testTimestamp.Add(23*time.Hour)))
+	assert.True(t, cs.isExpired(testTimestamp.Add(24*time.Hour)))
+}
+
+func TestNewAggregationConfig(t *testing.T) {
+	assert.Equal(t, DefaultAggregationConfig(), NewAggregationConfig(nil))
+
+	growth := 0.1
+	config := NewAggregationConfig(&vpa_types.AggregationPolicy{
+		HistoryLength:             &metav1.Duration{Duration: 48 * time.Hour},
+		CPUHistogramDecayHalfLife: &metav1.Duration{Duration: time.Hour},
+		HistogramBucketSizeGrowth: &growth,
+	})
+	assert.Equal(t, AggregationConfig{
+		HistoryLength:                48 * time.Hour,
+		HistogramBucketSizeGrowth:    0.1,
+		CPUHistogramDecayHalfLife:    time.Hour,
+		MemoryHistogramDecayHalfLife: MemoryHistogramDecayHalfLife,
+		HistogramBackend:             vpa_types.HistogramBackendDecaying,
+	}, config)
+
+	nonDecaying := vpa_types.HistogramBackendNonDecaying
+	config = NewAggregationConfig(&vpa_types.AggregationPolicy{HistogramBackend: &nonDecaying})
+	assert.Equal(t, vpa_types.HistogramBackendNonDecaying, config.HistogramBackend)
+
+	// Invalid values are ignored.
+	invalidGrowth := 0.
+	unknownBackend := vpa_types.HistogramBackend("unknown")
+	config = NewAggregationConfig(&vpa_types.AggregationPolicy{
+		MemoryHistogramDecayHalfLife: &metav1.Duration{Duration: -time.Hour},
+		HistogramBucketSizeGrowth:    &invalidGrowth,
+		HistogramBackend:             &unknownBackend,
+	})
+	assert.Equal(t, DefaultAggregationConfig(), config)
+}
+
+func TestAggregateContainerStateCheckpointWithConfig(t *testing.T) {
+	config := DefaultAggregationConfig()
+	config.HistogramBucketSizeGrowth = 0.1
+	config.CPUHistogramDecayHalfLife = time.Hour
+	cs := NewAggregateContainerStateWithConfig(config)
+	cs.AggregateCPUUsage.AddSample(1, 1, testTimestamp)
+	checkpoint, err := cs.SaveToCheckpoint()
+	assert.NoError(t, err)
+	assert.Equal(t, &vpa_types.HistogramParametersCheckpoint{
+		BucketSizeGrowth:    0.1,
+		CPUDecayHalfLife:    metav1.Duration{Duration: time.Hour},
+		MemoryDecayHalfLife: metav1.Duration{Duration: MemoryHistogramDecayHalfLife},
+		Backend:             vpa_types.HistogramBackendDecaying,
+	}, checkpoint.HistogramParameters)
+
+	// Histograms with other buckets are rejected.
+	assert.Error(t, NewAggregateContainerState().LoadFromCheckpoint(checkpoint))
+
+	// Histograms with other half-lives are loaded.
+	config.CPUHistogramDecayHalfLife = 2 * time.Hour
+	loaded := NewAggregateContainerStateWithConfig(config)
+	assert.NoError(t, loaded.LoadFromCheckpoint(checkpoint))
+	assert.False(t, loaded.AggregateCPUUsage.IsEmpty())
+
+	// Checkpoints without parameters have the default buckets.
+	checkpoint.HistogramParameters = nil
+	assert.Error(t, loaded.LoadFromCheckpoint(checkpoint))
+	assert.NoError(t, NewAggregateContainerState().LoadFromCheckpoint(checkpoint))
+}
+
+func TestAggregateContainerStateHistogramBackend(t *testing.T) {
+	config := DefaultAggregationConfig()
+	config.HistogramBackend = vpa_types.HistogramBackendNonDecaying
+	cs := NewAggregateContainerStateWithConfig(config)
+	cs.AggregateCPUUsage.AddSample(1, 1, testTimestamp)
+	cs.AggregateCPUUsage.AddSample(1, 1, testTimestamp.Add(24*time.Hour))
+
+	// Samples are not decayed, so both have the same weight.
+	checkpoint, err := cs.SaveToCheckpoint()
+	assert.NoError(t, err)
+	assert.Equal(t, vpa_types.HistogramBackendNonDecaying, checkpoint.HistogramParameters.Backend)
+	assert.Equal(t, 2., checkpoint.CPUHistogram.TotalWeight)
+
+	// Histograms of other backends are rejected.
+	assert.Error(t, NewAggregateContainerState().LoadFromCheckpoint(checkpoint))
+	assert.NoError(t, NewAggregateContainerStateWithConfig(config).LoadFromCheckpoint(checkpoint))
+}
+
+func TestRegisterHistogramBackend(t *testing.T) {
+	assert.Equal(t, []vpa_types.HistogramBackend{vpa_types.HistogramBackendDecaying, vpa_types.HistogramBackendNonDecaying}, RegisteredHistogramBackends())
+	assert.Panics(t, func() {
+		RegisterHistogramBackend(vpa_types.HistogramBackendDecaying, histogramBackends[vpa_types.HistogramBackendDecaying])
+	})
+}
+
+func TestAggregateContainerStateUpdateConfig(t *testing.T) {
+	cs := NewAggregateContainerState()
+	cs.AddSample(&ContainerUsageSample{
+		MeasureStart: testTimestamp,
+		Usage:        CPUAmountFromCores(1.0),
+		Request:      testRequest[ResourceCPU],
+		Resource:     ResourceCPU,
+	})
+
+	// The history is migrated to the new half-life.
+	config := DefaultAggregationConfig()
+	config.CPUHistogramDecayHalfLife = time.Hour
+	cs.UpdateConfig(config)
+	assert.Equal(t, config, cs.Config())
+	assert.Equal(t, 1, cs.TotalSamplesCount)
+	assert.False(t, cs.AggregateCPUUsage.IsEmpty())
+
+	// The history is discarded if the buckets change.
+	config.HistogramBucketSizeGrowth = 0.1
+	cs.UpdateConfig(config)
+	assert.Equal(t, config, cs.Config())
+	assert.Equal(t, 0, cs.TotalSamplesCount)
+	assert.True(t, cs.AggregateCPUUsage.IsEmpty())
+	assert.True(t, cs.LastSampleStart.IsZero())
+}
@@ -19,7 +19,9 @@ package model
 import (
 	"time"
 
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/util"
+	"k8s.io/klog"
 )
 
 var (
@@ -35,17 +37,20 @@ var (
 	MemoryAggregationInterval = time.Hour * 24
 	// CPUHistogramOptions are options to be used by histograms that store
 	// CPU measures expressed in cores.
-	CPUHistogramOptions = cpuHistogramOptions()
+	CPUHistogramOptions = cpuHistogramOptions(HistogramBucketSizeGrowth)
 	// MemoryHistogramOptions are options to be used by histograms that
 	// store memory measures expressed in bytes.
-	MemoryHistogramOptions = memoryHistogramOptions()
+	MemoryHistogramOptions = memoryHistogramOptions(HistogramBucketSizeGrowth)
+	// EphemeralStorageHistogramOptions are options to be used by histograms
+	// that store ephemeral storage measures expressed in bytes.
+	EphemeralStorageHistogramOptions = ephemeralStorageHistogramOptions(HistogramBucketSizeGrowth)
 	// HistogramBucketSizeGrowth defines the growth rate of the histogram buckets.
 	// Each bucket is wider than the previous one by this fraction.
 	HistogramBucketSizeGrowth = 0.05 // Make each bucket 5% larger than the previous one.
 	// MemoryHistogramDecayHalfLife is the amount of time it takes a historical
-	// memory usage sample to lose half of its weight. In other words, a fresh
-	// usage sample is twice as 'important' as one with age equal to the half
-	// life period.
+	// memory or ephemeral storage usage sample to lose half of its weight. In
+	// other words, a fresh usage sample is twice as 'important' as one with
+	// age equal to the half life period.
 	MemoryHistogramDecayHalfLife = time.Hour * 24
 	// CPUHistogramDecayHalfLife is the amount of time it takes a historical
 	// CPU usage sample to lose half of its weight.
@@ -58,28 +63,141 @@ const (
 	// epsilon is the minimal weight kept in histograms, it should be small enough that old samples
 	// (just inside MemoryAggregationWindowLength) added with minSampleWeight are still kept
 	epsilon = 0.001 * minSampleWeight
+	// minHistogramBucketSizeGrowth and maxHistogramBucketSizeGrowth bound
+	// the bucket size growth set per VPA. Smaller growths need too many
+	// buckets.
+	minHistogramBucketSizeGrowth = 0.01
+	maxHistogramBucketSizeGrowth = 1.0
 )
 
-func cpuHistogramOptions() util.HistogramOptions {
+// AggregationConfig holds the parameters of the aggregation of usage samples
+// in AggregateContainerState. It can be set per VPA in its AggregationPolicy.
+type AggregationConfig struct {
+	// HistoryLength is the length of the usage history kept in the
+	// aggregation.
+	HistoryLength time.Duration
+	// HistogramBucketSizeGrowth is the growth rate of the histogram buckets.
+	HistogramBucketSizeGrowth float64
+	// CPUHistogramDecayHalfLife is the half-life of CPU usage samples.
+	CPUHistogramDecayHalfLife time.Duration
+	// MemoryHistogramDecayHalfLife is the half-life of memory and ephemeral
+	// storage usage samples.
+	MemoryHistogramDecayHalfLife time.Duration
+	// HistogramBackend is the name of the registered HistogramBackend
+	// creating the histograms.
+	HistogramBackend vpa_types.HistogramBackend
+}
+
+// DefaultAggregationConfig returns the aggregation parameters given by the
+// package variables.
+func DefaultAggregationConfig() AggregationConfig {
+	return AggregationConfig{
+		HistoryLength:                MemoryAggregationWindowLength,
+		HistogramBucketSizeGrowth:    HistogramBucketSizeGrowth,
+		CPUHistogramDecayHalfLife:    CPUHistogramDecayHalfLife,
+		MemoryHistogramDecayHalfLife: MemoryHistogramDecayHalfLife,
+		HistogramBackend:             vpa_types.HistogramBackendDecaying,
+	}
+}
+
+// NewAggregationConfig returns the aggregation parameters set in the policy,
+// with the defaults for the parameters that are not set or are invalid. The
+// policy can be nil.
+func NewAggregationConfig(policy *vpa_types.AggregationPolicy) AggregationConfig {
+	config := DefaultAggregationConfig()
+	if policy == nil {
+		return config
+	}
+	if policy.HistoryLength != nil && policy.HistoryLength.Duration > 0 {
+		config.HistoryLength = policy.HistoryLength.Duration
+	}
+	if growth := policy.HistogramBucketSizeGrowth; growth != nil {
+		if *growth >= minHistogramBucketSizeGrowth && *growth <= maxHistogramBucketSizeGrowth {
+			config.HistogramBucketSizeGrowth = *growth
+		} else {
+			klog.Warningf("Ignoring histogram bucket size growth %v out of range [%v, %v]",
+				*growth, minHistogramBucketSizeGrowth, maxHistogramBucketSizeGrowth)
+		}
+	}
+	if policy.CPUHistogramDecayHalfLife != nil && policy.CPUHistogramDecayHalfLife.Duration > 0 {
+		config.CPUHistogramDecayHalfLife = policy.CPUHistogramDecayHalfLife.Duration
+	}
+	if policy.MemoryHistogramDecayHalfLife != nil && policy.MemoryHistogramDecayHalfLife.Duration > 0 {
+		config.MemoryHistogramDecayHalfLife = policy.MemoryHistogramDecayHalfLife.Duration
+	}
+	if backend := policy.HistogramBackend; backend != nil {
+		if _, found := histogramBackends[*backend]; found {
+			config.HistogramBackend = *backend
+		} else {
+			klog.Warningf("Ignoring unknown histogram backend %s, registered backends: %v", *backend, RegisteredHistogramBackends())
+		}
+	}
+	return config
+}
+
+// histogramBackend returns the backend creating the histograms, the decaying
+// one if the config doesn't name a registered backend.
+func (c AggregationConfig) histogramBackend() HistogramBackend {
+	if backend, found := histogramBackends[c.HistogramBackend]; found {
+		return backend
+	}
+	return histogramBackends[vpa_types.HistogramBackendDecaying]
+}
+
+func (c AggregationConfig) cpuHistogramOptions() util.HistogramOptions {
+	if c.HistogramBucketSizeGrowth == HistogramBucketSizeGrowth {
+		return CPUHistogramOptions
+	}
+	return cpuHistogramOptions(c.HistogramBucketSizeGrowth)
+}
+
+func (c AggregationConfig) memoryHistogramOptions() util.HistogramOptions {
+	if c.HistogramBucketSizeGrowth == HistogramBucketSizeGrowth {
+		return MemoryHistogramOptions
+	}
+	return memoryHistogramOptions(c.HistogramBucketSizeGrowth)
+}
+
+func (c AggregationConfig) ephemeralStorageHistogramOptions() util.HistogramOptions {
+	if c.HistogramBucketSizeGrowth == HistogramBucketSizeGrowth {
+		return EphemeralStorageHistogramOptions
+	}
+	return ephemeralStorageHistogramOptions(c.HistogramBucketSizeGrowth)
+}
+
+func cpuHistogramOptions(bucketSizeGrowth float64) util.HistogramOptions {
 	// CPU histograms use exponential bucketing scheme with the smallest bucket
-	// size of 0.01 core, max of 1000.0 cores and the relative error of HistogramRelativeError.
+	// size of 0.01 core, max of 1000.0 cores and the relative error of bucketSizeGrowth.
 	//
 	// When parameters below are changed SupportedCheckpointVersion has to be bumped.
-	options, err := util.NewExponentialHistogramOptions(1000.0, 0.01, 1.+HistogramBucketSizeGrowth, epsilon)
+	options, err := util.NewExponentialHistogramOptions(1000.0, 0.01, 1.+bucketSizeGrowth, epsilon)
 	if err != nil {
 		panic("Invalid CPU histogram options") // Should not happen.
 	}
 	return options
 }
 
-func memoryHistogramOptions() util.HistogramOptions {
+func memoryHistogramOptions(bucketSizeGrowth float64) util.HistogramOptions {
 	// Memory histograms use exponential bucketing scheme with the smallest
-	// bucket size of 10MB, max of 1TB and the relative error of HistogramRelativeError.
+	// bucket size of 10MB, max of 1TB and the relative error of bucketSizeGrowth.
 	//
 	// When parameters below are changed SupportedCheckpointVersion has to be bumped.
-	options, err := util.NewExponentialHistogramOptions(1e12, 1e7, 1.+HistogramBucketSizeGrowth, epsilon)
+	options, err := util.NewExponentialHistogramOptions(1e12, 1e7, 1.+bucketSizeGrowth, epsilon)
 	if err != nil {
 		panic("Invalid memory histogram options") // Should not happen.
 	}
 	return options
 }
+
+func ephemeralStorageHistogramOptions(bucketSizeGrowth float64) util.HistogramOptions {
+	// Ephemeral storage histograms use exponential bucketing scheme with the
+	// smallest bucket size of 10MB, max of 10TB and the relative error of
+	// bucketSizeGrowth.
+	//
+	// When parameters below are changed SupportedCheckpointVersion has to be bumped.
+	options, err := util.NewExponentialHistogramOptions(1e13, 1e7, 1.+bucketSizeGrowth, epsilon)
+	if err != nil {
+		panic("Invalid ephemeral storage histogram options") // Should not happen.
+	}
+	return options
+}
@@ -18,6 +18,7 @@ package model
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	apiv1 "k8s.io/api/core/v1"
@@ -134,6 +135,24 @@ func (cluster *ClusterState) DeletePod(podID PodID) {
 	delete(cluster.Pods, podID)
 }
 
+// CountVpaPods returns the number of live pods matched by the VPA, i.e. pods
+// that have not succeeded or failed.
+func (cluster *ClusterState) CountVpaPods(vpa *Vpa) int {
+	count := 0
+	for podID, pod := range cluster.Pods {
+		if podID.Namespace != vpa.ID.Namespace || vpa.PodSelector == nil {
+			continue
+		}
+		if pod.Phase == apiv1.PodSucceeded || pod.Phase == apiv1.PodFailed {
+			continue
+		}
+		if vpa.PodSelector.Matches(cluster.labelSetMap[pod.labelSetKey]) {
+			count++
+		}
+	}
+	return count
+}
+
 // AddOrUpdateContainer creates a new container with the given ContainerID and
 // adds it to the parent pod in the ClusterState object, if not yet present.
 // Requires the pod to be added to the ClusterState first. Otherwise an error is
@@ -153,6 +172,16 @@ func (cluster *ClusterState) AddOrUpdateContainer(containerID ContainerID, reque
 	return nil
 }
 
+// AddOrUpdateInitContainer is AddOrUpdateContainer for an init container. It
+// also marks the aggregation of the container as one of init containers.
+func (cluster *ClusterState) AddOrUpdateInitContainer(containerID ContainerID, request Resources) error {
+	if err := cluster.AddOrUpdateContainer(containerID, request); err != nil {
+		return err
+	}
+	cluster.findOrCreateAggregateContainerState(containerID).IsInitContainer = true
+	return nil
+}
+
 // AddSample adds a new usage sample to the proper container in the ClusterState
 // object. Requires the container as well as the parent pod to be added to the
 // ClusterState first. Otherwise an error is returned.
@@ -181,13 +210,54 @@ func (cluster *ClusterState) RecordOOM(containerID ContainerID, timestamp time.T
 	if !containerExists {
 		return NewKeyError(containerID.ContainerName)
 	}
-	err := containerState.RecordOOM(timestamp, requestedMemory)
+	err := containerState.RecordOOMWithBumpUp(timestamp, requestedMemory, cluster.oomBumpUpConfig(pod, containerID.ContainerName))
 	if err != nil {
 		return fmt.Errorf("error while recording OOM for %v, Reason: %v", containerID, err)
 	}
+	cluster.findOrCreateAggregateContainerState(containerID).RecordOOM(timestamp)
 	return nil
 }
 
+// oomBumpUpConfig returns the OOM bump up set by the VPAs of the container.
+// If several VPAs match the container, the largest bump up is used.
+func (cluster *ClusterState) oomBumpUpConfig(pod *PodState, containerName string) OOMBumpUpConfig {
+	aggregateStateKey := cluster.MakeAggregateStateKey(pod, containerName)
+	config, found := DefaultOOMBumpUpConfig(), false
+	for _, vpa := range cluster.Vpas {
+		if !vpa.UsesAggregation(aggregateStateKey) {
+			continue
+		}
+		if found {
+			config = maxOOMBumpUpConfig(config, vpa.OOMBumpUpConfig)
+		} else {
+			config, found = vpa.OOMBumpUpConfig, true
+		}
+	}
+	return config
+}
+
+// ReplaceUsage discards the usage aggregated for all containers and
+// aggregates the given samples instead, e.g. the usage history of the
+// containers from Prometheus. The OOMs recorded for the containers are
+// aggregated again. The samples of each container must be in chronological
+// order for each resource. Samples of containers that are not in the
+// ClusterState are skipped. Returns the number of samples aggregated.
+func (cluster *ClusterState) ReplaceUsage(samples map[ContainerID][]ContainerUsageSample) int {
+	for _, aggregateContainerState := range cluster.aggregateStateMap {
+		aggregateContainerState.resetUsage()
+	}
+	added := 0
+	for podID, pod := range cluster.Pods {
+		for containerName, container := range pod.Containers {
+			containerID := ContainerID{PodID: podID, ContainerName: containerName}
+			replaced, containerAdded := container.replaceUsage(samples[containerID])
+			pod.Containers[containerName] = replaced
+			added += containerAdded
+		}
+	}
+	return added
+}
+
 // AddOrUpdateVpa adds a new VPA with a given ID to the ClusterState if it
 // didn't yet exist. If the VPA already existed but had a different pod
 // selector, the pod selector is updated. Updates the links between the VPA and
@@ -203,26 +273,47 @@ func (cluster *ClusterState) AddOrUpdateVpa(apiObject *vpa_types.VerticalPodAuto
 		currentRecommendation = apiObject.Status.Recommendation
 	}
 
+	aggregationConfig := NewAggregationConfig(apiObject.Spec.AggregationPolicy)
+
 	vpa, vpaExists := cluster.Vpas[vpaID]
+	var controllerStates ContainerNameToControllerStateMap
 	if vpaExists && (vpa.PodSelector.String() != selector.String()) {
 		// Pod selector was changed. Delete the VPA object and recreate
-		// it with the new selector.
+		// it with the new selector. The controller state is kept, as it
+		// doesn't depend on the set of matched pods.
 		if err := cluster.DeleteVpa(vpaID); err != nil {
 			return err
 		}
+		controllerStates = vpa.ControllerStates
 		vpaExists = false
 	}
 	if !vpaExists {
 		vpa = NewVpa(vpaID, selector, apiObject.CreationTimestamp.Time)
+		if controllerStates != nil {
+			vpa.ControllerStates = controllerStates
+		}
+		vpa.AggregationConfig = aggregationConfig
 		cluster.Vpas[vpaID] = vpa
 		for aggregationKey, aggregation := range cluster.aggregateStateMap {
-			vpa.UseAggregationIfMatching(aggregationKey, aggregation)
+			if vpa.UseAggregationIfMatching(aggregationKey, aggregation) {
+				cluster.updateAggregationConfig(aggregationKey, aggregation, true)
+			}
 		}
 	}
 	vpa.TargetRef = apiObject.Spec.TargetRef
 	vpa.Conditions = conditionsMap
 	vpa.Recommendation = currentRecommendation
 	vpa.ResourcePolicy = apiObject.Spec.ResourcePolicy
+	vpa.ControllerPolicy = apiObject.Spec.ControllerPolicy
+	vpa.AggregationPolicy = apiObject.Spec.AggregationPolicy
+	vpa.RecommenderPolicy = apiObject.Spec.RecommenderPolicy
+	vpa.OOMBumpUpConfig = NewOOMBumpUpConfig(apiObject.Spec.OOMBumpUpPolicy)
+	if vpa.AggregationConfig != aggregationConfig {
+		vpa.SetAggregationConfig(aggregationConfig)
+		for aggregationKey, aggregation := range vpa.aggregateContainerStates {
+			cluster.updateAggregationConfig(aggregationKey, aggregation, true)
+		}
+	}
 	if apiObject.Spec.UpdatePolicy != nil {
 		vpa.UpdateMode = apiObject.Spec.UpdatePolicy.UpdateMode
 	}
@@ -231,13 +322,64 @@ func (cluster *ClusterState) AddOrUpdateVpa(apiObject *vpa_types.VerticalPodAuto
 
 // DeleteVpa removes a VPA with the given ID from the ClusterState.
 func (cluster *ClusterState) DeleteVpa(vpaID VpaID) error {
-	if _, vpaExists := cluster.Vpas[vpaID]; !vpaExists {
+	vpa, vpaExists := cluster.Vpas[vpaID]
+	if !vpaExists {
 		return NewKeyError(vpaID)
 	}
 	delete(cluster.Vpas, vpaID)
+	// The aggregations shared with other VPAs can take their parameters.
+	for aggregationKey, aggregation := range vpa.aggregateContainerStates {
+		cluster.updateAggregationConfig(aggregationKey, aggregation, true)
+	}
 	return nil
 }
 
+// updateAggregationConfig migrates the aggregation to the aggregation
+// parameters of the VPAs using it. An aggregation shared by VPAs with
+// different parameters is not migrated back and forth between them, which
+// would discard its history if the histogram buckets differ. If keepCurrent
+// is set and one of the VPAs uses the current parameters of the aggregation,
+// they are kept. Otherwise the aggregation takes the parameters of the oldest
+// VPA. The other VPAs leave the aggregation out of their recommendations, see
+// Vpa.ConflictingAggregations.
+func (cluster *ClusterState) updateAggregationConfig(aggregationKey AggregateStateKey, aggregation *AggregateContainerState, keepCurrent bool) {
+	vpas := make([]*Vpa, 0)
+	for _, vpa := range cluster.Vpas {
+		if vpa.UsesAggregation(aggregationKey) {
+			vpas = append(vpas, vpa)
+		}
+	}
+	if len(vpas) == 0 {
+		return
+	}
+	sort.Slice(vpas, func(i, j int) bool {
+		if !vpas[i].Created.Equal(vpas[j].Created) {
+			return vpas[i].Created.Before(vpas[j].Created)
+		}
+		if vpas[i].ID.Namespace != vpas[j].ID.Namespace {
+			return vpas[i].ID.Namespace < vpas[j].ID.Namespace
+		}
+		return vpas[i].ID.VpaName < vpas[j].ID.VpaName
+	})
+	owner := vpas[0]
+	if keepCurrent {
+		for _, vpa := range vpas {
+			if vpa.AggregationConfig == aggregation.Config() {
+				owner = vpa
+				break
+			}
+		}
+	}
+	aggregation.UpdateConfig(owner.AggregationConfig)
+	for _, vpa := range vpas {
+		if vpa.AggregationConfig != owner.AggregationConfig {
+			klog.Warningf("Container %v with labels %v in namespace %v is matched by VPAs with different aggregation policies, "+
+				"it is aggregated with the policy of VPA %v and not used by VPA %v",
+				aggregationKey.ContainerName(), aggregationKey.Labels(), aggregationKey.Namespace(), owner.ID.VpaName, vpa.ID.VpaName)
+		}
+	}
+}
+
 func newPod(id PodID) *PodState {
 	return &PodState{
 		ID:         id,
@@ -287,6 +429,7 @@ func (cluster *ClusterState) findOrCreateAggregateContainerState(containerID Con
 		for _, vpa := range cluster.Vpas {
 			vpa.UseAggregationIfMatching(aggregateStateKey, aggregateContainerState)
 		}
+		cluster.updateAggregationConfig(aggregateStateKey, aggregateContainerState, false)
 	}
 	return aggregateContainerState
 }
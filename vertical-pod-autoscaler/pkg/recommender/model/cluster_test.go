@@ -22,6 +22,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
 	"k8s.io/klog"
@@ -156,6 +157,49 @@ func TestClusterRecordOOM(t *testing.T) {
 	// Verify that OOM was aggregated into the aggregated stats.
 	aggregation := cluster.findOrCreateAggregateContainerState(testContainerID)
 	assert.NotEmpty(t, aggregation.AggregateMemoryPeaks)
+	assert.Equal(t, 1, aggregation.OOMCount)
+	assert.Equal(t, time.Unix(0, 0), aggregation.LastOOMTime)
+}
+
+func TestClusterRecordOOMUsesVpaBumpUpPolicy(t *testing.T) {
+	ratio := 2.0
+	minBumpUp := resource.MustParse("0")
+	invalidRatio := 0.5
+	cases := []struct {
+		name           string
+		policy         *vpa_types.OOMBumpUpPolicy
+		expectedMemory ResourceAmount
+	}{
+		{
+			name:           "default",
+			expectedMemory: MemoryAmountFromBytes(1.2e9),
+		}, {
+			name:           "custom",
+			policy:         &vpa_types.OOMBumpUpPolicy{Ratio: &ratio, MinBumpUp: &minBumpUp},
+			expectedMemory: MemoryAmountFromBytes(2e9),
+		}, {
+			name:           "invalid ratio",
+			policy:         &vpa_types.OOMBumpUpPolicy{Ratio: &invalidRatio},
+			expectedMemory: MemoryAmountFromBytes(1.2e9),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := NewClusterState()
+			var apiObject vpa_types.VerticalPodAutoscaler
+			apiObject.Namespace = testVpaID.Namespace
+			apiObject.Name = testVpaID.VpaName
+			apiObject.Spec.OOMBumpUpPolicy = tc.policy
+			labelSelector, _ := metav1.ParseToLabelSelector(testSelectorStr)
+			selector, _ := metav1.LabelSelectorAsSelector(labelSelector)
+			assert.NoError(t, cluster.AddOrUpdateVpa(&apiObject, selector))
+			cluster.AddOrUpdatePod(testPodID, testLabels, apiv1.PodRunning)
+			assert.NoError(t, cluster.AddOrUpdateContainer(testContainerID, testRequest))
+
+			assert.NoError(t, cluster.RecordOOM(testContainerID, testTimestamp, MemoryAmountFromBytes(1e9)))
+			assert.Equal(t, tc.expectedMemory, cluster.Pods[testPodID].Containers[testContainerID.ContainerName].GetMaxMemoryPeak())
+		})
+	}
 }
 
 // Verifies that AddSample and AddOrUpdateContainer methods return a proper
@@ -222,6 +266,19 @@ func TestAddPodThenAddVpa(t *testing.T) {
 	assert.Contains(t, vpa.aggregateContainerStates, aggregateStateKey)
 }
 
+// Creates a pod with an init container and a regular container. Verifies that
+// only the aggregation of the init container is marked as such.
+func TestAddPodWithInitContainer(t *testing.T) {
+	cluster := NewClusterState()
+	vpa := addTestVpa(cluster)
+	addTestPod(cluster)
+	addTestContainer(cluster)
+	assert.NoError(t, cluster.AddOrUpdateInitContainer(ContainerID{testPodID, "init"}, testRequest))
+	aggregations := vpa.AggregateStateByContainerName()
+	assert.True(t, aggregations["init"].IsInitContainer)
+	assert.False(t, aggregations["container-1"].IsInitContainer)
+}
+
 // Creates a VPA and a matching pod, then change the pod labels such that it is
 // no longer matched by the VPA. Verifies that the links between the pod and the
 // VPA are removed.
@@ -261,6 +318,15 @@ func TestUpdatePodSelector(t *testing.T) {
 	assert.Contains(t, vpa.aggregateContainerStates, cluster.aggregateStateKeyForContainerID(testContainerID))
 }
 
+func TestUpdatePodSelectorKeepsControllerState(t *testing.T) {
+	cluster := NewClusterState()
+	vpa := addTestVpa(cluster)
+	vpa.ControllerStateForContainer("container-1").IntegralTerm = 0.5
+
+	vpa = addVpa(cluster, testVpaID, "label-1 = value-2")
+	assert.Equal(t, 0.5, vpa.ControllerStateForContainer("container-1").IntegralTerm)
+}
+
 // Verify that two copies of the same AggregateStateKey are equal.
 func TestEqualAggregateStateKey(t *testing.T) {
 	cluster := NewClusterState()
@@ -328,3 +394,117 @@ func TestEmptySelector(t *testing.T) {
 	assert.Contains(t, vpa.aggregateContainerStates, cluster.aggregateStateKeyForContainerID(containerID1))
 	assert.Contains(t, vpa.aggregateContainerStates, cluster.aggregateStateKeyForContainerID(containerID2))
 }
+
+func TestCountVpaPods(t *testing.T) {
+	cluster := NewClusterState()
+	vpa := addTestVpa(cluster)
+	assert.Equal(t, 0, cluster.CountVpaPods(vpa))
+
+	cluster.AddOrUpdatePod(testPodID, testLabels, apiv1.PodRunning)
+	cluster.AddOrUpdatePod(PodID{"namespace-1", "pod-2"}, testLabels, apiv1.PodPending)
+	// Not counted: finished pod, pod not matching the selector and pod in another namespace.
+	cluster.AddOrUpdatePod(PodID{"namespace-1", "pod-3"}, testLabels, apiv1.PodSucceeded)
+	cluster.AddOrUpdatePod(PodID{"namespace-1", "pod-4"}, emptyLabels, apiv1.PodRunning)
+	cluster.AddOrUpdatePod(PodID{"namespace-2", "pod-5"}, testLabels, apiv1.PodRunning)
+	assert.Equal(t, 2, cluster.CountVpaPods(vpa))
+}
+
+// Verifies that changing the AggregationPolicy of a VPA updates the config of
+// the aggregations of its containers.
+func TestUpdateVpaAggregationPolicy(t *testing.T) {
+	cluster := NewClusterState()
+	vpa := addTestVpa(cluster)
+	addTestPod(cluster)
+	addTestContainer(cluster)
+	aggregation := cluster.findOrCreateAggregateContainerState(testContainerID)
+	assert.Equal(t, DefaultAggregationConfig(), aggregation.Config())
+
+	var apiObject vpa_types.VerticalPodAutoscaler
+	apiObject.Namespace = testVpaID.Namespace
+	apiObject.Name = testVpaID.VpaName
+	apiObject.Spec.AggregationPolicy = &vpa_types.AggregationPolicy{
+		CPUHistogramDecayHalfLife: &metav1.Duration{Duration: time.Hour},
+	}
+	assert.NoError(t, cluster.AddOrUpdateVpa(&apiObject, vpa.PodSelector))
+	expected := DefaultAggregationConfig()
+	expected.CPUHistogramDecayHalfLife = time.Hour
+	assert.Equal(t, expected, vpa.AggregationConfig)
+	assert.Equal(t, expected, aggregation.Config())
+}
+
+// Creates two overlapping VPAs with different half-lives. Verifies that the
+// shared aggregation keeps the parameters of the older VPA, is left out by the
+// newer one and is migrated with its history once the older VPA is deleted.
+func TestOverlappingVpasWithDifferentAggregationPolicies(t *testing.T) {
+	cluster := NewClusterState()
+	addVpaWithHalfLife := func(id VpaID, halfLife time.Duration, created time.Time) *Vpa {
+		var apiObject vpa_types.VerticalPodAutoscaler
+		apiObject.Namespace = id.Namespace
+		apiObject.Name = id.VpaName
+		apiObject.CreationTimestamp = metav1.NewTime(created)
+		apiObject.Spec.AggregationPolicy = &vpa_types.AggregationPolicy{
+			CPUHistogramDecayHalfLife: &metav1.Duration{Duration: halfLife},
+		}
+		labelSelector, err := metav1.ParseToLabelSelector(testSelectorStr)
+		assert.NoError(t, err)
+		selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+		assert.NoError(t, err)
+		assert.NoError(t, cluster.AddOrUpdateVpa(&apiObject, selector))
+		return cluster.Vpas[id]
+	}
+	olderVpa := addVpaWithHalfLife(testVpaID, time.Hour, testTimestamp)
+	addTestPod(cluster)
+	addTestContainer(cluster)
+	assert.NoError(t, cluster.AddSample(makeTestUsageSample()))
+	newerVpaID := VpaID{"namespace-1", "vpa-2"}
+	newerVpa := addVpaWithHalfLife(newerVpaID, 2*time.Hour, testTimestamp.Add(time.Minute))
+	aggregation := cluster.findOrCreateAggregateContainerState(testContainerID)
+
+	// Updating the VPAs doesn't move the aggregation between them.
+	for i := 0; i < 2; i++ {
+		addVpaWithHalfLife(newerVpaID, 2*time.Hour, testTimestamp.Add(time.Minute))
+		addVpaWithHalfLife(testVpaID, time.Hour, testTimestamp)
+		assert.Equal(t, olderVpa.AggregationConfig, aggregation.Config())
+		assert.Empty(t, olderVpa.ConflictingAggregations())
+		assert.Equal(t, []string{"container-1"}, newerVpa.ConflictingAggregations())
+		assert.Equal(t, 1, olderVpa.AggregateStateByContainerName()["container-1"].TotalSamplesCount)
+		assert.Empty(t, newerVpa.AggregateStateByContainerName())
+	}
+
+	assert.NoError(t, cluster.DeleteVpa(testVpaID))
+	assert.Equal(t, newerVpa.AggregationConfig, aggregation.Config())
+	assert.Empty(t, newerVpa.ConflictingAggregations())
+	assert.Equal(t, 1, newerVpa.AggregateStateByContainerName()["container-1"].TotalSamplesCount)
+}
+
+func TestClusterReplaceUsage(t *testing.T) {
+	cluster := NewClusterState()
+	cluster.AddOrUpdatePod(testPodID, testLabels, apiv1.PodRunning)
+	assert.NoError(t, cluster.AddOrUpdateContainer(testContainerID, testRequest))
+	assert.NoError(t, cluster.AddSample(makeTestUsageSample()))
+	assert.NoError(t, cluster.RecordOOM(testContainerID, testTimestamp, MemoryAmountFromBytes(1e9)))
+
+	// The history covers a gap before the live sample.
+	history := map[ContainerID][]ContainerUsageSample{testContainerID: {
+		{MeasureStart: testTimestamp.Add(-2 * time.Hour), Usage: CPUAmountFromCores(1), Resource: ResourceCPU},
+		{MeasureStart: testTimestamp.Add(-time.Hour), Usage: CPUAmountFromCores(2), Resource: ResourceCPU},
+		{MeasureStart: testTimestamp.Add(-time.Hour), Usage: MemoryAmountFromBytes(1e8), Resource: ResourceMemory},
+	}}
+	assert.Equal(t, 4, cluster.ReplaceUsage(history))
+
+	aggregation := cluster.findOrCreateAggregateContainerState(testContainerID)
+	assert.Equal(t, 2, aggregation.TotalSamplesCount)
+	assert.Equal(t, testTimestamp.Add(-2*time.Hour), aggregation.FirstSampleStart)
+	assert.Equal(t, testTimestamp.Add(-time.Hour), aggregation.LastSampleStart)
+	// The OOM is aggregated again and still counted once.
+	assert.Equal(t, 1, aggregation.OOMCount)
+	assert.True(t, aggregation.AggregateMemoryPeaks.Percentile(1.0) >= 1e9)
+
+	container := cluster.GetContainer(testContainerID)
+	assert.Equal(t, testRequest, container.Request)
+	assert.Equal(t, testTimestamp.Add(-time.Hour), container.LastCPUSampleStart)
+	// Live samples after the history are aggregated.
+	liveSample := makeTestUsageSample()
+	liveSample.MeasureStart = testTimestamp.Add(time.Minute)
+	assert.NoError(t, cluster.AddSample(liveSample))
+}
@@ -18,6 +18,7 @@ package model
 
 import (
 	"fmt"
+	"sort"
 	"time"
 )
 
@@ -33,9 +34,9 @@ const (
 type ContainerUsageSample struct {
 	// Start of the measurement interval.
 	MeasureStart time.Time
-	// Average CPU usage in cores or memory usage in bytes.
+	// Average CPU usage in cores or memory or ephemeral storage usage in bytes.
 	Usage ResourceAmount
-	// CPU or memory request at the time of measurment.
+	// CPU, memory or ephemeral storage request at the time of measurment.
 	Request ResourceAmount
 	// Which resource is this sample for.
 	Resource ResourceName
@@ -44,7 +45,7 @@ type ContainerUsageSample struct {
 // ContainerState stores information about a single container instance.
 // Each ContainerState has a pointer to the aggregation that is used for
 // aggregating its usage samples.
-// It holds the recent history of CPU and memory utilization.
+// It holds the recent history of CPU, memory and ephemeral storage utilization.
 //   Note: samples are added to intervals based on their start timestamps.
 type ContainerState struct {
 	// Current request.
@@ -59,6 +60,16 @@ type ContainerState struct {
 	WindowEnd time.Time
 	// Start of the latest memory usage sample that was aggregated.
 	lastMemorySampleStart time.Time
+	// Max ephemeral storage usage observed in the current aggregation interval.
+	ephemeralStoragePeak ResourceAmount
+	// End time of the current ephemeral storage aggregation interval (not inclusive).
+	EphemeralStorageWindowEnd time.Time
+	// Start of the latest ephemeral storage usage sample that was aggregated.
+	lastEphemeralStorageSampleStart time.Time
+	// Memory samples estimated from the OOM events in the memory
+	// aggregation window, in chronological order. They are aggregated again
+	// if the usage of the container is replaced.
+	oomSamples []ContainerUsageSample
 	// Aggregation to add usage samples to.
 	aggregator ContainerStateAggregator
 }
@@ -146,8 +157,60 @@ func (container *ContainerState) addMemorySample(sample *ContainerUsageSample, i
 	return true
 }
 
+// GetEphemeralStoragePeak returns maximum ephemeral storage usage in the
+// current aggregation interval.
+func (container *ContainerState) GetEphemeralStoragePeak() ResourceAmount {
+	return container.ephemeralStoragePeak
+}
+
+// addEphemeralStorageSample aggregates one ephemeral storage peak per
+// aggregation interval, in the same way as addMemorySample.
+func (container *ContainerState) addEphemeralStorageSample(sample *ContainerUsageSample) bool {
+	ts := sample.MeasureStart
+	if !sample.isValid(ResourceEphemeralStorage) || ts.Before(container.lastEphemeralStorageSampleStart) {
+		return false // Discard invalid or outdated samples.
+	}
+	container.lastEphemeralStorageSampleStart = ts
+	if container.EphemeralStorageWindowEnd.IsZero() { // This is the first sample.
+		container.EphemeralStorageWindowEnd = ts
+	}
+
+	if ts.Before(container.EphemeralStorageWindowEnd) {
+		if sample.Usage <= container.ephemeralStoragePeak {
+			return true
+		}
+		if container.ephemeralStoragePeak != 0 {
+			// Remove the old peak.
+			container.aggregator.SubtractSample(&ContainerUsageSample{
+				MeasureStart: container.EphemeralStorageWindowEnd,
+				Usage:        container.ephemeralStoragePeak,
+				Request:      sample.Request,
+				Resource:     ResourceEphemeralStorage,
+			})
+		}
+	} else {
+		// Shift the aggregation window to the next interval.
+		shift := truncate(ts.Sub(container.EphemeralStorageWindowEnd), MemoryAggregationInterval) + MemoryAggregationInterval
+		container.EphemeralStorageWindowEnd = container.EphemeralStorageWindowEnd.Add(shift)
+	}
+	container.aggregator.AddSample(&ContainerUsageSample{
+		MeasureStart: container.EphemeralStorageWindowEnd,
+		Usage:        sample.Usage,
+		Request:      sample.Request,
+		Resource:     ResourceEphemeralStorage,
+	})
+	container.ephemeralStoragePeak = sample.Usage
+	return true
+}
+
 // RecordOOM adds info regarding OOM event in the model as an artificial memory sample.
 func (container *ContainerState) RecordOOM(timestamp time.Time, requestedMemory ResourceAmount) error {
+	return container.RecordOOMWithBumpUp(timestamp, requestedMemory, DefaultOOMBumpUpConfig())
+}
+
+// RecordOOMWithBumpUp adds info regarding OOM event in the model as an
+// artificial memory sample, bumped up as given by the config.
+func (container *ContainerState) RecordOOMWithBumpUp(timestamp time.Time, requestedMemory ResourceAmount, bumpUp OOMBumpUpConfig) error {
 	// Discard old OOM
 	if timestamp.Before(container.WindowEnd.Add(-1 * MemoryAggregationInterval)) {
 		return fmt.Errorf("OOM event will be discarded - it is too old (%v)", timestamp)
@@ -155,8 +218,7 @@ func (container *ContainerState) RecordOOM(timestamp time.Time, requestedMemory
 	// Get max of the request and the recent usage-based memory peak.
 	// Omitting oomPeak here to protect against recommendation running too high on subsequent OOMs.
 	memoryUsed := ResourceAmountMax(requestedMemory, container.memoryPeak)
-	memoryNeeded := ResourceAmountMax(memoryUsed+MemoryAmountFromBytes(OOMMinBumpUp),
-		ScaleResource(memoryUsed, OOMBumpUpRatio))
+	memoryNeeded := bumpUp.memoryNeeded(memoryUsed)
 
 	oomMemorySample := ContainerUsageSample{
 		MeasureStart: timestamp,
@@ -166,9 +228,48 @@ func (container *ContainerState) RecordOOM(timestamp time.Time, requestedMemory
 	if !container.addMemorySample(&oomMemorySample, true) {
 		return fmt.Errorf("adding OOM sample failed")
 	}
+	container.oomSamples = append(container.oomSamples, oomMemorySample)
+	for len(container.oomSamples) > 0 && timestamp.Sub(container.oomSamples[0].MeasureStart) >= MemoryAggregationWindowLength {
+		container.oomSamples = container.oomSamples[1:]
+	}
 	return nil
 }
 
+// replaceUsage returns a new ContainerState with the same request and
+// aggregation, that aggregated the given samples and the OOMs of the
+// container. The samples must be in chronological order for each resource.
+// Returns the number of samples aggregated.
+func (container *ContainerState) replaceUsage(samples []ContainerUsageSample) (*ContainerState, int) {
+	replaced := NewContainerState(container.Request, container.aggregator)
+	replaced.oomSamples = container.oomSamples
+	// OOMs are aggregated in order with the memory samples.
+	type replayedSample struct {
+		ContainerUsageSample
+		isOOM bool
+	}
+	replayed := make([]replayedSample, 0, len(samples)+len(container.oomSamples))
+	for _, sample := range samples {
+		replayed = append(replayed, replayedSample{sample, false})
+	}
+	for _, sample := range container.oomSamples {
+		replayed = append(replayed, replayedSample{sample, true})
+	}
+	sort.SliceStable(replayed, func(i, j int) bool { return replayed[i].MeasureStart.Before(replayed[j].MeasureStart) })
+	added := 0
+	for i := range replayed {
+		var ok bool
+		if replayed[i].isOOM {
+			ok = replaced.addMemorySample(&replayed[i].ContainerUsageSample, true)
+		} else {
+			ok = replaced.AddSample(&replayed[i].ContainerUsageSample)
+		}
+		if ok {
+			added++
+		}
+	}
+	return replaced, added
+}
+
 // AddSample adds a usage sample to the given ContainerState. Requires samples
 // for a single resource to be passed in chronological order (i.e. in order of
 // growing MeasureStart). Invalid samples (out of order or measure out of legal
@@ -182,6 +283,8 @@ func (container *ContainerState) AddSample(sample *ContainerUsageSample) bool {
 		return container.addCPUSample(sample)
 	case ResourceMemory:
 		return container.addMemorySample(sample, false)
+	case ResourceEphemeralStorage:
+		return container.addEphemeralStorageSample(sample)
 	default:
 		return false
 	}
@@ -49,28 +49,32 @@ func newUsageSample(timestamp time.Time, usage int64, resource ResourceName) *Co
 }
 
 type ContainerTest struct {
-	mockCPUHistogram        *util.MockHistogram
-	mockMemoryHistogram     *util.MockHistogram
-	aggregateContainerState *AggregateContainerState
-	container               *ContainerState
+	mockCPUHistogram              *util.MockHistogram
+	mockMemoryHistogram           *util.MockHistogram
+	mockEphemeralStorageHistogram *util.MockHistogram
+	aggregateContainerState       *AggregateContainerState
+	container                     *ContainerState
 }
 
 func newContainerTest() ContainerTest {
 	mockCPUHistogram := new(util.MockHistogram)
 	mockMemoryHistogram := new(util.MockHistogram)
+	mockEphemeralStorageHistogram := new(util.MockHistogram)
 	aggregateContainerState := &AggregateContainerState{
-		AggregateCPUUsage:    mockCPUHistogram,
-		AggregateMemoryPeaks: mockMemoryHistogram,
+		AggregateCPUUsage:              mockCPUHistogram,
+		AggregateMemoryPeaks:           mockMemoryHistogram,
+		AggregateEphemeralStoragePeaks: mockEphemeralStorageHistogram,
 	}
 	container := &ContainerState{
 		Request:    TestRequest,
 		aggregator: aggregateContainerState,
 	}
 	return ContainerTest{
-		mockCPUHistogram:        mockCPUHistogram,
-		mockMemoryHistogram:     mockMemoryHistogram,
-		aggregateContainerState: aggregateContainerState,
-		container:               container,
+		mockCPUHistogram:              mockCPUHistogram,
+		mockMemoryHistogram:           mockMemoryHistogram,
+		mockEphemeralStorageHistogram: mockEphemeralStorageHistogram,
+		aggregateContainerState:       aggregateContainerState,
+		container:                     container,
 	}
 }
 
@@ -120,6 +124,39 @@ func TestAggregateContainerUsageSamples(t *testing.T) {
 		testTimestamp.Add(4*timeStep), -1000, ResourceMemory)))
 }
 
+// Verifies that ephemeral storage peaks are aggregated in the ephemeral
+// storage peaks histogram, one per aggregation interval.
+func TestAggregateEphemeralStorageUsageSamples(t *testing.T) {
+	test := newContainerTest()
+	c := test.container
+	timeStep := MemoryAggregationInterval / 2
+	windowEnd := testTimestamp.Add(MemoryAggregationInterval)
+	test.mockEphemeralStorageHistogram.On("AddSample", 5.0, 1.0, windowEnd)
+	test.mockEphemeralStorageHistogram.On("SubtractSample", 5.0, 1.0, windowEnd)
+	test.mockEphemeralStorageHistogram.On("AddSample", 10.0, 1.0, windowEnd)
+	windowEnd = windowEnd.Add(MemoryAggregationInterval)
+	test.mockEphemeralStorageHistogram.On("AddSample", 2.0, 1.0, windowEnd)
+
+	assert.True(t, c.AddSample(newUsageSample(
+		testTimestamp, 5, ResourceEphemeralStorage)))
+	assert.True(t, c.AddSample(newUsageSample(
+		testTimestamp.Add(timeStep), 10, ResourceEphemeralStorage)))
+	// Lower than the peak of the interval.
+	assert.True(t, c.AddSample(newUsageSample(
+		testTimestamp.Add(timeStep+time.Minute), 7, ResourceEphemeralStorage)))
+	assert.True(t, c.AddSample(newUsageSample(
+		testTimestamp.Add(2*timeStep), 2, ResourceEphemeralStorage)))
+	assert.Equal(t, ResourceAmount(2), c.GetEphemeralStoragePeak())
+	assert.Equal(t, windowEnd, c.EphemeralStorageWindowEnd)
+
+	// Discard invalid samples.
+	assert.False(t, c.AddSample(newUsageSample( // Out of order sample.
+		testTimestamp.Add(timeStep), 1000, ResourceEphemeralStorage)))
+	assert.False(t, c.AddSample(newUsageSample( // Negative usage.
+		testTimestamp.Add(4*timeStep), -1000, ResourceEphemeralStorage)))
+	test.mockEphemeralStorageHistogram.AssertExpectations(t)
+}
+
 func TestRecordOOMIncreasedByBumpUp(t *testing.T) {
 	test := newContainerTest()
 	memoryAggregationWindowEnd := testTimestamp.Add(MemoryAggregationInterval)
@@ -156,6 +193,16 @@ func TestRecordOOMIncreasedByMin(t *testing.T) {
 	assert.NoError(t, test.container.RecordOOM(testTimestamp, ResourceAmount(1*mb)))
 }
 
+func TestRecordOOMWithBumpUp(t *testing.T) {
+	test := newContainerTest()
+	memoryAggregationWindowEnd := testTimestamp.Add(MemoryAggregationInterval)
+	// Bump up by 50%, at least 600Mb.
+	test.mockMemoryHistogram.On("AddSample", 1600.0*mb, 1.0, memoryAggregationWindowEnd)
+
+	bumpUp := OOMBumpUpConfig{Ratio: 1.5, MinBumpUp: 600 * mb}
+	assert.NoError(t, test.container.RecordOOMWithBumpUp(testTimestamp, ResourceAmount(1000*mb), bumpUp))
+}
+
 func TestRecordOOMMaxedWithKnownSample(t *testing.T) {
 	test := newContainerTest()
 	memoryAggregationWindowEnd := testTimestamp.Add(MemoryAggregationInterval)
@@ -0,0 +1,88 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+)
+
+// ControllerState holds the state the response-time controller carries
+// between iterations for a single container of a VPA.
+type ControllerState struct {
+	// Integral term of the controller computed in the last iteration.
+	IntegralTerm float64
+	// Last samples of the request counters, keyed by the described object
+	// ("namespace/name").
+	RequestCounters map[string]CounterSample
+	// Time of the last iteration. Zero if the controller has not run yet.
+	LastUpdate time.Time
+	// Total of the request counters at LastUpdate, loaded from a checkpoint
+	// written before the counters were kept per object. It is used in place
+	// of RequestCounters until the controller runs again.
+	LegacyRequestCount float64
+}
+
+// CounterSample is a sample of a monotonically increasing counter.
+type CounterSample struct {
+	// Value of the counter.
+	Value float64
+	// Time of the sample.
+	Timestamp time.Time
+}
+
+// ContainerNameToControllerStateMap maps a container name to the state of
+// the controller computing its recommendation.
+type ContainerNameToControllerStateMap map[string]*ControllerState
+
+// SaveToCheckpoint serializes ControllerState as ControllerStateCheckpoint.
+func (s *ControllerState) SaveToCheckpoint() *vpa_types.ControllerStateCheckpoint {
+	checkpoint := &vpa_types.ControllerStateCheckpoint{
+		LastUpdateTime: metav1.NewTime(s.LastUpdate),
+		IntegralTerm:   s.IntegralTerm,
+		RequestCount:   s.LegacyRequestCount,
+	}
+	for object, sample := range s.RequestCounters {
+		checkpoint.RequestCounters = append(checkpoint.RequestCounters, vpa_types.RequestCounterSample{
+			Object:    object,
+			Value:     sample.Value,
+			Timestamp: metav1.NewTime(sample.Timestamp),
+		})
+	}
+	sort.Slice(checkpoint.RequestCounters, func(i, j int) bool {
+		return checkpoint.RequestCounters[i].Object < checkpoint.RequestCounters[j].Object
+	})
+	return checkpoint
+}
+
+// LoadFromCheckpoint deserializes data from ControllerStateCheckpoint into
+// the ControllerState.
+func (s *ControllerState) LoadFromCheckpoint(checkpoint *vpa_types.ControllerStateCheckpoint) {
+	s.LastUpdate = checkpoint.LastUpdateTime.Time
+	s.IntegralTerm = checkpoint.IntegralTerm
+	s.RequestCounters = make(map[string]CounterSample, len(checkpoint.RequestCounters))
+	for _, sample := range checkpoint.RequestCounters {
+		s.RequestCounters[sample.Object] = CounterSample{Value: sample.Value, Timestamp: sample.Timestamp.Time}
+	}
+	s.LegacyRequestCount = 0
+	if len(s.RequestCounters) == 0 {
+		s.LegacyRequestCount = checkpoint.RequestCount
+	}
+}
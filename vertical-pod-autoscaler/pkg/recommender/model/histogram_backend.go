@@ -0,0 +1,75 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/util"
+)
+
+// HistogramBackend creates the histograms AggregateContainerState aggregates
+// the usage samples in. Backends are registered under a name with
+// RegisterHistogramBackend and selected per VPA with
+// AggregationPolicy.HistogramBackend.
+type HistogramBackend interface {
+	// NewHistogram returns an empty histogram with the given bucketing
+	// options. Backends that decay the samples use the given half-life.
+	NewHistogram(options util.HistogramOptions, halfLife time.Duration) util.Histogram
+}
+
+// HistogramBackendFunc is a HistogramBackend implemented by a function.
+type HistogramBackendFunc func(options util.HistogramOptions, halfLife time.Duration) util.Histogram
+
+// NewHistogram calls f(options, halfLife).
+func (f HistogramBackendFunc) NewHistogram(options util.HistogramOptions, halfLife time.Duration) util.Histogram {
+	return f(options, halfLife)
+}
+
+var histogramBackends = make(map[vpa_types.HistogramBackend]HistogramBackend)
+
+func init() {
+	RegisterHistogramBackend(vpa_types.HistogramBackendDecaying, HistogramBackendFunc(util.NewDecayingHistogram))
+	RegisterHistogramBackend(vpa_types.HistogramBackendNonDecaying, HistogramBackendFunc(
+		func(options util.HistogramOptions, _ time.Duration) util.Histogram {
+			return util.NewHistogram(options)
+		}))
+}
+
+// RegisterHistogramBackend makes the backend available under the given name.
+// It is meant to be called from init functions and panics if the name is
+// already registered.
+func RegisterHistogramBackend(name vpa_types.HistogramBackend, backend HistogramBackend) {
+	if _, found := histogramBackends[name]; found {
+		panic(fmt.Sprintf("histogram backend %s is already registered", name))
+	}
+	histogramBackends[name] = backend
+}
+
+// RegisteredHistogramBackends returns the sorted names of the registered
+// histogram backends.
+func RegisteredHistogramBackends() []vpa_types.HistogramBackend {
+	names := make([]vpa_types.HistogramBackend, 0, len(histogramBackends))
+	for name := range histogramBackends {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/klog"
+)
+
+// OOMBumpUpConfig holds how much the memory sample recorded after an OOM
+// exceeds the memory used by the container. It can be set per VPA in its
+// OOMBumpUpPolicy.
+type OOMBumpUpConfig struct {
+	// Ratio by which the memory used is multiplied.
+	Ratio float64
+	// MinBumpUp is the minimum memory in bytes added to the memory used.
+	MinBumpUp float64
+}
+
+// DefaultOOMBumpUpConfig returns the bump up given by OOMBumpUpRatio and
+// OOMMinBumpUp.
+func DefaultOOMBumpUpConfig() OOMBumpUpConfig {
+	return OOMBumpUpConfig{
+		Ratio:     OOMBumpUpRatio,
+		MinBumpUp: OOMMinBumpUp,
+	}
+}
+
+// NewOOMBumpUpConfig returns the bump up set in the policy, with the defaults
+// for the parameters that are not set or are invalid. The policy can be nil.
+func NewOOMBumpUpConfig(policy *vpa_types.OOMBumpUpPolicy) OOMBumpUpConfig {
+	config := DefaultOOMBumpUpConfig()
+	if policy == nil {
+		return config
+	}
+	if ratio := policy.Ratio; ratio != nil {
+		if *ratio >= 1 {
+			config.Ratio = *ratio
+		} else {
+			klog.Warningf("Ignoring OOM bump up ratio %v lower than 1", *ratio)
+		}
+	}
+	if policy.MinBumpUp != nil && policy.MinBumpUp.Sign() >= 0 {
+		config.MinBumpUp = float64(policy.MinBumpUp.Value())
+	}
+	return config
+}
+
+// memoryNeeded returns the memory sample recorded for an OOM of a container
+// that used the given memory.
+func (c OOMBumpUpConfig) memoryNeeded(memoryUsed ResourceAmount) ResourceAmount {
+	return ResourceAmountMax(memoryUsed+MemoryAmountFromBytes(c.MinBumpUp),
+		ScaleResource(memoryUsed, c.Ratio))
+}
+
+// maxOOMBumpUpConfig returns the bump up that is the largest in both
+// parameters.
+func maxOOMBumpUpConfig(a, b OOMBumpUpConfig) OOMBumpUpConfig {
+	if b.Ratio > a.Ratio {
+		a.Ratio = b.Ratio
+	}
+	if b.MinBumpUp > a.MinBumpUp {
+		a.MinBumpUp = b.MinBumpUp
+	}
+	return a
+}
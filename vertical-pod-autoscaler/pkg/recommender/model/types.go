@@ -27,7 +27,7 @@ type ResourceName string
 
 // ResourceAmount represents quantity of a certain resource within a container.
 // Note this keeps CPU in millicores (which is not a standard unit in APIs)
-// and memory in bytes.
+// and memory and ephemeral storage in bytes.
 // Allowed values are in the range from 0 to MaxResourceAmount.
 type ResourceAmount int64
 
@@ -39,6 +39,8 @@ const (
 	ResourceCPU ResourceName = "cpu"
 	// ResourceMemory represents memory, in bytes. (500Gi = 500GiB = 500 * 1024 * 1024 * 1024).
 	ResourceMemory ResourceName = "memory"
+	// ResourceEphemeralStorage represents local ephemeral storage, in bytes.
+	ResourceEphemeralStorage ResourceName = "ephemeral-storage"
 	// MaxResourceAmount is the maximum allowed value of resource amount.
 	MaxResourceAmount = ResourceAmount(1e14)
 )
@@ -73,6 +75,21 @@ func QuantityFromMemoryAmount(memoryAmount ResourceAmount) resource.Quantity {
 	return *resource.NewScaledQuantity(int64(memoryAmount), 0)
 }
 
+// EphemeralStorageAmountFromBytes converts ephemeral storage bytes to a ResourceAmount.
+func EphemeralStorageAmountFromBytes(bytes float64) ResourceAmount {
+	return resourceAmountFromFloat(bytes)
+}
+
+// BytesFromEphemeralStorageAmount converts ResourceAmount to number of bytes expressed as float64.
+func BytesFromEphemeralStorageAmount(ephemeralStorageAmount ResourceAmount) float64 {
+	return float64(ephemeralStorageAmount)
+}
+
+// QuantityFromEphemeralStorageAmount converts ephemeral storage ResourceAmount to a resource.Quantity.
+func QuantityFromEphemeralStorageAmount(ephemeralStorageAmount ResourceAmount) resource.Quantity {
+	return *resource.NewScaledQuantity(int64(ephemeralStorageAmount), 0)
+}
+
 // ScaleResource returns the resource amount multiplied by a given factor.
 func ScaleResource(amount ResourceAmount, factor float64) ResourceAmount {
 	return resourceAmountFromFloat(float64(amount) * factor)
@@ -91,6 +108,9 @@ func ResourcesAsResourceList(resources Resources) apiv1.ResourceList {
 		case ResourceMemory:
 			newKey = apiv1.ResourceMemory
 			quantity = QuantityFromMemoryAmount(resourceAmount)
+		case ResourceEphemeralStorage:
+			newKey = apiv1.ResourceEphemeralStorage
+			quantity = QuantityFromEphemeralStorageAmount(resourceAmount)
 		default:
 			klog.Errorf("Cannot translate %v resource name", key)
 			continue
@@ -100,6 +120,24 @@ func ResourcesAsResourceList(resources Resources) apiv1.ResourceList {
 	return result
 }
 
+// ResourcesFromResourceList converts ResourceList to internal Resources
+// representation. Resources other than CPU, memory and ephemeral storage are
+// skipped.
+func ResourcesFromResourceList(resourceList apiv1.ResourceList) Resources {
+	result := make(Resources)
+	for key, quantity := range resourceList {
+		switch key {
+		case apiv1.ResourceCPU:
+			result[ResourceCPU] = resourceAmountFromFloat(float64(quantity.MilliValue()))
+		case apiv1.ResourceMemory:
+			result[ResourceMemory] = resourceAmountFromFloat(float64(quantity.Value()))
+		case apiv1.ResourceEphemeralStorage:
+			result[ResourceEphemeralStorage] = resourceAmountFromFloat(float64(quantity.Value()))
+		}
+	}
+	return result
+}
+
 // RoundResourceAmount returns the given resource amount rounded down to the
 // whole multiple of another resource amount (unit).
 func RoundResourceAmount(amount, unit ResourceAmount) ResourceAmount {
@@ -78,11 +78,32 @@ type Vpa struct {
 	Conditions vpaConditionsMap
 	// Most recently computed recommendation. Can be nil.
 	Recommendation *vpa_types.RecommendedPodResources
+	// Past recommendations with their explanations, oldest first. Empty if
+	// the recommendation history is disabled.
+	RecommendationHistory []vpa_types.RecommendationHistoryEntry
+	// Number of live pods matched by the VPA when the recommendation was
+	// computed.
+	PodCount int
 	// All container aggregations that contribute to this VPA.
 	// TODO: Garbage collect old AggregateContainerStates.
 	aggregateContainerStates aggregateContainerStatesMap
 	// Pod Resource Policy provided in the VPA API object. Can be nil.
 	ResourcePolicy *vpa_types.PodResourcePolicy
+	// Response-time controller policy provided in the VPA API object. Can be nil.
+	ControllerPolicy *vpa_types.ControllerPolicy
+	// Aggregation policy provided in the VPA API object. Can be nil.
+	AggregationPolicy *vpa_types.AggregationPolicy
+	// Parameters of the aggregations contributing to this VPA, resolved from
+	// the AggregationPolicy.
+	AggregationConfig AggregationConfig
+	// Histogram recommender policy provided in the VPA API object. Can be nil.
+	RecommenderPolicy *vpa_types.RecommenderPolicy
+	// Bump up of the memory samples recorded after OOMs of the containers,
+	// resolved from the OOMBumpUpPolicy in the VPA API object.
+	OOMBumpUpConfig OOMBumpUpConfig
+	// State of the response-time controller for containers whose
+	// recommendation is computed by the controller. The key is container name.
+	ControllerStates ContainerNameToControllerStateMap
 	// Initial checkpoints of AggregateContainerStates for containers.
 	// The key is container name.
 	ContainersInitialAggregateState ContainerNameToAggregateStateMap
@@ -106,6 +127,9 @@ func NewVpa(id VpaID, selector labels.Selector, created time.Time) *Vpa {
 		PodSelector:                     selector,
 		aggregateContainerStates:        make(aggregateContainerStatesMap),
 		ContainersInitialAggregateState: make(ContainerNameToAggregateStateMap),
+		ControllerStates:                make(ContainerNameToControllerStateMap),
+		AggregationConfig:               DefaultAggregationConfig(),
+		OOMBumpUpConfig:                 DefaultOOMBumpUpConfig(),
 		Created:                         created,
 		Conditions:                      make(vpaConditionsMap),
 		IsV1Beta1API:                    false,
@@ -114,11 +138,44 @@ func NewVpa(id VpaID, selector labels.Selector, created time.Time) *Vpa {
 }
 
 // UseAggregationIfMatching checks if the given aggregation matches (contributes to) this VPA
-// and adds it to the set of VPA's aggregations if that is the case.
-func (vpa *Vpa) UseAggregationIfMatching(aggregationKey AggregateStateKey, aggregation *AggregateContainerState) {
+// and adds it to the set of VPA's aggregations if that is the case. Returns true if the
+// aggregation was added. The aggregation can be shared with other VPAs, so it is migrated
+// to the aggregation parameters of the VPA by the ClusterState.
+func (vpa *Vpa) UseAggregationIfMatching(aggregationKey AggregateStateKey, aggregation *AggregateContainerState) bool {
 	if !vpa.UsesAggregation(aggregationKey) && vpa.matchesAggregation(aggregationKey) {
 		vpa.aggregateContainerStates[aggregationKey] = aggregation
+		return true
 	}
+	return false
+}
+
+// SetAggregationConfig sets the aggregation parameters of the VPA and
+// migrates the checkpointed aggregations of the VPA to them. The aggregations
+// contributing to the VPA are migrated by the ClusterState.
+func (vpa *Vpa) SetAggregationConfig(config AggregationConfig) {
+	vpa.AggregationConfig = config
+	for _, aggregation := range vpa.ContainersInitialAggregateState {
+		aggregation.UpdateConfig(config)
+	}
+}
+
+// ConflictingAggregations returns the sorted names of the containers with
+// aggregations that have other parameters than the VPA, because they are
+// shared with a VPA with a different AggregationPolicy. These aggregations
+// are left out of the recommendation of the VPA.
+func (vpa *Vpa) ConflictingAggregations() []string {
+	conflicting := make(map[string]bool)
+	for aggregationKey, aggregation := range vpa.aggregateContainerStates {
+		if aggregation.Config() != vpa.AggregationConfig {
+			conflicting[aggregationKey.ContainerName()] = true
+		}
+	}
+	containerNames := make([]string, 0, len(conflicting))
+	for containerName := range conflicting {
+		containerNames = append(containerNames, containerName)
+	}
+	sort.Strings(containerNames)
+	return containerNames
 }
 
 // UsesAggregation returns true iff an aggregation with the given key contributes to the VPA.
@@ -137,7 +194,7 @@ func (vpa *Vpa) MergeCheckpointedState(aggregateContainerStateMap ContainerNameT
 	for containerName, aggregation := range vpa.ContainersInitialAggregateState {
 		aggregateContainerState, found := aggregateContainerStateMap[containerName]
 		if !found {
-			aggregateContainerState = NewAggregateContainerState()
+			aggregateContainerState = NewAggregateContainerStateWithConfig(vpa.AggregationConfig)
 			aggregateContainerStateMap[containerName] = aggregateContainerState
 		}
 		aggregateContainerState.MergeContainerState(aggregation)
@@ -147,11 +204,25 @@ func (vpa *Vpa) MergeCheckpointedState(aggregateContainerStateMap ContainerNameT
 // AggregateStateByContainerName returns a map from container name to the aggregated state
 // of all containers with that name, belonging to pods matched by the VPA.
 func (vpa *Vpa) AggregateStateByContainerName() ContainerNameToAggregateStateMap {
-	containerNameToAggregateStateMap := AggregateStateByContainerName(vpa.aggregateContainerStates)
+	containerNameToAggregateStateMap := AggregateStateByContainerName(vpa.aggregateContainerStates, vpa.AggregationConfig)
 	vpa.MergeCheckpointedState(containerNameToAggregateStateMap)
 	return containerNameToAggregateStateMap
 }
 
+// ControllerStateForContainer returns the (possibly newly created) state of
+// the response-time controller for the container with the given name.
+func (vpa *Vpa) ControllerStateForContainer(containerName string) *ControllerState {
+	if vpa.ControllerStates == nil {
+		vpa.ControllerStates = make(ContainerNameToControllerStateMap)
+	}
+	state, found := vpa.ControllerStates[containerName]
+	if !found {
+		state = &ControllerState{}
+		vpa.ControllerStates[containerName] = state
+	}
+	return state
+}
+
 // HasRecommendation returns if the VPA object contains any recommendation
 func (vpa *Vpa) HasRecommendation() bool {
 	return (vpa.Recommendation != nil) && len(vpa.Recommendation.ContainerRecommendations) > 0
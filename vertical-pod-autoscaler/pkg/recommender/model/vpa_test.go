@@ -21,6 +21,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
 )
 
 var (
@@ -39,3 +41,49 @@ func TestMergeAggregateContainerState(t *testing.T) {
 
 	assert.Contains(t, containerNameToAggregateStateMap, "test")
 }
+
+func TestControllerStateForContainer(t *testing.T) {
+	vpa := NewVpa(VpaID{}, nil, anyTime)
+	state := vpa.ControllerStateForContainer("test")
+	state.IntegralTerm = 0.5
+	assert.Equal(t, state, vpa.ControllerStateForContainer("test"))
+	assert.NotEqual(t, state, vpa.ControllerStateForContainer("other"))
+}
+
+func TestControllerStateCheckpointRoundTrip(t *testing.T) {
+	state := ControllerState{
+		IntegralTerm: 0.25,
+		RequestCounters: map[string]CounterSample{
+			"default/pod-2": {Value: 20, Timestamp: time.Unix(990, 0)},
+			"default/pod-1": {Value: 1234, Timestamp: time.Unix(995, 0)},
+		},
+		LastUpdate: time.Unix(1000, 0),
+	}
+	checkpoint := state.SaveToCheckpoint()
+	if assert.Len(t, checkpoint.RequestCounters, 2) {
+		assert.Equal(t, "default/pod-1", checkpoint.RequestCounters[0].Object)
+		assert.Equal(t, "default/pod-2", checkpoint.RequestCounters[1].Object)
+	}
+	restored := ControllerState{}
+	restored.LoadFromCheckpoint(checkpoint)
+	assert.Equal(t, state.IntegralTerm, restored.IntegralTerm)
+	assert.True(t, state.LastUpdate.Equal(restored.LastUpdate))
+	if assert.Len(t, restored.RequestCounters, 2) {
+		assert.Equal(t, 1234.0, restored.RequestCounters["default/pod-1"].Value)
+		assert.True(t, time.Unix(995, 0).Equal(restored.RequestCounters["default/pod-1"].Timestamp))
+	}
+}
+
+func TestControllerStateLoadsLegacyRequestCount(t *testing.T) {
+	checkpoint := &vpa_types.ControllerStateCheckpoint{
+		LastUpdateTime: metav1.NewTime(time.Unix(1000, 0)),
+		IntegralTerm:   0.25,
+		RequestCount:   1254,
+	}
+	state := ControllerState{}
+	state.LoadFromCheckpoint(checkpoint)
+	assert.Equal(t, 1254.0, state.LegacyRequestCount)
+	assert.Empty(t, state.RequestCounters)
+	// The legacy total is kept until the controller runs again.
+	assert.Equal(t, 1254.0, state.SaveToCheckpoint().RequestCount)
+}
@@ -19,19 +19,24 @@ package routines
 import (
 	"context"
 	"flag"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
 	vpa_clientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
 	vpa_api "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/typed/autoscaling.k8s.io/v1beta2"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/checkpoint"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/metrics"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/logic"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
 	metrics_recommender "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/recommender"
 	vpa_utils "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+	kube_client "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
 )
 
@@ -41,12 +46,14 @@ const (
 )
 
 var (
-	checkpointsWriteTimeout = flag.Duration("checkpoints-timeout", time.Minute, `Timeout for writing checkpoints since the start of the recommender's main loop`)
-	minCheckpointsPerRun    = flag.Int("min-checkpoints", 10, "Minimum number of checkpoints to write per recommender's main loop")
-
-	customClient *kubernetes.Clientset
-	customConfig *rest.Config
-	customError error
+	checkpointsWriteTimeout   = flag.Duration("checkpoints-timeout", time.Minute, `Timeout for writing checkpoints since the start of the recommender's main loop`)
+	minCheckpointsPerRun      = flag.Int("min-checkpoints", 10, "Minimum number of checkpoints to write per recommender's main loop")
+	recommendationHistorySize = flag.Int("recommendation-history-size", 0, `Maximum number of past recommendations kept in the status of each VPA, 0 disables the recommendation history`)
+	checkpointStoreBackend    = flag.String("checkpoint-store", checkpoint.CRDStore, `Where checkpoints are stored: crd (an uncompressed VerticalPodAutoscalerCheckpoint object per container), configmap (a fixed number of ConfigMaps of gzip compressed checkpoints) or file (a gzip compressed local snapshot, e.g. on a persistent volume)`)
+	checkpointStoreNamespace  = flag.String("checkpoint-store-namespace", "kube-system", `Namespace of the ConfigMaps storing checkpoints when --checkpoint-store=configmap`)
+	checkpointConfigMaps      = flag.Int("checkpoint-configmaps", 16, `Number of ConfigMaps the checkpoints are spread over when --checkpoint-store=configmap. Each ConfigMap is limited to 1MiB, checkpoints of a ConfigMap above the limit are not written`)
+	checkpointFile            = flag.String("checkpoint-file", "/var/lib/vpa/checkpoints.json.gz", `File storing the checkpoints when --checkpoint-store=file`)
+	checkpointMigrateFrom     = flag.String("checkpoint-migrate-from", "", `If set, checkpoints are moved from this store to --checkpoint-store on startup`)
 )
 
 // Recommender recommend resources for certain containers, based on utilization periodically got from metrics api.
@@ -91,6 +98,8 @@ func (r *recommender) GetClusterStateFeeder() input.ClusterStateFeeder {
 func (r *recommender) UpdateVPAs() {
 	cnt := metrics_recommender.NewObjectCounter()
 	defer cnt.Observe()
+	telemetry := metrics_recommender.NewControllerTelemetry()
+	defer telemetry.Observe()
 
 	for _, observedVpa := range r.clusterState.ObservedVpas {
 		key := model.VpaID{
@@ -101,18 +110,42 @@ func (r *recommender) UpdateVPAs() {
 		if !found {
 			continue
 		}
-		resources := r.podResourceRecommender.GetRecommendedPodResources(GetContainerNameToAggregateStateMap(vpa), customClient)
+		vpa.PodCount = r.clusterState.CountVpaPods(vpa)
+		containerNameToAggregateStateMap := GetContainerNameToAggregateStateMap(vpa)
+		resources := r.podResourceRecommender.GetRecommendedPodResources(containerNameToAggregateStateMap, vpa)
 		had := vpa.HasRecommendation()
 		vpa.Recommendation = getCappedRecommendation(vpa.ID, resources, observedVpa.Spec.ResourcePolicy)
 		// Set RecommendationProvided if recommendation not empty.
 		if len(vpa.Recommendation.ContainerRecommendations) > 0 {
 			vpa.Conditions.Set(vpa_types.RecommendationProvided, true, "", "")
+			vpa.Conditions.Set(vpa_types.RecommenderSelected, true, "", getRecommenderSelectionMessage(resources))
 			if !had {
 				metrics_recommender.ObserveRecommendationLatency(vpa.Created)
 			}
 		} else {
 			vpa.Conditions.Set(vpa_types.RecommendationProvided, false, "", "")
+			delete(vpa.Conditions, vpa_types.RecommenderSelected)
+		}
+		for containerName, res := range resources {
+			if res.CustomMetricsProblem != nil {
+				metrics_recommender.RecordCustomMetricsUnavailable(res.CustomMetricsProblem.Reason, res.CustomMetricsProblem.Fallback)
+			}
+			if res.ControllerStep != nil {
+				telemetry.Add(vpa.ID, containerName, getControllerSignals(res.ControllerStep))
+			}
+		}
+		if message := getCustomMetricsUnavailableMessage(resources); message != "" {
+			vpa.Conditions.Set(vpa_types.CustomMetricsUnavailable, true, "", message)
+		} else {
+			delete(vpa.Conditions, vpa_types.CustomMetricsUnavailable)
 		}
+		if conflicting := vpa.ConflictingAggregations(); len(conflicting) > 0 {
+			vpa.Conditions.Set(vpa_types.AggregationPolicyConflict, true, "",
+				fmt.Sprintf("Containers matched by another VPA with a different aggregation policy: %s", strings.Join(conflicting, ", ")))
+		} else {
+			delete(vpa.Conditions, vpa_types.AggregationPolicyConflict)
+		}
+		vpa.RecommendationHistory = getRecommendationHistory(vpa, resources, containerNameToAggregateStateMap, observedVpa.Status.RecommendationHistory)
 		cnt.Add(vpa)
 
 		_, err := vpa_utils.UpdateVpaStatusIfNeeded(
@@ -149,6 +182,99 @@ func getCappedRecommendation(vpaID model.VpaID, resources logic.RecommendedPodRe
 	return cappedRecommendation
 }
 
+// getRecommendationHistory returns the observed recommendation history of the
+// VPA with the current recommendation appended, explained by the inputs of the
+// algorithms that produced it.
+func getRecommendationHistory(vpa *model.Vpa, resources logic.RecommendedPodResources,
+	containerNameToAggregateStateMap model.ContainerNameToAggregateStateMap,
+	history []vpa_types.RecommendationHistoryEntry) []vpa_types.RecommendationHistoryEntry {
+	if *recommendationHistorySize <= 0 {
+		return nil
+	}
+	if !vpa.HasRecommendation() {
+		return history
+	}
+	params := logic.GetHistogramParams(vpa)
+	entry := vpa_types.RecommendationHistoryEntry{Time: metav1.Now()}
+	for _, recommendation := range vpa.Recommendation.ContainerRecommendations {
+		explanation := vpa_types.RecommendationExplanation{
+			ContainerName: recommendation.ContainerName,
+			Target:        recommendation.Target,
+			Recommender:   resources[recommendation.ContainerName].Recommender,
+		}
+		if aggregation, found := containerNameToAggregateStateMap[recommendation.ContainerName]; found {
+			explanation.TotalSamplesCount = aggregation.TotalSamplesCount
+			explanation.OOMCount = aggregation.OOMCount
+			if !aggregation.LastOOMTime.IsZero() {
+				lastOOMTime := metav1.NewTime(aggregation.LastOOMTime)
+				explanation.LastOOMTime = &lastOOMTime
+			}
+		}
+		if step := resources[recommendation.ContainerName].ControllerStep; step != nil {
+			controllerError := step.Error
+			explanation.ControllerError = &controllerError
+		} else if explanation.Recommender == vpa_types.ContainerRecommenderHistogram {
+			cpuPercentile, memoryPercentile := params.TargetCPUPercentile, params.TargetMemoryPercentile
+			explanation.TargetCPUPercentile = &cpuPercentile
+			explanation.TargetMemoryPercentile = &memoryPercentile
+		}
+		entry.ContainerRecommendations = append(entry.ContainerRecommendations, explanation)
+	}
+	return vpa_utils.AppendRecommendationHistory(history, entry, *recommendationHistorySize)
+}
+
+// getRecommenderSelectionMessage describes which algorithm produced the
+// recommendation of each container, e.g. "app: response-time-controller, sidecar: histogram".
+func getRecommenderSelectionMessage(resources logic.RecommendedPodResources) string {
+	containerNames := make([]string, 0, len(resources))
+	for containerName := range resources {
+		containerNames = append(containerNames, containerName)
+	}
+	sort.Strings(containerNames)
+	selections := make([]string, 0, len(containerNames))
+	for _, containerName := range containerNames {
+		selections = append(selections, fmt.Sprintf("%s: %s", containerName, resources[containerName].Recommender))
+	}
+	return strings.Join(selections, ", ")
+}
+
+// getCustomMetricsUnavailableMessage describes the containers whose
+// response-time controller could not use its custom metrics, e.g. "app: Stale, HoldLastRecommendation (metric response_time is 5m0s old)".
+// Returns an empty string if there are no such containers.
+func getCustomMetricsUnavailableMessage(resources logic.RecommendedPodResources) string {
+	containerNames := make([]string, 0, len(resources))
+	for containerName, res := range resources {
+		if res.CustomMetricsProblem != nil {
+			containerNames = append(containerNames, containerName)
+		}
+	}
+	sort.Strings(containerNames)
+	problems := make([]string, 0, len(containerNames))
+	for _, containerName := range containerNames {
+		problem := resources[containerName].CustomMetricsProblem
+		problems = append(problems, fmt.Sprintf("%s: %s, %s (%s)", containerName, problem.Reason, problem.Fallback, problem.Message))
+	}
+	return strings.Join(problems, "; ")
+}
+
+// getControllerSignals converts the terms of the response-time controller to
+// the signals exported as metrics.
+func getControllerSignals(step *logic.ControllerStep) metrics_recommender.ControllerSignals {
+	return metrics_recommender.ControllerSignals{
+		Requests:              step.Requests,
+		ResponseTime:          step.ResponseTime,
+		Error:                 step.Error,
+		ProportionalTerm:      step.ProportionalTerm,
+		IntegralTerm:          step.IntegralTerm,
+		Output:                step.Output,
+		TargetCores:           step.TargetCores,
+		Cores:                 step.Cores,
+		PredictedResponseTime: step.PredictedResponseTime,
+		SLAViolated:           step.Error < 0,
+		Saturated:             step.Saturated,
+	}
+}
+
 func (r *recommender) MaintainCheckpoints(ctx context.Context, minCheckpointsPerRun int) {
 	now := time.Now()
 	if r.useCheckpoints {
@@ -233,27 +359,58 @@ func (c RecommenderFactory) Make() Recommender {
 // NewRecommender creates a new recommender instance.
 // Dependencies are created automatically.
 // Deprecated; use RecommenderFactory instead.
-func NewRecommender(config *rest.Config, checkpointsGCInterval time.Duration, useCheckpoints bool) Recommender {
-
-	// get config for custom client
-	customConfig, customError = rest.InClusterConfig()
-	if customError != nil {
-		panic(customError.Error())
-	}
-	// creates the clientset
-	customClient, customError = kubernetes.NewForConfig(customConfig)
-	if customError != nil {
-		panic(customError.Error())
+func NewRecommender(config *rest.Config, checkpointsGCInterval time.Duration, useCheckpoints bool, recommenderName string) Recommender {
+	customMetricsClient, err := metrics.NewCustomMetricsClientForConfig(config)
+	if err != nil {
+		klog.Fatalf("Failed to create custom metrics client: %v", err)
 	}
 
 	clusterState := model.NewClusterState()
+	checkpointStore := newCheckpointStore(config, recommenderName, useCheckpoints)
 	return RecommenderFactory{
 		ClusterState:           clusterState,
-		ClusterStateFeeder:     input.NewClusterStateFeeder(config, clusterState),
-		CheckpointWriter:       checkpoint.NewCheckpointWriter(clusterState, vpa_clientset.NewForConfigOrDie(config).AutoscalingV1beta2()),
+		ClusterStateFeeder:     input.NewClusterStateFeeder(config, clusterState, recommenderName, checkpointStore),
+		CheckpointWriter:       checkpoint.NewCheckpointWriter(clusterState, checkpointStore),
 		VpaClient:              vpa_clientset.NewForConfigOrDie(config).AutoscalingV1beta2(),
-		PodResourceRecommender: logic.CreatePodResourceRecommender(),
+		PodResourceRecommender: logic.CreatePodResourceRecommender(customMetricsClient),
 		CheckpointsGCInterval:  checkpointsGCInterval,
 		UseCheckpoints:         useCheckpoints,
 	}.Make()
 }
+
+func newCheckpointStore(config *rest.Config, recommenderName string, useCheckpoints bool) checkpoint.CheckpointStore {
+	vpaCheckpointClient := vpa_clientset.NewForConfigOrDie(config).AutoscalingV1beta2()
+	kubeClient := kube_client.NewForConfigOrDie(config)
+	storeConfig := checkpoint.StoreConfig{
+		Backend:         *checkpointStoreBackend,
+		Namespace:       *checkpointStoreNamespace,
+		ConfigMapPrefix: "vpa-checkpoints-" + recommenderName,
+		Shards:          *checkpointConfigMaps,
+		File:            *checkpointFile,
+	}
+	checkpointStore, err := checkpoint.NewCheckpointStore(storeConfig, vpaCheckpointClient, kubeClient.CoreV1())
+	if err != nil {
+		klog.Fatalf("Failed to create checkpoint store: %v", err)
+	}
+	if !useCheckpoints || *checkpointMigrateFrom == "" || *checkpointMigrateFrom == *checkpointStoreBackend {
+		return checkpointStore
+	}
+
+	storeConfig.Backend = *checkpointMigrateFrom
+	migrateFrom, err := checkpoint.NewCheckpointStore(storeConfig, vpaCheckpointClient, kubeClient.CoreV1())
+	if err != nil {
+		klog.Fatalf("Failed to create checkpoint store to migrate from: %v", err)
+	}
+	namespaceList, err := kubeClient.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		klog.Fatalf("Cannot list namespaces to migrate checkpoints: %v", err)
+	}
+	namespaces := make([]string, 0, len(namespaceList.Items))
+	for _, namespace := range namespaceList.Items {
+		namespaces = append(namespaces, namespace.Name)
+	}
+	if err := checkpoint.MigrateCheckpoints(migrateFrom, checkpointStore, namespaces); err != nil {
+		klog.Fatalf("Failed to migrate checkpoints from %v to %v store: %v", *checkpointMigrateFrom, *checkpointStoreBackend, err)
+	}
+	return checkpointStore
+}
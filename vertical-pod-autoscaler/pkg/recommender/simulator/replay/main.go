@@ -0,0 +1,82 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command replay runs a recorded load trace through the response-time
+// controller offline and prints the allocated cores and the predicted
+// response time over time in CSV format.
+//
+// The controller is configured with the same control-* flags as the
+// recommender, e.g.:
+//
+//	replay --trace=trace.csv --replicas=3 --control-sla=0.6 --control-a=0.5
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	kube_flag "k8s.io/apiserver/pkg/util/flag"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/logic"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/simulator"
+	"k8s.io/klog"
+)
+
+var (
+	traceFile    = flag.String("trace", "", `CSV file with the trace: time in seconds, request rate per second and optionally response time in seconds. Standard input if empty`)
+	plant        = flag.String("plant", "model", `Model of the workload: "model" for the controller model with the plant-a* coefficients, "trace" to replay the recorded response times`)
+	plantA1      = flag.Float64("plant-a1", 0.1963, `a1 coefficient of the workload model`)
+	plantA2      = flag.Float64("plant-a2", 0.002, `a2 coefficient of the workload model`)
+	plantA3      = flag.Float64("plant-a3", 0.5658, `a3 coefficient of the workload model`)
+	replicas     = flag.Int("replicas", 1, `Number of replicas serving the load`)
+	initialCores = flag.Float64("initial-cores", 1.0, `Cores allocated to each replica before the first iteration`)
+)
+
+func main() {
+	kube_flag.InitFlags()
+
+	var input io.Reader = os.Stdin
+	if *traceFile != "" {
+		file, err := os.Open(*traceFile)
+		if err != nil {
+			klog.Fatalf("Cannot open trace: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+	trace, err := simulator.ReadTrace(input)
+	if err != nil {
+		klog.Fatalf("Cannot read trace: %v", err)
+	}
+
+	config := simulator.Config{
+		Params:       logic.DefaultControllerParams(""),
+		Replicas:     *replicas,
+		InitialCores: *initialCores,
+	}
+	switch *plant {
+	case "model":
+		config.Plant = simulator.ModelPlant{A1: *plantA1, A2: *plantA2, A3: *plantA3}
+	case "trace":
+		config.Plant = simulator.TracePlant{}
+	default:
+		klog.Fatalf("Unknown plant %q", *plant)
+	}
+
+	if err := simulator.WriteSteps(os.Stdout, simulator.Run(config, trace)); err != nil {
+		klog.Fatalf("Cannot write steps: %v", err)
+	}
+}
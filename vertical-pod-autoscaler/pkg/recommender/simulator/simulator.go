@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package simulator replays recorded load traces through the response-time
+// controller offline, against a model of the workload (the plant). It is
+// meant for tuning the controller and for asserting its behaviour in tests.
+package simulator
+
+import (
+	"time"
+
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/logic"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+)
+
+// TracePoint is a sample of a recorded load trace.
+type TracePoint struct {
+	// Time since the start of the trace.
+	Time time.Duration
+	// Request rate of the workload, in requests per second.
+	RequestRate float64
+	// Recorded response time, in seconds. Used by TracePlant only.
+	ResponseTime float64
+}
+
+// Plant models the response time of the workload.
+type Plant interface {
+	// ResponseTime returns the response time, in seconds, of a replica
+	// serving the given requests in the sampling period with the given cores.
+	ResponseTime(point TracePoint, requests, cores float64) float64
+}
+
+// ModelPlant is a workload whose response time follows the model of the
+// controller with the given coefficients:
+// rt = a1 + 1000*a2*requests/(requests+1000*a3*cores).
+// Using coefficients different from the nominal ones simulates a model error.
+type ModelPlant struct {
+	A1 float64
+	A2 float64
+	A3 float64
+}
+
+// ResponseTime implements Plant.
+func (p ModelPlant) ResponseTime(point TracePoint, requests, cores float64) float64 {
+	denominator := requests + 1000.0*p.A3*cores
+	if denominator <= 0 {
+		return p.A1
+	}
+	return p.A1 + 1000.0*p.A2*requests/denominator
+}
+
+// TracePlant replays the response times recorded in the trace, regardless of
+// the allocated cores. It is useful to inspect how the controller reacts to
+// the recorded inputs, not to assess the closed loop.
+type TracePlant struct{}
+
+// ResponseTime implements Plant.
+func (TracePlant) ResponseTime(point TracePoint, requests, cores float64) float64 {
+	return point.ResponseTime
+}
+
+// Config configures a simulation.
+type Config struct {
+	// Configuration of the controller.
+	Params logic.ControllerParams
+	// Model of the workload.
+	Plant Plant
+	// Number of replicas serving the load. Values below 1 mean 1.
+	Replicas int
+	// Cores allocated to each replica before the first iteration.
+	InitialCores float64
+}
+
+// Step is the outcome of an iteration of the controller.
+type Step struct {
+	// Time since the start of the trace.
+	Time time.Duration
+	// Request rate of the workload, in requests per second.
+	RequestRate float64
+	// Response time observed by the controller, with the cores allocated in
+	// the previous iteration.
+	ResponseTime float64
+	// Cores allocated to each replica by the controller.
+	Cores float64
+	// Response time the plant predicts with the allocated cores.
+	PredictedResponseTime float64
+	// Terms computed by the controller.
+	Controller logic.ControllerStep
+}
+
+// Run replays the trace through the controller, running an iteration for
+// every point of the trace, and returns the steps of the simulation.
+func Run(config Config, trace []TracePoint) []Step {
+	replicas := config.Replicas
+	if replicas < 1 {
+		replicas = 1
+	}
+	state := &model.ControllerState{}
+	cores := config.InitialCores
+	steps := make([]Step, 0, len(trace))
+	for i, point := range trace {
+		period := config.Params.SamplingPeriod
+		if i > 0 {
+			period = point.Time - trace[i-1].Time
+		}
+		requests := point.RequestRate / float64(replicas) * config.Params.SamplingPeriod.Seconds()
+		responseTime := config.Plant.ResponseTime(point, requests, cores)
+		controllerStep := logic.StepController(config.Params, state, responseTime, requests, period)
+		cores = controllerStep.Cores
+		steps = append(steps, Step{
+			Time:                  point.Time,
+			RequestRate:           point.RequestRate,
+			ResponseTime:          responseTime,
+			Cores:                 cores,
+			PredictedResponseTime: config.Plant.ResponseTime(point, requests, cores),
+			Controller:            controllerStep,
+		})
+	}
+	return steps
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/logic"
+)
+
+var nominalPlant = ModelPlant{A1: 0.1963, A2: 0.002, A3: 0.5658}
+
+func testParams(closedLoopPole float64) logic.ControllerParams {
+	return logic.ControllerParams{
+		SLA:            0.6,
+		ClosedLoopPole: closedLoopPole,
+		NominalPole:    0.8,
+		A1Nom:          nominalPlant.A1,
+		A2Nom:          nominalPlant.A2,
+		A3Nom:          nominalPlant.A3,
+		MinCores:       0.025,
+		MaxCores:       4,
+		SamplingPeriod: 30 * time.Second,
+	}
+}
+
+// stepTrace returns a trace sampled every 30 seconds whose request rate
+// changes from the first to the second value halfway through.
+func stepTrace(length int, before, after float64) []TracePoint {
+	trace := make([]TracePoint, length)
+	for i := range trace {
+		trace[i] = TracePoint{Time: time.Duration(i) * 30 * time.Second, RequestRate: before}
+		if i >= length/2 {
+			trace[i].RequestRate = after
+		}
+	}
+	return trace
+}
+
+func TestRunFollowsTrace(t *testing.T) {
+	trace := stepTrace(10, 10, 20)
+	steps := Run(Config{Params: testParams(0.9), Plant: nominalPlant, Replicas: 2, InitialCores: 1}, trace)
+	assert.Len(t, steps, len(trace))
+	for i, step := range steps {
+		assert.Equal(t, trace[i].Time, step.Time)
+		assert.Equal(t, trace[i].RequestRate, step.RequestRate)
+		// 2 replicas serving the rate during a 30 seconds period.
+		assert.InDelta(t, trace[i].RequestRate*15, step.Controller.Requests, 1e-9)
+		assert.Equal(t, step.Controller.Cores, step.Cores)
+	}
+	// The first response time is observed with the initial cores, the next
+	// ones with the cores allocated in the previous step.
+	assert.InDelta(t, nominalPlant.ResponseTime(trace[0], 150, 1), steps[0].ResponseTime, 1e-9)
+	for i := 1; i < len(steps); i++ {
+		assert.InDelta(t, nominalPlant.ResponseTime(trace[i], steps[i].Controller.Requests, steps[i-1].Cores), steps[i].ResponseTime, 1e-9)
+	}
+}
+
+func TestRunIsDeterministic(t *testing.T) {
+	trace := stepTrace(20, 10, 20)
+	config := Config{Params: testParams(0.5), Plant: nominalPlant, Replicas: 2, InitialCores: 1}
+	assert.Equal(t, Run(config, trace), Run(config, trace))
+}
+
+func TestRunKeepsCoresWithinBounds(t *testing.T) {
+	for _, closedLoopPole := range []float64{0.5, 0.8, 0.9, 0.95} {
+		params := testParams(closedLoopPole)
+		for _, plant := range []Plant{nominalPlant, ModelPlant{A1: 0.1963, A2: 0.002, A3: 0.45}} {
+			steps := Run(Config{Params: params, Plant: plant, Replicas: 2, InitialCores: 0.1}, stepTrace(60, 10, 20))
+			for _, step := range steps {
+				assert.True(t, step.Cores >= params.MinCores && step.Cores <= params.MaxCores,
+					"pole %v: cores %v out of bounds at %v", closedLoopPole, step.Cores, step.Time)
+				if step.ResponseTime > params.SLA {
+					assert.Equal(t, params.MaxCores, step.Cores, "pole %v: SLA violated at %v", closedLoopPole, step.Time)
+				}
+			}
+		}
+	}
+}
+
+func TestRunTracksSLA(t *testing.T) {
+	params := testParams(0.9)
+	steps := Run(Config{Params: params, Plant: nominalPlant, Replicas: 2, InitialCores: 1}, stepTrace(60, 10, 20))
+
+	// The controller settles below the SLA both before and after the load
+	// doubles, allocating more cores to the higher load.
+	for _, settled := range [][]Step{steps[20:30], steps[50:60]} {
+		for i, step := range settled {
+			assert.True(t, step.ResponseTime <= params.SLA, "response time %v above the SLA at %v", step.ResponseTime, step.Time)
+			assert.True(t, step.ResponseTime >= 0.8*params.SLA, "response time %v too far below the SLA at %v", step.ResponseTime, step.Time)
+			if i > 0 {
+				assert.InEpsilon(t, settled[i-1].Cores, step.Cores, 0.05, "cores not settled at %v", step.Time)
+			}
+		}
+	}
+	assert.True(t, steps[59].Cores > steps[29].Cores)
+}
+
+func TestTracePlant(t *testing.T) {
+	trace := []TracePoint{
+		{Time: 0, RequestRate: 10, ResponseTime: 0.3},
+		{Time: 30 * time.Second, RequestRate: 10, ResponseTime: 0.9},
+		{Time: 60 * time.Second, RequestRate: 10, ResponseTime: 0.4},
+	}
+	params := testParams(0.9)
+	steps := Run(Config{Params: params, Plant: TracePlant{}, Replicas: 1, InitialCores: 1}, trace)
+	for i, step := range steps {
+		assert.Equal(t, trace[i].ResponseTime, step.ResponseTime)
+		assert.Equal(t, trace[i].ResponseTime, step.PredictedResponseTime)
+	}
+	assert.Equal(t, params.MaxCores, steps[1].Cores)
+}
+
+func TestModelPlant(t *testing.T) {
+	assert.InDelta(t, 0.1963, nominalPlant.ResponseTime(TracePoint{}, 0, 1), 1e-9)
+	assert.InDelta(t, 0.1963, nominalPlant.ResponseTime(TracePoint{}, 0, 0), 1e-9)
+	// More cores give a lower response time.
+	assert.True(t, nominalPlant.ResponseTime(TracePoint{}, 300, 2) < nominalPlant.ResponseTime(TracePoint{}, 300, 1))
+	// Without cores the response time tends to a1 + 1000*a2.
+	assert.InDelta(t, 2.1963, nominalPlant.ResponseTime(TracePoint{}, 300, 0), 1e-9)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ReadTrace reads a trace in CSV format. Every record holds the time since the
+// start of the trace in seconds, the request rate in requests per second and
+// optionally the response time in seconds. Lines starting with '#' and a
+// header line are skipped.
+func ReadTrace(r io.Reader) ([]TracePoint, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	trace := make([]TracePoint, 0, len(records))
+	for i, record := range records {
+		if i == 0 && len(record) > 0 {
+			if _, err := strconv.ParseFloat(record[0], 64); err != nil {
+				// Header.
+				continue
+			}
+		}
+		point, err := parseTracePoint(record)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %v", i+1, err)
+		}
+		if len(trace) > 0 && point.Time <= trace[len(trace)-1].Time {
+			return nil, fmt.Errorf("record %d: time %v is not after the previous record", i+1, point.Time)
+		}
+		trace = append(trace, point)
+	}
+	return trace, nil
+}
+
+func parseTracePoint(record []string) (TracePoint, error) {
+	if len(record) < 2 || len(record) > 3 {
+		return TracePoint{}, fmt.Errorf("expected 2 or 3 fields, got %d", len(record))
+	}
+	values := make([]float64, len(record))
+	for i, field := range record {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return TracePoint{}, err
+		}
+		if value < 0 {
+			return TracePoint{}, fmt.Errorf("negative value %v", value)
+		}
+		values[i] = value
+	}
+	point := TracePoint{
+		Time:        time.Duration(values[0] * float64(time.Second)),
+		RequestRate: values[1],
+	}
+	if len(values) == 3 {
+		point.ResponseTime = values[2]
+	}
+	return point, nil
+}
+
+// WriteSteps writes the steps of a simulation in CSV format, with a header.
+func WriteSteps(w io.Writer, steps []Step) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"time", "request_rate", "response_time", "cores", "predicted_response_time"}); err != nil {
+		return err
+	}
+	for _, step := range steps {
+		record := []string{
+			strconv.FormatFloat(step.Time.Seconds(), 'f', 3, 64),
+			strconv.FormatFloat(step.RequestRate, 'f', 3, 64),
+			strconv.FormatFloat(step.ResponseTime, 'f', 3, 64),
+			strconv.FormatFloat(step.Cores, 'f', 3, 64),
+			strconv.FormatFloat(step.PredictedResponseTime, 'f', 3, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadTrace(t *testing.T) {
+	input := `# Recorded on the test cluster.
+time,request_rate,response_time
+0, 10, 0.3
+30, 12.5
+90.5, 20, 0.45
+`
+	trace, err := ReadTrace(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []TracePoint{
+		{Time: 0, RequestRate: 10, ResponseTime: 0.3},
+		{Time: 30 * time.Second, RequestRate: 12.5},
+		{Time: 90500 * time.Millisecond, RequestRate: 20, ResponseTime: 0.45},
+	}, trace)
+}
+
+func TestReadTraceErrors(t *testing.T) {
+	for name, input := range map[string]string{
+		"too few fields":   "0\n",
+		"too many fields":  "0,1,2,3\n",
+		"not a number":     "0,1\n30,x\n",
+		"negative value":   "0,-1\n",
+		"time not after":   "0,1\n30,1\n30,2\n",
+		"header not first": "0,1\ntime,request_rate\n",
+		"unterminated csv": "0,\"1\n",
+	} {
+		_, err := ReadTrace(strings.NewReader(input))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestWriteSteps(t *testing.T) {
+	var buffer bytes.Buffer
+	err := WriteSteps(&buffer, []Step{
+		{Time: 30 * time.Second, RequestRate: 10, ResponseTime: 0.5, Cores: 1.25, PredictedResponseTime: 0.55},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "time,request_rate,response_time,cores,predicted_response_time\n"+
+		"30.000,10.000,0.500,1.250,0.550\n", buffer.String())
+}
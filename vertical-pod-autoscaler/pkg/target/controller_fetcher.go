@@ -0,0 +1,119 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package target
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	kube_client "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/scale"
+)
+
+const (
+	// maxOwnerChainLength bounds the walk up the owner chain, to guard
+	// against cycles in the owner references.
+	maxOwnerChainLength = 10
+)
+
+// ScalableController is a controller implementing the scale subresource.
+type ScalableController struct {
+	Namespace string
+	Kind      string
+	Name      string
+	// Replicas is the desired number of replicas from the scale subresource.
+	Replicas int
+}
+
+// ControllerFetcher resolves controllers that are not well known to VPA,
+// e.g. custom resources managing pods directly or through other custom
+// resources.
+type ControllerFetcher interface {
+	// FindScalableController walks the owner chain starting from the given
+	// owner, in the given namespace, and returns the first controller
+	// implementing the scale subresource. Returns nil if there is none, or
+	// if reading an owner in the chain is forbidden.
+	FindScalableController(namespace string, owner *metav1.OwnerReference) (*ScalableController, error)
+}
+
+// NewControllerFetcher returns new instance of ControllerFetcher.
+func NewControllerFetcher(config *rest.Config, kubeClient kube_client.Interface) ControllerFetcher {
+	scaleNamespacer, mapper := newScaleClient(config, kubeClient)
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		glog.Fatalf("Could not create dynamicClient: %v", err)
+	}
+	return &controllerFetcher{
+		scaleNamespacer: scaleNamespacer,
+		mapper:          mapper,
+		dynamicClient:   dynamicClient,
+	}
+}
+
+// controllerFetcher implements ControllerFetcher interface by querying API
+// server for the scale subresource of the controllers, and for the owner
+// references of the controllers without it.
+type controllerFetcher struct {
+	scaleNamespacer scale.ScalesGetter
+	mapper          apimeta.RESTMapper
+	dynamicClient   dynamic.Interface
+}
+
+func (f *controllerFetcher) FindScalableController(namespace string, owner *metav1.OwnerReference) (*ScalableController, error) {
+	for i := 0; owner != nil; i++ {
+		if i == maxOwnerChainLength {
+			return nil, fmt.Errorf("owner chain of %s %s/%s is longer than %d", owner.Kind, namespace, owner.Name, maxOwnerChainLength)
+		}
+		groupVersion, err := schema.ParseGroupVersion(owner.APIVersion)
+		if err != nil {
+			return nil, err
+		}
+		mapping, err := f.mapper.RESTMapping(schema.GroupKind{Group: groupVersion.Group, Kind: owner.Kind}, groupVersion.Version)
+		if err != nil {
+			return nil, err
+		}
+		scale, err := f.scaleNamespacer.Scales(namespace).Get(mapping.Resource.GroupResource(), owner.Name)
+		if err == nil {
+			return &ScalableController{
+				Namespace: namespace,
+				Kind:      owner.Kind,
+				Name:      owner.Name,
+				Replicas:  int(scale.Spec.Replicas),
+			}, nil
+		}
+		// No scale subresource (or we lack RBAC), continue with the controller
+		// of the owner.
+		obj, err := f.dynamicClient.Resource(mapping.Resource).Namespace(namespace).Get(owner.Name, metav1.GetOptions{})
+		if errors.IsForbidden(err) {
+			// Without RBAC to read the owner its controller can't be found,
+			// treat it as if there was no scalable controller.
+			glog.V(4).Infof("Not allowed to get %s %s/%s, assuming no scalable controller: %v", owner.Kind, namespace, owner.Name, err)
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %s/%s is not available, err: %v", owner.Kind, namespace, owner.Name, err)
+		}
+		owner = metav1.GetControllerOf(obj)
+	}
+	return nil, nil
+}
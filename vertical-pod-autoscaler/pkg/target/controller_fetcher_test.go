@@ -0,0 +1,171 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package target
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	autoscalingapi "k8s.io/api/autoscaling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/scale"
+)
+
+var (
+	clusterKind    = schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Cluster"}
+	clusterSetKind = schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "ClusterSet"}
+)
+
+func objectKey(resource schema.GroupResource, name string) string {
+	return resource.String() + "/" + name
+}
+
+type fakeScales struct {
+	scales map[string]*autoscalingapi.Scale
+}
+
+func (f *fakeScales) Scales(namespace string) scale.ScaleInterface {
+	return f
+}
+
+func (f *fakeScales) Get(resource schema.GroupResource, name string) (*autoscalingapi.Scale, error) {
+	if scale, found := f.scales[objectKey(resource, name)]; found {
+		return scale, nil
+	}
+	return nil, fmt.Errorf("%s %s has no scale subresource", resource, name)
+}
+
+func (f *fakeScales) Update(resource schema.GroupResource, scale *autoscalingapi.Scale) (*autoscalingapi.Scale, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// fakeDynamicClient serves the objects by resource and name. Only Get is
+// implemented.
+type fakeDynamicClient struct {
+	dynamic.NamespaceableResourceInterface
+	objects   map[string]*unstructured.Unstructured
+	forbidden map[string]bool
+	resource  schema.GroupVersionResource
+}
+
+func (f *fakeDynamicClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &fakeDynamicClient{objects: f.objects, forbidden: f.forbidden, resource: resource}
+}
+
+func (f *fakeDynamicClient) Namespace(string) dynamic.ResourceInterface {
+	return f
+}
+
+func (f *fakeDynamicClient) Get(name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if f.forbidden[objectKey(f.resource.GroupResource(), name)] {
+		return nil, apierrors.NewForbidden(f.resource.GroupResource(), name, fmt.Errorf("no RBAC"))
+	}
+	if obj, found := f.objects[objectKey(f.resource.GroupResource(), name)]; found {
+		return obj, nil
+	}
+	return nil, fmt.Errorf("%s %s not found", f.resource, name)
+}
+
+func newTestControllerFetcher(scales map[string]*autoscalingapi.Scale, objects ...*unstructured.Unstructured) *controllerFetcher {
+	mapper := apimeta.NewDefaultRESTMapper([]schema.GroupVersion{clusterKind.GroupVersion()})
+	mapper.Add(clusterKind, apimeta.RESTScopeNamespace)
+	mapper.Add(clusterSetKind, apimeta.RESTScopeNamespace)
+	objectsMap := make(map[string]*unstructured.Unstructured)
+	for _, obj := range objects {
+		mapping, _ := mapper.RESTMapping(obj.GroupVersionKind().GroupKind())
+		objectsMap[objectKey(mapping.Resource.GroupResource(), obj.GetName())] = obj
+	}
+	return &controllerFetcher{
+		scaleNamespacer: &fakeScales{scales: scales},
+		mapper:          mapper,
+		dynamicClient:   &fakeDynamicClient{objects: objectsMap},
+	}
+}
+
+func newTestObject(kind schema.GroupVersionKind, name string, owner *metav1.OwnerReference) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(kind)
+	obj.SetNamespace("default")
+	obj.SetName(name)
+	if owner != nil {
+		obj.SetOwnerReferences([]metav1.OwnerReference{*owner})
+	}
+	return obj
+}
+
+func newTestOwnerReference(kind schema.GroupVersionKind, name string) *metav1.OwnerReference {
+	isController := true
+	return &metav1.OwnerReference{
+		APIVersion: kind.GroupVersion().String(),
+		Kind:       kind.Kind,
+		Name:       name,
+		Controller: &isController,
+	}
+}
+
+func TestFindScalableControllerOwner(t *testing.T) {
+	f := newTestControllerFetcher(map[string]*autoscalingapi.Scale{
+		"clusters.example.com/cluster": {Spec: autoscalingapi.ScaleSpec{Replicas: 3}},
+	})
+	scalable, err := f.FindScalableController("default", newTestOwnerReference(clusterKind, "cluster"))
+	assert.NoError(t, err)
+	assert.Equal(t, &ScalableController{Namespace: "default", Kind: "Cluster", Name: "cluster", Replicas: 3}, scalable)
+}
+
+func TestFindScalableControllerWalksOwnerChain(t *testing.T) {
+	f := newTestControllerFetcher(map[string]*autoscalingapi.Scale{
+		"clustersets.example.com/cluster-set": {Spec: autoscalingapi.ScaleSpec{Replicas: 5}},
+	}, newTestObject(clusterKind, "cluster", newTestOwnerReference(clusterSetKind, "cluster-set")))
+	scalable, err := f.FindScalableController("default", newTestOwnerReference(clusterKind, "cluster"))
+	assert.NoError(t, err)
+	assert.Equal(t, &ScalableController{Namespace: "default", Kind: "ClusterSet", Name: "cluster-set", Replicas: 5}, scalable)
+}
+
+func TestFindScalableControllerNotFound(t *testing.T) {
+	f := newTestControllerFetcher(nil, newTestObject(clusterKind, "cluster", nil))
+	scalable, err := f.FindScalableController("default", newTestOwnerReference(clusterKind, "cluster"))
+	assert.NoError(t, err)
+	assert.Nil(t, scalable)
+}
+
+func TestFindScalableControllerMissingOwner(t *testing.T) {
+	f := newTestControllerFetcher(nil)
+	_, err := f.FindScalableController("default", newTestOwnerReference(clusterKind, "cluster"))
+	assert.Error(t, err)
+}
+
+func TestFindScalableControllerOwnerCycle(t *testing.T) {
+	f := newTestControllerFetcher(nil,
+		newTestObject(clusterKind, "cluster", newTestOwnerReference(clusterSetKind, "cluster-set")),
+		newTestObject(clusterSetKind, "cluster-set", newTestOwnerReference(clusterKind, "cluster")))
+	_, err := f.FindScalableController("default", newTestOwnerReference(clusterKind, "cluster"))
+	assert.Error(t, err)
+}
+
+func TestFindScalableControllerForbiddenOwner(t *testing.T) {
+	f := newTestControllerFetcher(nil)
+	f.dynamicClient = &fakeDynamicClient{forbidden: map[string]bool{"clusters.example.com/cluster": true}}
+	scalable, err := f.FindScalableController("default", newTestOwnerReference(clusterKind, "cluster"))
+	assert.NoError(t, err)
+	assert.Nil(t, scalable)
+}
@@ -65,17 +65,7 @@ const (
 
 // NewVpaTargetSelectorFetcher returns new instance of VpaTargetSelectorFetcher
 func NewVpaTargetSelectorFetcher(config *rest.Config, kubeClient kube_client.Interface, factory informers.SharedInformerFactory) VpaTargetSelectorFetcher {
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
-	if err != nil {
-		glog.Fatalf("Could not create discoveryClient: %v", err)
-	}
-	resolver := scale.NewDiscoveryScaleKindResolver(discoveryClient)
-	restClient := kubeClient.CoreV1().RESTClient()
-	cachedDiscoveryClient := cacheddiscovery.NewMemCacheClient(discoveryClient)
-	mapper := restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscoveryClient)
-	go wait.Until(func() {
-		mapper.Reset()
-	}, discoveryResetPeriod, make(chan struct{}))
+	scaleNamespacer, mapper := newScaleClient(config, kubeClient)
 
 	informersMap := map[wellKnownController]cache.SharedIndexInformer{
 		daemonSet:             factory.Apps().V1().DaemonSets().Informer(),
@@ -91,13 +81,12 @@ func NewVpaTargetSelectorFetcher(config *rest.Config, kubeClient kube_client.Int
 		go informer.Run(stopCh)
 		synced := cache.WaitForCacheSync(stopCh, informer.HasSynced)
 		if !synced {
-			glog.Fatalf("Could not sync cache for %s: %v", kind, err)
+			glog.Fatalf("Could not sync cache for %s", kind)
 		} else {
 			glog.Infof("Initial sync of %s completed", kind)
 		}
 	}
 
-	scaleNamespacer := scale.New(restClient, mapper, dynamic.LegacyAPIPathResolverFunc, resolver)
 	return &vpaTargetSelectorFetcher{
 		scaleNamespacer: scaleNamespacer,
 		mapper:          mapper,
@@ -105,6 +94,25 @@ func NewVpaTargetSelectorFetcher(config *rest.Config, kubeClient kube_client.Int
 	}
 }
 
+// newScaleClient returns a client for the scale subresource of any resource,
+// and the REST mapper it uses to map kinds to resources. The mapper is reset
+// periodically to discover new resources.
+func newScaleClient(config *rest.Config, kubeClient kube_client.Interface) (scale.ScalesGetter, apimeta.RESTMapper) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		glog.Fatalf("Could not create discoveryClient: %v", err)
+	}
+	resolver := scale.NewDiscoveryScaleKindResolver(discoveryClient)
+	restClient := kubeClient.CoreV1().RESTClient()
+	cachedDiscoveryClient := cacheddiscovery.NewMemCacheClient(discoveryClient)
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscoveryClient)
+	go wait.Until(func() {
+		mapper.Reset()
+	}, discoveryResetPeriod, make(chan struct{}))
+
+	return scale.New(restClient, mapper, dynamic.LegacyAPIPathResolverFunc, resolver), mapper
+}
+
 // vpaTargetSelectorFetcher implements VpaTargetSelectorFetcher interface
 // by querying API server for the controller pointed by VPA's targetRef
 type vpaTargetSelectorFetcher struct {
@@ -0,0 +1,58 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mocktarget
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	target "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target"
+)
+
+// MockControllerFetcher is a mock of ControllerFetcher interface
+type MockControllerFetcher struct {
+	ctrl     *gomock.Controller
+	recorder *_MockControllerFetcherRecorder
+}
+
+// Recorder for MockControllerFetcher (not exported)
+type _MockControllerFetcherRecorder struct {
+	mock *MockControllerFetcher
+}
+
+// NewMockControllerFetcher returns mock instance of a mock of ControllerFetcher
+func NewMockControllerFetcher(ctrl *gomock.Controller) *MockControllerFetcher {
+	mock := &MockControllerFetcher{ctrl: ctrl}
+	mock.recorder = &_MockControllerFetcherRecorder{mock}
+	return mock
+}
+
+// EXPECT enables configuring expectaions
+func (_m *MockControllerFetcher) EXPECT() *_MockControllerFetcherRecorder {
+	return _m.recorder
+}
+
+// FindScalableController enables configuring expectations on FindScalableController method
+func (_m *MockControllerFetcher) FindScalableController(namespace string, owner *v1.OwnerReference) (*target.ScalableController, error) {
+	ret := _m.ctrl.Call(_m, "FindScalableController", namespace, owner)
+	ret0, _ := ret[0].(*target.ScalableController)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockControllerFetcherRecorder) FindScalableController(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FindScalableController", arg0, arg1)
+}
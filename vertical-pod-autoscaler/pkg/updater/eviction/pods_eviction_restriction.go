@@ -24,6 +24,7 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target"
 	metrics_updater "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/updater"
 	appsinformer "k8s.io/client-go/informers/apps/v1"
 	coreinformer "k8s.io/client-go/informers/core/v1"
@@ -73,6 +74,7 @@ type podsEvictionRestrictionFactoryImpl struct {
 	rcInformer                cache.SharedIndexInformer // informer for Replication Controllers
 	ssInformer                cache.SharedIndexInformer // informer for Stateful Sets
 	rsInformer                cache.SharedIndexInformer // informer for Replica Sets
+	controllerFetcher         target.ControllerFetcher  // resolves controllers of other kinds
 	minReplicas               int
 	evictionToleranceFraction float64
 }
@@ -155,8 +157,10 @@ func (e *podsEvictionRestrictionImpl) Evict(podToEvict *apiv1.Pod, eventRecorder
 	return nil
 }
 
-// NewPodsEvictionRestrictionFactory creates PodsEvictionRestrictionFactory
-func NewPodsEvictionRestrictionFactory(client kube_client.Interface, minReplicas int,
+// NewPodsEvictionRestrictionFactory creates PodsEvictionRestrictionFactory.
+// The controllerFetcher resolves the owners of pods that are not well-known
+// controllers; if nil, such pods are grouped by their direct owner.
+func NewPodsEvictionRestrictionFactory(client kube_client.Interface, controllerFetcher target.ControllerFetcher, minReplicas int,
 	evictionToleranceFraction float64) (PodsEvictionRestrictionFactory, error) {
 	rcInformer, err := setUpInformer(client, replicationController)
 	if err != nil {
@@ -175,6 +179,7 @@ func NewPodsEvictionRestrictionFactory(client kube_client.Interface, minReplicas
 		rcInformer:                rcInformer, // informer for Replication Controllers
 		ssInformer:                ssInformer, // informer for Replica Sets
 		rsInformer:                rsInformer, // informer for Stateful Sets
+		controllerFetcher:         controllerFetcher,
 		minReplicas:               minReplicas,
 		evictionToleranceFraction: evictionToleranceFraction}, nil
 }
@@ -186,9 +191,10 @@ func (f *podsEvictionRestrictionFactoryImpl) NewPodsEvictionRestriction(pods []*
 	// Evictions may be later limited by pod disruption budget if configured.
 
 	livePods := make(map[podReplicaCreator][]*apiv1.Pod)
+	resolvedOwners := make(map[podReplicaCreator]*target.ScalableController)
 
 	for _, pod := range pods {
-		creator, err := getPodReplicaCreator(pod)
+		creator, err := f.getPodReplicaCreator(pod, resolvedOwners)
 		if err != nil {
 			klog.Errorf("failed to obtain replication info for pod %s: %v", pod.Name, err)
 			continue
@@ -200,6 +206,13 @@ func (f *podsEvictionRestrictionFactoryImpl) NewPodsEvictionRestriction(pods []*
 		livePods[*creator] = append(livePods[*creator], pod)
 	}
 
+	scalableReplicas := make(map[podReplicaCreator]int)
+	for _, scalable := range resolvedOwners {
+		if scalable != nil {
+			scalableReplicas[getScalableReplicaCreator(scalable)] = scalable.Replicas
+		}
+	}
+
 	podToReplicaCreatorMap := make(map[string]podReplicaCreator)
 	creatorToSingleGroupStatsMap := make(map[podReplicaCreator]singleGroupStats)
 
@@ -212,8 +225,16 @@ func (f *podsEvictionRestrictionFactoryImpl) NewPodsEvictionRestriction(pods []*
 		}
 
 		var configured int
-		if creator.Kind == job {
-			// Job has no replicas configuration, so we will use actual number of live pods as replicas count.
+		if replicas, found := scalableReplicas[creator]; found {
+			if replicas == 0 {
+				klog.Errorf("failed to obtain replication info for %v %v/%v. It has no replicas config",
+					creator.Kind, creator.Namespace, creator.Name)
+				continue
+			}
+			configured = replicas
+		} else if creator.Kind == job || !isWellKnown(creator.Kind) {
+			// Job and custom controllers without the scale subresource have no replicas configuration,
+			// so we will use actual number of live pods as replicas count.
 			configured = actual
 		} else {
 			var err error
@@ -243,7 +264,14 @@ func (f *podsEvictionRestrictionFactoryImpl) NewPodsEvictionRestriction(pods []*
 		creatorToSingleGroupStatsMap: creatorToSingleGroupStatsMap}
 }
 
-func getPodReplicaCreator(pod *apiv1.Pod) (*podReplicaCreator, error) {
+// getPodReplicaCreator returns the controller managing the replicas of the
+// pod. Pods of well-known controllers are grouped by their direct owner. For
+// other owners the owner chain is walked up to the first controller with the
+// scale subresource, e.g. a custom resource owning the custom resource that
+// owns the pods. Pods without such a controller are grouped by their direct
+// owner. The resolved owners are cached in resolvedOwners.
+func (f *podsEvictionRestrictionFactoryImpl) getPodReplicaCreator(pod *apiv1.Pod,
+	resolvedOwners map[podReplicaCreator]*target.ScalableController) (*podReplicaCreator, error) {
 	creator := managingControllerRef(pod)
 	if creator == nil {
 		return nil, nil
@@ -253,7 +281,39 @@ func getPodReplicaCreator(pod *apiv1.Pod) (*podReplicaCreator, error) {
 		Name:      creator.Name,
 		Kind:      controllerKind(creator.Kind),
 	}
-	return podReplicaCreator, nil
+	if isWellKnown(podReplicaCreator.Kind) || f.controllerFetcher == nil {
+		return podReplicaCreator, nil
+	}
+	scalable, found := resolvedOwners[*podReplicaCreator]
+	if !found {
+		var err error
+		scalable, err = f.controllerFetcher.FindScalableController(pod.Namespace, creator)
+		if err != nil {
+			return nil, err
+		}
+		resolvedOwners[*podReplicaCreator] = scalable
+	}
+	if scalable == nil {
+		return podReplicaCreator, nil
+	}
+	scalableCreator := getScalableReplicaCreator(scalable)
+	return &scalableCreator, nil
+}
+
+func getScalableReplicaCreator(scalable *target.ScalableController) podReplicaCreator {
+	return podReplicaCreator{
+		Namespace: scalable.Namespace,
+		Name:      scalable.Name,
+		Kind:      controllerKind(scalable.Kind),
+	}
+}
+
+func isWellKnown(kind controllerKind) bool {
+	switch kind {
+	case replicationController, statefulSet, replicaSet, job:
+		return true
+	}
+	return false
 }
 
 func getPodID(pod *apiv1.Pod) string {
@@ -321,14 +381,7 @@ func (f *podsEvictionRestrictionFactoryImpl) getReplicaCount(creator podReplicaC
 }
 
 func managingControllerRef(pod *apiv1.Pod) *metav1.OwnerReference {
-	var managingController metav1.OwnerReference
-	for _, ownerReference := range pod.ObjectMeta.GetOwnerReferences() {
-		if *ownerReference.Controller {
-			managingController = ownerReference
-			break
-		}
-	}
-	return &managingController
+	return metav1.GetControllerOf(pod)
 }
 
 func setUpInformer(kubeClient kube_client.Interface, kind controllerKind) (cache.SharedIndexInformer, error) {
@@ -21,11 +21,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target"
+	target_mock "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target/mock"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/test"
 	appsinformer "k8s.io/client-go/informers/apps/v1"
 	coreinformer "k8s.io/client-go/informers/core/v1"
@@ -363,6 +366,111 @@ func TestEvictReplicatedByJob(t *testing.T) {
 	}
 }
 
+func TestEvictReplicatedByScalableCustomController(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cluster := metav1.TypeMeta{
+		Kind:       "Cluster",
+		APIVersion: "example.com/v1",
+	}
+	clusterObjectMeta := metav1.ObjectMeta{
+		Name:      "cluster",
+		Namespace: "default",
+	}
+
+	livePods := 5
+
+	pods := make([]*apiv1.Pod, livePods)
+	for i := range pods {
+		pods[i] = test.Pod().WithName(getTestPodName(i)).WithCreator(&clusterObjectMeta, &cluster).Get()
+	}
+
+	controllerFetcher := target_mock.NewMockControllerFetcher(ctrl)
+	controllerFetcher.EXPECT().FindScalableController("default", gomock.Any()).Return(&target.ScalableController{
+		Namespace: "default",
+		Kind:      "ClusterSet",
+		Name:      "cluster-set",
+		Replicas:  4,
+	}, nil).Times(1)
+
+	factory, _ := getEvictionRestrictionFactory(nil, nil, nil, 2, 0.5)
+	factory.(*podsEvictionRestrictionFactoryImpl).controllerFetcher = controllerFetcher
+	eviction := factory.NewPodsEvictionRestriction(pods)
+
+	for _, pod := range pods {
+		assert.True(t, eviction.CanEvict(pod))
+	}
+
+	// 4 replicas are configured and 5 pods are running, so 3 pods can be evicted.
+	for _, pod := range pods[:3] {
+		err := eviction.Evict(pod, test.FakeEventRecorder())
+		assert.Nil(t, err, "Should evict with no error")
+	}
+	for _, pod := range pods[3:] {
+		err := eviction.Evict(pod, test.FakeEventRecorder())
+		assert.Error(t, err, "Error expected")
+	}
+}
+
+func TestEvictReplicatedByCustomControllerWithoutScale(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cluster := metav1.TypeMeta{
+		Kind:       "Cluster",
+		APIVersion: "example.com/v1",
+	}
+	clusterObjectMeta := metav1.ObjectMeta{
+		Name:      "cluster",
+		Namespace: "default",
+	}
+
+	livePods := 5
+
+	pods := make([]*apiv1.Pod, livePods)
+	for i := range pods {
+		pods[i] = test.Pod().WithName(getTestPodName(i)).WithCreator(&clusterObjectMeta, &cluster).Get()
+	}
+
+	controllerFetcher := target_mock.NewMockControllerFetcher(ctrl)
+	controllerFetcher.EXPECT().FindScalableController("default", gomock.Any()).Return(nil, nil).Times(1)
+
+	factory, _ := getEvictionRestrictionFactory(nil, nil, nil, 2, 0.5)
+	factory.(*podsEvictionRestrictionFactoryImpl).controllerFetcher = controllerFetcher
+	eviction := factory.NewPodsEvictionRestriction(pods)
+
+	for _, pod := range pods {
+		assert.True(t, eviction.CanEvict(pod))
+	}
+
+	// The live pods are counted as replicas, like for Jobs.
+	for _, pod := range pods[:2] {
+		err := eviction.Evict(pod, test.FakeEventRecorder())
+		assert.Nil(t, err, "Should evict with no error")
+	}
+	for _, pod := range pods[2:] {
+		err := eviction.Evict(pod, test.FakeEventRecorder())
+		assert.Error(t, err, "Error expected")
+	}
+}
+
+func TestEvictNotReplicated(t *testing.T) {
+	pods := make([]*apiv1.Pod, 3)
+	for i := range pods {
+		pods[i] = test.Pod().WithName(getTestPodName(i)).Get()
+	}
+
+	factory, _ := getEvictionRestrictionFactory(nil, nil, nil, 1, 0.5)
+	eviction := factory.NewPodsEvictionRestriction(pods)
+
+	for _, pod := range pods {
+		assert.False(t, eviction.CanEvict(pod))
+		err := eviction.Evict(pod, test.FakeEventRecorder())
+		assert.Error(t, err, "Error expected")
+	}
+}
+
 func TestEvictTooFewReplicas(t *testing.T) {
 	replicas := int32(5)
 	livePods := 5
@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inplace
+
+import (
+	"encoding/json"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	metrics_updater "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/updater"
+	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+	kube_client "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+)
+
+// Resizer changes the resources of running pods without recreating them.
+type Resizer interface {
+	// Resize sets the resource requests of the containers of the pod to the
+	// targets of the recommendation, scaling the limits of the containers
+	// whose limits are controlled according to the policy. Returns error if
+	// the resize is rejected, in which case the pod keeps its resources.
+	Resize(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources, policy *vpa_types.PodResourcePolicy, eventRecorder record.EventRecorder) error
+}
+
+type podPatchResizer struct {
+	client kube_client.Interface
+}
+
+// NewPodPatchResizer returns a Resizer patching the resource requests in the
+// spec of the pods. API servers treating the resources of pods as immutable
+// reject the patch, so the updater falls back to eviction; with a fake
+// clientset, or once the API allows changing the resources of running pods,
+// the pods are resized.
+func NewPodPatchResizer(client kube_client.Interface) Resizer {
+	return &podPatchResizer{client: client}
+}
+
+type containerPatch struct {
+	Name      string               `json:"name"`
+	Resources resourceRequirements `json:"resources"`
+}
+
+type resourceRequirements struct {
+	Requests apiv1.ResourceList `json:"requests"`
+	Limits   apiv1.ResourceList `json:"limits,omitempty"`
+}
+
+// Resize patches the requests of the containers of the pod that differ from
+// the recommended targets, and the corresponding limits if controlled.
+func (r *podPatchResizer) Resize(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources, policy *vpa_types.PodResourcePolicy, eventRecorder record.EventRecorder) error {
+	containers := getContainerPatches(pod, recommendation, policy)
+	if len(containers) == 0 {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"containers": containers,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.client.CoreV1().Pods(pod.Namespace).Patch(pod.Name, types.StrategicMergePatchType, patch)
+	if err != nil {
+		klog.Errorf("failed to resize pod %s/%s, error: %v", pod.Namespace, pod.Name, err)
+		return err
+	}
+	eventRecorder.Event(pod, apiv1.EventTypeNormal, "ResizedByVPA",
+		"Pod was resized in place by VPA Updater to apply resource recommendation.")
+	metrics_updater.AddResizedPod()
+	return nil
+}
+
+func getContainerPatches(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources, policy *vpa_types.PodResourcePolicy) []containerPatch {
+	if recommendation == nil {
+		return nil
+	}
+	containers := []containerPatch{}
+	for _, container := range pod.Spec.Containers {
+		requests := getChangedRequests(container, recommendation)
+		if len(requests) == 0 {
+			continue
+		}
+		resources := resourceRequirements{Requests: requests}
+		if vpa_api_util.GetContainerControlledValues(container.Name, policy) == vpa_types.ContainerControlledValuesRequestsAndLimits {
+			resources.Limits = vpa_api_util.GetProportionalLimits(container, requests)
+		}
+		containers = append(containers, containerPatch{Name: container.Name, Resources: resources})
+	}
+	return containers
+}
+
+func getChangedRequests(container apiv1.Container, recommendation *vpa_types.RecommendedPodResources) apiv1.ResourceList {
+	for _, containerRecommendation := range recommendation.ContainerRecommendations {
+		if containerRecommendation.ContainerName != container.Name {
+			continue
+		}
+		requests := apiv1.ResourceList{}
+		for resource, target := range containerRecommendation.Target {
+			if current, found := container.Resources.Requests[resource]; !found || current.Cmp(target) != 0 {
+				requests[resource] = target
+			}
+		}
+		return requests
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inplace
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/test"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+)
+
+func recommendation(containerName, cpu, memory string) *vpa_types.RecommendedPodResources {
+	return &vpa_types.RecommendedPodResources{
+		ContainerRecommendations: []vpa_types.RecommendedContainerResources{{
+			ContainerName: containerName,
+			Target: apiv1.ResourceList{
+				apiv1.ResourceCPU:    resource.MustParse(cpu),
+				apiv1.ResourceMemory: resource.MustParse(memory),
+			},
+		}},
+	}
+}
+
+func TestResize(t *testing.T) {
+	pod := test.Pod().WithName("pod").
+		AddContainer(test.BuildTestContainer("container1", "1", "100M")).
+		AddContainer(test.BuildTestContainer("container2", "1", "100M")).Get()
+	client := fake.NewSimpleClientset(pod)
+	resizer := NewPodPatchResizer(client)
+
+	err := resizer.Resize(pod, recommendation("container1", "2", "100M"), nil, test.FakeEventRecorder())
+	assert.NoError(t, err)
+
+	resized, err := client.CoreV1().Pods(pod.Namespace).Get(pod.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, resource.MustParse("2"), resized.Spec.Containers[0].Resources.Requests[apiv1.ResourceCPU])
+	assert.Equal(t, resource.MustParse("100M"), resized.Spec.Containers[0].Resources.Requests[apiv1.ResourceMemory])
+	assert.Equal(t, resource.MustParse("1"), resized.Spec.Containers[1].Resources.Requests[apiv1.ResourceCPU])
+}
+
+func TestResizeScalesControlledLimits(t *testing.T) {
+	pod := test.Pod().WithName("pod").AddContainer(test.BuildTestContainer("container1", "1", "100M")).Get()
+	pod.Spec.Containers[0].Resources.Limits = apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("2")}
+	client := fake.NewSimpleClientset(pod)
+	resizer := NewPodPatchResizer(client)
+	controlledValues := vpa_types.ContainerControlledValuesRequestsAndLimits
+	policy := &vpa_types.PodResourcePolicy{
+		ContainerPolicies: []vpa_types.ContainerResourcePolicy{{
+			ContainerName:    vpa_types.DefaultContainerResourcePolicy,
+			ControlledValues: &controlledValues,
+		}},
+	}
+
+	err := resizer.Resize(pod, recommendation("container1", "3", "100M"), policy, test.FakeEventRecorder())
+	assert.NoError(t, err)
+
+	resized, err := client.CoreV1().Pods(pod.Namespace).Get(pod.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	cpuRequest := resized.Spec.Containers[0].Resources.Requests[apiv1.ResourceCPU]
+	cpuLimit := resized.Spec.Containers[0].Resources.Limits[apiv1.ResourceCPU]
+	assert.Equal(t, int64(3000), cpuRequest.MilliValue())
+	assert.Equal(t, int64(6000), cpuLimit.MilliValue())
+	_, hasMemoryLimit := resized.Spec.Containers[0].Resources.Limits[apiv1.ResourceMemory]
+	assert.False(t, hasMemoryLimit)
+}
+
+func TestResizeWithoutChange(t *testing.T) {
+	pod := test.Pod().WithName("pod").AddContainer(test.BuildTestContainer("container1", "1", "100M")).Get()
+	client := fake.NewSimpleClientset(pod)
+	resizer := NewPodPatchResizer(client)
+
+	assert.NoError(t, resizer.Resize(pod, recommendation("container1", "1", "100M"), nil, test.FakeEventRecorder()))
+	assert.NoError(t, resizer.Resize(pod, recommendation("other", "2", "200M"), nil, test.FakeEventRecorder()))
+	assert.NoError(t, resizer.Resize(pod, nil, nil, test.FakeEventRecorder()))
+	for _, action := range client.Actions() {
+		assert.NotEqual(t, "patch", action.GetVerb())
+	}
+}
+
+func TestResizeRejected(t *testing.T) {
+	pod := test.Pod().WithName("pod").AddContainer(test.BuildTestContainer("container1", "1", "100M")).Get()
+	client := fake.NewSimpleClientset(pod)
+	client.PrependReactor("patch", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("pod updates may not change fields other than image")
+	})
+	resizer := NewPodPatchResizer(client)
+
+	assert.Error(t, resizer.Resize(pod, recommendation("container1", "2", "100M"), nil, test.FakeEventRecorder()))
+}
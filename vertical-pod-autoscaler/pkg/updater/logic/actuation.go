@@ -0,0 +1,153 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/updater/priority"
+	metrics_updater "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/updater"
+	"k8s.io/klog"
+)
+
+const (
+	// Reasons for skipping the update of a pod, used as metric labels.
+	skippedPodUpdateInterval     = "PodUpdateInterval"
+	skippedVpaUpdateInterval     = "VpaUpdateInterval"
+	skippedConcurrentDisruptions = "MaxConcurrentDisruptions"
+)
+
+// actuationHistory remembers when the updater last updated pods and VPAs.
+// It is kept in memory, so it is lost when the updater restarts; the start
+// time of the pods bounds the update rate of evicted pods anyway.
+type actuationHistory struct {
+	podUpdates map[string]time.Time
+	vpaUpdates map[string]time.Time
+}
+
+func (h *actuationHistory) recordUpdate(vpaKey, podKey string, now time.Time) {
+	if h.podUpdates == nil {
+		h.podUpdates = make(map[string]time.Time)
+		h.vpaUpdates = make(map[string]time.Time)
+	}
+	h.podUpdates[podKey] = now
+	h.vpaUpdates[vpaKey] = now
+}
+
+// garbageCollect forgets the pods and VPAs that are not live anymore.
+func (h *actuationHistory) garbageCollect(liveVpas map[string]bool, livePods map[string]bool) {
+	for key := range h.podUpdates {
+		if !livePods[key] {
+			delete(h.podUpdates, key)
+		}
+	}
+	for key := range h.vpaUpdates {
+		if !liveVpas[key] {
+			delete(h.vpaUpdates, key)
+		}
+	}
+}
+
+// actuationLimiter enforces the ActuationPolicy of a VPA during a loop of the
+// updater.
+type actuationLimiter struct {
+	policy      vpa_types.ActuationPolicy
+	history     *actuationHistory
+	vpaKey      string
+	now         time.Time
+	disruptions int
+}
+
+// newActuationLimiter creates the limiter for the VPA. Disruptions is the
+// number of pods of the VPA being replaced at the start of the loop.
+func newActuationLimiter(vpa *vpa_types.VerticalPodAutoscaler, history *actuationHistory, disruptions int, now time.Time) *actuationLimiter {
+	limiter := &actuationLimiter{
+		history:     history,
+		vpaKey:      vpa.Namespace + "/" + vpa.Name,
+		now:         now,
+		disruptions: disruptions,
+	}
+	if policy := getActuationPolicy(vpa); policy != nil {
+		limiter.policy = *policy
+	}
+	return limiter
+}
+
+// canUpdate checks if the policy allows updating the pod now. Disruptive
+// updates are also subject to the limit of concurrent disruptions.
+func (l *actuationLimiter) canUpdate(pod *apiv1.Pod, disruptive bool) bool {
+	if interval := l.policy.MinVpaUpdateInterval; interval != nil {
+		if last, found := l.history.vpaUpdates[l.vpaKey]; found && l.now.Sub(last) < interval.Duration {
+			return l.skip(pod, skippedVpaUpdateInterval)
+		}
+	}
+	if interval := l.policy.MinPodUpdateInterval; interval != nil {
+		last, found := l.history.podUpdates[getPodKey(pod)]
+		if pod.Status.StartTime != nil && (!found || pod.Status.StartTime.Time.After(last)) {
+			last, found = pod.Status.StartTime.Time, true
+		}
+		if found && l.now.Sub(last) < interval.Duration {
+			return l.skip(pod, skippedPodUpdateInterval)
+		}
+	}
+	if max := l.policy.MaxConcurrentDisruptions; disruptive && max != nil && l.disruptions >= int(*max) {
+		return l.skip(pod, skippedConcurrentDisruptions)
+	}
+	return true
+}
+
+func (l *actuationLimiter) skip(pod *apiv1.Pod, reason string) bool {
+	klog.V(2).Infof("not updating pod %v, limited by the actuation policy: %s", pod.Name, reason)
+	metrics_updater.AddSkippedUpdate(reason)
+	return false
+}
+
+// recordUpdate records an update of the pod.
+func (l *actuationLimiter) recordUpdate(pod *apiv1.Pod, disruptive bool) {
+	l.history.recordUpdate(l.vpaKey, getPodKey(pod), l.now)
+	if disruptive {
+		l.disruptions++
+	}
+}
+
+// getUpdateConfig returns the configuration of the update priority calculator
+// for the VPA, or nil for the default.
+func getUpdateConfig(vpa *vpa_types.VerticalPodAutoscaler) *priority.UpdateConfig {
+	if policy := getActuationPolicy(vpa); policy != nil && policy.MinRelativeChange != nil {
+		return &priority.UpdateConfig{MinChangePriority: *policy.MinRelativeChange}
+	}
+	return nil
+}
+
+func getActuationPolicy(vpa *vpa_types.VerticalPodAutoscaler) *vpa_types.ActuationPolicy {
+	if vpa.Spec.UpdatePolicy == nil {
+		return nil
+	}
+	return vpa.Spec.UpdatePolicy.ActuationPolicy
+}
+
+// isDisrupted checks if the pod is being replaced: terminating after an
+// eviction or not running yet.
+func isDisrupted(pod *apiv1.Pod) bool {
+	return pod.DeletionTimestamp != nil || pod.Status.Phase == apiv1.PodPending
+}
+
+func getPodKey(pod *apiv1.Pod) string {
+	return pod.Namespace + "/" + pod.Name
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+	target_mock "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target/mock"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/test"
+)
+
+func vpaWithActuationPolicy(policy *vpa_types.ActuationPolicy) *vpa_types.VerticalPodAutoscaler {
+	vpa := test.VerticalPodAutoscaler().WithName("vpa").WithContainer("container1").Get()
+	updateMode := vpa_types.UpdateModeAuto
+	vpa.Spec.UpdatePolicy = &vpa_types.PodUpdatePolicy{UpdateMode: &updateMode, ActuationPolicy: policy}
+	return vpa
+}
+
+func podStartedAt(name string, startTime time.Time) *apiv1.Pod {
+	pod := test.Pod().WithName(name).AddContainer(test.BuildTestContainer("container1", "1", "100M")).Get()
+	pod.Status.StartTime = &metav1.Time{Time: startTime}
+	return pod
+}
+
+func TestActuationLimiterWithoutPolicy(t *testing.T) {
+	now := time.Now()
+	history := &actuationHistory{}
+	limiter := newActuationLimiter(vpaWithActuationPolicy(nil), history, 10, now)
+	pod := podStartedAt("pod", now)
+	for i := 0; i < 3; i++ {
+		assert.True(t, limiter.canUpdate(pod, true))
+		limiter.recordUpdate(pod, true)
+	}
+}
+
+func TestActuationLimiterIntervals(t *testing.T) {
+	now := time.Now()
+	vpa := vpaWithActuationPolicy(&vpa_types.ActuationPolicy{
+		MinPodUpdateInterval: &metav1.Duration{Duration: 10 * time.Minute},
+		MinVpaUpdateInterval: &metav1.Duration{Duration: time.Minute},
+	})
+	history := &actuationHistory{}
+	pod1 := podStartedAt("pod1", now.Add(-time.Hour))
+	pod2 := podStartedAt("pod2", now.Add(-time.Hour))
+	newPod := podStartedAt("pod3", now.Add(-5*time.Minute))
+
+	limiter := newActuationLimiter(vpa, history, 0, now)
+	// A pod started recently counts as updated.
+	assert.False(t, limiter.canUpdate(newPod, false))
+	assert.True(t, limiter.canUpdate(pod1, false))
+	limiter.recordUpdate(pod1, false)
+	// Another pod of the VPA was just updated.
+	assert.False(t, limiter.canUpdate(pod2, false))
+
+	limiter = newActuationLimiter(vpa, history, 0, now.Add(2*time.Minute))
+	assert.False(t, limiter.canUpdate(pod1, false))
+	assert.True(t, limiter.canUpdate(pod2, false))
+
+	limiter = newActuationLimiter(vpa, history, 0, now.Add(11*time.Minute))
+	assert.True(t, limiter.canUpdate(pod1, false))
+	assert.True(t, limiter.canUpdate(newPod, false))
+}
+
+func TestActuationLimiterConcurrentDisruptions(t *testing.T) {
+	now := time.Now()
+	maxDisruptions := int32(2)
+	vpa := vpaWithActuationPolicy(&vpa_types.ActuationPolicy{MaxConcurrentDisruptions: &maxDisruptions})
+	pod1 := podStartedAt("pod1", now.Add(-time.Hour))
+	pod2 := podStartedAt("pod2", now.Add(-time.Hour))
+
+	limiter := newActuationLimiter(vpa, &actuationHistory{}, 1, now)
+	assert.True(t, limiter.canUpdate(pod1, true))
+	limiter.recordUpdate(pod1, true)
+	assert.False(t, limiter.canUpdate(pod2, true))
+	// In-place resizes are not disruptions.
+	assert.True(t, limiter.canUpdate(pod2, false))
+}
+
+func TestActuationHistoryGarbageCollect(t *testing.T) {
+	now := time.Now()
+	history := &actuationHistory{}
+	history.recordUpdate("default/vpa1", "default/pod1", now)
+	history.recordUpdate("default/vpa2", "default/pod2", now)
+	history.garbageCollect(map[string]bool{"default/vpa1": true}, map[string]bool{"default/pod2": true})
+	assert.Equal(t, map[string]time.Time{"default/pod2": now}, history.podUpdates)
+	assert.Equal(t, map[string]time.Time{"default/vpa1": now}, history.vpaUpdates)
+}
+
+func TestGetUpdateConfig(t *testing.T) {
+	assert.Nil(t, getUpdateConfig(vpaWithActuationPolicy(nil)))
+	assert.Nil(t, getUpdateConfig(vpaWithActuationPolicy(&vpa_types.ActuationPolicy{})))
+	minRelativeChange := 0.3
+	config := getUpdateConfig(vpaWithActuationPolicy(&vpa_types.ActuationPolicy{MinRelativeChange: &minRelativeChange}))
+	if assert.NotNil(t, config) {
+		assert.Equal(t, 0.3, config.MinChangePriority)
+	}
+}
+
+func TestRunOnceLimitsConcurrentDisruptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	labels := map[string]string{"app": "testingApp"}
+	selector := parseLabelSelector("app = testingApp")
+	pods := make([]*apiv1.Pod, 5)
+	eviction := &test.PodsEvictionRestrictionMock{}
+	for i := range pods {
+		pods[i] = test.Pod().WithName("test_" + strconv.Itoa(i)).AddContainer(test.BuildTestContainer("container1", "1", "100M")).Get()
+		pods[i].Labels = labels
+		eviction.On("CanEvict", pods[i]).Return(true)
+		eviction.On("Evict", pods[i], nil).Return(nil)
+	}
+	// A pod evicted in a previous loop and still terminating.
+	terminating := test.Pod().WithName("terminating").AddContainer(test.BuildTestContainer("container1", "1", "100M")).Get()
+	terminating.Labels = labels
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	podLister := &test.PodListerMock{}
+	podLister.On("List").Return(append(pods, terminating), nil)
+
+	maxDisruptions := int32(2)
+	vpaObj := vpaWithActuationPolicy(&vpa_types.ActuationPolicy{MaxConcurrentDisruptions: &maxDisruptions})
+	vpaObj.Status.Recommendation = test.Recommendation().WithContainer("container1").WithTarget("2", "200M").Get()
+	vpaLister := &test.VerticalPodAutoscalerListerMock{}
+	vpaLister.On("List").Return([]*vpa_types.VerticalPodAutoscaler{vpaObj}, nil).Once()
+	mockSelectorFetcher := target_mock.NewMockVpaTargetSelectorFetcher(ctrl)
+	mockSelectorFetcher.EXPECT().Fetch(gomock.Eq(vpaObj)).Return(selector, nil)
+
+	updater := &updater{
+		vpaLister:               vpaLister,
+		podLister:               podLister,
+		evictionFactory:         &fakeEvictFactory{eviction},
+		recommendationProcessor: &test.FakeRecommendationProcessor{},
+		selectorFetcher:         mockSelectorFetcher,
+	}
+	updater.RunOnce()
+	eviction.AssertNumberOfCalls(t, "Evict", 1)
+	assert.Len(t, updater.actuationHistory.podUpdates, 1)
+}
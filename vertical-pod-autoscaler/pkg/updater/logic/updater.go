@@ -29,6 +29,7 @@ import (
 	vpa_lister "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/listers/autoscaling.k8s.io/v1beta2"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/updater/eviction"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/updater/inplace"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/updater/priority"
 	metrics_updater "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/updater"
 	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
@@ -56,11 +57,15 @@ type updater struct {
 	recommendationProcessor vpa_api_util.RecommendationProcessor
 	evictionAdmission       priority.PodEvictionAdmission
 	selectorFetcher         target.VpaTargetSelectorFetcher
+	resizer                 inplace.Resizer
+	actuationHistory        actuationHistory
+	// dryRun makes the updater leave the pods of all VPAs untouched.
+	dryRun bool
 }
 
 // NewUpdater creates Updater with given configuration
-func NewUpdater(kubeClient kube_client.Interface, vpaClient *vpa_clientset.Clientset, minReplicasForEvicition int, evictionToleranceFraction float64, recommendationProcessor vpa_api_util.RecommendationProcessor, evictionAdmission priority.PodEvictionAdmission, selectorFetcher target.VpaTargetSelectorFetcher) (Updater, error) {
-	factory, err := eviction.NewPodsEvictionRestrictionFactory(kubeClient, minReplicasForEvicition, evictionToleranceFraction)
+func NewUpdater(kubeClient kube_client.Interface, vpaClient *vpa_clientset.Clientset, minReplicasForEvicition int, evictionToleranceFraction float64, recommendationProcessor vpa_api_util.RecommendationProcessor, evictionAdmission priority.PodEvictionAdmission, selectorFetcher target.VpaTargetSelectorFetcher, controllerFetcher target.ControllerFetcher, resizer inplace.Resizer, dryRun bool) (Updater, error) {
+	factory, err := eviction.NewPodsEvictionRestrictionFactory(kubeClient, controllerFetcher, minReplicasForEvicition, evictionToleranceFraction)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create eviction restriction factory: %v", err)
 	}
@@ -72,6 +77,8 @@ func NewUpdater(kubeClient kube_client.Interface, vpaClient *vpa_clientset.Clien
 		recommendationProcessor: recommendationProcessor,
 		evictionAdmission:       evictionAdmission,
 		selectorFetcher:         selectorFetcher,
+		resizer:                 resizer,
+		dryRun:                  dryRun,
 	}, nil
 }
 
@@ -89,8 +96,9 @@ func (u *updater) RunOnce() {
 
 	for _, vpa := range vpaList {
 		if vpa_api_util.GetUpdateMode(vpa) != vpa_types.UpdateModeRecreate &&
-			vpa_api_util.GetUpdateMode(vpa) != vpa_types.UpdateModeAuto {
-			klog.V(3).Infof("skipping VPA object %v because its mode is not \"Recreate\" or \"Auto\"", vpa.Name)
+			vpa_api_util.GetUpdateMode(vpa) != vpa_types.UpdateModeAuto &&
+			vpa_api_util.GetUpdateMode(vpa) != vpa_types.UpdateModeInPlace {
+			klog.V(3).Infof("skipping VPA object %v because its mode is not \"Recreate\", \"Auto\" or \"InPlace\"", vpa.Name)
 			continue
 		}
 		selector, err := u.selectorFetcher.Fetch(vpa)
@@ -124,9 +132,16 @@ func (u *updater) RunOnce() {
 	allLivePods := filterDeletedPods(podsList)
 
 	controlledPods := make(map[*vpa_types.VerticalPodAutoscaler][]*apiv1.Pod)
-	for _, pod := range allLivePods {
+	disruptedPods := make(map[*vpa_types.VerticalPodAutoscaler]int)
+	for _, pod := range podsList {
 		controllingVPA := vpa_api_util.GetControllingVPAForPod(pod, vpas)
-		if controllingVPA != nil {
+		if controllingVPA == nil {
+			continue
+		}
+		if isDisrupted(pod) {
+			disruptedPods[controllingVPA.Vpa]++
+		}
+		if pod.DeletionTimestamp == nil {
 			controlledPods[controllingVPA.Vpa] = append(controlledPods[controllingVPA.Vpa], pod)
 		}
 	}
@@ -137,28 +152,77 @@ func (u *updater) RunOnce() {
 	}
 	timer.ObserveStep("AdmissionInit")
 
+	now := time.Now()
 	for vpa, livePods := range controlledPods {
+		// The admission controller doesn't apply the recommendations of VPAs
+		// in dry-run, so their pods would be evicted again and again.
+		if u.dryRun || vpa_api_util.IsDryRun(vpa) {
+			klog.V(3).Infof("skipping pods of VPA object %v in dry-run", vpa.Name)
+			continue
+		}
 		evictionLimiter := u.evictionFactory.NewPodsEvictionRestriction(livePods)
+		actuationLimiter := newActuationLimiter(vpa, &u.actuationHistory, disruptedPods[vpa], now)
+		if vpa_api_util.GetUpdateMode(vpa) == vpa_types.UpdateModeInPlace {
+			u.resizePods(vpa, livePods, evictionLimiter, actuationLimiter)
+			continue
+		}
 		podsForUpdate := u.getPodsUpdateOrder(filterNonEvictablePods(livePods, evictionLimiter), vpa)
 
 		for _, pod := range podsForUpdate {
-			if !evictionLimiter.CanEvict(pod) {
+			if !evictionLimiter.CanEvict(pod) || !actuationLimiter.canUpdate(pod, true) {
 				continue
 			}
 			klog.V(2).Infof("evicting pod %v", pod.Name)
 			evictErr := evictionLimiter.Evict(pod, u.eventRecorder)
 			if evictErr != nil {
 				klog.Warningf("evicting pod %v failed: %v", pod.Name, evictErr)
+			} else {
+				actuationLimiter.recordUpdate(pod, true)
 			}
 		}
 	}
+	u.actuationHistory.garbageCollect(getVpaKeys(vpas), getPodKeys(allLivePods))
 	timer.ObserveStep("EvictPods")
 	timer.ObserveTotal()
 }
 
+// resizePods applies the recommendation to the pods that need an update by
+// resizing them in place. Pods whose resize is rejected are evicted instead,
+// within the limits of the eviction restriction and the actuation policy.
+func (u *updater) resizePods(vpa *vpa_types.VerticalPodAutoscaler, livePods []*apiv1.Pod, evictionLimiter eviction.PodsEvictionRestriction, actuationLimiter *actuationLimiter) {
+	for _, pod := range u.getPodsUpdateOrder(livePods, vpa) {
+		if !actuationLimiter.canUpdate(pod, false) {
+			continue
+		}
+		recommendation, _, err := u.recommendationProcessor.Apply(vpa.Status.Recommendation, vpa.Spec.ResourcePolicy, vpa.Status.Conditions, pod)
+		if err != nil {
+			klog.Warningf("cannot process recommendation for pod %v: %v", pod.Name, err)
+			continue
+		}
+		klog.V(2).Infof("resizing pod %v", pod.Name)
+		resizeErr := u.resizer.Resize(pod, recommendation, vpa.Spec.ResourcePolicy, u.eventRecorder)
+		if resizeErr == nil {
+			actuationLimiter.recordUpdate(pod, false)
+			continue
+		}
+		klog.Warningf("resizing pod %v failed, falling back to eviction: %v", pod.Name, resizeErr)
+		metrics_updater.AddFailedResize()
+		if !evictionLimiter.CanEvict(pod) || !actuationLimiter.canUpdate(pod, true) {
+			continue
+		}
+		klog.V(2).Infof("evicting pod %v", pod.Name)
+		evictErr := evictionLimiter.Evict(pod, u.eventRecorder)
+		if evictErr != nil {
+			klog.Warningf("evicting pod %v failed: %v", pod.Name, evictErr)
+		} else {
+			actuationLimiter.recordUpdate(pod, true)
+		}
+	}
+}
+
 // getPodsUpdateOrder returns list of pods that should be updated ordered by update priority
 func (u *updater) getPodsUpdateOrder(pods []*apiv1.Pod, vpa *vpa_types.VerticalPodAutoscaler) []*apiv1.Pod {
-	priorityCalculator := priority.NewUpdatePriorityCalculator(vpa.Spec.ResourcePolicy, vpa.Status.Conditions, nil, u.recommendationProcessor)
+	priorityCalculator := priority.NewUpdatePriorityCalculator(vpa.Spec.ResourcePolicy, vpa.Status.Conditions, getUpdateConfig(vpa), u.recommendationProcessor)
 	recommendation := vpa.Status.Recommendation
 
 	for _, pod := range pods {
@@ -178,6 +242,22 @@ func filterNonEvictablePods(pods []*apiv1.Pod, evictionRestriciton eviction.Pods
 	return result
 }
 
+func getVpaKeys(vpas []*vpa_api_util.VpaWithSelector) map[string]bool {
+	keys := make(map[string]bool, len(vpas))
+	for _, vpa := range vpas {
+		keys[vpa.Vpa.Namespace+"/"+vpa.Vpa.Name] = true
+	}
+	return keys
+}
+
+func getPodKeys(pods []*apiv1.Pod) map[string]bool {
+	keys := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		keys[getPodKey(pod)] = true
+	}
+	return keys
+}
+
 func filterDeletedPods(pods []*apiv1.Pod) []*apiv1.Pod {
 	result := make([]*apiv1.Pod, 0)
 	for _, pod := range pods {
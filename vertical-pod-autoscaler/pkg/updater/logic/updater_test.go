@@ -17,6 +17,7 @@ limitations under the License.
 package logic
 
 import (
+	"fmt"
 	"strconv"
 	"testing"
 
@@ -28,6 +29,7 @@ import (
 	target_mock "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target/mock"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/updater/eviction"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/test"
+	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
 )
 
 func parseLabelSelector(selector string) labels.Selector {
@@ -96,6 +98,135 @@ func TestRunOnce(t *testing.T) {
 	eviction.AssertNumberOfCalls(t, "Evict", 5)
 }
 
+func TestRunOnceInPlace(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		resizeErr      error
+		expectedEvicts int
+	}{
+		{name: "resize succeeds", resizeErr: nil, expectedEvicts: 0},
+		{name: "resize rejected", resizeErr: fmt.Errorf("pod resources are immutable"), expectedEvicts: 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			livePods := 5
+			labels := map[string]string{"app": "testingApp"}
+			selector := parseLabelSelector("app = testingApp")
+			containerName := "container1"
+			pods := make([]*apiv1.Pod, livePods)
+			eviction := &test.PodsEvictionRestrictionMock{}
+			resizer := &test.ResizerMock{}
+
+			vpaObj := test.VerticalPodAutoscaler().
+				WithContainer(containerName).
+				WithTarget("2", "200M").
+				WithMinAllowed("1", "100M").
+				WithMaxAllowed("3", "1G").
+				Get()
+			updateMode := vpa_types.UpdateModeInPlace
+			vpaObj.Spec.UpdatePolicy = &vpa_types.PodUpdatePolicy{UpdateMode: &updateMode}
+
+			for i := range pods {
+				pods[i] = test.Pod().WithName("test_" + strconv.Itoa(i)).AddContainer(test.BuildTestContainer(containerName, "1", "100M")).Get()
+				pods[i].Labels = labels
+				// Resizes do not need the eviction budget.
+				eviction.On("CanEvict", pods[i]).Return(i == 0 || tc.resizeErr != nil)
+				eviction.On("Evict", pods[i], nil).Return(nil)
+				resizer.On("Resize", pods[i], vpaObj.Status.Recommendation, vpaObj.Spec.ResourcePolicy, nil).Return(tc.resizeErr)
+			}
+
+			vpaLister := &test.VerticalPodAutoscalerListerMock{}
+			vpaLister.On("List").Return([]*vpa_types.VerticalPodAutoscaler{vpaObj}, nil).Once()
+			podLister := &test.PodListerMock{}
+			podLister.On("List").Return(pods, nil)
+			mockSelectorFetcher := target_mock.NewMockVpaTargetSelectorFetcher(ctrl)
+
+			updater := &updater{
+				vpaLister:               vpaLister,
+				podLister:               podLister,
+				evictionFactory:         &fakeEvictFactory{eviction},
+				recommendationProcessor: &test.FakeRecommendationProcessor{},
+				selectorFetcher:         mockSelectorFetcher,
+				resizer:                 resizer,
+			}
+
+			mockSelectorFetcher.EXPECT().Fetch(gomock.Eq(vpaObj)).Return(selector, nil)
+			updater.RunOnce()
+			resizer.AssertNumberOfCalls(t, "Resize", 5)
+			eviction.AssertNumberOfCalls(t, "Evict", tc.expectedEvicts)
+		})
+	}
+}
+
+func TestRunOnceDryRun(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		updateMode    vpa_types.UpdateMode
+		dryRunFlag    bool
+		dryRunVpa     bool
+		expectedCalls int
+	}{
+		{name: "auto", updateMode: vpa_types.UpdateModeAuto, expectedCalls: 5},
+		{name: "auto with dry-run annotation", updateMode: vpa_types.UpdateModeAuto, dryRunVpa: true},
+		{name: "auto with dry-run flag", updateMode: vpa_types.UpdateModeAuto, dryRunFlag: true},
+		{name: "in-place with dry-run annotation", updateMode: vpa_types.UpdateModeInPlace, dryRunVpa: true},
+		{name: "in-place with dry-run flag", updateMode: vpa_types.UpdateModeInPlace, dryRunFlag: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			labels := map[string]string{"app": "testingApp"}
+			containerName := "container1"
+			pods := make([]*apiv1.Pod, 5)
+			eviction := &test.PodsEvictionRestrictionMock{}
+			resizer := &test.ResizerMock{}
+
+			vpaObj := test.VerticalPodAutoscaler().
+				WithContainer(containerName).
+				WithTarget("2", "200M").
+				WithMinAllowed("1", "100M").
+				WithMaxAllowed("3", "1G").
+				Get()
+			vpaObj.Spec.UpdatePolicy = &vpa_types.PodUpdatePolicy{UpdateMode: &tc.updateMode}
+			if tc.dryRunVpa {
+				vpaObj.Annotations = map[string]string{vpa_api_util.DryRunAnnotation: "true"}
+			}
+
+			for i := range pods {
+				pods[i] = test.Pod().WithName("test_" + strconv.Itoa(i)).AddContainer(test.BuildTestContainer(containerName, "1", "100M")).Get()
+				pods[i].Labels = labels
+				eviction.On("CanEvict", pods[i]).Return(true)
+				eviction.On("Evict", pods[i], nil).Return(nil)
+				resizer.On("Resize", pods[i], vpaObj.Status.Recommendation, vpaObj.Spec.ResourcePolicy, nil).Return(nil)
+			}
+
+			vpaLister := &test.VerticalPodAutoscalerListerMock{}
+			vpaLister.On("List").Return([]*vpa_types.VerticalPodAutoscaler{vpaObj}, nil).Once()
+			podLister := &test.PodListerMock{}
+			podLister.On("List").Return(pods, nil)
+			mockSelectorFetcher := target_mock.NewMockVpaTargetSelectorFetcher(ctrl)
+
+			updater := &updater{
+				vpaLister:               vpaLister,
+				podLister:               podLister,
+				evictionFactory:         &fakeEvictFactory{eviction},
+				recommendationProcessor: &test.FakeRecommendationProcessor{},
+				selectorFetcher:         mockSelectorFetcher,
+				resizer:                 resizer,
+				dryRun:                  tc.dryRunFlag,
+			}
+
+			mockSelectorFetcher.EXPECT().Fetch(gomock.Eq(vpaObj)).Return(parseLabelSelector("app = testingApp"), nil)
+			updater.RunOnce()
+			eviction.AssertNumberOfCalls(t, "Evict", tc.expectedCalls)
+			resizer.AssertNumberOfCalls(t, "Resize", 0)
+		})
+	}
+}
+
 func TestVPAOff(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
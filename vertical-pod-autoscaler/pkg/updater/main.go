@@ -25,7 +25,9 @@ import (
 	kube_flag "k8s.io/apiserver/pkg/util/flag"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/common"
 	vpa_clientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/updater/inplace"
 	updater "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/updater/logic"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/leaderelection"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics"
 	metrics_updater "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/updater"
 	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
@@ -46,6 +48,9 @@ var (
 		`Fraction of replica count that can be evicted for update, if more than one pod can be evicted.`)
 
 	address = flag.String("address", ":8943", "The address to expose Prometheus metrics.")
+
+	dryRun = flag.Bool("dry-run", false,
+		`If set to true, pods are neither evicted nor resized. Should be set together with the --dry-run flag of the admission controller. Can be enabled per VPA with the `+vpa_api_util.DryRunAnnotation+` annotation.`)
 )
 
 const (
@@ -56,7 +61,9 @@ func main() {
 	kube_flag.InitFlags()
 	klog.V(1).Infof("Vertical Pod Autoscaler %s Updater", common.VerticalPodAutoscalerVersion)
 
-	healthCheck := metrics.NewHealthCheck(*updaterInterval*5, true)
+	// Inactivity is checked once this replica runs the updater, which may
+	// wait for the leadership.
+	healthCheck := metrics.NewHealthCheck(*updaterInterval*5, false)
 	metrics.Initialize(*address, healthCheck)
 	metrics_updater.Register()
 
@@ -65,6 +72,13 @@ func main() {
 		klog.Fatalf("Failed to build Kubernetes client : fail to create config: %v", err)
 	}
 	kubeClient := kube_client.NewForConfigOrDie(config)
+	leaderelection.Run(kubeClient, "vpa-updater", func() {
+		healthCheck.StartMonitoring()
+		run(config, kubeClient, healthCheck)
+	})
+}
+
+func run(config *kube_restclient.Config, kubeClient kube_client.Interface, healthCheck *metrics.HealthCheck) {
 	vpaClient := vpa_clientset.NewForConfigOrDie(config)
 	factory := informers.NewSharedInformerFactory(kubeClient, defaultResyncPeriod)
 	targetSelectorFetcher := target.NewCompositeTargetSelectorFetcher(
@@ -72,7 +86,7 @@ func main() {
 		target.NewBeta1TargetSelectorFetcher(config),
 	)
 	// TODO: use SharedInformerFactory in updater
-	updater, err := updater.NewUpdater(kubeClient, vpaClient, *minReplicas, *evictionToleranceFraction, vpa_api_util.NewCappingRecommendationProcessor(), nil, targetSelectorFetcher)
+	updater, err := updater.NewUpdater(kubeClient, vpaClient, *minReplicas, *evictionToleranceFraction, vpa_api_util.NewCappingRecommendationProcessor(), nil, targetSelectorFetcher, target.NewControllerFetcher(config, kubeClient), inplace.NewPodPatchResizer(kubeClient), *dryRun)
 	if err != nil {
 		klog.Fatalf("Failed to create updater: %v", err)
 	}
@@ -22,6 +22,7 @@ import (
 
 	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/test"
+	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
 
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -379,3 +380,27 @@ func TestNoRecommendationForContainer(t *testing.T) {
 	result := calculator.getUpdatePriority(pod, nil)
 	assert.NotNil(t, result)
 }
+
+func TestUpdateBeyondControlledLimit(t *testing.T) {
+	pod := test.Pod().WithName("POD1").AddContainer(test.BuildTestContainer(containerName, "2", "")).Get()
+	pod.Spec.Containers[0].Resources.Limits = apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("2")}
+	recommendation := test.Recommendation().WithContainer(containerName).WithTarget("4", "").Get()
+	timestampNow := pod.Status.StartTime.Time.Add(time.Hour * 24)
+
+	// The recommendation is capped to the limit, so there is nothing to update.
+	calculator := NewUpdatePriorityCalculator(nil, nil, nil, vpa_api_util.NewCappingRecommendationProcessor())
+	calculator.AddPod(pod, recommendation, timestampNow)
+	assert.Empty(t, calculator.GetSortedPods(NewDefaultPodEvictionAdmission()))
+
+	// The limit is scaled with the request.
+	controlledValues := vpa_types.ContainerControlledValuesRequestsAndLimits
+	policy := &vpa_types.PodResourcePolicy{
+		ContainerPolicies: []vpa_types.ContainerResourcePolicy{{
+			ContainerName:    containerName,
+			ControlledValues: &controlledValues,
+		}},
+	}
+	calculator = NewUpdatePriorityCalculator(policy, nil, nil, vpa_api_util.NewCappingRecommendationProcessor())
+	calculator.AddPod(pod, recommendation, timestampNow)
+	assert.Exactly(t, []*apiv1.Pod{pod}, calculator.GetSortedPods(NewDefaultPodEvictionAdmission()))
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package leaderelection runs VPA components replicated for high
+// availability, with a single active replica elected through a lease.
+package leaderelection
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	kube_client "k8s.io/client-go/kubernetes"
+	kube_leaderelection "k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog"
+)
+
+var (
+	leaderElect = flag.Bool("leader-elect", false,
+		`Start a leader election client and gain leadership before executing the main loop. Enable this when running replicated components for high availability`)
+	leaseDuration = flag.Duration("leader-elect-lease-duration", 15*time.Second,
+		`Duration that non-leader candidates will wait after observing a leadership renewal until attempting to acquire leadership of a led but unrenewed leader slot`)
+	renewDeadline = flag.Duration("leader-elect-renew-deadline", 10*time.Second,
+		`Interval between attempts by the acting master to renew a leadership slot before it stops leading. Must be less than the lease duration`)
+	retryPeriod = flag.Duration("leader-elect-retry-period", 2*time.Second,
+		`Duration the clients should wait between attempting acquisition and renewal of a leadership`)
+	resourceLock = flag.String("leader-elect-resource-lock", resourcelock.LeasesResourceLock,
+		`Type of resource object used for locking during leader election. Supported options are leases, endpoints and configmaps`)
+	resourceNamespace = flag.String("leader-elect-resource-namespace", "kube-system",
+		`Namespace of the resource object used for locking during leader election`)
+	resourceName = flag.String("leader-elect-resource-name", "",
+		`Name of the resource object used for locking during leader election. Defaults to the name of the component`)
+)
+
+// Run calls run, which is expected to run the main loop of the component
+// forever. If leader election is enabled, run is called once this replica
+// acquires the leadership, and the process exits when the leadership is lost.
+// On SIGTERM the leadership is released, so that another replica takes over
+// without waiting for the lease to expire.
+func Run(kubeClient kube_client.Interface, component string, run func()) {
+	if !*leaderElect {
+		run()
+		return
+	}
+
+	id, err := os.Hostname()
+	if err != nil {
+		klog.Fatalf("Unable to get hostname: %v", err)
+	}
+	name := *resourceName
+	if name == "" {
+		name = component
+	}
+	lock, err := resourcelock.New(
+		*resourceLock,
+		*resourceNamespace,
+		name,
+		kubeClient.CoreV1(),
+		kubeClient.CoordinationV1beta1(),
+		resourcelock.ResourceLockConfig{Identity: id},
+	)
+	if err != nil {
+		klog.Fatalf("Unable to create leader election lock: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	go func() {
+		<-signals
+		klog.Info("Received SIGTERM, releasing the leadership")
+		cancel()
+	}()
+
+	kube_leaderelection.RunOrDie(ctx, kube_leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   *leaseDuration,
+		RenewDeadline:   *renewDeadline,
+		RetryPeriod:     *retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            name,
+		Callbacks: kube_leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(_ context.Context) {
+				klog.Infof("Acquired the leadership of %s/%s as %s", *resourceNamespace, name, id)
+				// The process exits when the leadership is lost, so the
+				// context can be ignored.
+				run()
+			},
+			OnStoppedLeading: func() {
+				if ctx.Err() != nil {
+					klog.Info("Released the leadership")
+					os.Exit(0)
+				}
+				klog.Fatalf("Lost the leadership of %s/%s", *resourceNamespace, name)
+			},
+		},
+	})
+}
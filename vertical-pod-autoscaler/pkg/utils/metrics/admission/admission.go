@@ -49,6 +49,9 @@ const (
 	Skipped AdmissionStatus = "skipped"
 	// Applied denotes an Admission Control execution when a recommendation was applied
 	Applied AdmissionStatus = "applied"
+	// DryRun denotes an Admission Control execution when a recommendation was
+	// recorded in dry-run instead of being applied
+	DryRun AdmissionStatus = "dry-run"
 )
 
 const (
@@ -69,6 +72,23 @@ var (
 		}, []string{"applied"},
 	)
 
+	admissionDryRunCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "admission_dry_run_pods_total",
+			Help:      "Number of Pods whose recommendation was recorded but not applied by VPA Admission Controller in dry-run.",
+		},
+	)
+
+	admissionDryRunRequestRatio = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "admission_dry_run_request_ratio",
+			Help:      "Ratio of the recommended to the original request of the containers admitted in dry-run.",
+			Buckets:   []float64{0.1, 0.25, 0.5, 0.75, 0.9, 1.0, 1.1, 1.25, 1.5, 2.0, 4.0, 10.0},
+		}, []string{"resource"},
+	)
+
 	admissionLatency = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: metricsNamespace,
@@ -82,6 +102,8 @@ var (
 // Register initializes all metrics for VPA Admission Contoller
 func Register() {
 	prometheus.MustRegister(admissionCount)
+	prometheus.MustRegister(admissionDryRunCount)
+	prometheus.MustRegister(admissionDryRunRequestRatio)
 	prometheus.MustRegister(admissionLatency)
 }
 
@@ -90,6 +112,17 @@ func OnAdmittedPod(touched bool) {
 	admissionCount.WithLabelValues(fmt.Sprintf("%v", touched)).Add(1)
 }
 
+// OnDryRunPod increases the counter of pods whose recommendation was recorded in dry-run
+func OnDryRunPod() {
+	admissionDryRunCount.Add(1)
+}
+
+// ObserveDryRunRequestRatio records the ratio of the recommended to the original
+// request of a resource of a container admitted in dry-run
+func ObserveDryRunRequestRatio(resource string, ratio float64) {
+	admissionDryRunRequestRatio.WithLabelValues(resource).Observe(ratio)
+}
+
 // NewAdmissionLatency provides a timer for admission latency; call Observe() on it to measure
 func NewAdmissionLatency() *AdmissionLatency {
 	return &AdmissionLatency{
@@ -42,6 +42,16 @@ func NewHealthCheck(activityTimeout time.Duration, checkTimeout bool) *HealthChe
 	}
 }
 
+// StartMonitoring activates the checks for inactivity, e.g. once the
+// component is elected leader. The activity timeout counts from now.
+func (hc *HealthCheck) StartMonitoring() {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	hc.checkTimeout = true
+	hc.lastActivity = time.Now()
+}
+
 // checkLastActivity returns true if the last activity was too long ago, with duration from it.
 func (hc *HealthCheck) checkLastActivity() (bool, time.Duration) {
 	hc.mutex.Lock()
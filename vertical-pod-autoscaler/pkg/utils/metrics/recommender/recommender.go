@@ -32,7 +32,7 @@ const (
 )
 
 var (
-	modes = []string{string(vpa_types.UpdateModeOff), string(vpa_types.UpdateModeInitial), string(vpa_types.UpdateModeRecreate), string(vpa_types.UpdateModeAuto)}
+	modes = []string{string(vpa_types.UpdateModeOff), string(vpa_types.UpdateModeInitial), string(vpa_types.UpdateModeRecreate), string(vpa_types.UpdateModeAuto), string(vpa_types.UpdateModeInPlace)}
 )
 
 type apiVersion string
@@ -60,6 +60,51 @@ var (
 		},
 	)
 
+	customMetricsUnavailable = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "custom_metrics_unavailable_total",
+			Help:      "Number of response-time controller recommendations computed without usable custom metrics.",
+		}, []string{"reason", "fallback"},
+	)
+
+	controllerLabels = []string{"namespace", "vpa", "container"}
+
+	controllerRequests = newControllerGauge("controller_requests",
+		"Requests per replica in the sampling period, the load seen by the response-time controller.")
+	controllerResponseTime = newControllerGauge("controller_response_time_seconds",
+		"Response time observed by the response-time controller.")
+	controllerError = newControllerGauge("controller_error_seconds",
+		"Difference between the SLA and the observed response time.")
+	controllerProportionalTerm = newControllerGauge("controller_proportional_term_seconds",
+		"Proportional term of the response-time controller.")
+	controllerIntegralTerm = newControllerGauge("controller_integral_term_seconds",
+		"Integral term of the response-time controller.")
+	controllerOutput = newControllerGauge("controller_output_seconds",
+		"Response time the response-time controller asks for.")
+	controllerTargetCores = newControllerGauge("controller_target_cores",
+		"Cores the nominal model needs to reach the output of the response-time controller.")
+	controllerCores = newControllerGauge("controller_cores",
+		"Cores recommended by the response-time controller.")
+	controllerPredictedResponseTime = newControllerGauge("controller_predicted_response_time_seconds",
+		"Response time the nominal model predicts with the recommended cores.")
+
+	controllerSLAViolations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "controller_sla_violations_total",
+			Help:      "Number of iterations of the response-time controller observing a response time above the SLA.",
+		}, controllerLabels,
+	)
+
+	controllerSaturations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "controller_saturations_total",
+			Help:      "Number of iterations of the response-time controller recommending the maximum cores.",
+		}, controllerLabels,
+	)
+
 	functionLatency = metrics.CreateExecutionTimeMetric(metricsNamespace,
 		"Time spent in various parts of VPA Recommender main loop.")
 )
@@ -70,6 +115,22 @@ type objectCounterKey struct {
 	apiVersion apiVersion
 }
 
+func newControllerGauge(name, help string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      name,
+			Help:      help,
+		}, controllerLabels,
+	)
+}
+
+func controllerGauges() []*prometheus.GaugeVec {
+	return []*prometheus.GaugeVec{controllerRequests, controllerResponseTime, controllerError,
+		controllerProportionalTerm, controllerIntegralTerm, controllerOutput, controllerTargetCores,
+		controllerCores, controllerPredictedResponseTime}
+}
+
 // ObjectCounter helps split all VPA objects into buckets
 type ObjectCounter struct {
 	cnt map[objectCounterKey]int
@@ -79,6 +140,12 @@ type ObjectCounter struct {
 func Register() {
 	prometheus.MustRegister(vpaObjectCount)
 	prometheus.MustRegister(recommendationLatency)
+	prometheus.MustRegister(customMetricsUnavailable)
+	for _, gauge := range controllerGauges() {
+		prometheus.MustRegister(gauge)
+	}
+	prometheus.MustRegister(controllerSLAViolations)
+	prometheus.MustRegister(controllerSaturations)
 	prometheus.MustRegister(functionLatency)
 }
 
@@ -92,6 +159,12 @@ func ObserveRecommendationLatency(created time.Time) {
 	recommendationLatency.Observe(time.Now().Sub(created).Seconds())
 }
 
+// RecordCustomMetricsUnavailable records a recommendation computed without
+// usable custom metrics, with the reason and the fallback used instead.
+func RecordCustomMetricsUnavailable(reason, fallback string) {
+	customMetricsUnavailable.WithLabelValues(reason, fallback).Inc()
+}
+
 // NewObjectCounter creates a new helper to split VPA objects into buckets
 func NewObjectCounter() *ObjectCounter {
 	obj := ObjectCounter{
@@ -134,3 +207,72 @@ func (oc *ObjectCounter) Observe() {
 		vpaObjectCount.WithLabelValues(k.mode, fmt.Sprintf("%v", k.has), string(k.apiVersion)).Set(float64(v))
 	}
 }
+
+// ControllerSignals are the signals of an iteration of the response-time
+// controller for a container.
+type ControllerSignals struct {
+	Requests              float64
+	ResponseTime          float64
+	Error                 float64
+	ProportionalTerm      float64
+	IntegralTerm          float64
+	Output                float64
+	TargetCores           float64
+	Cores                 float64
+	PredictedResponseTime float64
+	// SLAViolated is set if the response time is above the SLA.
+	SLAViolated bool
+	// Saturated is set if the cores are clamped at the maximum.
+	Saturated bool
+}
+
+type controllerKey struct {
+	namespace string
+	vpa       string
+	container string
+}
+
+// ControllerTelemetry collects the signals of the response-time controller
+// computed in a loop of the recommender.
+type ControllerTelemetry struct {
+	signals map[controllerKey]ControllerSignals
+}
+
+// NewControllerTelemetry creates a new helper to export the signals of the
+// response-time controller.
+func NewControllerTelemetry() *ControllerTelemetry {
+	return &ControllerTelemetry{signals: make(map[controllerKey]ControllerSignals)}
+}
+
+// Add records the signals of the controller for the given container and
+// counts SLA violations and saturation.
+func (ct *ControllerTelemetry) Add(vpaID model.VpaID, containerName string, signals ControllerSignals) {
+	key := controllerKey{namespace: vpaID.Namespace, vpa: vpaID.VpaName, container: containerName}
+	ct.signals[key] = signals
+	if signals.SLAViolated {
+		controllerSLAViolations.WithLabelValues(key.namespace, key.vpa, key.container).Inc()
+	}
+	if signals.Saturated {
+		controllerSaturations.WithLabelValues(key.namespace, key.vpa, key.container).Inc()
+	}
+}
+
+// Observe exports the recorded signals as gauges. Containers without signals
+// in this loop are removed from the gauges, so that they do not report stale
+// values.
+func (ct *ControllerTelemetry) Observe() {
+	for _, gauge := range controllerGauges() {
+		gauge.Reset()
+	}
+	for k, v := range ct.signals {
+		controllerRequests.WithLabelValues(k.namespace, k.vpa, k.container).Set(v.Requests)
+		controllerResponseTime.WithLabelValues(k.namespace, k.vpa, k.container).Set(v.ResponseTime)
+		controllerError.WithLabelValues(k.namespace, k.vpa, k.container).Set(v.Error)
+		controllerProportionalTerm.WithLabelValues(k.namespace, k.vpa, k.container).Set(v.ProportionalTerm)
+		controllerIntegralTerm.WithLabelValues(k.namespace, k.vpa, k.container).Set(v.IntegralTerm)
+		controllerOutput.WithLabelValues(k.namespace, k.vpa, k.container).Set(v.Output)
+		controllerTargetCores.WithLabelValues(k.namespace, k.vpa, k.container).Set(v.TargetCores)
+		controllerCores.WithLabelValues(k.namespace, k.vpa, k.container).Set(v.Cores)
+		controllerPredictedResponseTime.WithLabelValues(k.namespace, k.vpa, k.container).Set(v.PredictedResponseTime)
+	}
+}
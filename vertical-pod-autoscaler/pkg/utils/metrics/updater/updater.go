@@ -35,6 +35,30 @@ var (
 		},
 	)
 
+	resizedCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "resized_pods_total",
+			Help:      "Number of Pods resized in place by Updater to apply a new recommendation.",
+		},
+	)
+
+	failedResizeCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "failed_resizes_total",
+			Help:      "Number of in-place resizes of Pods rejected, after which Updater falls back to eviction.",
+		},
+	)
+
+	skippedUpdateCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "skipped_updates_total",
+			Help:      "Number of Pod updates postponed by Updater because of the actuation policy of the VPA.",
+		}, []string{"reason"},
+	)
+
 	functionLatency = metrics.CreateExecutionTimeMetric(metricsNamespace,
 		"Time spent in various parts of VPA Updater main loop.")
 )
@@ -42,6 +66,9 @@ var (
 // Register initializes all metrics for VPA Updater
 func Register() {
 	prometheus.MustRegister(evictedCount)
+	prometheus.MustRegister(resizedCount)
+	prometheus.MustRegister(failedResizeCount)
+	prometheus.MustRegister(skippedUpdateCount)
 	prometheus.MustRegister(functionLatency)
 }
 
@@ -54,3 +81,19 @@ func NewExecutionTimer() *metrics.ExecutionTimer {
 func AddEvictedPod() {
 	evictedCount.Add(1)
 }
+
+// AddResizedPod increases the counter of pods resized in place by VPA
+func AddResizedPod() {
+	resizedCount.Add(1)
+}
+
+// AddFailedResize increases the counter of rejected in-place resizes
+func AddFailedResize() {
+	failedResizeCount.Add(1)
+}
+
+// AddSkippedUpdate increases the counter of pod updates postponed by the
+// actuation policy, for the given reason
+func AddSkippedUpdate(reason string) {
+	skippedUpdateCount.WithLabelValues(reason).Inc()
+}
@@ -141,6 +141,17 @@ func (m *PodsEvictionRestrictionMock) CanEvict(pod *apiv1.Pod) bool {
 	return args.Bool(0)
 }
 
+// ResizerMock is a mock of Resizer
+type ResizerMock struct {
+	mock.Mock
+}
+
+// Resize is a mock implementation of Resizer.Resize
+func (m *ResizerMock) Resize(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources, policy *vpa_types.PodResourcePolicy, eventRecorder record.EventRecorder) error {
+	args := m.Called(pod, recommendation, policy, eventRecorder)
+	return args.Error(0)
+}
+
 // PodListerMock is a mock of PodLister
 type PodListerMock struct {
 	mock.Mock
@@ -37,6 +37,10 @@ import (
 	"k8s.io/klog"
 )
 
+// DryRunAnnotation set to "true" on a VPA makes the VPA components only
+// preview its recommendation instead of applying it to the pods.
+const DryRunAnnotation = "vpaDryRun"
+
 // VpaWithSelector is a pair a VPA and its selector.
 type VpaWithSelector struct {
 	Vpa      *vpa_types.VerticalPodAutoscaler
@@ -65,7 +69,8 @@ func patchVpa(vpaClient vpa_api.VerticalPodAutoscalerInterface, vpaName string,
 func UpdateVpaStatusIfNeeded(vpaClient vpa_api.VerticalPodAutoscalerInterface, vpa *model.Vpa,
 	oldStatus *vpa_types.VerticalPodAutoscalerStatus) (result *vpa_types.VerticalPodAutoscaler, err error) {
 	newStatus := &vpa_types.VerticalPodAutoscalerStatus{
-		Conditions: vpa.Conditions.AsList(),
+		Conditions:            vpa.Conditions.AsList(),
+		RecommendationHistory: vpa.RecommendationHistory,
 	}
 	if vpa.Recommendation != nil {
 		newStatus.Recommendation = vpa.Recommendation
@@ -149,6 +154,11 @@ func GetUpdateMode(vpa *vpa_types.VerticalPodAutoscaler) vpa_types.UpdateMode {
 	return *vpa.Spec.UpdatePolicy.UpdateMode
 }
 
+// IsDryRun returns true if the VPA has the DryRunAnnotation.
+func IsDryRun(vpa *vpa_types.VerticalPodAutoscaler) bool {
+	return vpa.Annotations[DryRunAnnotation] == "true"
+}
+
 // GetContainerResourcePolicy returns the ContainerResourcePolicy for a given policy
 // and container name. It returns nil if there is no policy specified for the container.
 func GetContainerResourcePolicy(containerName string, policy *vpa_types.PodResourcePolicy) *vpa_types.ContainerResourcePolicy {
@@ -166,6 +176,39 @@ func GetContainerResourcePolicy(containerName string, policy *vpa_types.PodResou
 	return defaultPolicy
 }
 
+// GetContainerControlledValues returns the controlled values of the container
+// with the given name. If none is specified it returns the default
+// (ContainerControlledValuesRequestsOnly).
+func GetContainerControlledValues(containerName string, policy *vpa_types.PodResourcePolicy) vpa_types.ContainerControlledValues {
+	containerPolicy := GetContainerResourcePolicy(containerName, policy)
+	if containerPolicy == nil || containerPolicy.ControlledValues == nil || *containerPolicy.ControlledValues == "" {
+		return vpa_types.ContainerControlledValuesRequestsOnly
+	}
+	return *containerPolicy.ControlledValues
+}
+
+// GetContainerControlledResources returns the resources controlled for the
+// container with the given name. If none are specified it returns the default
+// (CPU and memory).
+func GetContainerControlledResources(containerName string, policy *vpa_types.PodResourcePolicy) []core.ResourceName {
+	containerPolicy := GetContainerResourcePolicy(containerName, policy)
+	if containerPolicy == nil || containerPolicy.ControlledResources == nil {
+		return []core.ResourceName{core.ResourceCPU, core.ResourceMemory}
+	}
+	return *containerPolicy.ControlledResources
+}
+
+// GetContainerRecommender returns the recommendation algorithm selected for
+// the container with the given name. If none is specified it returns the
+// given default.
+func GetContainerRecommender(containerName string, policy *vpa_types.PodResourcePolicy, defaultRecommender vpa_types.ContainerRecommender) vpa_types.ContainerRecommender {
+	containerPolicy := GetContainerResourcePolicy(containerName, policy)
+	if containerPolicy == nil || containerPolicy.Recommender == nil || *containerPolicy.Recommender == "" {
+		return defaultRecommender
+	}
+	return *containerPolicy.Recommender
+}
+
 // CreateOrUpdateVpaCheckpoint updates the status field of the VPA Checkpoint API object.
 // If object doesn't exits it is created.
 func CreateOrUpdateVpaCheckpoint(vpaCheckpointClient vpa_api.VerticalPodAutoscalerCheckpointInterface,
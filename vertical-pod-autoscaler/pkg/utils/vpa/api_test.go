@@ -60,6 +60,14 @@ func TestUpdateVpaIfNeeded(t *testing.T) {
 	recommendation := test.Recommendation().WithContainer(containerName).WithTarget("5", "200").Get()
 	observedVpaBuilder := test.VerticalPodAutoscaler().WithName("vpa").WithNamespace("test").WithContainer(containerName)
 	modelVpa.Recommendation = recommendation
+	modelVpaWithHistory := *modelVpa
+	modelVpaWithHistory.RecommendationHistory = []vpa_types.RecommendationHistoryEntry{{
+		Time: meta.NewTime(anytime),
+		ContainerRecommendations: []vpa_types.RecommendationExplanation{{
+			ContainerName: containerName,
+			Target:        recommendation.ContainerRecommendations[0].Target,
+		}},
+	}}
 	testCases := []struct {
 		caseName       string
 		vpa            *model.Vpa
@@ -91,6 +99,12 @@ func TestUpdateVpaIfNeeded(t *testing.T) {
 				AppendCondition(vpa_types.RecommendationProvided, core.ConditionTrue, "reason", "msg", anytime).
 				AppendCondition(vpa_types.LowConfidence, core.ConditionTrue, "reason", "msg", anytime).Get().Status,
 			expectedUpdate: true,
+		}, {
+			caseName: "Updates on recommendation history change.",
+			vpa:      &modelVpaWithHistory,
+			observedStatus: &observedVpaBuilder.WithTarget("5", "200").
+				AppendCondition(vpa_types.RecommendationProvided, core.ConditionTrue, "reason", "msg", anytime).Get().Status,
+			expectedUpdate: true,
 		},
 	}
 	for _, tc := range testCases {
@@ -198,3 +212,17 @@ func TestGetContainerResourcePolicy(t *testing.T) {
 	assert.Equal(t, &containerPolicy2, GetContainerResourcePolicy("container2", &policy))
 	assert.Equal(t, &defaultPolicy, GetContainerResourcePolicy("container3", &policy))
 }
+
+func TestGetContainerRecommender(t *testing.T) {
+	controller := vpa_types.ContainerRecommenderResponseTimeController
+	policy := vpa_types.PodResourcePolicy{
+		ContainerPolicies: []vpa_types.ContainerResourcePolicy{
+			{ContainerName: "container1", Recommender: &controller},
+			{ContainerName: "container2"},
+		},
+	}
+	assert.Equal(t, vpa_types.ContainerRecommenderResponseTimeController, GetContainerRecommender("container1", &policy, vpa_types.ContainerRecommenderHistogram))
+	assert.Equal(t, vpa_types.ContainerRecommenderHistogram, GetContainerRecommender("container2", &policy, vpa_types.ContainerRecommenderHistogram))
+	assert.Equal(t, vpa_types.ContainerRecommenderHistogram, GetContainerRecommender("container3", &policy, vpa_types.ContainerRecommenderHistogram))
+	assert.Equal(t, vpa_types.ContainerRecommenderResponseTimeController, GetContainerRecommender("container1", nil, vpa_types.ContainerRecommenderResponseTimeController))
+}
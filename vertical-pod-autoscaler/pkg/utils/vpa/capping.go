@@ -86,6 +86,9 @@ func (c *cappingRecommendationProcessor) Apply(
 }
 
 // getCappedRecommendationForContainer returns a recommendation for the given container, adjusted to obey policy and limits.
+// Resources that are not controlled by the policy are removed from the recommendation.
+// The recommendation is capped to the limits of the container only if the limits are not controlled by VPA,
+// otherwise the limits are scaled together with the requests.
 func getCappedRecommendationForContainer(
 	container apiv1.Container,
 	containerRecommendation *vpa_types.RecommendedContainerResources,
@@ -95,8 +98,10 @@ func getCappedRecommendationForContainer(
 	}
 	// containerPolicy can be nil (user does not have to configure it).
 	containerPolicy := GetContainerResourcePolicy(container.Name, policy)
+	controlledValues := GetContainerControlledValues(container.Name, policy)
 
 	cappedRecommendations := containerRecommendation.DeepCopy()
+	filterControlledResources(cappedRecommendations, GetContainerControlledResources(container.Name, policy))
 
 	cappingAnnotations := make([]string, 0)
 
@@ -105,6 +110,9 @@ func getCappedRecommendationForContainer(
 		if genAnnotations {
 			cappingAnnotations = append(cappingAnnotations, annotations...)
 		}
+		if controlledValues != vpa_types.ContainerControlledValuesRequestsOnly {
+			return
+		}
 		// TODO: If limits and policy are conflicting, set some condition on the VPA.
 		annotations = capRecommendationToContainerLimit(recommendation, container)
 		if genAnnotations {
@@ -162,6 +170,7 @@ func applyVPAPolicyForContainer(containerName string,
 		return nil, fmt.Errorf("no recommendation available for container name %v", containerName)
 	}
 	cappedRecommendations := containerRecommendation.DeepCopy()
+	filterControlledResources(cappedRecommendations, GetContainerControlledResources(containerName, policy))
 	// containerPolicy can be nil (user does not have to configure it).
 	containerPolicy := GetContainerResourcePolicy(containerName, policy)
 	if containerPolicy == nil {
@@ -184,6 +193,22 @@ func applyVPAPolicyForContainer(containerName string,
 	return cappedRecommendations, nil
 }
 
+// filterControlledResources removes the resources that are not controlled
+// from the target and the bounds of the recommendation.
+func filterControlledResources(recommendation *vpa_types.RecommendedContainerResources, controlledResources []apiv1.ResourceName) {
+	controlled := make(map[apiv1.ResourceName]bool, len(controlledResources))
+	for _, resourceName := range controlledResources {
+		controlled[resourceName] = true
+	}
+	for _, resources := range []apiv1.ResourceList{recommendation.Target, recommendation.LowerBound, recommendation.UpperBound} {
+		for resourceName := range resources {
+			if !controlled[resourceName] {
+				delete(resources, resourceName)
+			}
+		}
+	}
+}
+
 func maybeCapToMin(recommended resource.Quantity, resourceName apiv1.ResourceName,
 	containerPolicy *vpa_types.ContainerResourcePolicy) (resource.Quantity, bool) {
 	min, found := containerPolicy.MinAllowed[resourceName]
@@ -208,9 +233,6 @@ func ApplyVPAPolicy(podRecommendation *vpa_types.RecommendedPodResources,
 	if podRecommendation == nil {
 		return nil, nil
 	}
-	if policy == nil {
-		return podRecommendation, nil
-	}
 
 	updatedRecommendations := []vpa_types.RecommendedContainerResources{}
 	for _, containerRecommendation := range podRecommendation.ContainerRecommendations {
@@ -238,11 +260,17 @@ func GetRecommendationForContainer(containerName string, recommendation *vpa_typ
 	return nil
 }
 
+// getContainer returns the container or the init container of the pod with the given name.
 func getContainer(containerName string, pod *apiv1.Pod) *apiv1.Container {
 	for i, container := range pod.Spec.Containers {
 		if container.Name == containerName {
 			return &pod.Spec.Containers[i]
 		}
 	}
+	for i, container := range pod.Spec.InitContainers {
+		if container.Name == containerName {
+			return &pod.Spec.InitContainers[i]
+		}
+	}
 	return nil
 }
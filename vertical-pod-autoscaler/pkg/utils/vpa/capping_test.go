@@ -88,6 +88,38 @@ func TestRecommendationCappedToLimit(t *testing.T) {
 	}, res.ContainerRecommendations[0].UpperBound)
 }
 
+func TestRecommendationNotCappedToControlledLimit(t *testing.T) {
+	pod := test.Pod().WithName("pod1").AddContainer(test.BuildTestContainer("ctr-name", "1", "")).Get()
+	pod.Spec.Containers[0].Resources.Limits = apiv1.ResourceList{
+		apiv1.ResourceCPU: *resource.NewScaledQuantity(3, 1),
+	}
+
+	podRecommendation := vpa_types.RecommendedPodResources{
+		ContainerRecommendations: []vpa_types.RecommendedContainerResources{
+			{
+				ContainerName: "ctr-name",
+				Target: apiv1.ResourceList{
+					apiv1.ResourceCPU: *resource.NewScaledQuantity(10, 1),
+				},
+			},
+		},
+	}
+	controlledValues := vpa_types.ContainerControlledValuesRequestsAndLimits
+	policy := vpa_types.PodResourcePolicy{
+		ContainerPolicies: []vpa_types.ContainerResourcePolicy{{
+			ContainerName:    "ctr-name",
+			ControlledValues: &controlledValues,
+		}},
+	}
+
+	res, annotations, err := NewCappingRecommendationProcessor().Apply(&podRecommendation, &policy, nil, pod)
+	assert.Nil(t, err)
+	assert.Equal(t, apiv1.ResourceList{
+		apiv1.ResourceCPU: *resource.NewScaledQuantity(10, 1),
+	}, res.ContainerRecommendations[0].Target)
+	assert.Empty(t, annotations)
+}
+
 func TestRecommendationCappedToMinMaxPolicy(t *testing.T) {
 	pod := test.Pod().WithName("pod1").AddContainer(test.BuildTestContainer("ctr-name", "", "")).Get()
 	podRecommendation := vpa_types.RecommendedPodResources{
@@ -256,3 +288,80 @@ func TestApplyVpa(t *testing.T) {
 		apiv1.ResourceMemory: *resource.NewScaledQuantity(4500, 1),
 	}, res.ContainerRecommendations[0].UpperBound)
 }
+
+func TestApplyVpaFiltersControlledResources(t *testing.T) {
+	recommended := apiv1.ResourceList{
+		apiv1.ResourceCPU:              *resource.NewScaledQuantity(30, 1),
+		apiv1.ResourceMemory:           *resource.NewScaledQuantity(5000, 1),
+		apiv1.ResourceEphemeralStorage: *resource.NewScaledQuantity(1, 9),
+	}
+	podRecommendation := vpa_types.RecommendedPodResources{
+		ContainerRecommendations: []vpa_types.RecommendedContainerResources{
+			{
+				ContainerName: "ctr-name",
+				Target:        recommended.DeepCopy(),
+				LowerBound:    recommended.DeepCopy(),
+				UpperBound:    recommended.DeepCopy(),
+			},
+			{
+				ContainerName: "init-ctr-name",
+				Target:        recommended.DeepCopy(),
+				LowerBound:    recommended.DeepCopy(),
+				UpperBound:    recommended.DeepCopy(),
+			},
+		},
+	}
+	controlledResources := []apiv1.ResourceName{apiv1.ResourceMemory, apiv1.ResourceEphemeralStorage}
+	policy := vpa_types.PodResourcePolicy{
+		ContainerPolicies: []vpa_types.ContainerResourcePolicy{
+			{
+				ContainerName:       "init-ctr-name",
+				ControlledResources: &controlledResources,
+			},
+		},
+	}
+
+	res, err := ApplyVPAPolicy(&podRecommendation, &policy)
+	assert.Nil(t, err)
+	// Ephemeral storage is not controlled by default.
+	assert.Equal(t, apiv1.ResourceList{
+		apiv1.ResourceCPU:    *resource.NewScaledQuantity(30, 1),
+		apiv1.ResourceMemory: *resource.NewScaledQuantity(5000, 1),
+	}, res.ContainerRecommendations[0].Target)
+	expected := apiv1.ResourceList{
+		apiv1.ResourceMemory:           *resource.NewScaledQuantity(5000, 1),
+		apiv1.ResourceEphemeralStorage: *resource.NewScaledQuantity(1, 9),
+	}
+	assert.Equal(t, expected, res.ContainerRecommendations[1].Target)
+	assert.Equal(t, expected, res.ContainerRecommendations[1].LowerBound)
+	assert.Equal(t, expected, res.ContainerRecommendations[1].UpperBound)
+}
+
+func TestApplyToInitContainer(t *testing.T) {
+	pod := test.Pod().WithName("pod1").AddContainer(test.BuildTestContainer("ctr-name", "1", "")).Get()
+	initContainer := test.BuildTestContainer("init-ctr-name", "1", "")
+	initContainer.Resources.Limits = apiv1.ResourceList{
+		apiv1.ResourceCPU: *resource.NewScaledQuantity(2, 0),
+	}
+	pod.Spec.InitContainers = []apiv1.Container{initContainer}
+
+	podRecommendation := vpa_types.RecommendedPodResources{
+		ContainerRecommendations: []vpa_types.RecommendedContainerResources{
+			{
+				ContainerName: "init-ctr-name",
+				Target: apiv1.ResourceList{
+					apiv1.ResourceCPU: *resource.NewScaledQuantity(3, 0),
+				},
+			},
+		},
+	}
+
+	res, annotations, err := NewCappingRecommendationProcessor().Apply(&podRecommendation, nil, nil, pod)
+	assert.Nil(t, err)
+	if assert.Len(t, res.ContainerRecommendations, 1) {
+		assert.Equal(t, apiv1.ResourceList{
+			apiv1.ResourceCPU: *resource.NewScaledQuantity(2, 0),
+		}, res.ContainerRecommendations[0].Target)
+	}
+	assert.Contains(t, annotations, "init-ctr-name")
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"math"
+	"math/big"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// GetProportionalLimit returns the limit preserving the ratio between the
+// original limit and request for the recommended request. It returns nil if
+// there is no original limit. If there is no original request, the limit is
+// equal to the recommended request, as the request defaults to the limit.
+func GetProportionalLimit(originalLimit, originalRequest, recommendedRequest *resource.Quantity, resourceName apiv1.ResourceName) *resource.Quantity {
+	if originalLimit == nil || originalLimit.IsZero() || recommendedRequest == nil {
+		return nil
+	}
+	if originalRequest == nil || originalRequest.IsZero() || originalRequest.Cmp(*originalLimit) == 0 {
+		result := recommendedRequest.DeepCopy()
+		return &result
+	}
+	if resourceName == apiv1.ResourceCPU {
+		milliValue := scaleValue(recommendedRequest.MilliValue(), originalLimit.MilliValue(), originalRequest.MilliValue())
+		return resource.NewMilliQuantity(milliValue, recommendedRequest.Format)
+	}
+	value := scaleValue(recommendedRequest.Value(), originalLimit.Value(), originalRequest.Value())
+	return resource.NewQuantity(value, recommendedRequest.Format)
+}
+
+// scaleValue returns value * numerator / denominator, without overflowing in
+// the multiplication. The result is capped to MaxInt64.
+func scaleValue(value, numerator, denominator int64) int64 {
+	result := big.NewInt(value)
+	result.Mul(result, big.NewInt(numerator))
+	result.Div(result, big.NewInt(denominator))
+	if !result.IsInt64() {
+		return math.MaxInt64
+	}
+	return result.Int64()
+}
+
+// GetProportionalLimits returns the limits of the container scaled
+// proportionally to the recommended requests, for the resources the container
+// has limits for.
+func GetProportionalLimits(container apiv1.Container, recommendedRequests apiv1.ResourceList) apiv1.ResourceList {
+	limits := apiv1.ResourceList{}
+	for resourceName, recommended := range recommendedRequests {
+		originalLimit, found := container.Resources.Limits[resourceName]
+		if !found {
+			continue
+		}
+		var originalRequest *resource.Quantity
+		if request, found := container.Resources.Requests[resourceName]; found {
+			originalRequest = &request
+		}
+		recommendedRequest := recommended
+		if limit := GetProportionalLimit(&originalLimit, originalRequest, &recommendedRequest, resourceName); limit != nil {
+			limits[resourceName] = *limit
+		}
+	}
+	return limits
+}
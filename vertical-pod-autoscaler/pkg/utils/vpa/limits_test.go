@@ -0,0 +1,117 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func quantity(value string) *resource.Quantity {
+	q := resource.MustParse(value)
+	return &q
+}
+
+func TestGetProportionalLimit(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		resourceName       apiv1.ResourceName
+		originalLimit      *resource.Quantity
+		originalRequest    *resource.Quantity
+		recommendedRequest *resource.Quantity
+		expectedLimit      *resource.Quantity
+	}{
+		{
+			name:               "no limit",
+			resourceName:       apiv1.ResourceCPU,
+			originalRequest:    quantity("1"),
+			recommendedRequest: quantity("2"),
+		},
+		{
+			name:               "no request",
+			resourceName:       apiv1.ResourceCPU,
+			originalLimit:      quantity("1"),
+			recommendedRequest: quantity("2"),
+			expectedLimit:      quantity("2"),
+		},
+		{
+			name:               "request equal to limit",
+			resourceName:       apiv1.ResourceMemory,
+			originalLimit:      quantity("100Mi"),
+			originalRequest:    quantity("100Mi"),
+			recommendedRequest: quantity("300Mi"),
+			expectedLimit:      quantity("300Mi"),
+		},
+		{
+			name:               "cpu",
+			resourceName:       apiv1.ResourceCPU,
+			originalLimit:      quantity("300m"),
+			originalRequest:    quantity("200m"),
+			recommendedRequest: quantity("500m"),
+			expectedLimit:      quantity("750m"),
+		},
+		{
+			name:               "memory",
+			resourceName:       apiv1.ResourceMemory,
+			originalLimit:      quantity("2Gi"),
+			originalRequest:    quantity("1Gi"),
+			recommendedRequest: quantity("300Mi"),
+			expectedLimit:      quantity("600Mi"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			limit := GetProportionalLimit(tc.originalLimit, tc.originalRequest, tc.recommendedRequest, tc.resourceName)
+			if tc.expectedLimit == nil {
+				assert.Nil(t, limit)
+			} else if assert.NotNil(t, limit) {
+				assert.Equal(t, tc.expectedLimit.MilliValue(), limit.MilliValue())
+			}
+		})
+	}
+}
+
+func TestGetProportionalLimitDoesNotOverflow(t *testing.T) {
+	limit := GetProportionalLimit(quantity("8Ei"), quantity("1"), quantity("4Ei"), apiv1.ResourceMemory)
+	if assert.NotNil(t, limit) {
+		assert.Equal(t, int64(math.MaxInt64), limit.Value())
+	}
+}
+
+func TestGetProportionalLimits(t *testing.T) {
+	container := apiv1.Container{
+		Resources: apiv1.ResourceRequirements{
+			Requests: apiv1.ResourceList{
+				apiv1.ResourceCPU:    resource.MustParse("1"),
+				apiv1.ResourceMemory: resource.MustParse("100Mi"),
+			},
+			Limits: apiv1.ResourceList{
+				apiv1.ResourceCPU: resource.MustParse("2"),
+			},
+		},
+	}
+	limits := GetProportionalLimits(container, apiv1.ResourceList{
+		apiv1.ResourceCPU:    resource.MustParse("3"),
+		apiv1.ResourceMemory: resource.MustParse("200Mi"),
+	})
+	assert.Len(t, limits, 1)
+	cpuLimit := limits[apiv1.ResourceCPU]
+	assert.Equal(t, int64(6000), cpuLimit.MilliValue())
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+)
+
+// AppendRecommendationHistory returns the history with the entry appended,
+// keeping at most maxSize most recent entries. The entry is only appended if
+// the target or the algorithm of some container differs from the last entry,
+// so that the history isn't flooded with the changes of the other inputs
+// between the loops of the recommender. Returns nil if maxSize is not
+// positive, i.e. the history is disabled. The given history is not modified,
+// so it can come from an informer cache.
+func AppendRecommendationHistory(history []vpa_types.RecommendationHistoryEntry, entry vpa_types.RecommendationHistoryEntry, maxSize int) []vpa_types.RecommendationHistoryEntry {
+	if maxSize <= 0 {
+		return nil
+	}
+	if len(history) == 0 || recommendationChanged(history[len(history)-1], entry) {
+		updated := make([]vpa_types.RecommendationHistoryEntry, len(history), len(history)+1)
+		copy(updated, history)
+		history = append(updated, entry)
+	}
+	if len(history) > maxSize {
+		history = history[len(history)-maxSize:]
+	}
+	return history
+}
+
+func recommendationChanged(last, entry vpa_types.RecommendationHistoryEntry) bool {
+	if len(last.ContainerRecommendations) != len(entry.ContainerRecommendations) {
+		return true
+	}
+	lastByName := make(map[string]vpa_types.RecommendationExplanation, len(last.ContainerRecommendations))
+	for _, recommendation := range last.ContainerRecommendations {
+		lastByName[recommendation.ContainerName] = recommendation
+	}
+	for _, recommendation := range entry.ContainerRecommendations {
+		lastRecommendation, found := lastByName[recommendation.ContainerName]
+		if !found || lastRecommendation.Recommender != recommendation.Recommender ||
+			!apiequality.Semantic.DeepEqual(lastRecommendation.Target, recommendation.Target) {
+			return true
+		}
+	}
+	return false
+}
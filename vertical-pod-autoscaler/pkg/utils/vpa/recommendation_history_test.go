@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1beta2"
+)
+
+func historyEntry(seconds int64, cpu string, recommender vpa_types.ContainerRecommender) vpa_types.RecommendationHistoryEntry {
+	return vpa_types.RecommendationHistoryEntry{
+		Time: meta.NewTime(time.Unix(seconds, 0)),
+		ContainerRecommendations: []vpa_types.RecommendationExplanation{{
+			ContainerName: containerName,
+			Target:        core.ResourceList{core.ResourceCPU: resource.MustParse(cpu)},
+			Recommender:   recommender,
+		}},
+	}
+}
+
+func TestAppendRecommendationHistory(t *testing.T) {
+	histogram := vpa_types.ContainerRecommenderHistogram
+	controller := vpa_types.ContainerRecommenderResponseTimeController
+	history := []vpa_types.RecommendationHistoryEntry{
+		historyEntry(1, "1", histogram),
+		historyEntry(2, "2", histogram),
+	}
+	for _, tc := range []struct {
+		name     string
+		entry    vpa_types.RecommendationHistoryEntry
+		maxSize  int
+		expected []vpa_types.RecommendationHistoryEntry
+	}{
+		{
+			name:     "disabled",
+			entry:    historyEntry(3, "3", histogram),
+			maxSize:  0,
+			expected: nil,
+		}, {
+			name:     "target changed",
+			entry:    historyEntry(3, "3", histogram),
+			maxSize:  3,
+			expected: []vpa_types.RecommendationHistoryEntry{history[0], history[1], historyEntry(3, "3", histogram)},
+		}, {
+			name:     "algorithm changed",
+			entry:    historyEntry(3, "2", controller),
+			maxSize:  3,
+			expected: []vpa_types.RecommendationHistoryEntry{history[0], history[1], historyEntry(3, "2", controller)},
+		}, {
+			name:     "unchanged",
+			entry:    historyEntry(3, "2000m", histogram),
+			maxSize:  3,
+			expected: history,
+		}, {
+			name:     "oldest dropped",
+			entry:    historyEntry(3, "3", histogram),
+			maxSize:  2,
+			expected: []vpa_types.RecommendationHistoryEntry{history[1], historyEntry(3, "3", histogram)},
+		}, {
+			name:     "size decreased",
+			entry:    historyEntry(3, "2", histogram),
+			maxSize:  1,
+			expected: []vpa_types.RecommendationHistoryEntry{history[1]},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			observed := append([]vpa_types.RecommendationHistoryEntry{}, history...)
+			assert.Equal(t, tc.expected, AppendRecommendationHistory(observed, tc.entry, tc.maxSize))
+		})
+	}
+}
+
+func TestAppendRecommendationHistoryDoesNotModifyInput(t *testing.T) {
+	history := make([]vpa_types.RecommendationHistoryEntry, 1, 2)
+	history[0] = historyEntry(1, "1", vpa_types.ContainerRecommenderHistogram)
+	backingArray := history[:2]
+
+	updated := AppendRecommendationHistory(history, historyEntry(2, "2", vpa_types.ContainerRecommenderHistogram), 3)
+	assert.Len(t, updated, 2)
+	assert.Len(t, history, 1)
+	assert.Equal(t, vpa_types.RecommendationHistoryEntry{}, backingArray[1])
+}
+
+func TestAppendRecommendationHistoryEmpty(t *testing.T) {
+	entry := historyEntry(1, "1", vpa_types.ContainerRecommenderHistogram)
+	assert.Equal(t, []vpa_types.RecommendationHistoryEntry{entry}, AppendRecommendationHistory(nil, entry, 1))
+}